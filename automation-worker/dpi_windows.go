@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+// dpiAwarenessPerMonitorV2 DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2
+const dpiAwarenessPerMonitorV2 = ^uintptr(3) // (DPI_AWARENESS_CONTEXT)-4
+
+// 开启Per-Monitor DPI感知，使鼠标坐标、屏幕尺寸和截图与主进程一样使用虚拟桌面的物理像素
+// 否则在缩放比例不是100%的显示器上，系统会把坐标换算为逻辑坐标，导致点击位置偏移
+func init() {
+	proc := syscall.NewLazyDLL("user32.dll").NewProc("SetProcessDpiAwarenessContext")
+	if proc.Find() == nil {
+		proc.Call(dpiAwarenessPerMonitorV2)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/go-vgo/robotgo"
+)
+
+// errorResponse 创建失败响应
+func errorResponse(code, format string, args ...interface{}) AutomationResponse {
+	return AutomationResponse{
+		Success: false,
+		Error:   fmt.Sprintf(format, args...),
+		Code:    code,
+	}
+}
+
+// intParams 读取多个整数参数
+func intParams(params map[string]interface{}, names ...string) ([]int, bool) {
+	values := make([]int, len(names))
+	for i, name := range names {
+		value, ok := params[name].(float64)
+		if !ok {
+			return nil, false
+		}
+		values[i] = int(value)
+	}
+	return values, true
+}
+
+// modifierKey 将主程序的修饰键名称转换为robotgo的名称
+func modifierKey(modifier string) string {
+	switch modifier {
+	case "win", "super", "cmd", "command":
+		return "cmd"
+	case "control":
+		return "ctrl"
+	case "option":
+		return "alt"
+	default:
+		return modifier
+	}
+}
+
+func handleMove(params map[string]interface{}) AutomationResponse {
+	values, ok := intParams(params, "x", "y")
+	if !ok {
+		return errorResponse(codeInvalidArgument, "invalid x or y coordinates")
+	}
+
+	robotgo.Move(values[0], values[1])
+
+	return AutomationResponse{
+		Success: true,
+		Message: fmt.Sprintf("moved to (%d, %d)", values[0], values[1]),
+		Data: map[string]interface{}{
+			"x": values[0],
+			"y": values[1],
+		},
+	}
+}
+
+func handleDrag(params map[string]interface{}) AutomationResponse {
+	values, ok := intParams(params, "from_x", "from_y", "to_x", "to_y")
+	if !ok {
+		return errorResponse(codeInvalidArgument, "invalid drag coordinates")
+	}
+
+	robotgo.Move(values[0], values[1])
+	robotgo.MilliSleep(100)
+	robotgo.Toggle("left")
+	robotgo.MilliSleep(100)
+	robotgo.Move(values[2], values[3])
+	robotgo.MilliSleep(100)
+	robotgo.Toggle("left", "up")
+
+	return AutomationResponse{
+		Success: true,
+		Message: fmt.Sprintf("dragged from (%d, %d) to (%d, %d)", values[0], values[1], values[2], values[3]),
+		Data: map[string]interface{}{
+			"from_x": values[0],
+			"from_y": values[1],
+			"to_x":   values[2],
+			"to_y":   values[3],
+		},
+	}
+}
+
+func handleScroll(params map[string]interface{}) AutomationResponse {
+	values, ok := intParams(params, "x", "y", "clicks")
+	if !ok {
+		return errorResponse(codeInvalidArgument, "invalid scroll parameters")
+	}
+	direction, _ := params["direction"].(string)
+
+	robotgo.Move(values[0], values[1])
+	clicks := values[2]
+	switch direction {
+	case "up":
+		robotgo.Scroll(0, clicks)
+	case "down":
+		robotgo.Scroll(0, -clicks)
+	case "left":
+		robotgo.Scroll(-clicks, 0)
+	case "right":
+		robotgo.Scroll(clicks, 0)
+	default:
+		return errorResponse(codeInvalidArgument, "invalid scroll direction: %s", direction)
+	}
+
+	return AutomationResponse{
+		Success: true,
+		Message: fmt.Sprintf("scrolled %s %d clicks at (%d, %d)", direction, clicks, values[0], values[1]),
+		Data: map[string]interface{}{
+			"x":         values[0],
+			"y":         values[1],
+			"direction": direction,
+			"clicks":    clicks,
+		},
+	}
+}
+
+func handleKeyDown(params map[string]interface{}) AutomationResponse {
+	return toggleKey(params, "down")
+}
+
+func handleKeyUp(params map[string]interface{}) AutomationResponse {
+	return toggleKey(params, "up")
+}
+
+// toggleKey 按下或释放按键
+func toggleKey(params map[string]interface{}, state string) AutomationResponse {
+	key, ok := params["key"].(string)
+	if !ok {
+		return errorResponse(codeInvalidArgument, "invalid key parameter")
+	}
+
+	if err := robotgo.KeyToggle(key, state); err != nil {
+		return errorResponse(codeUnknown, "failed to toggle key %s %s: %v", key, state, err)
+	}
+
+	return AutomationResponse{
+		Success: true,
+		Message: fmt.Sprintf("key %s: %s", state, key),
+		Data: map[string]interface{}{
+			"key":   key,
+			"state": state,
+		},
+	}
+}
+
+func handleHotkey(params map[string]interface{}) AutomationResponse {
+	key, ok := params["key"].(string)
+	if !ok {
+		return errorResponse(codeInvalidArgument, "invalid key parameter")
+	}
+
+	var modifiers []interface{}
+	var names []string
+	if list, ok := params["modifiers"].([]interface{}); ok {
+		for _, item := range list {
+			if name, ok := item.(string); ok {
+				modifiers = append(modifiers, modifierKey(name))
+				names = append(names, modifierKey(name))
+			}
+		}
+	}
+
+	if err := robotgo.KeyTap(key, modifiers...); err != nil {
+		return errorResponse(codeUnknown, "failed to press hotkey: %v", err)
+	}
+
+	return AutomationResponse{
+		Success: true,
+		Message: fmt.Sprintf("pressed hotkey: %v+%s", names, key),
+		Data: map[string]interface{}{
+			"key":       key,
+			"modifiers": names,
+		},
+	}
+}
+
+func handleGetPosition(params map[string]interface{}) AutomationResponse {
+	x, y := robotgo.Location()
+
+	return AutomationResponse{
+		Success: true,
+		Message: fmt.Sprintf("mouse position: (%d, %d)", x, y),
+		Data: map[string]interface{}{
+			"x": x,
+			"y": y,
+		},
+	}
+}
+
+func handleScreenSize(params map[string]interface{}) AutomationResponse {
+	width, height := robotgo.GetScreenSize()
+
+	return AutomationResponse{
+		Success: true,
+		Message: fmt.Sprintf("screen size: %dx%d", width, height),
+		Data: map[string]interface{}{
+			"width":    width,
+			"height":   height,
+			"displays": robotgo.DisplaysNum(),
+			"platform": runtime.GOOS,
+		},
+	}
+}
+
+func handleGetClipboard(params map[string]interface{}) AutomationResponse {
+	text, err := robotgo.ReadAll()
+	if err != nil {
+		return errorResponse(codeUnknown, "failed to read clipboard: %v", err)
+	}
+
+	return AutomationResponse{
+		Success: true,
+		Message: "clipboard read",
+		Data: map[string]interface{}{
+			"text": text,
+		},
+	}
+}
+
+func handleSetClipboard(params map[string]interface{}) AutomationResponse {
+	text, ok := params["text"].(string)
+	if !ok {
+		return errorResponse(codeInvalidArgument, "invalid text parameter")
+	}
+
+	if err := robotgo.WriteAll(text); err != nil {
+		return errorResponse(codeUnknown, "failed to write clipboard: %v", err)
+	}
+
+	return AutomationResponse{
+		Success: true,
+		Message: "clipboard updated",
+		Data: map[string]interface{}{
+			"length": len([]rune(text)),
+		},
+	}
+}
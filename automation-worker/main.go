@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"os"
 
 	"github.com/go-vgo/robotgo"
@@ -20,26 +25,35 @@ type AutomationResponse struct {
 	Message string                 `json:"message"`
 	Data    map[string]interface{} `json:"data,omitempty"`
 	Error   string                 `json:"error,omitempty"`
+	Code    string                 `json:"code,omitempty"` // 失败时的错误码，取值与core.ErrorCode一致
 }
 
+// 错误码，与core.ErrorCode保持一致
+const (
+	codeInvalidArgument = "invalid_argument"
+	codeUnsupported     = "unsupported"
+	codeUnknown         = "unknown"
+)
+
 func main() {
+	// 无参数时以常驻模式运行，通过stdin/stdout进行JSON-RPC通信
 	if len(os.Args) < 2 {
-		response := AutomationResponse{
-			Success: false,
-			Error:   "no request provided",
+		server := NewRPCServer(os.Stdin, os.Stdout)
+		if err := server.Serve(); err != nil {
+			fmt.Fprintf(os.Stderr, "rpc server stopped: %v\n", err)
+			os.Exit(1)
 		}
-		output, _ := json.Marshal(response)
-		fmt.Print(string(output))
 		return
 	}
 
-	// 解析请求
+	// 兼容旧的单次调用模式：通过命令行参数传递请求
 	var request AutomationRequest
 	err := json.Unmarshal([]byte(os.Args[1]), &request)
 	if err != nil {
 		response := AutomationResponse{
 			Success: false,
 			Error:   fmt.Sprintf("failed to parse request: %v", err),
+			Code:    codeInvalidArgument,
 		}
 		output, _ := json.Marshal(response)
 		fmt.Print(string(output))
@@ -52,45 +66,72 @@ func main() {
 	fmt.Print(string(output))
 }
 
+// actionHandlers 支持的操作及其处理函数
+var actionHandlers map[string]func(params map[string]interface{}) AutomationResponse
+
+func init() {
+	actionHandlers = map[string]func(params map[string]interface{}) AutomationResponse{
+		"click":           handleClick,
+		"type":            handleType,
+		"keypress":        handleKeyPress,
+		"key_down":        handleKeyDown,
+		"key_up":          handleKeyUp,
+		"hotkey":          handleHotkey,
+		"move":            handleMove,
+		"drag":            handleDrag,
+		"scroll":          handleScroll,
+		"get_position":    handleGetPosition,
+		"screen_size":     handleScreenSize,
+		"get_clipboard":   handleGetClipboard,
+		"set_clipboard":   handleSetClipboard,
+		"screenshot":      handleScreenshot,
+		"screenshot_area": handleScreenshotArea,
+		"info":            handleInfo,
+	}
+}
+
 func executeAction(request AutomationRequest) AutomationResponse {
-	switch request.Action {
-	case "click":
-		return handleClick(request.Parameters)
-	case "type":
-		return handleType(request.Parameters)
-	case "keypress":
-		return handleKeyPress(request.Parameters)
-	case "screenshot":
-		return handleScreenshot(request.Parameters)
-	case "info":
-		return handleInfo(request.Parameters)
-	default:
+	handler, ok := actionHandlers[request.Action]
+	if !ok {
 		return AutomationResponse{
 			Success: false,
 			Error:   fmt.Sprintf("unknown action: %s", request.Action),
+			Code:    codeUnsupported,
 		}
 	}
+	return handler(request.Parameters)
 }
 
 func handleClick(params map[string]interface{}) AutomationResponse {
 	x, ok1 := params["x"].(float64)
 	y, ok2 := params["y"].(float64)
-	
+
 	if !ok1 || !ok2 {
 		return AutomationResponse{
 			Success: false,
 			Error:   "invalid x or y coordinates",
+			Code:    codeInvalidArgument,
 		}
 	}
 
-	robotgo.Click(int(x), int(y))
-	
+	button, _ := params["button"].(string)
+	if button == "" {
+		button = "left"
+	}
+	double, _ := params["double"].(bool)
+
+	// robotgo.Click只接受按键参数，需要先移动到目标位置
+	robotgo.Move(int(x), int(y))
+	robotgo.Click(button, double)
+
 	return AutomationResponse{
 		Success: true,
 		Message: fmt.Sprintf("clicked at (%d, %d)", int(x), int(y)),
 		Data: map[string]interface{}{
-			"x": int(x),
-			"y": int(y),
+			"x":      int(x),
+			"y":      int(y),
+			"button": button,
+			"double": double,
 		},
 	}
 }
@@ -101,11 +142,12 @@ func handleType(params map[string]interface{}) AutomationResponse {
 		return AutomationResponse{
 			Success: false,
 			Error:   "invalid text parameter",
+			Code:    codeInvalidArgument,
 		}
 	}
 
 	robotgo.TypeStr(text)
-	
+
 	return AutomationResponse{
 		Success: true,
 		Message: fmt.Sprintf("typed text: %s", text),
@@ -121,11 +163,12 @@ func handleKeyPress(params map[string]interface{}) AutomationResponse {
 		return AutomationResponse{
 			Success: false,
 			Error:   "invalid key parameter",
+			Code:    codeInvalidArgument,
 		}
 	}
 
 	robotgo.KeyTap(key)
-	
+
 	return AutomationResponse{
 		Success: true,
 		Message: fmt.Sprintf("pressed key: %s", key),
@@ -135,24 +178,111 @@ func handleKeyPress(params map[string]interface{}) AutomationResponse {
 	}
 }
 
+// handleScreenshot 截取指定显示器（默认主显示器），返回编码后的图像
 func handleScreenshot(params map[string]interface{}) AutomationResponse {
-	bitmap := robotgo.CaptureScreen()
-	if bitmap == nil {
+	display := 0
+	if value, ok := params["display"].(float64); ok {
+		display = int(value)
+	}
+	if display < 0 || display >= robotgo.DisplaysNum() {
+		return AutomationResponse{
+			Success: false,
+			Error:   fmt.Sprintf("invalid display index: %d", display),
+			Code:    codeInvalidArgument,
+		}
+	}
+
+	x, y, w, h := robotgo.GetDisplayBounds(display)
+	img, err := robotgo.CaptureImg(x, y, w, h, display)
+	if err != nil {
 		return AutomationResponse{
 			Success: false,
-			Error:   "failed to capture screen",
+			Error:   fmt.Sprintf("failed to capture screen: %v", err),
+			Code:    codeUnknown,
 		}
 	}
 
-	// 将bitmap转换为base64
-	// 这里简化处理，实际应用中可能需要更复杂的图像处理
+	return encodeCapture(img, params, display, x, y, w, h)
+}
+
+// handleScreenshotArea 截取虚拟桌面中的指定区域
+func handleScreenshotArea(params map[string]interface{}) AutomationResponse {
+	x, ok1 := params["x"].(float64)
+	y, ok2 := params["y"].(float64)
+	w, ok3 := params["width"].(float64)
+	h, ok4 := params["height"].(float64)
+	if !ok1 || !ok2 || !ok3 || !ok4 || w <= 0 || h <= 0 {
+		return AutomationResponse{
+			Success: false,
+			Error:   "invalid area parameters",
+			Code:    codeInvalidArgument,
+		}
+	}
+
+	img, err := robotgo.CaptureImg(int(x), int(y), int(w), int(h))
+	if err != nil {
+		return AutomationResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to capture area: %v", err),
+			Code:    codeUnknown,
+		}
+	}
+
+	return encodeCapture(img, params, -1, int(x), int(y), int(w), int(h))
+}
+
+// encodeCapture 按请求的格式编码图像，图像数据以base64放在image字段
+func encodeCapture(img image.Image, params map[string]interface{}, display, x, y, w, h int) AutomationResponse {
+	format, _ := params["format"].(string)
+	if format == "" {
+		format = "png"
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg", "jpg":
+		format = "jpeg"
+		quality := 85
+		if value, ok := params["quality"].(float64); ok && value > 0 && value <= 100 {
+			quality = int(value)
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	default:
+		err = fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return AutomationResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to encode image: %v", err),
+			Code:    codeUnknown,
+		}
+	}
+
+	size := img.Bounds().Size()
+	scale := 1.0
+	if w > 0 {
+		scale = float64(size.X) / float64(w)
+	}
+
 	return AutomationResponse{
 		Success: true,
-		Message: "screenshot taken",
+		Message: fmt.Sprintf("screenshot taken (%dx%d)", size.X, size.Y),
 		Data: map[string]interface{}{
-			"format": "bitmap",
-			"width":  bitmap.Width,
-			"height": bitmap.Height,
+			"image":         base64.StdEncoding.EncodeToString(buf.Bytes()),
+			"format":        format,
+			"display_index": display,
+			"bounds": map[string]interface{}{
+				"x":      x,
+				"y":      y,
+				"width":  w,
+				"height": h,
+			},
+			"width":        size.X,
+			"height":       size.Y,
+			"scale_factor": scale,
 		},
 	}
 }
@@ -162,14 +292,11 @@ func handleInfo(params map[string]interface{}) AutomationResponse {
 		Success: true,
 		Message: "automation worker info",
 		Data: map[string]interface{}{
-			"version": "1.0.0",
-			"engine":  "robotgo",
-			"capabilities": []string{
-				"click",
-				"type", 
-				"keypress",
-				"screenshot",
-			},
+			"version":          WorkerVersion,
+			"protocol_version": ProtocolVersion,
+			"engine":           "robotgo",
+			"capabilities":     capabilities(),
+			"descriptor":       descriptor(),
 		},
 	}
 }
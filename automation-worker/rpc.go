@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+const (
+	// WorkerVersion worker程序版本
+	WorkerVersion = "1.1.0"
+	// ProtocolVersion JSON-RPC会话协议版本，主程序握手时校验
+	ProtocolVersion = 1
+
+	// 协议内置方法
+	methodHandshake = "handshake"
+	methodPing      = "ping"
+	methodShutdown  = "shutdown"
+
+	// JSON-RPC错误码
+	errCodeParse          = -32700
+	errCodeMethodNotFound = -32601
+)
+
+// RPCRequest 行分隔的JSON-RPC请求
+type RPCRequest struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	ID      uint64                 `json:"id"`
+	Method  string                 `json:"method"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// RPCResponse 行分隔的JSON-RPC响应
+type RPCResponse struct {
+	JSONRPC string              `json:"jsonrpc"`
+	ID      uint64              `json:"id"`
+	Result  *AutomationResponse `json:"result,omitempty"`
+	Error   *RPCError           `json:"error,omitempty"`
+}
+
+// RPCError 协议级错误（操作本身的失败放在Result中）
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// RPCServer 常驻模式下的请求处理器
+type RPCServer struct {
+	in  io.Reader
+	out io.Writer
+
+	writeMu  sync.Mutex // 保证每条响应完整写出一行
+	actionMu sync.Mutex // robotgo操作不是并发安全的，串行执行
+	wg       sync.WaitGroup
+}
+
+// NewRPCServer 创建RPC服务
+func NewRPCServer(in io.Reader, out io.Writer) *RPCServer {
+	return &RPCServer{in: in, out: out}
+}
+
+// Serve 循环读取请求直到stdin关闭或收到shutdown
+func (s *RPCServer) Serve() error {
+	scanner := bufio.NewScanner(s.in)
+	// 请求中可能携带较大的文本，放宽单行长度限制
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var request RPCRequest
+		if err := json.Unmarshal(line, &request); err != nil {
+			s.writeResponse(RPCResponse{
+				Error: &RPCError{Code: errCodeParse, Message: fmt.Sprintf("failed to parse request: %v", err)},
+			})
+			continue
+		}
+
+		switch request.Method {
+		case methodHandshake:
+			s.writeResponse(RPCResponse{ID: request.ID, Result: handshakeResponse()})
+		case methodPing:
+			// 心跳不经过操作锁，即使有操作在执行也能及时响应
+			s.writeResponse(RPCResponse{ID: request.ID, Result: &AutomationResponse{
+				Success: true,
+				Message: "pong",
+			}})
+		case methodShutdown:
+			// 等待正在执行的请求完成后再退出
+			s.wg.Wait()
+			s.writeResponse(RPCResponse{ID: request.ID, Result: &AutomationResponse{
+				Success: true,
+				Message: "worker shutting down",
+			}})
+			return nil
+		default:
+			s.wg.Add(1)
+			go s.handle(request)
+		}
+	}
+
+	s.wg.Wait()
+	return scanner.Err()
+}
+
+// handle 执行单个请求并写回响应
+func (s *RPCServer) handle(request RPCRequest) {
+	defer s.wg.Done()
+
+	if _, ok := actionHandlers[request.Method]; !ok {
+		s.writeResponse(RPCResponse{
+			ID:    request.ID,
+			Error: &RPCError{Code: errCodeMethodNotFound, Message: fmt.Sprintf("unknown action: %s", request.Method)},
+		})
+		return
+	}
+
+	s.actionMu.Lock()
+	response := executeAction(AutomationRequest{Action: request.Method, Parameters: request.Params})
+	s.actionMu.Unlock()
+
+	s.writeResponse(RPCResponse{ID: request.ID, Result: &response})
+}
+
+// writeResponse 写出一行响应
+func (s *RPCServer) writeResponse(response RPCResponse) {
+	response.JSONRPC = "2.0"
+	data, err := json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(RPCResponse{
+			JSONRPC: "2.0",
+			ID:      response.ID,
+			Error:   &RPCError{Code: errCodeParse, Message: fmt.Sprintf("failed to marshal response: %v", err)},
+		})
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+// handshakeResponse 握手信息：版本与能力
+func handshakeResponse() *AutomationResponse {
+	return &AutomationResponse{
+		Success: true,
+		Message: "automation worker ready",
+		Data: map[string]interface{}{
+			"version":          WorkerVersion,
+			"protocol_version": ProtocolVersion,
+			"engine":           "robotgo",
+			"capabilities":     capabilities(),
+			"descriptor":       descriptor(),
+		},
+	}
+}
+
+// workerKeys robotgo支持的具名按键（单个字符按键总是支持）
+var workerKeys = []string{
+	"backspace", "capslock", "delete", "down", "end", "enter", "esc", "escape",
+	"f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9", "f10", "f11", "f12",
+	"home", "insert", "left", "pagedown", "pageup", "printscreen", "right", "space", "tab", "up",
+}
+
+// descriptor 机器可读的能力描述，字段与core.Capabilities一致（操作列表由主程序按capabilities映射）
+func descriptor() map[string]interface{} {
+	return map[string]interface{}{
+		"platforms":     []string{runtime.GOOS},
+		"keys":          workerKeys,
+		"modifiers":     []string{"alt", "ctrl", "shift", "win"},
+		"mouse_buttons": []string{"left", "middle", "right"},
+		"multi_monitor": true,
+		"unicode_text":  true,
+	}
+}
+
+// capabilities 返回支持的操作列表
+func capabilities() []string {
+	names := make([]string, 0, len(actionHandlers))
+	for name := range actionHandlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -17,7 +17,8 @@ const (
 type WindowManager struct {
 	app                *application.App
 	winMap             map[string]*application.WebviewWindow
-	floatingStickySide int // 浮动窗口贴边位置：0-无贴边，1-左，2-右，3-上
+	floatingStickySide int              // 浮动窗口贴边位置：0-无贴边，1-左，2-右，3-上
+	floatingScreenRect application.Rect // 浮动窗口所在显示器的范围（与窗口位置相同的DIP坐标）
 
 	initializeSuccess bool // 初始化是否成功
 }
@@ -67,21 +68,20 @@ func (wm *WindowManager) initializeFloating() {
 
 	win.RegisterHook(events.Common.WindowDidMove, func(event *application.WindowEvent) {
 		rect := win.Bounds()
-		// 判断窗口是否靠近屏幕边缘
-		screenWidth := w32.GetSystemMetrics(w32.SM_CXSCREEN)
-		screenHeight := w32.GetSystemMetrics(w32.SM_CYSCREEN)
+		// 判断窗口是否靠近所在显示器的边缘
+		screen := wm.floatingScreen(win)
 
 		oldStickySide := wm.floatingStickySide
-		if rect.X <= 0 {
+		if rect.X <= screen.X {
 			// 左侧
 			wm.floatingStickySide = 1
-		} else if rect.X+rect.Width >= screenWidth {
+		} else if rect.X+rect.Width >= screen.X+screen.Width {
 			// 右侧
 			wm.floatingStickySide = 2
-		} else if rect.Y <= 0 {
+		} else if rect.Y <= screen.Y {
 			// 上方
 			wm.floatingStickySide = 3
-		} else if rect.Y+rect.Height >= screenHeight {
+		} else if rect.Y+rect.Height >= screen.Y+screen.Height {
 			// 下方（新增）
 			wm.floatingStickySide = 4
 		} else {
@@ -96,47 +96,59 @@ func (wm *WindowManager) initializeFloating() {
 
 	// 鼠标进入时显示窗口，这里只能用前端传入的自定义事件完成
 	wm.app.Event.On(constant.EventMouseEnterFloating, func(event *application.CustomEvent) {
+		rect := win.Bounds()
+		screen := wm.floatingScreen(win)
 		switch wm.floatingStickySide {
 		case 1:
-			rect := win.Bounds()
-			win.SetPosition(0, rect.Y)
+			win.SetPosition(screen.X, rect.Y)
 		case 2:
-			rect := win.Bounds()
-			screenWidth := w32.GetSystemMetrics(w32.SM_CXSCREEN)
-			win.SetPosition(screenWidth-rect.Width, rect.Y)
+			win.SetPosition(screen.X+screen.Width-rect.Width, rect.Y)
 		case 3:
-			rect := win.Bounds()
-			win.SetPosition(rect.X, 0)
+			win.SetPosition(rect.X, screen.Y)
 		case 4:
-			rect := win.Bounds()
-			screenHeight := w32.GetSystemMetrics(w32.SM_CYSCREEN)
-			win.SetPosition(rect.X, screenHeight-rect.Height)
+			win.SetPosition(rect.X, screen.Y+screen.Height-rect.Height)
 		}
 	})
 
 	// 鼠标离开时隐藏窗口
 	wm.app.Event.On(constant.EventMouseLeaveFloating, func(event *application.CustomEvent) {
+		rect := win.Bounds()
+		screen := wm.floatingScreen(win)
 		switch wm.floatingStickySide {
 		case 1:
-			rect := win.Bounds()
-			win.SetPosition(0-rect.Width+snapShow, rect.Y)
+			win.SetPosition(screen.X-rect.Width+snapShow, rect.Y)
 		case 2:
-			rect := win.Bounds()
-			screenWidth := w32.GetSystemMetrics(w32.SM_CXSCREEN)
-			win.SetPosition(screenWidth-snapShow, rect.Y)
+			win.SetPosition(screen.X+screen.Width-snapShow, rect.Y)
 		case 3:
-			rect := win.Bounds()
-			win.SetPosition(rect.X, 0-rect.Height+snapShow)
+			win.SetPosition(rect.X, screen.Y-rect.Height+snapShow)
 		case 4:
-			rect := win.Bounds()
-			screenHeight := w32.GetSystemMetrics(w32.SM_CYSCREEN)
-			win.SetPosition(rect.X, screenHeight-snapShow)
+			win.SetPosition(rect.X, screen.Y+screen.Height-snapShow)
 		}
 	})
 
 	wm.winMap[WindowFloating] = win
 }
 
+// floatingScreen 浮动窗口所在显示器的范围
+// 多显示器时按窗口实际所在的显示器判断贴边，而不是只看主显示器；
+// 窗口贴边隐藏后大部分位于显示器外，只要还有一部分在原显示器上就继续使用它，避免被判断到相邻显示器
+func (wm *WindowManager) floatingScreen(win *application.WebviewWindow) application.Rect {
+	rect := win.Bounds()
+	if !wm.floatingScreenRect.IsEmpty() && !rect.Intersect(wm.floatingScreenRect).IsEmpty() {
+		return wm.floatingScreenRect
+	}
+
+	if screen, err := win.GetScreen(); err == nil && screen != nil {
+		wm.floatingScreenRect = screen.Bounds
+	} else {
+		wm.floatingScreenRect = application.Rect{
+			Width:  w32.GetSystemMetrics(w32.SM_CXSCREEN),
+			Height: w32.GetSystemMetrics(w32.SM_CYSCREEN),
+		}
+	}
+	return wm.floatingScreenRect
+}
+
 func (wm *WindowManager) initializeSettings() {
 	win := wm.app.Window.NewWithOptions(application.WebviewWindowOptions{
 		Title:     "点点配置项",
@@ -0,0 +1,287 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Capabilities 自动化引擎的能力描述
+// 任务分解时提供给模型，执行前用于拒绝引擎无法完成的计划
+type Capabilities struct {
+	Engine       string        `json:"engine"`
+	Platforms    []string      `json:"platforms,omitempty"` // 支持的操作系统（runtime.GOOS取值），为空表示与平台无关
+	Operations   []Operation   `json:"operations"`
+	Keys         []string      `json:"keys"` // 支持的具名按键，单个字母和数字按键不在此列出
+	Modifiers    []KeyModifier `json:"modifiers"`
+	MouseButtons []MouseButton `json:"mouse_buttons"`
+	MultiMonitor bool          `json:"multi_monitor"` // 能否区分并截取多个显示器
+	UnicodeText  bool          `json:"unicode_text"`  // Type能否输入任意Unicode文本，否则只支持字母、数字、空格、Tab和换行
+
+	Backends []*Capabilities `json:"backends,omitempty"` // 组合引擎的各个后端
+}
+
+// CapabilityProvider 能够描述自身能力的引擎
+type CapabilityProvider interface {
+	Capabilities() *Capabilities
+}
+
+// ContextCapabilityProvider 获取能力时可能需要启动后端（例如握手）的引擎，ctx取消时应尽快返回
+type ContextCapabilityProvider interface {
+	CapabilitiesContext(ctx context.Context) *Capabilities
+}
+
+// operationMethods 操作与core.AutomationEngine方法名的对应关系
+var operationMethods = map[Operation]string{
+	OpClick:       "Click",
+	OpDoubleClick: "DoubleClick",
+	OpRightClick:  "RightClick",
+	OpDrag:        "Drag",
+	OpMove:        "Move",
+	OpGetPosition: "GetPosition",
+	OpScroll:      "Scroll",
+
+	OpType:      "Type",
+	OpKeyPress:  "KeyPress",
+	OpKeyDown:   "KeyDown",
+	OpKeyUp:     "KeyUp",
+	OpHotkey:    "Hotkey",
+	OpCopy:      "Copy",
+	OpPaste:     "Paste",
+	OpSelectAll: "SelectAll",
+
+	OpLaunch:           "Launch",
+	OpLaunchWithPath:   "LaunchWithPath",
+	OpLaunchApp:        "LaunchApp",
+	OpGetInstalledApps: "GetInstalledApps",
+	OpFindApp:          "FindApp",
+
+	OpCreateFile:  "CreateFile",
+	OpCreateDir:   "CreateDir",
+	OpMoveFile:    "MoveFile",
+	OpCopyFile:    "CopyFile",
+	OpDeleteFile:  "DeleteFile",
+	OpDeleteDir:   "DeleteDir",
+	OpRenameFile:  "RenameFile",
+	OpFileExists:  "FileExists",
+	OpGetFileInfo: "GetFileInfo",
+	OpListDir:     "ListDir",
+
+	OpScreenshot:     "Screenshot",
+	OpScreenshotArea: "ScreenshotArea",
+	OpGetScreenSize:  "GetScreenSize",
+	OpGetDisplays:    "GetDisplays",
+	OpFindImage:      "FindImage",
+	OpFindText:       "FindText",
+	OpGetPixelColor:  "GetPixelColor",
+
+	OpGetClipboard:    "GetClipboard",
+	OpSetClipboard:    "SetClipboard",
+	OpGetActiveWindow: "GetActiveWindow",
+	OpGetWindows:      "GetWindows",
+	OpActivateWindow:  "ActivateWindow",
+	OpCloseWindow:     "CloseWindow",
+	OpMinimizeWindow:  "MinimizeWindow",
+	OpMaximizeWindow:  "MaximizeWindow",
+
+	OpWait: "Wait",
+}
+
+// ImplementedOperations 根据方法集判断引擎实现了哪些操作（按名称排序）
+// 只实现了部分core.AutomationEngine方法的后端也能得到准确的操作列表
+func ImplementedOperations(engine interface{}) []Operation {
+	if engine == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(engine)
+	var operations []Operation
+	for operation, method := range operationMethods {
+		if _, ok := t.MethodByName(method); ok {
+			operations = append(operations, operation)
+		} else if _, ok := t.MethodByName(method + "Context"); ok {
+			operations = append(operations, operation)
+		}
+	}
+	sortOperations(operations)
+	return operations
+}
+
+// CapabilitiesOf 获取引擎的能力描述
+// 引擎未实现CapabilityProvider时只能根据方法集推断支持的操作
+func CapabilitiesOf(name string, engine interface{}) *Capabilities {
+	if provider, ok := engine.(CapabilityProvider); ok {
+		if caps := provider.Capabilities(); caps != nil {
+			return caps
+		}
+	}
+	return &Capabilities{
+		Engine:     name,
+		Operations: ImplementedOperations(engine),
+	}
+}
+
+// CapabilitiesOfContext 获取引擎的能力描述，引擎实现了ContextCapabilityProvider时优先使用
+func CapabilitiesOfContext(ctx context.Context, name string, engine interface{}) *Capabilities {
+	if provider, ok := engine.(ContextCapabilityProvider); ok {
+		if caps := provider.CapabilitiesContext(ctx); caps != nil {
+			return caps
+		}
+	}
+	return CapabilitiesOf(name, engine)
+}
+
+// Supports 是否支持指定操作
+func (c *Capabilities) Supports(operation Operation) bool {
+	for _, op := range c.Operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing 返回operations中不支持的操作
+func (c *Capabilities) Missing(operations ...Operation) []Operation {
+	var missing []Operation
+	for _, operation := range operations {
+		if !c.Supports(operation) {
+			missing = append(missing, operation)
+		}
+	}
+	return missing
+}
+
+// SupportsKey 是否支持指定按键（不区分大小写）
+// 单个字母和数字总是支持，其他单字符按键只有支持Unicode输入的引擎才支持
+func (c *Capabilities) SupportsKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range c.Keys {
+		if k == key {
+			return true
+		}
+	}
+
+	runes := []rune(key)
+	if len(runes) != 1 {
+		return false
+	}
+	r := runes[0]
+	return (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))) || c.UnicodeText
+}
+
+// SupportsModifier 是否支持指定修饰键
+func (c *Capabilities) SupportsModifier(modifier KeyModifier) bool {
+	for _, m := range c.Modifiers {
+		if m == modifier {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsButton 是否支持指定鼠标按键
+func (c *Capabilities) SupportsButton(button MouseButton) bool {
+	for _, b := range c.MouseButtons {
+		if b == button {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsText 能否输入指定文本，不能时返回第一个无法输入的字符
+func (c *Capabilities) SupportsText(text string) (rune, bool) {
+	if c.UnicodeText {
+		return 0, true
+	}
+	for _, r := range text {
+		if !IsBasicTextRune(r) {
+			return r, false
+		}
+	}
+	return 0, true
+}
+
+// IsBasicTextRune 不支持Unicode输入的引擎也能输入的字符：ASCII字母、数字、空格、Tab和换行
+func IsBasicTextRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r == ' ', r == '\t', r == '\n':
+		return true
+	default:
+		return false
+	}
+}
+
+// MergeCapabilities 合并多个后端的能力：支持的操作、按键等取并集
+// 组合引擎会把操作路由到支持它的后端，因此任一后端支持即视为支持
+func MergeCapabilities(engine string, backends ...*Capabilities) *Capabilities {
+	merged := &Capabilities{Engine: engine}
+
+	operations := make(map[Operation]bool)
+	keys := make(map[string]bool)
+	modifiers := make(map[KeyModifier]bool)
+	buttons := make(map[MouseButton]bool)
+	platforms := make(map[string]bool)
+	for _, caps := range backends {
+		if caps == nil {
+			continue
+		}
+		merged.Backends = append(merged.Backends, caps)
+		for _, op := range caps.Operations {
+			operations[op] = true
+		}
+		for _, key := range caps.Keys {
+			keys[key] = true
+		}
+		for _, modifier := range caps.Modifiers {
+			modifiers[modifier] = true
+		}
+		for _, button := range caps.MouseButtons {
+			buttons[button] = true
+		}
+		for _, platform := range caps.Platforms {
+			platforms[platform] = true
+		}
+		merged.MultiMonitor = merged.MultiMonitor || caps.MultiMonitor
+		merged.UnicodeText = merged.UnicodeText || caps.UnicodeText
+	}
+
+	for op := range operations {
+		merged.Operations = append(merged.Operations, op)
+	}
+	sortOperations(merged.Operations)
+	merged.Keys = sortedKeys(keys)
+	for modifier := range modifiers {
+		merged.Modifiers = append(merged.Modifiers, modifier)
+	}
+	sort.Slice(merged.Modifiers, func(i, j int) bool { return merged.Modifiers[i] < merged.Modifiers[j] })
+	for button := range buttons {
+		merged.MouseButtons = append(merged.MouseButtons, button)
+	}
+	sort.Slice(merged.MouseButtons, func(i, j int) bool { return merged.MouseButtons[i] < merged.MouseButtons[j] })
+	merged.Platforms = sortedKeys(platforms)
+	return merged
+}
+
+// sortOperations 按名称排序操作列表
+func sortOperations(operations []Operation) {
+	sort.Slice(operations, func(i, j int) bool { return operations[i] < operations[j] })
+}
+
+// sortedKeys 返回集合中的元素（按名称排序）
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	list := make([]string, 0, len(set))
+	for key := range set {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
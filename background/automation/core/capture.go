@@ -0,0 +1,188 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+)
+
+// DisplayIndexVirtual 截图不对应单个显示器（区域截图或整个虚拟桌面）
+const DisplayIndexVirtual = -1
+
+// ScreenCapture 截图结果
+// 所有Screenshot/ScreenshotArea实现都以它作为OperationResult.Data返回
+type ScreenCapture struct {
+	ImageData    []byte  `json:"-"`             // 编码后的图像数据（PNG/JPEG）
+	Format       string  `json:"format"`        // 图像格式：png / jpeg
+	DisplayIndex int     `json:"display_index"` // 显示器索引，DisplayIndexVirtual表示不属于单个显示器
+	Bounds       Rect    `json:"bounds"`        // 截图区域在虚拟桌面中的坐标
+	Width        int     `json:"width"`         // 图像像素宽度
+	Height       int     `json:"height"`        // 图像像素高度
+	ScaleFactor  float64 `json:"scale_factor"`  // 图像像素与桌面坐标的比例
+	Size         int     `json:"size"`          // 图像数据字节数
+}
+
+// NewScreenCapture 根据编码后的图像创建截图结果
+// bounds为空时使用图像尺寸作为区域，比例为1
+func NewScreenCapture(imageData []byte, displayIndex int, bounds Rect) (*ScreenCapture, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image data: %w", err)
+	}
+
+	if bounds.Width <= 0 || bounds.Height <= 0 {
+		bounds.Width = config.Width
+		bounds.Height = config.Height
+	}
+
+	return &ScreenCapture{
+		ImageData:    imageData,
+		Format:       format,
+		DisplayIndex: displayIndex,
+		Bounds:       bounds,
+		Width:        config.Width,
+		Height:       config.Height,
+		ScaleFactor:  float64(config.Width) / float64(bounds.Width),
+		Size:         len(imageData),
+	}, nil
+}
+
+// NewCaptureResult 创建截图成功结果
+func NewCaptureResult(message string, capture *ScreenCapture) *OperationResult {
+	return NewSuccessResult(message, capture)
+}
+
+// CaptureFromResult 从操作结果中取出截图
+func CaptureFromResult(result *OperationResult) (*ScreenCapture, bool) {
+	if result == nil || !result.Success {
+		return nil, false
+	}
+	switch data := result.Data.(type) {
+	case *ScreenCapture:
+		return data, data != nil && len(data.ImageData) > 0
+	case ScreenCapture:
+		return &data, len(data.ImageData) > 0
+	default:
+		return nil, false
+	}
+}
+
+// ToDisplayCapture 转换为显示器截图信息
+func (c *ScreenCapture) ToDisplayCapture(isActive bool) DisplayCapture {
+	return DisplayCapture{
+		Index:     c.DisplayIndex,
+		Bounds:    image.Rect(c.Bounds.X, c.Bounds.Y, c.Bounds.X+c.Bounds.Width, c.Bounds.Y+c.Bounds.Height),
+		ImageData: c.ImageData,
+		Width:     c.Width,
+		Height:    c.Height,
+		IsActive:  isActive,
+	}
+}
+
+// Capture 转换回截图结果，用于截图像素坐标与虚拟桌面坐标的转换
+func (d DisplayCapture) Capture() *ScreenCapture {
+	bounds := Rect{X: d.Bounds.Min.X, Y: d.Bounds.Min.Y, Width: d.Bounds.Dx(), Height: d.Bounds.Dy()}
+	scale := 1.0
+	if bounds.Width > 0 && d.Width > 0 {
+		scale = float64(d.Width) / float64(bounds.Width)
+	}
+	return &ScreenCapture{
+		ImageData:    d.ImageData,
+		DisplayIndex: d.Index,
+		Bounds:       bounds,
+		Width:        d.Width,
+		Height:       d.Height,
+		ScaleFactor:  scale,
+		Size:         len(d.ImageData),
+	}
+}
+
+// ToPixels 虚拟桌面坐标转换为截图的像素坐标
+func (c *ScreenCapture) ToPixels(r Rect) Rect {
+	scale := c.scale()
+	x0 := int(math.Floor(float64(r.X-c.Bounds.X) * scale))
+	y0 := int(math.Floor(float64(r.Y-c.Bounds.Y) * scale))
+	x1 := int(math.Ceil(float64(r.X+r.Width-c.Bounds.X) * scale))
+	y1 := int(math.Ceil(float64(r.Y+r.Height-c.Bounds.Y) * scale))
+	return Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+// ToDesktop 截图的像素坐标转换为虚拟桌面坐标
+func (c *ScreenCapture) ToDesktop(r Rect) Rect {
+	scale := c.scale()
+	return Rect{
+		X:      c.Bounds.X + int(math.Round(float64(r.X)/scale)),
+		Y:      c.Bounds.Y + int(math.Round(float64(r.Y)/scale)),
+		Width:  int(math.Round(float64(r.Width) / scale)),
+		Height: int(math.Round(float64(r.Height) / scale)),
+	}
+}
+
+// PointToDesktop 截图的像素坐标点转换为虚拟桌面坐标
+func (c *ScreenCapture) PointToDesktop(p Point) Point {
+	scale := c.scale()
+	return Point{
+		X: c.Bounds.X + int(math.Round(float64(p.X)/scale)),
+		Y: c.Bounds.Y + int(math.Round(float64(p.Y)/scale)),
+	}
+}
+
+// PointToPixels 虚拟桌面坐标点转换为截图的像素坐标
+func (c *ScreenCapture) PointToPixels(p Point) Point {
+	scale := c.scale()
+	return Point{
+		X: int(math.Round(float64(p.X-c.Bounds.X) * scale)),
+		Y: int(math.Round(float64(p.Y-c.Bounds.Y) * scale)),
+	}
+}
+
+// scale 像素与桌面坐标的比例，未设置时视为1
+func (c *ScreenCapture) scale() float64 {
+	if c.ScaleFactor <= 0 {
+		return 1
+	}
+	return c.ScaleFactor
+}
+
+// Crop 截取截图中的一个区域（虚拟桌面坐标），返回新的PNG截图
+// 区域超出截图的部分会被裁掉，与截图不相交时返回invalid_argument
+func (c *ScreenCapture) Crop(region Rect) (*ScreenCapture, error) {
+	img, _, err := image.Decode(bytes.NewReader(c.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image data: %w", err)
+	}
+
+	pixels := c.ToPixels(region)
+	area := image.Rect(pixels.X, pixels.Y, pixels.X+pixels.Width, pixels.Y+pixels.Height).
+		Add(img.Bounds().Min).Intersect(img.Bounds())
+	if area.Empty() {
+		return nil, NewError(ErrInvalidArgument, fmt.Sprintf("region %+v outside screen capture %+v", region, c.Bounds), nil)
+	}
+
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, NewError(ErrUnsupported, "screen capture image cannot be cropped", nil)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sub.SubImage(area)); err != nil {
+		return nil, fmt.Errorf("encode cropped image: %w", err)
+	}
+
+	origin := area.Min.Sub(img.Bounds().Min)
+	bounds := c.ToDesktop(Rect{X: origin.X, Y: origin.Y, Width: area.Dx(), Height: area.Dy()})
+	return &ScreenCapture{
+		ImageData:    buf.Bytes(),
+		Format:       "png",
+		DisplayIndex: DisplayIndexVirtual,
+		Bounds:       bounds,
+		Width:        area.Dx(),
+		Height:       area.Dy(),
+		ScaleFactor:  c.scale(),
+		Size:         buf.Len(),
+	}, nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// 支持context的操作接口
+// 每个方法与无context版本一一对应，ctx被取消或超时后应尽快返回失败结果
+
+// ContextMouseOperator 支持取消的鼠标操作接口
+type ContextMouseOperator interface {
+	ClickContext(ctx context.Context, x, y int, button MouseButton) *OperationResult
+	DoubleClickContext(ctx context.Context, x, y int) *OperationResult
+	RightClickContext(ctx context.Context, x, y int) *OperationResult
+	DragContext(ctx context.Context, fromX, fromY, toX, toY int) *OperationResult
+	MoveContext(ctx context.Context, x, y int) *OperationResult
+	GetPositionContext(ctx context.Context) (*Point, *OperationResult)
+	ScrollContext(ctx context.Context, x, y int, direction string, clicks int) *OperationResult
+}
+
+// ContextKeyboardOperator 支持取消的键盘操作接口
+type ContextKeyboardOperator interface {
+	TypeContext(ctx context.Context, text string) *OperationResult
+	KeyPressContext(ctx context.Context, key string) *OperationResult
+	KeyDownContext(ctx context.Context, key string) *OperationResult
+	KeyUpContext(ctx context.Context, key string) *OperationResult
+	HotkeyContext(ctx context.Context, modifiers []KeyModifier, key string) *OperationResult
+}
+
+// ContextFileOperator 支持取消的文件操作接口
+type ContextFileOperator interface {
+	CreateFileContext(ctx context.Context, path string, content []byte) *OperationResult
+	CreateDirContext(ctx context.Context, path string) *OperationResult
+	MoveFileContext(ctx context.Context, src, dst string) *OperationResult
+	CopyFileContext(ctx context.Context, src, dst string) *OperationResult
+	DeleteFileContext(ctx context.Context, path string) *OperationResult
+	DeleteDirContext(ctx context.Context, path string) *OperationResult
+	RenameFileContext(ctx context.Context, oldPath, newPath string) *OperationResult
+	FileExistsContext(ctx context.Context, path string) (bool, *OperationResult)
+	GetFileInfoContext(ctx context.Context, path string) (interface{}, *OperationResult)
+	ListDirContext(ctx context.Context, path string) ([]string, *OperationResult)
+}
+
+// ContextScreenOperator 支持取消的屏幕操作接口
+type ContextScreenOperator interface {
+	ScreenshotContext(ctx context.Context) ([]byte, *OperationResult)
+	ScreenshotAreaContext(ctx context.Context, rect Rect) ([]byte, *OperationResult)
+	GetScreenSizeContext(ctx context.Context) (*Size, *OperationResult)
+	FindImageContext(ctx context.Context, templatePath string) (*Point, *OperationResult)
+	FindTextContext(ctx context.Context, text string) (*Point, *OperationResult)
+}
+
+// ContextSystemOperator 支持取消的系统操作接口
+type ContextSystemOperator interface {
+	GetClipboardContext(ctx context.Context) (string, *OperationResult)
+	SetClipboardContext(ctx context.Context, text string) *OperationResult
+	GetActiveWindowContext(ctx context.Context) (*WindowInfo, *OperationResult)
+	GetWindowsContext(ctx context.Context) ([]*WindowInfo, *OperationResult)
+	ActivateWindowContext(ctx context.Context, handle uintptr) *OperationResult
+	CloseWindowContext(ctx context.Context, handle uintptr) *OperationResult
+	MinimizeWindowContext(ctx context.Context, handle uintptr) *OperationResult
+	MaximizeWindowContext(ctx context.Context, handle uintptr) *OperationResult
+}
+
+// ContextAppLauncher 支持取消的应用程序启动接口
+type ContextAppLauncher interface {
+	LaunchContext(ctx context.Context, appName string) *OperationResult
+	LaunchWithPathContext(ctx context.Context, path string, args ...string) *OperationResult
+	LaunchAppContext(ctx context.Context, app *AppInfo) *OperationResult
+}
+
+// ContextAutomationEngine 支持取消的自动化引擎接口
+type ContextAutomationEngine interface {
+	AutomationEngine
+	ContextMouseOperator
+	ContextKeyboardOperator
+	ContextFileOperator
+	ContextAppLauncher
+	ContextScreenOperator
+	ContextSystemOperator
+
+	// WaitContext 等待指定毫秒数，ctx取消时立即返回
+	WaitContext(ctx context.Context, duration int) *OperationResult
+}
+
+// NewCancelledResult 创建操作被取消的结果
+func NewCancelledResult(ctx context.Context) *OperationResult {
+	return NewErrorResult("operation cancelled", context.Cause(ctx))
+}
+
+// CheckContext ctx已取消时返回取消结果，否则返回nil
+func CheckContext(ctx context.Context) *OperationResult {
+	if ctx.Err() != nil {
+		return NewCancelledResult(ctx)
+	}
+	return nil
+}
+
+// Sleep 可被ctx打断的等待
+func Sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import "math"
+
+// 坐标系说明：
+//   - 虚拟桌面坐标：鼠标、窗口和截图API共用的全局坐标，多个显示器拼接成一个平面，
+//     主显示器左上角为原点。Windows下进程开启了Per-Monitor DPI感知，单位是物理像素
+//   - 显示器逻辑坐标：相对某个显示器左上角、按该显示器缩放比例换算后的坐标，
+//     与用户在该显示器上看到的界面尺寸一致（150%缩放下1个逻辑单位等于1.5个物理像素）
+//   - 截图像素坐标：截图图像中的像素位置，通过ScreenCapture.ToDesktop/ToPixels与虚拟桌面坐标互相转换
+//
+// 所有点击、查找和窗口操作都使用虚拟桌面坐标；视觉模型返回的截图像素坐标必须先转换再使用。
+
+// Display 显示器信息
+type Display struct {
+	Index       int     `json:"index"`
+	Name        string  `json:"name,omitempty"`
+	Bounds      Rect    `json:"bounds"`       // 显示器在虚拟桌面中的范围
+	ScaleFactor float64 `json:"scale_factor"` // 显示缩放比例（DPI/96），150%缩放为1.5
+	Primary     bool    `json:"primary"`      // 是否为主显示器
+}
+
+// scale 缩放比例，未设置时视为1
+func (d Display) scale() float64 {
+	if d.ScaleFactor <= 0 {
+		return 1
+	}
+	return d.ScaleFactor
+}
+
+// DPI 显示器的有效DPI
+func (d Display) DPI() int {
+	return int(math.Round(96 * d.scale()))
+}
+
+// LogicalSize 显示器在逻辑坐标下的尺寸
+func (d Display) LogicalSize() Size {
+	return Size{
+		Width:  int(math.Round(float64(d.Bounds.Width) / d.scale())),
+		Height: int(math.Round(float64(d.Bounds.Height) / d.scale())),
+	}
+}
+
+// Contains 虚拟桌面坐标p是否在显示器范围内
+func (d Display) Contains(p Point) bool {
+	return p.X >= d.Bounds.X && p.X < d.Bounds.X+d.Bounds.Width &&
+		p.Y >= d.Bounds.Y && p.Y < d.Bounds.Y+d.Bounds.Height
+}
+
+// ToLogical 将虚拟桌面坐标转换为该显示器的逻辑坐标
+func (d Display) ToLogical(p Point) Point {
+	return Point{
+		X: int(math.Round(float64(p.X-d.Bounds.X) / d.scale())),
+		Y: int(math.Round(float64(p.Y-d.Bounds.Y) / d.scale())),
+	}
+}
+
+// ToGlobal 将该显示器的逻辑坐标转换为虚拟桌面坐标
+func (d Display) ToGlobal(p Point) Point {
+	return Point{
+		X: d.Bounds.X + int(math.Round(float64(p.X)*d.scale())),
+		Y: d.Bounds.Y + int(math.Round(float64(p.Y)*d.scale())),
+	}
+}
+
+// RectToGlobal 将该显示器逻辑坐标下的矩形转换为虚拟桌面坐标
+func (d Display) RectToGlobal(r Rect) Rect {
+	origin := d.ToGlobal(Point{X: r.X, Y: r.Y})
+	return Rect{
+		X:      origin.X,
+		Y:      origin.Y,
+		Width:  int(math.Round(float64(r.Width) * d.scale())),
+		Height: int(math.Round(float64(r.Height) * d.scale())),
+	}
+}
+
+// PrimaryDisplay 主显示器，没有标记主显示器时返回包含原点的显示器或第一个显示器
+func PrimaryDisplay(displays []Display) *Display {
+	for i := range displays {
+		if displays[i].Primary {
+			return &displays[i]
+		}
+	}
+	if display := DisplayAt(displays, Point{}); display != nil {
+		return display
+	}
+	return nil
+}
+
+// DisplayAt 包含虚拟桌面坐标p的显示器，p不在任何显示器上时返回距离最近的显示器
+func DisplayAt(displays []Display, p Point) *Display {
+	var nearest *Display
+	best := math.MaxFloat64
+	for i := range displays {
+		d := &displays[i]
+		if d.Contains(p) {
+			return d
+		}
+		dx := math.Max(math.Max(float64(d.Bounds.X-p.X), 0), float64(p.X-(d.Bounds.X+d.Bounds.Width-1)))
+		dy := math.Max(math.Max(float64(d.Bounds.Y-p.Y), 0), float64(p.Y-(d.Bounds.Y+d.Bounds.Height-1)))
+		if distance := dx*dx + dy*dy; distance < best {
+			best = distance
+			nearest = d
+		}
+	}
+	return nearest
+}
+
+// VirtualBounds 所有显示器组成的虚拟桌面范围
+func VirtualBounds(displays []Display) Rect {
+	if len(displays) == 0 {
+		return Rect{}
+	}
+	r := displays[0].Bounds
+	x1, y1 := r.X+r.Width, r.Y+r.Height
+	for _, d := range displays[1:] {
+		r.X, r.Y = min(r.X, d.Bounds.X), min(r.Y, d.Bounds.Y)
+		x1, y1 = max(x1, d.Bounds.X+d.Bounds.Width), max(y1, d.Bounds.Y+d.Bounds.Height)
+	}
+	r.Width, r.Height = x1-r.X, y1-r.Y
+	return r
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrorCode 结构化错误码
+// 重试策略和面向用户的提示都应根据错误码判断，而不是匹配错误文本
+type ErrorCode string
+
+const (
+	ErrUnknown           ErrorCode = "unknown"            // 未分类的错误
+	ErrPermissionDenied  ErrorCode = "permission_denied"  // 权限不足（系统权限、API密钥无效等）
+	ErrNotFound          ErrorCode = "not_found"          // 文件、窗口、应用、屏幕元素不存在
+	ErrUnsupported       ErrorCode = "unsupported"        // 当前平台或引擎不支持该操作
+	ErrInvalidArgument   ErrorCode = "invalid_argument"   // 参数错误
+	ErrTimeout           ErrorCode = "timeout"            // 操作超时
+	ErrCancelled         ErrorCode = "cancelled"          // 操作被取消
+	ErrNoEffect          ErrorCode = "no_effect"          // 操作执行了但屏幕没有可见的变化（如点击位置错误）
+	ErrEngineUnavailable ErrorCode = "engine_unavailable" // 自动化引擎或worker不可用
+	ErrNotConfigured     ErrorCode = "not_configured"     // 缺少必要配置（如模型地址、API密钥）
+	ErrNetwork           ErrorCode = "network"            // 网络连接失败
+	ErrLLMUnavailable    ErrorCode = "llm_unavailable"    // 模型服务不可用
+	ErrLLMRateLimited    ErrorCode = "llm_rate_limited"   // 模型服务限流
+	ErrLLMInvalidOutput  ErrorCode = "llm_invalid_output" // 模型返回内容无法解析或不符合要求
+)
+
+// Retryable 该类错误默认是否值得重试
+func (c ErrorCode) Retryable() bool {
+	switch c {
+	case ErrTimeout, ErrEngineUnavailable, ErrNetwork, ErrLLMUnavailable, ErrLLMRateLimited, ErrLLMInvalidOutput:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryDelay 第attempt次失败后重试前的等待时间
+// 按尝试次数线性退避，限流错误等待更久
+func RetryDelay(code ErrorCode, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := time.Duration(attempt) * 500 * time.Millisecond
+	if code == ErrLLMRateLimited {
+		delay *= 4
+	}
+	return delay
+}
+
+// Error 带错误码的错误
+// Cause保存原始错误，可以通过errors.Is/errors.As沿错误链查找
+type Error struct {
+	Code      ErrorCode
+	Message   string
+	Retryable bool
+	Cause     error
+}
+
+// NewError 创建带错误码的错误，是否可重试由错误码决定
+func NewError(code ErrorCode, message string, cause error) *Error {
+	return &Error{
+		Code:      code,
+		Message:   message,
+		Retryable: code.Retryable(),
+		Cause:     cause,
+	}
+}
+
+// Error 实现error接口
+func (e *Error) Error() string {
+	switch {
+	case e.Message == "" && e.Cause == nil:
+		return string(e.Code)
+	case e.Message == "":
+		return e.Cause.Error()
+	case e.Cause == nil:
+		return e.Message
+	default:
+		return e.Message + ": " + e.Cause.Error()
+	}
+}
+
+// Unwrap 返回原始错误
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is 错误码相同的*Error视为同一类错误，便于 errors.Is(err, &core.Error{Code: core.ErrNotFound})
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Message == "" && t.Cause == nil
+}
+
+// CodeOf 获取错误链上的错误码
+// 没有显式错误码时按标准库错误推断，无法推断时返回ErrUnknown
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrCancelled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrTimeout
+	case errors.Is(err, os.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, os.ErrPermission):
+		return ErrPermissionDenied
+	case errors.Is(err, errors.ErrUnsupported):
+		return ErrUnsupported
+	default:
+		return ErrUnknown
+	}
+}
+
+// IsRetryable 错误是否值得重试
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Retryable
+	}
+	return CodeOf(err).Retryable()
+}
+
+// Err 将失败结果还原为带错误码的错误，成功结果返回nil
+// 结果在进程内创建时保留原始错误链；经过JSON传输的结果只保留错误码和文本
+func (r *OperationResult) Err() error {
+	if r == nil || r.Success {
+		return nil
+	}
+
+	cause := r.cause
+	if cause == nil && r.Error != "" {
+		cause = errors.New(r.Error)
+	}
+	code := r.Code
+	if code == "" {
+		code = ErrUnknown
+	}
+	return &Error{
+		Code:      code,
+		Message:   r.Message,
+		Retryable: r.Retryable,
+		Cause:     cause,
+	}
+}
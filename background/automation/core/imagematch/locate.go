@@ -0,0 +1,83 @@
+package imagematch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+
+	"diandian/background/automation/core"
+)
+
+// LoadTemplate 读取模板图像文件（PNG/JPEG）
+func LoadTemplate(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, core.NewError(core.ErrNotFound, fmt.Sprintf("template %s not found", path), err)
+		}
+		return nil, fmt.Errorf("read template %s: %w", path, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, core.NewError(core.ErrInvalidArgument, fmt.Sprintf("template %s is not a valid image", path), err)
+	}
+	return img, nil
+}
+
+// FindInCapture 在截图中查找模板，Region和返回的匹配区域都使用虚拟桌面坐标
+// 模板按截图的像素尺寸匹配；模板与截图的DPI不同时应通过Scales指定缩放比例
+func FindInCapture(capture *core.ScreenCapture, template image.Image, options Options) ([]Match, error) {
+	if capture == nil || len(capture.ImageData) == 0 {
+		return nil, core.NewError(core.ErrInvalidArgument, "empty screen capture", nil)
+	}
+	img, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		return nil, core.NewError(core.ErrInvalidArgument, "screen capture is not a valid image", err)
+	}
+	return FindInImage(img, capture.Bounds, template, options), nil
+}
+
+// FindInImage 在覆盖虚拟桌面区域bounds的图像中查找模板，坐标约定与FindInCapture相同
+func FindInImage(img image.Image, bounds core.Rect, template image.Image, options Options) []Match {
+	capture := &core.ScreenCapture{Bounds: bounds, ScaleFactor: 1}
+	if bounds.Width > 0 {
+		capture.ScaleFactor = float64(img.Bounds().Dx()) / float64(bounds.Width)
+	}
+
+	if options.Region != nil {
+		region := capture.ToPixels(*options.Region)
+		options.Region = &region
+	}
+
+	matches := Find(img, template, options)
+	for i := range matches {
+		matches[i].Rect = capture.ToDesktop(matches[i].Rect)
+	}
+	return matches
+}
+
+// NewResult 根据匹配结果创建FindImage的操作结果，返回最佳匹配的中心点
+// 找到时Data包含最佳匹配的坐标、置信度以及全部匹配；未找到时返回not_found
+func NewResult(templatePath string, matches []Match, options Options) (*core.Point, *core.OperationResult) {
+	if len(matches) == 0 {
+		return nil, core.NewErrorResult("image not found",
+			core.NewError(core.ErrNotFound, fmt.Sprintf("%s not found on screen (threshold %.2f)", templatePath, options.Threshold), nil))
+	}
+
+	best := matches[0]
+	point := best.Center()
+	return &point, core.NewSuccessResult(
+		fmt.Sprintf("found %s at (%d, %d), confidence %.3f", templatePath, point.X, point.Y, best.Confidence),
+		map[string]interface{}{
+			"x":          point.X,
+			"y":          point.Y,
+			"rect":       best.Rect,
+			"confidence": best.Confidence,
+			"scale":      best.Scale,
+			"matches":    matches,
+		})
+}
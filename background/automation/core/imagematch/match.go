@@ -0,0 +1,333 @@
+// Package imagematch 提供纯Go的模板匹配：在截图中查找图标、按钮等模板图像
+//
+// 匹配使用灰度图上的归一化互相关（NCC），置信度为0-1之间的相关系数，
+// 对整体亮度和对比度变化不敏感。为了在全屏截图上保持可用的速度，
+// 先在缩小的图像金字塔上粗略搜索，再逐级回到原始分辨率精确定位。
+package imagematch
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"diandian/background/automation/core"
+)
+
+// Options 模板匹配选项
+type Options struct {
+	Threshold  float64    // 最低置信度，0-1
+	Scales     []float64  // 模板的缩放比例，用于截图与模板DPI不同的情况；为空时只按原尺寸匹配
+	Region     *core.Rect // 只在该区域内搜索，为nil时搜索整幅图像
+	MaxResults int        // 最多返回的匹配数，0表示不限制
+}
+
+// DefaultOptions 默认匹配选项：置信度0.9，只按原尺寸匹配，返回所有匹配
+func DefaultOptions() Options {
+	return Options{
+		Threshold: 0.9,
+		Scales:    []float64{1},
+	}
+}
+
+// DPIScales 常见的Windows显示缩放比例，模板在100%缩放下截取时可用于多尺度搜索
+var DPIScales = []float64{1, 1.25, 1.5, 1.75, 2}
+
+// Match 一个匹配结果
+type Match struct {
+	Rect       core.Rect `json:"rect"`       // 匹配区域
+	Confidence float64   `json:"confidence"` // 置信度，0-1
+	Scale      float64   `json:"scale"`      // 匹配时使用的模板缩放比例
+}
+
+// Center 匹配区域的中心点
+func (m Match) Center() core.Point {
+	return core.Point{X: m.Rect.X + m.Rect.Width/2, Y: m.Rect.Y + m.Rect.Height/2}
+}
+
+const (
+	minPyramidTemplate = 8    // 金字塔最粗一级模板的最小边长
+	maxPyramidLevels   = 4    // 金字塔最大层数
+	coarseMargin       = 0.25 // 粗搜索时置信度阈值的放宽量
+	maxCandidates      = 200  // 粗搜索保留的候选位置数量上限
+	refineRadius       = 2    // 逐级细化时的搜索半径（像素）
+	minTemplateSize    = 3    // 缩放后模板的最小边长
+	flatVariance       = 1e-6 // 方差小于该值的模板或窗口视为纯色
+)
+
+// Find 在img中查找template，返回按置信度从高到低排列的匹配（像素坐标）
+// 重叠的匹配只保留置信度最高的一个
+func Find(img, template image.Image, options Options) []Match {
+	screen := newPlane(img)
+	offset := image.Point{}
+	if options.Region != nil {
+		region := image.Rect(options.Region.X, options.Region.Y,
+			options.Region.X+options.Region.Width, options.Region.Y+options.Region.Height)
+		screen = screen.crop(region)
+		offset = region.Intersect(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())).Min
+	}
+
+	scales := options.Scales
+	if len(scales) == 0 {
+		scales = []float64{1}
+	}
+
+	base := newPlane(template)
+	var matches []Match
+	for _, scale := range scales {
+		if scale <= 0 {
+			continue
+		}
+		tpl := base
+		if scale != 1 {
+			tpl = base.resize(int(math.Round(float64(base.w)*scale)), int(math.Round(float64(base.h)*scale)))
+		}
+		if tpl.w < minTemplateSize || tpl.h < minTemplateSize || tpl.w > screen.w || tpl.h > screen.h {
+			continue
+		}
+
+		for _, p := range search(screen, tpl, options.Threshold) {
+			matches = append(matches, Match{
+				Rect:       core.Rect{X: p.x + offset.X, Y: p.y + offset.Y, Width: tpl.w, Height: tpl.h},
+				Confidence: p.score,
+				Scale:      scale,
+			})
+		}
+	}
+
+	matches = suppress(matches)
+	if options.MaxResults > 0 && len(matches) > options.MaxResults {
+		matches = matches[:options.MaxResults]
+	}
+	return matches
+}
+
+// FindBest 查找置信度最高的匹配
+func FindBest(img, template image.Image, options Options) (Match, bool) {
+	options.MaxResults = 1
+	matches := Find(img, template, options)
+	if len(matches) == 0 {
+		return Match{}, false
+	}
+	return matches[0], true
+}
+
+// position 候选位置及其置信度
+type position struct {
+	x, y  int
+	score float64
+}
+
+// search 在金字塔上由粗到细查找模板，返回置信度不低于threshold的位置
+func search(screen, tpl *plane, threshold float64) []position {
+	screens := []*plane{screen}
+	tpls := []*plane{tpl}
+	for len(tpls) <= maxPyramidLevels {
+		t, s := tpls[len(tpls)-1], screens[len(screens)-1]
+		if t.w/2 < minPyramidTemplate || t.h/2 < minPyramidTemplate {
+			break
+		}
+		tpls = append(tpls, t.half())
+		screens = append(screens, s.half())
+	}
+
+	// 最粗一级全图搜索，保留局部最大值作为候选
+	top := len(tpls) - 1
+	coarse := threshold
+	if top > 0 {
+		coarse = math.Max(0, threshold-coarseMargin)
+	}
+	candidates := scan(screens[top], tpls[top], coarse)
+
+	// 逐级回到原始分辨率，在候选位置附近精确定位
+	for level := top - 1; level >= 0; level-- {
+		s, t := screens[level], tpls[level]
+		matcher := newMatcher(s, t)
+		refined := candidates[:0]
+		for _, c := range candidates {
+			best := position{score: -1}
+			for y := c.y*2 - refineRadius; y <= c.y*2+refineRadius; y++ {
+				for x := c.x*2 - refineRadius; x <= c.x*2+refineRadius; x++ {
+					if x < 0 || y < 0 || x+t.w > s.w || y+t.h > s.h {
+						continue
+					}
+					if score := matcher.score(x, y); score > best.score {
+						best = position{x: x, y: y, score: score}
+					}
+				}
+			}
+			if best.score >= 0 {
+				refined = append(refined, best)
+			}
+		}
+		candidates = refined
+	}
+
+	var result []position
+	for _, c := range candidates {
+		if c.score >= threshold {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// scan 计算所有位置的置信度，返回不低于threshold的局部最大值（最多maxCandidates个）
+func scan(screen, tpl *plane, threshold float64) []position {
+	w, h := screen.w-tpl.w+1, screen.h-tpl.h+1
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+
+	matcher := newMatcher(screen, tpl)
+	scores := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			scores[y*w+x] = matcher.score(x, y)
+		}
+	}
+
+	var candidates []position
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			score := scores[y*w+x]
+			if score < threshold || !localMaximum(scores, w, h, x, y) {
+				continue
+			}
+			candidates = append(candidates, position{x: x, y: y, score: score})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	return candidates
+}
+
+// localMaximum 位置的置信度是否不低于3x3邻域内的其他位置
+func localMaximum(scores []float64, w, h, x, y int) bool {
+	score := scores[y*w+x]
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			nx, ny := x+dx, y+dy
+			if (dx == 0 && dy == 0) || nx < 0 || ny < 0 || nx >= w || ny >= h {
+				continue
+			}
+			if scores[ny*w+nx] > score {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// suppress 按置信度排序并去掉与更高置信度匹配重叠的结果
+func suppress(matches []Match) []Match {
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Confidence > matches[j].Confidence })
+
+	var kept []Match
+	for _, m := range matches {
+		overlapping := false
+		for _, k := range kept {
+			if overlap(m.Rect, k.Rect) > 0.3 {
+				overlapping = true
+				break
+			}
+		}
+		if !overlapping {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// overlap 两个矩形的交集占较小矩形面积的比例
+func overlap(a, b core.Rect) float64 {
+	x0, y0 := max(a.X, b.X), max(a.Y, b.Y)
+	x1, y1 := min(a.X+a.Width, b.X+b.Width), min(a.Y+a.Height, b.Y+b.Height)
+	if x0 >= x1 || y0 >= y1 {
+		return 0
+	}
+	smaller := min(a.Width*a.Height, b.Width*b.Height)
+	return float64((x1-x0)*(y1-y0)) / float64(smaller)
+}
+
+// matcher 在一幅图像上计算某个模板的NCC
+// 窗口的均值和方差通过积分图在常数时间内得到
+type matcher struct {
+	screen *plane
+	tpl    *plane
+
+	sum, sumSq []float64 // 积分图，尺寸为(w+1)*(h+1)
+
+	centered []float64 // 去均值后的模板
+	tplMean  float64
+	tplNorm  float64 // 去均值模板的L2范数
+}
+
+// newMatcher 为screen和tpl创建匹配器
+func newMatcher(screen, tpl *plane) *matcher {
+	m := &matcher{screen: screen, tpl: tpl}
+
+	stride := screen.w + 1
+	m.sum = make([]float64, stride*(screen.h+1))
+	m.sumSq = make([]float64, stride*(screen.h+1))
+	for y := 0; y < screen.h; y++ {
+		var rowSum, rowSumSq float64
+		for x := 0; x < screen.w; x++ {
+			v := screen.pix[y*screen.w+x]
+			rowSum += v
+			rowSumSq += v * v
+			m.sum[(y+1)*stride+x+1] = m.sum[y*stride+x+1] + rowSum
+			m.sumSq[(y+1)*stride+x+1] = m.sumSq[y*stride+x+1] + rowSumSq
+		}
+	}
+
+	for _, v := range tpl.pix {
+		m.tplMean += v
+	}
+	m.tplMean /= float64(len(tpl.pix))
+	m.centered = make([]float64, len(tpl.pix))
+	for i, v := range tpl.pix {
+		m.centered[i] = v - m.tplMean
+		m.tplNorm += m.centered[i] * m.centered[i]
+	}
+	m.tplNorm = math.Sqrt(m.tplNorm)
+	return m
+}
+
+// window 窗口像素的和与平方和
+func (m *matcher) window(x, y int) (sum, sumSq float64) {
+	stride := m.screen.w + 1
+	a, b := y*stride+x, y*stride+x+m.tpl.w
+	c, d := (y+m.tpl.h)*stride+x, (y+m.tpl.h)*stride+x+m.tpl.w
+	return m.sum[d] - m.sum[b] - m.sum[c] + m.sum[a], m.sumSq[d] - m.sumSq[b] - m.sumSq[c] + m.sumSq[a]
+}
+
+// score 模板左上角位于(x, y)时的置信度，负相关视为0
+func (m *matcher) score(x, y int) float64 {
+	n := float64(len(m.tpl.pix))
+	sum, sumSq := m.window(x, y)
+	variance := sumSq - sum*sum/n
+
+	// 纯色模板无法计算相关系数，改为比较窗口是否为同一颜色
+	if m.tplNorm*m.tplNorm/n < flatVariance {
+		if variance/n > flatVariance {
+			return 0
+		}
+		return math.Max(0, 1-math.Abs(sum/n-m.tplMean))
+	}
+	if variance/n < flatVariance {
+		return 0
+	}
+
+	var dot float64
+	for ty := 0; ty < m.tpl.h; ty++ {
+		row := (y+ty)*m.screen.w + x
+		trow := ty * m.tpl.w
+		for tx := 0; tx < m.tpl.w; tx++ {
+			dot += m.screen.pix[row+tx] * m.centered[trow+tx]
+		}
+	}
+	return math.Max(0, math.Min(1, dot/(math.Sqrt(variance)*m.tplNorm)))
+}
@@ -0,0 +1,94 @@
+package imagematch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"diandian/background/automation/core"
+)
+
+// testScreen 生成由随机色块组成的图像，色块足够大使金字塔缩小后仍保留结构
+func testScreen(w, h int) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y += 4 {
+		for x := 0; x < w; x += 4 {
+			c := color.RGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 255}
+			draw.Draw(img, image.Rect(x, y, x+4, y+4), &image.Uniform{C: c}, image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+// crop 复制图像的一部分作为模板
+func crop(img *image.RGBA, rect image.Rectangle) *image.RGBA {
+	tpl := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(tpl, tpl.Bounds(), img, rect.Min, draw.Src)
+	return tpl
+}
+
+func TestFindExactMatch(t *testing.T) {
+	screen := testScreen(320, 240)
+	template := crop(screen, image.Rect(132, 88, 172, 120))
+
+	match, ok := FindBest(screen, template, DefaultOptions())
+	if !ok {
+		t.Fatal("没有找到模板")
+	}
+	want := core.Rect{X: 132, Y: 88, Width: 40, Height: 32}
+	if match.Rect != want {
+		t.Errorf("匹配区域 = %+v，期望 %+v", match.Rect, want)
+	}
+	if match.Confidence < 0.99 {
+		t.Errorf("置信度 = %.3f，期望接近1", match.Confidence)
+	}
+	if center := match.Center(); center != (core.Point{X: 152, Y: 104}) {
+		t.Errorf("中心点 = %+v", center)
+	}
+}
+
+func TestFindRespectsRegion(t *testing.T) {
+	screen := testScreen(320, 240)
+	template := crop(screen, image.Rect(200, 160, 240, 192))
+
+	options := DefaultOptions()
+	options.Region = &core.Rect{X: 0, Y: 0, Width: 160, Height: 120}
+	if matches := Find(screen, template, options); len(matches) != 0 {
+		t.Errorf("区域外的模板不应匹配，得到 %+v", matches)
+	}
+
+	options.Region = &core.Rect{X: 180, Y: 140, Width: 100, Height: 80}
+	match, ok := FindBest(screen, template, options)
+	if !ok || match.Rect.X != 200 || match.Rect.Y != 160 {
+		t.Errorf("区域内的匹配 = %+v %v，期望位于(200, 160)", match.Rect, ok)
+	}
+}
+
+func TestFindFlatTemplate(t *testing.T) {
+	screen := testScreen(320, 240)
+	template := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(template, template.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+
+	if matches := Find(screen, template, DefaultOptions()); len(matches) != 0 {
+		t.Errorf("纯色模板不应匹配随机图像，得到 %d 个匹配", len(matches))
+	}
+}
+
+func TestFindInImageDesktopCoordinates(t *testing.T) {
+	// 截图覆盖副显示器(1920, 0)，像素尺寸是逻辑尺寸的2倍
+	screen := testScreen(320, 240)
+	template := crop(screen, image.Rect(100, 60, 140, 100))
+	bounds := core.Rect{X: 1920, Y: 0, Width: 160, Height: 120}
+
+	matches := FindInImage(screen, bounds, template, DefaultOptions())
+	if len(matches) == 0 {
+		t.Fatal("没有找到模板")
+	}
+	want := core.Rect{X: 1970, Y: 30, Width: 20, Height: 20}
+	if matches[0].Rect != want {
+		t.Errorf("桌面坐标 = %+v，期望 %+v", matches[0].Rect, want)
+	}
+}
@@ -0,0 +1,96 @@
+package imagematch
+
+import (
+	"image"
+	"math"
+)
+
+// plane 灰度图像，像素取值0-1
+type plane struct {
+	w, h int
+	pix  []float64
+}
+
+// newPlane 将图像转换为灰度
+func newPlane(img image.Image) *plane {
+	bounds := img.Bounds()
+	p := &plane{w: bounds.Dx(), h: bounds.Dy()}
+	p.pix = make([]float64, p.w*p.h)
+
+	// 截图通常是RGBA，直接读取像素避免逐点接口调用
+	if rgba, ok := img.(*image.RGBA); ok {
+		for y := 0; y < p.h; y++ {
+			row := rgba.Pix[(y+bounds.Min.Y-rgba.Rect.Min.Y)*rgba.Stride+(bounds.Min.X-rgba.Rect.Min.X)*4:]
+			for x := 0; x < p.w; x++ {
+				r, g, b := row[x*4], row[x*4+1], row[x*4+2]
+				p.pix[y*p.w+x] = luminance(float64(r), float64(g), float64(b)) / 255
+			}
+		}
+		return p
+	}
+
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			p.pix[y*p.w+x] = luminance(float64(r), float64(g), float64(b)) / 0xffff
+		}
+	}
+	return p
+}
+
+// luminance ITU-R BT.601 亮度
+func luminance(r, g, b float64) float64 {
+	return 0.299*r + 0.587*g + 0.114*b
+}
+
+// crop 截取区域，区域超出图像的部分会被裁掉
+func (p *plane) crop(r image.Rectangle) *plane {
+	r = r.Intersect(image.Rect(0, 0, p.w, p.h))
+	c := &plane{w: r.Dx(), h: r.Dy()}
+	c.pix = make([]float64, c.w*c.h)
+	for y := 0; y < c.h; y++ {
+		copy(c.pix[y*c.w:(y+1)*c.w], p.pix[(r.Min.Y+y)*p.w+r.Min.X:])
+	}
+	return c
+}
+
+// half 按2x2取平均缩小一半
+func (p *plane) half() *plane {
+	h := &plane{w: p.w / 2, h: p.h / 2}
+	h.pix = make([]float64, h.w*h.h)
+	for y := 0; y < h.h; y++ {
+		for x := 0; x < h.w; x++ {
+			i := 2*y*p.w + 2*x
+			h.pix[y*h.w+x] = (p.pix[i] + p.pix[i+1] + p.pix[i+p.w] + p.pix[i+p.w+1]) / 4
+		}
+	}
+	return h
+}
+
+// resize 双线性插值缩放到指定尺寸
+func (p *plane) resize(w, h int) *plane {
+	r := &plane{w: w, h: h}
+	if w <= 0 || h <= 0 {
+		return r
+	}
+	r.pix = make([]float64, w*h)
+
+	sx, sy := float64(p.w)/float64(w), float64(p.h)/float64(h)
+	for y := 0; y < h; y++ {
+		fy := math.Max(0, (float64(y)+0.5)*sy-0.5)
+		y0 := min(int(fy), p.h-1)
+		y1 := min(y0+1, p.h-1)
+		wy := fy - float64(y0)
+		for x := 0; x < w; x++ {
+			fx := math.Max(0, (float64(x)+0.5)*sx-0.5)
+			x0 := min(int(fx), p.w-1)
+			x1 := min(x0+1, p.w-1)
+			wx := fx - float64(x0)
+
+			top := p.pix[y0*p.w+x0]*(1-wx) + p.pix[y0*p.w+x1]*wx
+			bottom := p.pix[y1*p.w+x0]*(1-wx) + p.pix[y1*p.w+x1]*wx
+			r.pix[y*w+x] = top*(1-wy) + bottom*wy
+		}
+	}
+	return r
+}
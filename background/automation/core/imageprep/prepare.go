@@ -0,0 +1,227 @@
+// Package imageprep 截图发送给视觉模型前的预处理：按最长边缩小并重新编码
+// 预处理后的截图仍然是core.ScreenCapture，Bounds保持不变而图像尺寸变小，
+// 模型返回的坐标直接用ToDesktop即可还原为虚拟桌面坐标
+package imageprep
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+
+	"diandian/background/automation/core"
+)
+
+// 支持的输出格式
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+)
+
+// Options 预处理选项
+type Options struct {
+	MaxEdge int    // 图像最长边的像素上限，<=0表示不缩放
+	Format  string // 输出格式：png / jpeg / webp
+	Quality int    // 有损格式的质量（1-100），PNG忽略
+}
+
+// DefaultOptions 默认选项：最长边1568像素、质量80的JPEG
+// 主流视觉模型会把更大的图像在服务端缩小，提前缩小既不损失精度又能大幅减少上传和推理时间
+func DefaultOptions() Options {
+	return Options{
+		MaxEdge: 1568,
+		Format:  FormatJPEG,
+		Quality: 80,
+	}
+}
+
+// Encoder 图像编码器
+type Encoder func(w io.Writer, img image.Image, quality int) error
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		FormatPNG: func(w io.Writer, img image.Image, quality int) error {
+			return png.Encode(w, img)
+		},
+		FormatJPEG: func(w io.Writer, img image.Image, quality int) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+		},
+	}
+)
+
+// RegisterEncoder 注册图像编码器
+// 标准库没有WebP编码器，需要WebP时由引入了编码库的一方注册，未注册时退回JPEG
+func RegisterEncoder(format string, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[normalizeFormat(format)] = encoder
+}
+
+// encoderFor 查找编码器，返回实际使用的格式
+func encoderFor(format string) (string, Encoder) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	if encoder, ok := encoders[format]; ok {
+		return format, encoder
+	}
+	return FormatJPEG, encoders[FormatJPEG]
+}
+
+// normalizeFormat 统一格式名称，如"jpg"、"JPEG"都视为jpeg，为空时使用jpeg
+func normalizeFormat(format string) string {
+	switch f := strings.ToLower(strings.TrimSpace(format)); f {
+	case "", "jpg":
+		return FormatJPEG
+	default:
+		return f
+	}
+}
+
+// Prepare 按选项缩小并编码截图
+// 返回的截图Bounds与原截图相同，Width/Height/ScaleFactor反映缩放后的尺寸，
+// 因此其ToDesktop/ToPixels可以在模型坐标与虚拟桌面坐标之间精确转换。
+// 尺寸不超过上限且格式相同时直接返回原截图
+func Prepare(capture *core.ScreenCapture, options Options) (*core.ScreenCapture, error) {
+	if capture == nil || len(capture.ImageData) == 0 {
+		return nil, core.NewError(core.ErrInvalidArgument, "empty screen capture", nil)
+	}
+	if options.Quality <= 0 || options.Quality > 100 {
+		options.Quality = DefaultOptions().Quality
+	}
+	format, encode := encoderFor(normalizeFormat(options.Format))
+
+	width, height := fit(capture.Width, capture.Height, options.MaxEdge)
+	if width == capture.Width && height == capture.Height && capture.Format != "" && normalizeFormat(capture.Format) == format {
+		return capture, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image data: %w", err)
+	}
+	if b := img.Bounds(); b.Dx() != width || b.Dy() != height {
+		img = Resize(img, width, height)
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, img, options.Quality); err != nil {
+		return nil, fmt.Errorf("encode %s: %w", format, err)
+	}
+
+	prepared := *capture
+	prepared.ImageData = buf.Bytes()
+	prepared.Format = format
+	prepared.Width = width
+	prepared.Height = height
+	prepared.Size = buf.Len()
+	if capture.Bounds.Width > 0 {
+		prepared.ScaleFactor = float64(width) / float64(capture.Bounds.Width)
+	}
+	return &prepared, nil
+}
+
+// PrepareImage 预处理编码后的图像，图像像素坐标即为返回截图的桌面坐标
+// 用于只有图像数据的调用方：模型坐标经ToDesktop转换后得到原图中的像素坐标
+func PrepareImage(imageData []byte, options Options) (*core.ScreenCapture, error) {
+	capture, err := core.NewScreenCapture(imageData, core.DisplayIndexVirtual, core.Rect{})
+	if err != nil {
+		return nil, err
+	}
+	return Prepare(capture, options)
+}
+
+// fit 计算按最长边等比缩小后的尺寸，只缩小不放大
+func fit(width, height, maxEdge int) (int, int) {
+	if maxEdge <= 0 || (width <= maxEdge && height <= maxEdge) {
+		return width, height
+	}
+	if width >= height {
+		return maxEdge, max(1, (height*maxEdge+width/2)/width)
+	}
+	return max(1, (width*maxEdge+height/2)/height), maxEdge
+}
+
+// Zoom 将截图等比缩放到最长边为edge像素并编码为PNG，用于放大局部截图供模型精确定位
+// 与Prepare一样保持Bounds不变，返回截图的ToDesktop可以把放大图中的坐标还原为虚拟桌面坐标
+func Zoom(capture *core.ScreenCapture, edge int) (*core.ScreenCapture, error) {
+	if capture == nil || len(capture.ImageData) == 0 {
+		return nil, core.NewError(core.ErrInvalidArgument, "empty screen capture", nil)
+	}
+	if edge <= 0 || capture.Width <= 0 || capture.Height <= 0 {
+		return nil, core.NewError(core.ErrInvalidArgument, "invalid zoom size", nil)
+	}
+	width, height := edge, max(1, (capture.Height*edge+capture.Width/2)/capture.Width)
+	if capture.Height > capture.Width {
+		width, height = max(1, (capture.Width*edge+capture.Height/2)/capture.Height), edge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image data: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, Resize(img, width, height)); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+
+	zoomed := *capture
+	zoomed.ImageData = buf.Bytes()
+	zoomed.Format = FormatPNG
+	zoomed.Width = width
+	zoomed.Height = height
+	zoomed.Size = buf.Len()
+	if capture.Bounds.Width > 0 {
+		zoomed.ScaleFactor = float64(width) / float64(capture.Bounds.Width)
+	}
+	return &zoomed, nil
+}
+
+// Resize 使用区域平均缩放图像
+// 缩小时每个目标像素取其覆盖的源像素的平均值，细小文字和线条比最近邻采样清晰；
+// 放大时每个目标像素对应一个源像素（最近邻），保留像素边界便于模型定位
+func Resize(img image.Image, width, height int) *image.RGBA {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		b := img.Bounds()
+		src = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for dy := 0; dy < height; dy++ {
+		y0 := dy * sh / height
+		y1 := max((dy+1)*sh/height, y0+1)
+		for dx := 0; dx < width; dx++ {
+			x0 := dx * sw / width
+			x1 := max((dx+1)*sw/width, x0+1)
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				i := src.PixOffset(sb.Min.X+x0, sb.Min.Y+y)
+				for x := x0; x < x1; x++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			j := dst.PixOffset(dx, dy)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
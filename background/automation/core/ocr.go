@@ -0,0 +1,35 @@
+package core
+
+import "context"
+
+// TextLevel 识别出的文本块的粒度
+type TextLevel string
+
+const (
+	TextLevelWord TextLevel = "word" // 单词（中文通常为单字或词组）
+	TextLevelLine TextLevel = "line" // 整行文本
+)
+
+// TextBox 识别出的一段文本及其位置
+type TextBox struct {
+	Text       string    `json:"text"`
+	Rect       Rect      `json:"rect"`       // 虚拟桌面坐标
+	Confidence float64   `json:"confidence"` // 识别置信度，0-1
+	Level      TextLevel `json:"level"`
+}
+
+// OCROptions 文字识别选项
+type OCROptions struct {
+	Languages []string // 语言提示，如"zh"、"en"；为空时由实现决定（通常为中英文）
+	Region    *Rect    // 只识别该区域（虚拟桌面坐标），为nil时识别整幅截图
+}
+
+// OCRProvider 文字识别后端
+// 返回的文本块坐标必须已转换为虚拟桌面坐标，可以同时包含单词和整行两种粒度
+type OCRProvider interface {
+	// Name 后端名称
+	Name() string
+
+	// Recognize 识别截图中的文本
+	Recognize(ctx context.Context, capture *ScreenCapture, options OCROptions) ([]TextBox, error)
+}
@@ -0,0 +1,265 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"unicode"
+
+	"diandian/background/automation/core"
+)
+
+// FindOptions 查找文本的选项
+type FindOptions struct {
+	core.OCROptions
+	Threshold  float64 // 最低相似度，0-1；1表示只接受包含查询文本的文本块
+	MaxResults int     // 最多返回的匹配数，0表示不限制
+}
+
+// DefaultFindOptions 默认查找选项：相似度0.75，四个字符以上的文本允许一个字符识别错误
+func DefaultFindOptions() FindOptions {
+	return FindOptions{Threshold: 0.75}
+}
+
+// Match 一个文本匹配结果
+type Match struct {
+	Box   core.TextBox `json:"box"`   // 匹配到的文本块
+	Rect  core.Rect    `json:"rect"`  // 查询文本在文本块中所占的区域（按字符宽度估算）
+	Score float64      `json:"score"` // 相似度，0-1
+}
+
+// Center 匹配区域的中心点，即点击位置
+func (m Match) Center() core.Point {
+	return core.Point{X: m.Rect.X + m.Rect.Width/2, Y: m.Rect.Y + m.Rect.Height/2}
+}
+
+// Locate 识别截图中的文本并查找query，返回最佳匹配的中心点
+func Locate(ctx context.Context, provider core.OCRProvider, capture *core.ScreenCapture, query string, options FindOptions) (*core.Point, *core.OperationResult) {
+	if provider == nil {
+		return nil, core.NewErrorResult("find text failed", core.NewError(core.ErrNotConfigured, "no OCR provider configured", nil))
+	}
+	boxes, err := provider.Recognize(ctx, capture, options.OCROptions)
+	if err != nil {
+		return nil, core.NewErrorResult(fmt.Sprintf("%s text recognition failed", provider.Name()), err)
+	}
+
+	point, result := NewResult(query, Find(boxes, query, options), options)
+	if data, ok := result.Data.(map[string]interface{}); ok {
+		data["provider"] = provider.Name()
+	}
+	return point, result
+}
+
+// NewResult 根据匹配结果创建FindText的操作结果
+// 找到时Data包含最佳匹配的点击坐标、文本、相似度以及全部匹配；未找到时返回not_found
+func NewResult(query string, matches []Match, options FindOptions) (*core.Point, *core.OperationResult) {
+	if len(matches) == 0 {
+		return nil, core.NewErrorResult("text not found",
+			core.NewError(core.ErrNotFound, fmt.Sprintf("%q not found on screen (threshold %.2f)", query, options.Threshold), nil))
+	}
+
+	best := matches[0]
+	point := best.Center()
+	return &point, core.NewSuccessResult(
+		fmt.Sprintf("found %q at (%d, %d) in %q, score %.2f", query, point.X, point.Y, best.Box.Text, best.Score),
+		map[string]interface{}{
+			"x":          point.X,
+			"y":          point.Y,
+			"text":       best.Box.Text,
+			"rect":       best.Rect,
+			"score":      best.Score,
+			"confidence": best.Box.Confidence,
+			"matches":    matches,
+		})
+}
+
+// Find 在识别出的文本块中查找query，按匹配程度从高到低排列
+// 比较前忽略大小写、空白、标点和全角半角差异；未完整包含query的文本块按编辑距离计算相似度
+func Find(boxes []core.TextBox, query string, options FindOptions) []Match {
+	strip := true
+	q := normalize(query, strip)
+	if len(q.runes) == 0 {
+		// 查询只包含标点时按原样比较
+		strip = false
+		q = normalize(query, strip)
+	}
+	if len(q.runes) == 0 {
+		return nil
+	}
+
+	type candidate struct {
+		Match
+		extra int // 文本块中查询以外的字符数，越少越接近完整标签
+	}
+	var candidates []candidate
+	for _, box := range boxes {
+		t := normalize(box.Text, strip)
+		score, start, end := similarity(q.runes, t.runes)
+		if score < options.Threshold || end <= start {
+			continue
+		}
+		rect := subRect(box, t.index[start], t.index[end-1]+1)
+		candidates = append(candidates, candidate{
+			Match: Match{Box: box, Rect: rect, Score: score},
+			extra: len(t.runes) - (end - start),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		switch {
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		case a.extra != b.extra:
+			return a.extra < b.extra
+		case a.Box.Level != b.Box.Level:
+			return a.Box.Level == core.TextLevelWord
+		default:
+			return a.Box.Confidence > b.Box.Confidence
+		}
+	})
+
+	// 单词和它所在的整行会匹配到同一位置，只保留排在前面的一个
+	var matches []Match
+	for _, c := range candidates {
+		duplicate := false
+		for _, m := range matches {
+			if overlap(c.Rect, m.Rect) > 0.5 {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			matches = append(matches, c.Match)
+		}
+	}
+	if options.MaxResults > 0 && len(matches) > options.MaxResults {
+		matches = matches[:options.MaxResults]
+	}
+	return matches
+}
+
+// normalized 规范化后的文本，index记录每个字符在原文本中的位置
+type normalized struct {
+	runes []rune
+	index []int
+}
+
+// normalize 转为小写半角并去掉空白；strip为true时同时去掉标点和符号
+func normalize(s string, strip bool) normalized {
+	var n normalized
+	for i, r := range []rune(s) {
+		switch {
+		case r == 0x3000:
+			r = ' '
+		case r >= 0xFF01 && r <= 0xFF5E:
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) || (strip && (unicode.IsPunct(r) || unicode.IsSymbol(r))) {
+			continue
+		}
+		n.runes = append(n.runes, unicode.ToLower(r))
+		n.index = append(n.index, i)
+	}
+	return n
+}
+
+// similarity 计算query与text的相似度，返回text中最相近片段的范围[start, end)
+// text包含query时相似度为1；否则在长度相近的所有片段中取编辑距离最小的一个
+func similarity(query, text []rune) (score float64, start, end int) {
+	if i := indexRunes(text, query); i >= 0 {
+		return 1, i, i + len(query)
+	}
+
+	for length := len(query) - 1; length <= len(query)+1; length++ {
+		if length < 1 || length > len(text) {
+			continue
+		}
+		for i := 0; i+length <= len(text); i++ {
+			distance := levenshtein(query, text[i:i+length])
+			s := 1 - float64(distance)/float64(max(len(query), length))
+			if s > score {
+				score, start, end = s, i, i+length
+			}
+		}
+	}
+	if score == 0 && len(text) > 0 && len(text) < len(query)-1 {
+		distance := levenshtein(query, text)
+		score, start, end = 1-float64(distance)/float64(len(query)), 0, len(text)
+	}
+	return score, start, end
+}
+
+// indexRunes text中第一次出现sub的位置，不存在时返回-1
+func indexRunes(text, sub []rune) int {
+	for i := 0; i+len(sub) <= len(text); i++ {
+		if string(text[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// levenshtein 按字符计算的编辑距离
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// subRect 估算文本块中第start到end个字符（原文本的字符位置）所占的区域
+// 按全角字符占两个半角字符宽度计算
+func subRect(box core.TextBox, start, end int) core.Rect {
+	runes := []rune(box.Text)
+	if start <= 0 && end >= len(runes) {
+		return box.Rect
+	}
+
+	var total, before, within int
+	for i, r := range runes {
+		w := 1
+		if isCJK(r) {
+			w = 2
+		}
+		total += w
+		switch {
+		case i < start:
+			before += w
+		case i < end:
+			within += w
+		}
+	}
+
+	rect := box.Rect
+	rect.X += rect.Width * before / total
+	rect.Width = max(1, rect.Width*within/total)
+	return rect
+}
+
+// overlap 两个矩形的交集占较小矩形面积的比例
+func overlap(a, b core.Rect) float64 {
+	x0, y0 := max(a.X, b.X), max(a.Y, b.Y)
+	x1, y1 := min(a.X+a.Width, b.X+b.Width), min(a.Y+a.Height, b.Y+b.Height)
+	if x0 >= x1 || y0 >= y1 {
+		return 0
+	}
+	smaller := min(a.Width*a.Height, b.Width*b.Height)
+	if smaller <= 0 {
+		return 0
+	}
+	return float64((x1-x0)*(y1-y0)) / float64(smaller)
+}
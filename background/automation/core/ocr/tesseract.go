@@ -0,0 +1,258 @@
+// Package ocr 提供core.OCRProvider的本地实现以及识别结果的文本匹配
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"diandian/background/automation/core"
+)
+
+// TesseractConfig tesseract后端配置
+type TesseractConfig struct {
+	Path      string        // tesseract可执行文件，为空时从PATH查找
+	Languages []string      // 未提供语言提示时使用的语言
+	PSM       int           // 页面分割模式，界面截图中的文字较分散，默认11（稀疏文本）
+	Timeout   time.Duration // 单次识别超时
+}
+
+// DefaultTesseractConfig 默认配置：中英文识别，稀疏文本模式，30秒超时
+func DefaultTesseractConfig() TesseractConfig {
+	return TesseractConfig{
+		Path:      "tesseract",
+		Languages: []string{"zh", "en"},
+		PSM:       11,
+		Timeout:   30 * time.Second,
+	}
+}
+
+// Tesseract 通过tesseract子进程识别文字
+type Tesseract struct {
+	config TesseractConfig
+}
+
+// NewTesseract 创建tesseract后端
+func NewTesseract(config TesseractConfig) *Tesseract {
+	if config.Path == "" {
+		config.Path = "tesseract"
+	}
+	return &Tesseract{config: config}
+}
+
+// Name 后端名称
+func (t *Tesseract) Name() string {
+	return "tesseract"
+}
+
+// Available tesseract可执行文件是否存在
+func (t *Tesseract) Available() bool {
+	_, err := exec.LookPath(t.config.Path)
+	return err == nil
+}
+
+// tesseractLanguages 语言提示与tesseract语言包名称的对应关系
+var tesseractLanguages = map[string]string{
+	"zh":      "chi_sim",
+	"zh-cn":   "chi_sim",
+	"zh-hans": "chi_sim",
+	"zh-tw":   "chi_tra",
+	"zh-hant": "chi_tra",
+	"en":      "eng",
+	"ja":      "jpn",
+	"ko":      "kor",
+}
+
+// languageArg 将语言提示转换为tesseract的-l参数，未知语言原样传递
+func languageArg(languages []string) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, language := range languages {
+		name, ok := tesseractLanguages[strings.ToLower(language)]
+		if !ok {
+			name = language
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// Recognize 识别截图中的文本，返回单词和整行两种粒度的文本块
+func (t *Tesseract) Recognize(ctx context.Context, capture *core.ScreenCapture, options core.OCROptions) ([]core.TextBox, error) {
+	if capture == nil || len(capture.ImageData) == 0 {
+		return nil, core.NewError(core.ErrInvalidArgument, "empty screen capture", nil)
+	}
+	if options.Region != nil {
+		cropped, err := capture.Crop(*options.Region)
+		if err != nil {
+			return nil, err
+		}
+		capture = cropped
+	}
+
+	languages := options.Languages
+	if len(languages) == 0 {
+		languages = t.config.Languages
+	}
+	args := []string{"stdin", "stdout"}
+	if lang := languageArg(languages); lang != "" {
+		args = append(args, "-l", lang)
+	}
+	if t.config.PSM > 0 {
+		args = append(args, "--psm", strconv.Itoa(t.config.PSM))
+	}
+	args = append(args, "tsv")
+
+	if t.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.config.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.config.Path, args...)
+	cmd.Stdin = bytes.NewReader(capture.ImageData)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, tesseractError(ctx, err, stderr.String())
+	}
+
+	return parseTSV(stdout.Bytes(), capture)
+}
+
+// tesseractError 为tesseract运行失败补充错误码
+func tesseractError(ctx context.Context, err error, stderr string) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("tesseract: %w", ctxErr)
+	}
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return core.NewError(core.ErrEngineUnavailable, "tesseract not installed", err)
+	}
+	if strings.Contains(stderr, "Failed loading language") {
+		return core.NewError(core.ErrNotConfigured, "tesseract language data missing", errors.New(strings.TrimSpace(stderr)))
+	}
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		err = fmt.Errorf("%w: %s", err, stderr)
+	}
+	return fmt.Errorf("tesseract failed: %w", err)
+}
+
+// tsvLine 同一行单词的聚合
+type tsvLine struct {
+	words      []core.TextBox
+	confidence float64
+}
+
+// parseTSV 解析tesseract的TSV输出
+// 列依次为 level page_num block_num par_num line_num word_num left top width height conf text，
+// 只读取单词（level 5），整行文本由同一行的单词合并得到
+func parseTSV(data []byte, capture *core.ScreenCapture) ([]core.TextBox, error) {
+	var words []core.TextBox
+	var order []string
+	lines := make(map[string]*tsvLine)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 12 || fields[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(fields[11])
+		confidence, err := strconv.ParseFloat(fields[10], 64)
+		if text == "" || err != nil || confidence < 0 {
+			continue
+		}
+
+		var box [4]int
+		for i := range box {
+			if box[i], err = strconv.Atoi(fields[6+i]); err != nil {
+				return nil, fmt.Errorf("invalid tesseract output: %q", scanner.Text())
+			}
+		}
+		word := core.TextBox{
+			Text:       text,
+			Rect:       capture.ToDesktop(core.Rect{X: box[0], Y: box[1], Width: box[2], Height: box[3]}),
+			Confidence: confidence / 100,
+			Level:      core.TextLevelWord,
+		}
+		words = append(words, word)
+
+		key := strings.Join(fields[1:5], "/")
+		line, ok := lines[key]
+		if !ok {
+			line = &tsvLine{}
+			lines[key] = line
+			order = append(order, key)
+		}
+		line.words = append(line.words, word)
+		line.confidence += word.Confidence
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read tesseract output: %w", err)
+	}
+
+	boxes := words
+	for _, key := range order {
+		line := lines[key]
+		if len(line.words) < 2 {
+			continue
+		}
+		boxes = append(boxes, core.TextBox{
+			Text:       joinWords(line.words),
+			Rect:       union(line.words),
+			Confidence: line.confidence / float64(len(line.words)),
+			Level:      core.TextLevelLine,
+		})
+	}
+	return boxes, nil
+}
+
+// joinWords 合并一行中的单词：中日韩文字之间不加空格，其他单词之间用空格分隔
+func joinWords(words []core.TextBox) string {
+	var b strings.Builder
+	var prev rune
+	for i, word := range words {
+		runes := []rune(word.Text)
+		if i > 0 && !(isCJK(prev) && isCJK(runes[0])) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(word.Text)
+		prev = runes[len(runes)-1]
+	}
+	return b.String()
+}
+
+// isCJK 是否为中日韩文字或全角标点
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF)
+}
+
+// union 包含所有文本块的最小矩形
+func union(boxes []core.TextBox) core.Rect {
+	r := boxes[0].Rect
+	x1, y1 := r.X+r.Width, r.Y+r.Height
+	for _, box := range boxes[1:] {
+		r.X, r.Y = min(r.X, box.Rect.X), min(r.Y, box.Rect.Y)
+		x1, y1 = max(x1, box.Rect.X+box.Rect.Width), max(y1, box.Rect.Y+box.Rect.Height)
+	}
+	r.Width, r.Height = x1-r.X, y1-r.Y
+	return r
+}
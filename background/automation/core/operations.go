@@ -0,0 +1,91 @@
+package core
+
+// Operation 自动化操作名称，与AutomationEngine的方法一一对应
+type Operation string
+
+const (
+	// 鼠标操作
+	OpClick       Operation = "click"
+	OpDoubleClick Operation = "double_click"
+	OpRightClick  Operation = "right_click"
+	OpDrag        Operation = "drag"
+	OpMove        Operation = "move"
+	OpGetPosition Operation = "get_position"
+	OpScroll      Operation = "scroll"
+
+	// 键盘操作
+	OpType      Operation = "type"
+	OpKeyPress  Operation = "key_press"
+	OpKeyDown   Operation = "key_down"
+	OpKeyUp     Operation = "key_up"
+	OpHotkey    Operation = "hotkey"
+	OpCopy      Operation = "copy"
+	OpPaste     Operation = "paste"
+	OpSelectAll Operation = "select_all"
+
+	// 应用程序
+	OpLaunch           Operation = "launch"
+	OpLaunchWithPath   Operation = "launch_with_path"
+	OpLaunchApp        Operation = "launch_app"
+	OpGetInstalledApps Operation = "get_installed_apps"
+	OpFindApp          Operation = "find_app"
+
+	// 文件操作
+	OpCreateFile  Operation = "create_file"
+	OpCreateDir   Operation = "create_dir"
+	OpMoveFile    Operation = "move_file"
+	OpCopyFile    Operation = "copy_file"
+	OpDeleteFile  Operation = "delete_file"
+	OpDeleteDir   Operation = "delete_dir"
+	OpRenameFile  Operation = "rename_file"
+	OpFileExists  Operation = "file_exists"
+	OpGetFileInfo Operation = "get_file_info"
+	OpListDir     Operation = "list_dir"
+
+	// 屏幕操作
+	OpScreenshot     Operation = "screenshot"
+	OpScreenshotArea Operation = "screenshot_area"
+	OpGetScreenSize  Operation = "get_screen_size"
+	OpGetDisplays    Operation = "get_displays"
+	OpFindImage      Operation = "find_image"
+	OpFindText       Operation = "find_text"
+	OpGetPixelColor  Operation = "get_pixel_color"
+
+	// 系统操作
+	OpGetClipboard    Operation = "get_clipboard"
+	OpSetClipboard    Operation = "set_clipboard"
+	OpGetActiveWindow Operation = "get_active_window"
+	OpGetWindows      Operation = "get_windows"
+	OpActivateWindow  Operation = "activate_window"
+	OpCloseWindow     Operation = "close_window"
+	OpMinimizeWindow  Operation = "minimize_window"
+	OpMaximizeWindow  Operation = "maximize_window"
+
+	OpWait Operation = "wait"
+)
+
+// readOnlyOperations 只读取状态、不会改变桌面或文件的操作
+var readOnlyOperations = map[Operation]bool{
+	OpGetPosition:      true,
+	OpGetInstalledApps: true,
+	OpFindApp:          true,
+	OpFileExists:       true,
+	OpGetFileInfo:      true,
+	OpListDir:          true,
+	OpScreenshot:       true,
+	OpScreenshotArea:   true,
+	OpGetScreenSize:    true,
+	OpGetDisplays:      true,
+	OpFindImage:        true,
+	OpFindText:         true,
+	OpGetPixelColor:    true,
+	OpGetClipboard:     true,
+	OpGetActiveWindow:  true,
+	OpGetWindows:       true,
+	OpWait:             true,
+}
+
+// ReadOnly 操作是否只读，只读操作重复执行不会产生副作用
+func (o Operation) ReadOnly() bool {
+	return readOnlyOperations[o]
+}
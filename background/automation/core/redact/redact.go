@@ -0,0 +1,236 @@
+// Package redact 截图发送到云端视觉模型前的脱敏处理
+// 根据窗口标题、进程名和文字识别结果找出敏感区域，并用涂黑或马赛克覆盖
+package redact
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"diandian/background/automation/core"
+)
+
+// Strategy 敏感区域的覆盖方式
+type Strategy string
+
+const (
+	StrategyBlackout Strategy = "blackout" // 涂黑
+	StrategyBlur     Strategy = "blur"     // 大块马赛克，保留大致颜色便于模型理解布局
+)
+
+// 敏感区域的来源
+const (
+	SourceWindow   = "window"   // 标题或进程名被屏蔽的窗口
+	SourcePassword = "password" // 文字识别发现的密码输入框
+	SourceSecret   = "secret"   // 文字识别发现的密钥、令牌等
+)
+
+// Policy 脱敏策略
+type Policy struct {
+	Enabled        bool
+	BlockedTitles  []string // 窗口标题包含任一关键字时遮挡整个窗口，不区分大小写
+	BlockedApps    []string // 进程名（不含扩展名）等于任一名称时遮挡整个窗口，不区分大小写
+	PasswordFields bool     // 是否通过文字识别检测密码输入框和密钥
+	Strategy       Strategy
+	Padding        int // 敏感区域向外扩展的距离（桌面坐标）
+}
+
+// DefaultPolicy 默认策略：遮挡常见密码管理器窗口和识别到的密码输入框，使用涂黑
+func DefaultPolicy() Policy {
+	return Policy{
+		Enabled:        true,
+		BlockedTitles:  []string{"1Password", "KeePass", "Bitwarden", "LastPass", "密码管理"},
+		BlockedApps:    []string{"1Password", "KeePass", "KeePassXC", "Bitwarden", "LastPass"},
+		PasswordFields: true,
+		Strategy:       StrategyBlackout,
+		Padding:        4,
+	}
+}
+
+// Region 一个需要遮挡的区域
+type Region struct {
+	Rect   core.Rect `json:"rect"`   // 虚拟桌面坐标
+	Source string    `json:"source"` // 来源：window / password / secret
+	Reason string    `json:"reason"` // 遮挡原因，如匹配到的窗口标题，不包含敏感内容本身
+}
+
+// WindowRegions 标题或进程名被屏蔽的窗口
+// 被其他窗口部分遮住的窗口也按整个窗口范围遮挡，宁可多遮不可漏遮
+func (p Policy) WindowRegions(windows []*core.WindowInfo) []Region {
+	var regions []Region
+	for _, window := range windows {
+		if window == nil || window.Rect.Width <= 0 || window.Rect.Height <= 0 {
+			continue
+		}
+		if reason, ok := p.blocked(window); ok {
+			regions = append(regions, Region{Rect: window.Rect, Source: SourceWindow, Reason: reason})
+		}
+	}
+	return regions
+}
+
+// blocked 窗口是否被屏蔽，返回匹配原因
+func (p Policy) blocked(window *core.WindowInfo) (string, bool) {
+	title := strings.ToLower(window.Title)
+	for _, keyword := range p.BlockedTitles {
+		if k := strings.ToLower(strings.TrimSpace(keyword)); k != "" && strings.Contains(title, k) {
+			return fmt.Sprintf("window title matches %q", keyword), true
+		}
+	}
+	process := strings.TrimSuffix(strings.ToLower(window.Process), ".exe")
+	for _, app := range p.BlockedApps {
+		if a := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(app)), ".exe"); a != "" && process == a {
+			return fmt.Sprintf("window process matches %q", app), true
+		}
+	}
+	return "", false
+}
+
+// passwordLabels 密码输入框旁边常见的标签文字
+var passwordLabels = []string{"密码", "口令", "password", "passwd", "passcode", "pin码", "pin code", "secret"}
+
+// maskRunes 密码输入框中用来显示已输入字符的掩码
+const maskRunes = "•●*＊·∙⚫◦"
+
+// secretPattern 常见的API密钥和令牌格式
+var secretPattern = regexp.MustCompile(`(?i)\b(sk-[a-z0-9_-]{16,}|gh[pousr]_[a-z0-9]{20,}|AKIA[0-9A-Z]{16}|xox[abpr]-[a-z0-9-]{10,}|eyJ[a-z0-9_-]{10,}\.[a-z0-9_-]{10,})`)
+
+// PasswordRegions 根据文字识别结果推测密码输入框和密钥所在的区域
+//   - 文字全部是掩码字符（如"••••••"）：已输入的密码
+//   - 短标签包含"密码"、"password"等：标签右侧和下方的输入框
+//   - 文字符合常见密钥格式（sk-、ghp_、AKIA等）：明文显示的密钥
+func PasswordRegions(boxes []core.TextBox) []Region {
+	var regions []Region
+	for _, box := range boxes {
+		text := strings.TrimSpace(box.Text)
+		if text == "" {
+			continue
+		}
+		switch {
+		case isMasked(text):
+			regions = append(regions, Region{Rect: box.Rect, Source: SourcePassword, Reason: "masked input text"})
+		case secretPattern.MatchString(text):
+			regions = append(regions, Region{Rect: box.Rect, Source: SourceSecret, Reason: "text looks like an access key"})
+		case isPasswordLabel(text):
+			h := max(box.Rect.Height, 1)
+			// 输入框通常在标签右侧，或者标签在上、输入框在下
+			right := core.Rect{X: box.Rect.X + box.Rect.Width, Y: box.Rect.Y - h/2, Width: 20 * h, Height: 2 * h}
+			below := core.Rect{X: box.Rect.X, Y: box.Rect.Y + h, Width: max(box.Rect.Width, 20*h), Height: 3 * h}
+			reason := fmt.Sprintf("next to password label %q", text)
+			regions = append(regions,
+				Region{Rect: right, Source: SourcePassword, Reason: reason},
+				Region{Rect: below, Source: SourcePassword, Reason: reason})
+		}
+	}
+	return regions
+}
+
+// isMasked 文字是否由掩码字符组成（至少3个）
+func isMasked(text string) bool {
+	text = strings.ReplaceAll(text, " ", "")
+	if utf8.RuneCountInString(text) < 3 {
+		return false
+	}
+	for _, r := range text {
+		if !strings.ContainsRune(maskRunes, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPasswordLabel 是否是密码输入框的标签，较长的句子不视为标签
+func isPasswordLabel(text string) bool {
+	if utf8.RuneCountInString(text) > 20 {
+		return false
+	}
+	lower := strings.ToLower(text)
+	for _, label := range passwordLabels {
+		if strings.Contains(lower, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 按策略覆盖截图中的敏感区域，返回新的PNG截图和实际覆盖的区域（与截图相交的部分）
+// 没有区域与截图相交时返回原截图
+func Apply(capture *core.ScreenCapture, regions []Region, policy Policy) (*core.ScreenCapture, []Region, error) {
+	if capture == nil || len(capture.ImageData) == 0 {
+		return nil, nil, core.NewError(core.ErrInvalidArgument, "empty screen capture", nil)
+	}
+	if len(regions) == 0 {
+		return capture, nil, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid image data: %w", err)
+	}
+	bounds := src.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), src, bounds.Min, draw.Src)
+
+	var applied []Region
+	for _, region := range regions {
+		rect := core.Rect{
+			X:      region.Rect.X - policy.Padding,
+			Y:      region.Rect.Y - policy.Padding,
+			Width:  region.Rect.Width + 2*policy.Padding,
+			Height: region.Rect.Height + 2*policy.Padding,
+		}
+		p := capture.ToPixels(rect)
+		area := image.Rect(p.X, p.Y, p.X+p.Width, p.Y+p.Height).Intersect(canvas.Bounds())
+		if area.Empty() {
+			continue
+		}
+		if policy.Strategy == StrategyBlur {
+			pixelate(canvas, area)
+		} else {
+			draw.Draw(canvas, area, image.NewUniform(color.Black), image.Point{}, draw.Src)
+		}
+		applied = append(applied, region)
+	}
+	if len(applied) == 0 {
+		return capture, nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, nil, fmt.Errorf("encode png: %w", err)
+	}
+	redacted := *capture
+	redacted.ImageData = buf.Bytes()
+	redacted.Format = "png"
+	redacted.Size = buf.Len()
+	return &redacted, applied, nil
+}
+
+// pixelate 用大块马赛克覆盖区域，块大小至少16像素，文字无法辨认
+func pixelate(img *image.RGBA, area image.Rectangle) {
+	block := max(16, min(area.Dx(), area.Dy())/3)
+	for y := area.Min.Y; y < area.Max.Y; y += block {
+		for x := area.Min.X; x < area.Max.X; x += block {
+			cell := image.Rect(x, y, x+block, y+block).Intersect(area)
+			var r, g, b, n int
+			for cy := cell.Min.Y; cy < cell.Max.Y; cy++ {
+				i := img.PixOffset(cell.Min.X, cy)
+				for cx := cell.Min.X; cx < cell.Max.X; cx++ {
+					r += int(img.Pix[i])
+					g += int(img.Pix[i+1])
+					b += int(img.Pix[i+2])
+					i += 4
+					n++
+				}
+			}
+			average := color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 255}
+			draw.Draw(img, cell, image.NewUniform(average), image.Point{}, draw.Src)
+		}
+	}
+}
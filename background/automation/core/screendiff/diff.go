@@ -0,0 +1,185 @@
+// Package screendiff 比较操作前后的截图，判断点击、输入等操作是否在屏幕上产生了可见的效果
+package screendiff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // 注册JPEG解码器，截图可能是JPEG格式
+	_ "image/png"
+	"math/bits"
+
+	"diandian/background/automation/core"
+)
+
+// pixelThreshold 任一颜色通道的差值超过该值的像素视为发生了变化，过滤压缩和抗锯齿带来的噪声
+const pixelThreshold = 24
+
+// Metrics 两张截图之间的差异指标
+type Metrics struct {
+	ScreenRatio  float64    `json:"screen_ratio"`           // 全屏发生变化的像素比例
+	RegionRatio  float64    `json:"region_ratio"`           // 操作位置附近发生变化的像素比例，没有操作位置时为0
+	Region       *core.Rect `json:"region,omitempty"`       // 操作位置附近的区域（桌面坐标）
+	HashDistance int        `json:"hash_distance"`          // 两张截图感知哈希的汉明距离（0-64）
+	BeforeHash   string     `json:"before_hash"`            // 操作前截图的感知哈希
+	AfterHash    string     `json:"after_hash"`             // 操作后截图的感知哈希
+	Observations int        `json:"observations,omitempty"` // 操作后截图的次数
+	ElapsedMS    int64      `json:"elapsed_ms,omitempty"`   // 从操作完成到最后一次截图的时间
+}
+
+// Compare 比较两张截图
+// focus为操作位置（桌面坐标），不为空时额外统计其周围radius范围内的变化
+func Compare(before, after *core.ScreenCapture, focus *core.Point, radius int) (Metrics, error) {
+	beforeImg, err := decode(before)
+	if err != nil {
+		return Metrics{}, err
+	}
+	afterImg, err := decode(after)
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	beforeHash, afterHash := Hash(beforeImg), Hash(afterImg)
+	metrics := Metrics{
+		HashDistance: Distance(beforeHash, afterHash),
+		BeforeHash:   fmt.Sprintf("%016x", beforeHash),
+		AfterHash:    fmt.Sprintf("%016x", afterHash),
+	}
+
+	if focus != nil {
+		region := core.Rect{X: focus.X - radius, Y: focus.Y - radius, Width: 2 * radius, Height: 2 * radius}
+		metrics.Region = &region
+	}
+
+	// 截图尺寸或位置不同（如分辨率改变）时无法逐像素比较，视为全部变化
+	if before.Bounds != after.Bounds || beforeImg.Bounds().Size() != afterImg.Bounds().Size() {
+		metrics.ScreenRatio = 1
+		if focus != nil {
+			metrics.RegionRatio = 1
+		}
+		return metrics, nil
+	}
+
+	size := beforeImg.Bounds().Size()
+	metrics.ScreenRatio = changedRatio(beforeImg, afterImg, image.Rect(0, 0, size.X, size.Y))
+	if metrics.Region != nil {
+		pixels := before.ToPixels(*metrics.Region)
+		area := image.Rect(pixels.X, pixels.Y, pixels.X+pixels.Width, pixels.Y+pixels.Height).
+			Intersect(image.Rect(0, 0, size.X, size.Y))
+		if !area.Empty() {
+			metrics.RegionRatio = changedRatio(beforeImg, afterImg, area)
+		}
+	}
+	return metrics, nil
+}
+
+// decode 解码截图
+func decode(capture *core.ScreenCapture) (image.Image, error) {
+	if capture == nil || len(capture.ImageData) == 0 {
+		return nil, core.NewError(core.ErrInvalidArgument, "empty screen capture", nil)
+	}
+	img, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image data: %w", err)
+	}
+	return img, nil
+}
+
+// changedRatio 统计area（相对图像左上角的像素坐标）内发生变化的像素比例
+func changedRatio(a, b image.Image, area image.Rectangle) float64 {
+	if area.Empty() {
+		return 0
+	}
+	offsetA, offsetB := a.Bounds().Min, b.Bounds().Min
+	changed := 0
+
+	// 截图通常是RGBA，直接读取像素避免逐点接口调用
+	rgbaA, okA := a.(*image.RGBA)
+	rgbaB, okB := b.(*image.RGBA)
+	if okA && okB {
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			rowA := rgbaA.PixOffset(offsetA.X+area.Min.X, offsetA.Y+y)
+			rowB := rgbaB.PixOffset(offsetB.X+area.Min.X, offsetB.Y+y)
+			for x := 0; x < area.Dx(); x++ {
+				i, j := rowA+x*4, rowB+x*4
+				if differs(int(rgbaA.Pix[i]), int(rgbaA.Pix[i+1]), int(rgbaA.Pix[i+2]),
+					int(rgbaB.Pix[j]), int(rgbaB.Pix[j+1]), int(rgbaB.Pix[j+2])) {
+					changed++
+				}
+			}
+		}
+	} else {
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				r1, g1, b1, _ := a.At(offsetA.X+x, offsetA.Y+y).RGBA()
+				r2, g2, b2, _ := b.At(offsetB.X+x, offsetB.Y+y).RGBA()
+				if differs(int(r1>>8), int(g1>>8), int(b1>>8), int(r2>>8), int(g2>>8), int(b2>>8)) {
+					changed++
+				}
+			}
+		}
+	}
+	return float64(changed) / float64(area.Dx()*area.Dy())
+}
+
+// differs 两个像素是否有通道差值超过pixelThreshold
+func differs(r1, g1, b1, r2, g2, b2 int) bool {
+	return abs(r1-r2) > pixelThreshold || abs(g1-g2) > pixelThreshold || abs(b1-b2) > pixelThreshold
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Hash 计算图像的64位差值哈希（dHash）
+// 图像缩小为9x8的灰度网格，每一位表示相邻两格的明暗关系；
+// 相似的画面哈希接近，对压缩噪声和轻微缩放不敏感
+func Hash(img image.Image) uint64 {
+	const cols, rows = 9, 8
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return 0
+	}
+
+	// 每个网格取其区域内采样点的平均灰度，采样步长限制了大图的计算量
+	var grid [rows][cols]float64
+	for gy := 0; gy < rows; gy++ {
+		y0 := bounds.Min.Y + gy*bounds.Dy()/rows
+		y1 := max(bounds.Min.Y+(gy+1)*bounds.Dy()/rows, y0+1)
+		for gx := 0; gx < cols; gx++ {
+			x0 := bounds.Min.X + gx*bounds.Dx()/cols
+			x1 := max(bounds.Min.X+(gx+1)*bounds.Dx()/cols, x0+1)
+			stepX, stepY := max((x1-x0)/16, 1), max((y1-y0)/16, 1)
+
+			var sum float64
+			n := 0
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			grid[gy][gx] = sum / float64(n)
+		}
+	}
+
+	var hash uint64
+	for gy := 0; gy < rows; gy++ {
+		for gx := 0; gx < cols-1; gx++ {
+			hash <<= 1
+			if grid[gy][gx] > grid[gy][gx+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance 两个感知哈希的汉明距离
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
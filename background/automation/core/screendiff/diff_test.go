@@ -0,0 +1,161 @@
+package screendiff
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// screen 生成灰色背景的截图，changes中的区域填充为白色
+func screen(t *testing.T, width, height int, changes ...image.Rectangle) *core.ScreenCapture {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 128, 128, 128, 255
+	}
+	for _, r := range changes {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.Set(x, y, color.White)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	capture, err := core.NewScreenCapture(buf.Bytes(), 0, core.Rect{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return capture
+}
+
+func TestCompare(t *testing.T) {
+	before := screen(t, 200, 100)
+	focus := &core.Point{X: 50, Y: 50}
+
+	metrics, err := Compare(before, screen(t, 200, 100), focus, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.ScreenRatio != 0 || metrics.RegionRatio != 0 || metrics.HashDistance != 0 {
+		t.Errorf("相同截图的差异 = %+v，期望全部为0", metrics)
+	}
+
+	// 操作位置附近10x10的区域变白：全屏0.5%，区域内25%
+	metrics, err = Compare(before, screen(t, 200, 100, image.Rect(45, 45, 55, 55)), focus, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.ScreenRatio != 0.005 {
+		t.Errorf("ScreenRatio = %v，期望 0.005", metrics.ScreenRatio)
+	}
+	if metrics.RegionRatio != 0.25 {
+		t.Errorf("RegionRatio = %v，期望 0.25", metrics.RegionRatio)
+	}
+	if metrics.Region == nil || *metrics.Region != (core.Rect{X: 40, Y: 40, Width: 20, Height: 20}) {
+		t.Errorf("Region = %v，期望 {40 40 20 20}", metrics.Region)
+	}
+
+	// 远离操作位置的变化不计入区域
+	metrics, _ = Compare(before, screen(t, 200, 100, image.Rect(0, 0, 30, 100)), focus, 10)
+	if metrics.RegionRatio != 0 || metrics.ScreenRatio != 0.15 || metrics.HashDistance == 0 {
+		t.Errorf("远处变化的差异 = %+v", metrics)
+	}
+
+	// 分辨率改变时视为全部变化
+	metrics, _ = Compare(before, screen(t, 100, 100), focus, 10)
+	if metrics.ScreenRatio != 1 || metrics.RegionRatio != 1 {
+		t.Errorf("尺寸不同时 ScreenRatio=%v RegionRatio=%v，期望 1", metrics.ScreenRatio, metrics.RegionRatio)
+	}
+
+	if _, err := Compare(before, &core.ScreenCapture{}, nil, 0); core.CodeOf(err) != core.ErrInvalidArgument {
+		t.Errorf("空截图错误码 = %s，期望 invalid_argument", core.CodeOf(err))
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if got := Distance(0b1011, 0b0110); got != 3 {
+		t.Errorf("Distance = %d，期望 3", got)
+	}
+	if got := Distance(^uint64(0), 0); got != 64 {
+		t.Errorf("Distance = %d，期望 64", got)
+	}
+}
+
+// frames 依次返回预先准备的截图，用完后一直返回最后一张
+type frames struct {
+	captures []*core.ScreenCapture
+	calls    int
+}
+
+func (f *frames) ScreenshotContext(ctx context.Context) ([]byte, *core.OperationResult) {
+	capture := f.captures[min(f.calls, len(f.captures)-1)]
+	f.calls++
+	return capture.ImageData, core.NewCaptureResult("screenshot", capture)
+}
+
+func TestObserve(t *testing.T) {
+	policy := DefaultPolicy()
+	// 出现变化时立即结束观察，超时足够长以免机器较慢时提前放弃
+	policy.Settle, policy.Interval, policy.Timeout = 0, time.Millisecond, time.Minute
+	// 超时为0时只观察一次，结果与耗时无关
+	once := policy
+	once.Timeout = 0
+	focus := &core.Point{X: 50, Y: 50}
+	click := func() *core.OperationResult { return core.NewSuccessResult("clicked", nil) }
+
+	t.Run("第二次截图才出现变化", func(t *testing.T) {
+		shots := &frames{captures: []*core.ScreenCapture{
+			screen(t, 200, 100), screen(t, 200, 100), screen(t, 200, 100, image.Rect(45, 45, 55, 55)),
+		}}
+		result := Observe(context.Background(), shots, focus, policy, click)
+		if !result.Success {
+			t.Fatalf("有变化时操作失败: %s", result.Error)
+		}
+		data := result.Data.(map[string]interface{})
+		if data["visible_effect"] != true || data["effect"].(Metrics).Observations != 2 {
+			t.Errorf("Data = %v，期望第2次观察到变化", data)
+		}
+		if shots.calls != 3 {
+			t.Errorf("截图次数 = %d，期望操作前1次、操作后2次", shots.calls)
+		}
+	})
+
+	t.Run("没有变化时可重试失败", func(t *testing.T) {
+		shots := &frames{captures: []*core.ScreenCapture{screen(t, 200, 100)}}
+		result := Observe(context.Background(), shots, focus, once, click)
+		if result.Success || result.Code != core.ErrNoEffect || !result.Retryable {
+			t.Errorf("结果 = %v %s retryable=%v，期望可重试的 no_effect", result.Success, result.Code, result.Retryable)
+		}
+		if shots.calls != 2 {
+			t.Errorf("截图次数 = %d，期望操作前后各1次", shots.calls)
+		}
+	})
+
+	t.Run("只记录时仍然成功", func(t *testing.T) {
+		record := once
+		record.Action = ActionRecord
+		result := Observe(context.Background(), &frames{captures: []*core.ScreenCapture{screen(t, 200, 100)}}, focus, record, click)
+		if !result.Success || result.Data.(map[string]interface{})["visible_effect"] != false {
+			t.Errorf("结果 = %v %v，期望成功并记录没有可见效果", result.Success, result.Data)
+		}
+	})
+
+	t.Run("截图失败不影响操作", func(t *testing.T) {
+		result := Observe(context.Background(), &frames{captures: []*core.ScreenCapture{{}}}, focus, once, click)
+		if !result.Success {
+			t.Fatalf("截图失败时操作失败: %s", result.Error)
+		}
+		if _, ok := result.Data.(map[string]interface{})["effect_error"]; !ok {
+			t.Errorf("Data = %v，期望记录effect_error", result.Data)
+		}
+	})
+}
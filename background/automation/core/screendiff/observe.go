@@ -0,0 +1,155 @@
+package screendiff
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// Action 操作没有可见效果时的处理方式
+type Action string
+
+const (
+	ActionNone   Action = "none"   // 不比较截图
+	ActionRecord Action = "record" // 只记录差异指标，操作仍视为成功
+	ActionRetry  Action = "retry"  // 操作失败，错误可重试（如重新生成点击坐标）
+	ActionFail   Action = "fail"   // 操作失败且不重试，交给上层重新规划
+)
+
+// Policy 判断操作是否产生效果的策略
+// 任一指标达到阈值即认为操作产生了可见效果
+type Policy struct {
+	Action          Action
+	MinScreenRatio  float64       // 全屏变化像素比例的阈值
+	MinRegionRatio  float64       // 操作位置附近变化像素比例的阈值
+	MinHashDistance int           // 感知哈希汉明距离的阈值
+	Radius          int           // 操作位置附近区域的半径（桌面坐标）
+	Settle          time.Duration // 操作完成后第一次截图前的等待时间
+	Timeout         time.Duration // 没有检测到变化时继续观察的最长时间，界面响应较慢时避免误判
+	Interval        time.Duration // 观察期间两次截图的间隔
+}
+
+// DefaultPolicy 默认策略：操作附近1%或全屏0.01%的像素变化即视为有效果，
+// 最多观察1.5秒，没有效果时步骤失败并重试
+func DefaultPolicy() Policy {
+	return Policy{
+		Action:          ActionRetry,
+		MinScreenRatio:  0.0001,
+		MinRegionRatio:  0.01,
+		MinHashDistance: 4,
+		Radius:          100,
+		Settle:          200 * time.Millisecond,
+		Timeout:         1500 * time.Millisecond,
+		Interval:        250 * time.Millisecond,
+	}
+}
+
+// Visible 差异指标是否表示屏幕发生了可见的变化
+func (p Policy) Visible(metrics Metrics) bool {
+	return metrics.ScreenRatio >= p.MinScreenRatio ||
+		(metrics.Region != nil && metrics.RegionRatio >= p.MinRegionRatio) ||
+		metrics.HashDistance >= p.MinHashDistance
+}
+
+// Screenshotter 截取整个桌面，HybridEngine实现了该接口
+type Screenshotter interface {
+	ScreenshotContext(ctx context.Context) ([]byte, *core.OperationResult)
+}
+
+// Observe 执行action并比较前后截图，差异指标记录在结果Data的"effect"中
+// focus为操作位置（桌面坐标），可以为空。截图失败时不影响操作本身，只在Data的"effect_error"中记录原因；
+// 没有可见效果时按policy.Action将结果改为no_effect失败
+func Observe(ctx context.Context, screen Screenshotter, focus *core.Point, policy Policy, action func() *core.OperationResult) *core.OperationResult {
+	if policy.Action == ActionNone {
+		return action()
+	}
+
+	before, captureErr := capture(ctx, screen)
+	result := action()
+	if !result.Success {
+		return result
+	}
+	if captureErr != nil {
+		return skipped(result, captureErr)
+	}
+
+	done := time.Now()
+	if err := core.Sleep(ctx, policy.Settle); err != nil {
+		return result
+	}
+	var metrics Metrics
+	for observations := 1; ; observations++ {
+		after, err := capture(ctx, screen)
+		if err != nil {
+			return skipped(result, err)
+		}
+		if metrics, err = Compare(before, after, focus, policy.Radius); err != nil {
+			return skipped(result, err)
+		}
+		metrics.Observations = observations
+		metrics.ElapsedMS = time.Since(done).Milliseconds()
+		if policy.Visible(metrics) || time.Since(done)+policy.Interval > policy.Timeout {
+			break
+		}
+		if err := core.Sleep(ctx, policy.Interval); err != nil {
+			return result
+		}
+	}
+
+	visible := policy.Visible(metrics)
+	data := map[string]interface{}{
+		"effect":         metrics,
+		"visible_effect": visible,
+	}
+	if visible || policy.Action == ActionRecord {
+		result.Data = merge(result.Data, data)
+		return result
+	}
+
+	err := core.NewError(core.ErrNoEffect, fmt.Sprintf(
+		"no visible effect after %dms (screen %.4f%%, region %.2f%%, hash distance %d)",
+		metrics.ElapsedMS, metrics.ScreenRatio*100, metrics.RegionRatio*100, metrics.HashDistance), nil)
+	err.Retryable = policy.Action == ActionRetry
+	failed := core.NewErrorResult(result.Message+", but the screen did not change", err)
+	failed.Data = merge(result.Data, data)
+	failed.Duration = result.Duration
+	return failed
+}
+
+// capture 截取桌面
+func capture(ctx context.Context, screen Screenshotter) (*core.ScreenCapture, error) {
+	_, result := screen.ScreenshotContext(ctx)
+	if capture, ok := core.CaptureFromResult(result); ok {
+		return capture, nil
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return nil, core.NewError(core.ErrUnknown, "screenshot returned no image", nil)
+}
+
+// skipped 无法比较截图时保留操作结果，并记录原因
+func skipped(result *core.OperationResult, err error) *core.OperationResult {
+	result.Data = merge(result.Data, map[string]interface{}{
+		"effect_error": err.Error(),
+	})
+	return result
+}
+
+// merge 将data合并到操作结果的Data中，原Data不是map时保存在"result"中
+func merge(original interface{}, data map[string]interface{}) map[string]interface{} {
+	switch d := original.(type) {
+	case nil:
+		return data
+	case map[string]interface{}:
+		for k, v := range data {
+			d[k] = v
+		}
+		return d
+	default:
+		data["result"] = original
+		return data
+	}
+}
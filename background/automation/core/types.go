@@ -11,8 +11,12 @@ type OperationResult struct {
 	Message   string        `json:"message"`
 	Data      interface{}   `json:"data,omitempty"`
 	Error     string        `json:"error,omitempty"`
+	Code      ErrorCode     `json:"code,omitempty"`      // 失败时的错误码
+	Retryable bool          `json:"retryable,omitempty"` // 失败是否值得重试
 	Duration  time.Duration `json:"duration"`
 	Timestamp time.Time     `json:"timestamp"`
+
+	cause error // 原始错误链，不参与序列化
 }
 
 // Point 坐标点
@@ -77,18 +81,20 @@ type AppInfo struct {
 
 // WindowInfo 窗口信息
 type WindowInfo struct {
-	Title  string  `json:"title"`
-	Class  string  `json:"class"`
-	PID    int     `json:"pid"`
-	Handle uintptr `json:"handle"`
-	Rect   Rect    `json:"rect"`
+	Title   string  `json:"title"`
+	Class   string  `json:"class"`
+	PID     int     `json:"pid"`
+	Process string  `json:"process,omitempty"` // 进程名（不含扩展名），获取失败时为空
+	Handle  uintptr `json:"handle"`
+	Rect    Rect    `json:"rect"`
 }
 
 // ScreenInfo 屏幕信息
 type ScreenInfo struct {
-	Width  int `json:"width"`
-	Height int `json:"height"`
-	DPI    int `json:"dpi"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	DPI         int     `json:"dpi"`
+	ScaleFactor float64 `json:"scale_factor"` // DPI/96
 }
 
 // DisplayCapture 显示器截图信息
@@ -121,14 +127,19 @@ func NewSuccessResult(message string, data interface{}) *OperationResult {
 }
 
 // NewErrorResult 创建错误结果
+// 错误码和是否可重试从err的错误链中获取，见CodeOf
 func NewErrorResult(message string, err error) *OperationResult {
 	result := &OperationResult{
 		Success:   false,
 		Message:   message,
+		Code:      ErrUnknown,
 		Timestamp: time.Now(),
 	}
 	if err != nil {
 		result.Error = err.Error()
+		result.Code = CodeOf(err)
+		result.Retryable = IsRetryable(err)
+		result.cause = err
 	}
 	return result
 }
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// TypeStrategy 文本输入策略
+type TypeStrategy string
+
+const (
+	TypeStrategyAuto  TypeStrategy = "auto"  // 包含非ASCII字符时粘贴，否则逐键输入；逐键输入不被支持时改用粘贴
+	TypeStrategyKeys  TypeStrategy = "keys"  // 逐键模拟输入，受输入法影响，通常只适合ASCII文本
+	TypeStrategyPaste TypeStrategy = "paste" // 写入剪贴板后按粘贴快捷键，不受输入法影响
+)
+
+// TypeOptions 文本输入选项
+type TypeOptions struct {
+	Strategy         TypeStrategy
+	RestoreClipboard bool          // 粘贴后恢复用户原来的剪贴板内容
+	PasteDelay       time.Duration // 按下粘贴键后等待目标程序读取剪贴板的时间，之后才恢复剪贴板
+}
+
+// DefaultTypeOptions 默认输入选项：自动选择策略，粘贴后恢复剪贴板
+func DefaultTypeOptions() TypeOptions {
+	return TypeOptions{
+		Strategy:         TypeStrategyAuto,
+		RestoreClipboard: true,
+		PasteDelay:       200 * time.Millisecond,
+	}
+}
+
+// ParseTypeStrategy 解析输入策略名称，空字符串视为auto
+func ParseTypeStrategy(name string) (TypeStrategy, error) {
+	switch strategy := TypeStrategy(name); strategy {
+	case "":
+		return TypeStrategyAuto, nil
+	case TypeStrategyAuto, TypeStrategyKeys, TypeStrategyPaste:
+		return strategy, nil
+	default:
+		return "", NewError(ErrInvalidArgument, fmt.Sprintf("unknown type strategy: %s", name), nil)
+	}
+}
+
+// Resolve 确定输入text时实际使用的策略
+func (o TypeOptions) Resolve(text string) TypeStrategy {
+	switch o.Strategy {
+	case TypeStrategyKeys, TypeStrategyPaste:
+		return o.Strategy
+	}
+	if RequiresPaste(text) {
+		return TypeStrategyPaste
+	}
+	return TypeStrategyKeys
+}
+
+// RequiresPaste 文本是否包含逐键输入不可靠的字符
+// 非ASCII字符（中文、全角标点、emoji等）依赖输入法或Unicode注入，逐键输入经常丢字或被输入法截获
+func RequiresPaste(text string) bool {
+	for _, r := range text {
+		if r > 0x7E || (r < 0x20 && r != '\t' && r != '\n') {
+			return true
+		}
+	}
+	return false
+}
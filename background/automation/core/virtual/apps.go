@@ -0,0 +1,147 @@
+package virtual
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"diandian/background/automation/core"
+)
+
+// AppSpec 可启动的虚拟应用程序
+type AppSpec struct {
+	Name        string     // 应用名称，Launch按名称查找（不区分大小写）
+	DisplayName string     // 显示名称
+	Path        string     // 可执行文件路径，LaunchWithPath按路径或文件名查找
+	Window      WindowSpec // 启动后打开的窗口
+}
+
+// NotepadApp 内置的记事本应用：一个带全窗口输入框的窗口，启动后输入框获得焦点
+func NotepadApp() AppSpec {
+	return AppSpec{
+		Name:        "notepad",
+		DisplayName: "记事本",
+		Path:        `C:\Windows\System32\notepad.exe`,
+		Window: WindowSpec{
+			Title: "无标题 - 记事本",
+			Class: "Notepad",
+			Widgets: []Widget{
+				{
+					ID:     "editor",
+					Kind:   WidgetInput,
+					Bounds: core.Rect{X: 4, Y: TitleBarHeight + 4, Width: 792, Height: 600 - TitleBarHeight - 8},
+				},
+			},
+			Focus: "editor",
+		},
+	}
+}
+
+// info 转换为core.AppInfo
+func (a AppSpec) info() *core.AppInfo {
+	return &core.AppInfo{
+		Name:        a.Name,
+		DisplayName: a.DisplayName,
+		Path:        a.Path,
+	}
+}
+
+// AddApp 注册可启动的应用程序
+func (e *Engine) AddApp(app AppSpec) {
+	e.mu.Lock()
+	e.apps[app.Name] = app
+	e.mu.Unlock()
+}
+
+// findApp 按名称、显示名称、路径或可执行文件名查找应用
+func (e *Engine) findApp(name string) (AppSpec, bool) {
+	if app, ok := e.apps[name]; ok {
+		return app, true
+	}
+
+	lower := strings.ToLower(name)
+	base := strings.ToLower(strings.TrimSuffix(filepath.Base(strings.ReplaceAll(name, `\`, "/")), filepath.Ext(name)))
+	for _, app := range e.apps {
+		appBase := strings.ToLower(strings.TrimSuffix(filepath.Base(strings.ReplaceAll(app.Path, `\`, "/")), filepath.Ext(app.Path)))
+		switch {
+		case strings.ToLower(app.Name) == lower,
+			strings.ToLower(app.DisplayName) == lower,
+			strings.EqualFold(app.Path, name),
+			appBase != "" && appBase == base:
+			return app, true
+		}
+	}
+	return AppSpec{}, false
+}
+
+// launch 在锁内启动应用
+func (e *Engine) launch(name string) *core.OperationResult {
+	app, ok := e.findApp(name)
+	if !ok {
+		return core.NewErrorResult("failed to launch application", core.NewError(core.ErrNotFound, fmt.Sprintf("application %q not found", name), nil))
+	}
+	window := e.openWindow(app.Window)
+	return core.NewSuccessResult(fmt.Sprintf("launched %s", app.Name), map[string]interface{}{
+		"app":    app.Name,
+		"pid":    window.PID,
+		"handle": window.Handle,
+		"title":  window.Title,
+	})
+}
+
+// Launch 按名称启动应用程序
+func (e *Engine) Launch(appName string) *core.OperationResult {
+	return e.do(core.OpLaunch, map[string]interface{}{"app": appName}, func() *core.OperationResult {
+		return e.launch(appName)
+	})
+}
+
+// LaunchWithPath 按路径启动应用程序
+func (e *Engine) LaunchWithPath(path string, args ...string) *core.OperationResult {
+	return e.do(core.OpLaunchWithPath, map[string]interface{}{"path": path, "args": args}, func() *core.OperationResult {
+		return e.launch(path)
+	})
+}
+
+// LaunchApp 启动应用程序
+func (e *Engine) LaunchApp(app *core.AppInfo) *core.OperationResult {
+	if app == nil {
+		return e.do(core.OpLaunchApp, nil, func() *core.OperationResult {
+			return core.NewErrorResult("failed to launch application", core.NewError(core.ErrInvalidArgument, "app is nil", nil))
+		})
+	}
+	return e.do(core.OpLaunchApp, map[string]interface{}{"app": app.Name, "path": app.Path}, func() *core.OperationResult {
+		if _, ok := e.findApp(app.Name); ok || app.Path == "" {
+			return e.launch(app.Name)
+		}
+		return e.launch(app.Path)
+	})
+}
+
+// GetInstalledApps 获取已注册的应用程序（按名称排序）
+func (e *Engine) GetInstalledApps() ([]*core.AppInfo, *core.OperationResult) {
+	var apps []*core.AppInfo
+	result := e.do(core.OpGetInstalledApps, nil, func() *core.OperationResult {
+		for _, app := range e.apps {
+			apps = append(apps, app.info())
+		}
+		sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+		return core.NewSuccessResult(fmt.Sprintf("found %d applications", len(apps)), apps)
+	})
+	return apps, result
+}
+
+// FindApp 查找应用程序
+func (e *Engine) FindApp(name string) (*core.AppInfo, *core.OperationResult) {
+	var info *core.AppInfo
+	result := e.do(core.OpFindApp, map[string]interface{}{"name": name}, func() *core.OperationResult {
+		app, ok := e.findApp(name)
+		if !ok {
+			return core.NewErrorResult("application not found", core.NewError(core.ErrNotFound, fmt.Sprintf("application %q not found", name), nil))
+		}
+		info = app.info()
+		return core.NewSuccessResult(fmt.Sprintf("found %s", app.Name), info)
+	})
+	return info, result
+}
@@ -0,0 +1,346 @@
+package virtual
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"diandian/background/automation/core"
+)
+
+// TitleBarHeight 窗口标题栏高度（拖拽标题栏可以移动窗口）
+const TitleBarHeight = 24
+
+// WidgetKind 控件类型
+type WidgetKind string
+
+const (
+	WidgetButton WidgetKind = "button" // 按钮，点击触发OnClick
+	WidgetInput  WidgetKind = "input"  // 输入框，点击获得焦点后接收键盘输入
+	WidgetLabel  WidgetKind = "label"  // 静态文本
+)
+
+// Widget 窗口中的控件
+type Widget struct {
+	ID       string
+	Kind     WidgetKind
+	Bounds   core.Rect   // 相对窗口左上角的区域
+	Text     string      // 按钮/标签文字或输入框内容
+	Color    color.Color // 填充色，为空时使用控件类型的默认颜色
+	Clicks   int         // 被点击次数
+	Selected bool        // 输入框内容是否被全选，全选后输入会替换原内容
+	OnClick  func(w *Window)
+}
+
+// Window 虚拟窗口
+type Window struct {
+	Handle    uintptr
+	Title     string
+	Class     string
+	PID       int
+	Process   string
+	Bounds    core.Rect
+	Minimized bool
+	Maximized bool
+	Widgets   []*Widget
+	Focus     string // 获得键盘焦点的输入框ID
+	ScrollX   int    // 累计水平滚动格数
+	ScrollY   int    // 累计垂直滚动格数（向下为正）
+	Saves     int    // 收到保存快捷键（Ctrl+S）的次数
+
+	restore core.Rect // 最大化前的位置
+}
+
+// WindowSpec 创建窗口的描述
+type WindowSpec struct {
+	Title   string
+	Class   string
+	Process string // 进程名，用于模拟按应用屏蔽的窗口
+	Bounds  core.Rect
+	Widgets []Widget
+	Focus   string // 初始获得焦点的输入框ID
+}
+
+// Widget 按ID查找控件
+func (w *Window) Widget(id string) *Widget {
+	for _, widget := range w.Widgets {
+		if widget.ID == id {
+			return widget
+		}
+	}
+	return nil
+}
+
+// focused 当前获得焦点的输入框
+func (w *Window) focused() *Widget {
+	if w.Focus == "" {
+		return nil
+	}
+	if widget := w.Widget(w.Focus); widget != nil && widget.Kind == WidgetInput {
+		return widget
+	}
+	return nil
+}
+
+// widgetAt 查找窗口中包含绝对坐标的控件（后添加的控件在上层）
+func (w *Window) widgetAt(x, y int) *Widget {
+	for i := len(w.Widgets) - 1; i >= 0; i-- {
+		widget := w.Widgets[i]
+		if contains(w.absolute(widget.Bounds), x, y) {
+			return widget
+		}
+	}
+	return nil
+}
+
+// absolute 将相对窗口的区域转换为屏幕坐标
+func (w *Window) absolute(rect core.Rect) core.Rect {
+	return core.Rect{X: w.Bounds.X + rect.X, Y: w.Bounds.Y + rect.Y, Width: rect.Width, Height: rect.Height}
+}
+
+// info 转换为core.WindowInfo
+func (w *Window) info() *core.WindowInfo {
+	return &core.WindowInfo{
+		Title:   w.Title,
+		Class:   w.Class,
+		PID:     w.PID,
+		Process: w.Process,
+		Handle:  w.Handle,
+		Rect:    w.Bounds,
+	}
+}
+
+// clone 深拷贝窗口（不包含回调的内部状态）
+func (w *Window) clone() *Window {
+	copied := *w
+	copied.Widgets = make([]*Widget, len(w.Widgets))
+	for i, widget := range w.Widgets {
+		c := *widget
+		copied.Widgets[i] = &c
+	}
+	return &copied
+}
+
+// contains 判断点是否在区域内
+func contains(rect core.Rect, x, y int) bool {
+	return x >= rect.X && x < rect.X+rect.Width && y >= rect.Y && y < rect.Y+rect.Height
+}
+
+// center 区域中心点
+func center(rect core.Rect) core.Point {
+	return core.Point{X: rect.X + rect.Width/2, Y: rect.Y + rect.Height/2}
+}
+
+// OpenWindow 打开一个窗口并置于最上层，返回窗口句柄
+func (e *Engine) OpenWindow(spec WindowSpec) uintptr {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.openWindow(spec).Handle
+}
+
+// openWindow 在锁内创建窗口
+func (e *Engine) openWindow(spec WindowSpec) *Window {
+	bounds := spec.Bounds
+	if bounds.Width <= 0 || bounds.Height <= 0 {
+		// 默认窗口位置按已打开窗口数量错开
+		offset := 40 * len(e.windows)
+		bounds = core.Rect{X: 100 + offset, Y: 80 + offset, Width: 800, Height: 600}
+	}
+
+	e.nextHandle++
+	e.nextPID++
+	window := &Window{
+		Handle:  e.nextHandle,
+		Title:   spec.Title,
+		Class:   spec.Class,
+		PID:     e.nextPID,
+		Process: spec.Process,
+		Bounds:  bounds,
+		Focus:   spec.Focus,
+	}
+	for _, widget := range spec.Widgets {
+		w := widget
+		window.Widgets = append(window.Widgets, &w)
+	}
+
+	e.windows = append(e.windows, window)
+	return window
+}
+
+// Window 获取窗口状态的快照
+func (e *Engine) Window(handle uintptr) (*Window, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if window := e.window(handle); window != nil {
+		return window.clone(), true
+	}
+	return nil, false
+}
+
+// FindWindow 按标题（不区分大小写的子串）查找最上层的窗口快照
+func (e *Engine) FindWindow(title string) (*Window, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	title = strings.ToLower(title)
+	for i := len(e.windows) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(e.windows[i].Title), title) {
+			return e.windows[i].clone(), true
+		}
+	}
+	return nil, false
+}
+
+// Clipboard 当前剪贴板内容
+func (e *Engine) Clipboard() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.clipboard
+}
+
+// window 按句柄查找窗口
+func (e *Engine) window(handle uintptr) *Window {
+	for _, window := range e.windows {
+		if window.Handle == handle {
+			return window
+		}
+	}
+	return nil
+}
+
+// active 当前活动窗口（最上层且未最小化）
+func (e *Engine) active() *Window {
+	for i := len(e.windows) - 1; i >= 0; i-- {
+		if !e.windows[i].Minimized {
+			return e.windows[i]
+		}
+	}
+	return nil
+}
+
+// windowAt 查找包含坐标的最上层窗口
+func (e *Engine) windowAt(x, y int) *Window {
+	for i := len(e.windows) - 1; i >= 0; i-- {
+		window := e.windows[i]
+		if !window.Minimized && contains(window.Bounds, x, y) {
+			return window
+		}
+	}
+	return nil
+}
+
+// raise 将窗口移到最上层
+func (e *Engine) raise(window *Window) {
+	for i, w := range e.windows {
+		if w == window {
+			e.windows = append(append(e.windows[:i:i], e.windows[i+1:]...), window)
+			return
+		}
+	}
+}
+
+// remove 关闭窗口
+func (e *Engine) remove(window *Window) {
+	for i, w := range e.windows {
+		if w == window {
+			e.windows = append(e.windows[:i:i], e.windows[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetClipboard 获取剪贴板内容
+func (e *Engine) GetClipboard() (string, *core.OperationResult) {
+	var text string
+	result := e.do(core.OpGetClipboard, nil, func() *core.OperationResult {
+		text = e.clipboard
+		return core.NewSuccessResult("clipboard read", map[string]interface{}{
+			"text": text,
+		})
+	})
+	return text, result
+}
+
+// SetClipboard 设置剪贴板内容
+func (e *Engine) SetClipboard(text string) *core.OperationResult {
+	return e.do(core.OpSetClipboard, map[string]interface{}{"text": text}, func() *core.OperationResult {
+		e.clipboard = text
+		return core.NewSuccessResult("clipboard updated", map[string]interface{}{
+			"length": len(text),
+		})
+	})
+}
+
+// GetActiveWindow 获取当前活动窗口
+func (e *Engine) GetActiveWindow() (*core.WindowInfo, *core.OperationResult) {
+	var info *core.WindowInfo
+	result := e.do(core.OpGetActiveWindow, nil, func() *core.OperationResult {
+		window := e.active()
+		if window == nil {
+			return core.NewErrorResult("no active window", core.NewError(core.ErrNotFound, "desktop is empty", nil))
+		}
+		info = window.info()
+		return core.NewSuccessResult(fmt.Sprintf("active window: %s", window.Title), info)
+	})
+	return info, result
+}
+
+// GetWindows 获取所有窗口（从上到下）
+func (e *Engine) GetWindows() ([]*core.WindowInfo, *core.OperationResult) {
+	var infos []*core.WindowInfo
+	result := e.do(core.OpGetWindows, nil, func() *core.OperationResult {
+		for i := len(e.windows) - 1; i >= 0; i-- {
+			infos = append(infos, e.windows[i].info())
+		}
+		return core.NewSuccessResult(fmt.Sprintf("found %d windows", len(infos)), infos)
+	})
+	return infos, result
+}
+
+// ActivateWindow 激活窗口
+func (e *Engine) ActivateWindow(handle uintptr) *core.OperationResult {
+	return e.windowOperation(core.OpActivateWindow, handle, func(window *Window) {
+		window.Minimized = false
+		e.raise(window)
+	})
+}
+
+// CloseWindow 关闭窗口
+func (e *Engine) CloseWindow(handle uintptr) *core.OperationResult {
+	return e.windowOperation(core.OpCloseWindow, handle, func(window *Window) {
+		e.remove(window)
+	})
+}
+
+// MinimizeWindow 最小化窗口
+func (e *Engine) MinimizeWindow(handle uintptr) *core.OperationResult {
+	return e.windowOperation(core.OpMinimizeWindow, handle, func(window *Window) {
+		window.Minimized = true
+	})
+}
+
+// MaximizeWindow 最大化窗口
+func (e *Engine) MaximizeWindow(handle uintptr) *core.OperationResult {
+	return e.windowOperation(core.OpMaximizeWindow, handle, func(window *Window) {
+		if !window.Maximized {
+			window.restore = window.Bounds
+			window.Maximized = true
+		}
+		window.Minimized = false
+		window.Bounds = core.Rect{Width: e.config.Width, Height: e.config.Height}
+		e.raise(window)
+	})
+}
+
+// windowOperation 对指定窗口执行操作
+func (e *Engine) windowOperation(operation core.Operation, handle uintptr, apply func(window *Window)) *core.OperationResult {
+	return e.do(operation, map[string]interface{}{"handle": handle}, func() *core.OperationResult {
+		window := e.window(handle)
+		if window == nil {
+			return core.NewErrorResult(fmt.Sprintf("%s failed", operation), core.NewError(core.ErrNotFound, fmt.Sprintf("window %#x not found", handle), nil))
+		}
+		apply(window)
+		return core.NewSuccessResult(fmt.Sprintf("%s: %s", operation, window.Title), window.info())
+	})
+}
@@ -0,0 +1,239 @@
+// Package virtual 提供内存中的虚拟桌面自动化引擎
+//
+// Engine实现完整的core.AutomationEngine，但不操作真实桌面：
+// 窗口、控件、鼠标键盘、剪贴板都保存在内存中，文件操作限定在一个临时目录内，
+// 截图由虚拟桌面渲染生成。所有操作按顺序记录，便于在无图形界面的CI环境中
+// 对执行器、AutomationService和HybridEngine做端到端测试。
+package virtual
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// Config 虚拟桌面配置
+type Config struct {
+	Width       int              // 屏幕逻辑宽度
+	Height      int              // 屏幕逻辑高度
+	ScaleFactor float64          // 截图像素与逻辑坐标的比例（模拟高DPI），默认1
+	Root        string           // 文件系统根目录，为空时创建临时目录
+	Apps        []AppSpec        // 可启动的应用程序
+	RealSleep   bool             // Wait是否真实等待，默认立即返回以保证测试确定性
+	Clock       func() time.Time // 操作记录使用的时钟，默认time.Now
+
+	// Capabilities 覆盖引擎报告的能力，用于模拟能力受限的后端；为nil时报告虚拟桌面的实际能力
+	Capabilities *core.Capabilities
+}
+
+// DefaultConfig 默认虚拟桌面配置：1920x1080，内置记事本应用
+func DefaultConfig() Config {
+	return Config{
+		Width:       1920,
+		Height:      1080,
+		ScaleFactor: 1,
+		Apps: []AppSpec{
+			NotepadApp(),
+		},
+	}
+}
+
+// Action 一次已执行的操作记录
+type Action struct {
+	Seq       int                    `json:"seq"`
+	Time      time.Time              `json:"time"`
+	Operation core.Operation         `json:"operation"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Success   bool                   `json:"success"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// Engine 虚拟桌面自动化引擎
+type Engine struct {
+	config Config
+
+	mu          sync.Mutex
+	root        string
+	ownsRoot    bool
+	mouse       core.Point
+	pressedKeys map[string]bool
+	clipboard   string
+	windows     []*Window // 按Z序排列，最后一个在最上层
+	nextHandle  uintptr
+	nextPID     int
+	apps        map[string]AppSpec
+	actions     []Action
+	failures    map[core.Operation][]error
+}
+
+// 确保Engine实现了完整的自动化引擎接口
+var _ core.AutomationEngine = (*Engine)(nil)
+
+// NewEngine 创建虚拟桌面引擎
+func NewEngine(config Config) (*Engine, error) {
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("invalid screen size %dx%d", config.Width, config.Height)
+	}
+	if config.ScaleFactor <= 0 {
+		config.ScaleFactor = 1
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+
+	engine := &Engine{
+		config:      config,
+		root:        config.Root,
+		pressedKeys: make(map[string]bool),
+		nextHandle:  0x1000,
+		nextPID:     1000,
+		apps:        make(map[string]AppSpec),
+		failures:    make(map[core.Operation][]error),
+	}
+
+	if engine.root == "" {
+		root, err := os.MkdirTemp("", "diandian-virtual-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create virtual file system: %w", err)
+		}
+		engine.root = root
+		engine.ownsRoot = true
+	}
+
+	for _, app := range config.Apps {
+		engine.apps[app.Name] = app
+	}
+
+	return engine, nil
+}
+
+// IsAvailable 虚拟引擎始终可用
+func (e *Engine) IsAvailable() bool {
+	return true
+}
+
+// Capabilities 虚拟桌面的能力描述
+// 未识别的按键不会报错但也没有效果，因此只列出有实际效果的具名按键
+func (e *Engine) Capabilities() *core.Capabilities {
+	if e.config.Capabilities != nil {
+		return e.config.Capabilities
+	}
+
+	return &core.Capabilities{
+		Engine:       "virtual",
+		Operations:   core.ImplementedOperations(e),
+		Keys:         []string{"backspace", "delete", "enter", "return", "space", "tab"},
+		Modifiers:    []core.KeyModifier{core.ModAlt, core.ModCtrl, core.ModShift, core.ModWin},
+		MouseButtons: []core.MouseButton{core.LeftButton, core.MiddleButton, core.RightButton},
+		UnicodeText:  true,
+	}
+}
+
+// Root 虚拟文件系统在宿主机上的根目录
+func (e *Engine) Root() string {
+	return e.root
+}
+
+// Actions 按执行顺序返回所有操作记录
+func (e *Engine) Actions() []Action {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	actions := make([]Action, len(e.actions))
+	copy(actions, e.actions)
+	return actions
+}
+
+// ClearActions 清空操作记录
+func (e *Engine) ClearActions() {
+	e.mu.Lock()
+	e.actions = nil
+	e.mu.Unlock()
+}
+
+// FailNext 让下一次指定操作返回err，用于测试回退和错误处理
+// 多次调用会按顺序排队
+func (e *Engine) FailNext(operation core.Operation, err error) {
+	e.mu.Lock()
+	e.failures[operation] = append(e.failures[operation], err)
+	e.mu.Unlock()
+}
+
+// do 在锁内执行一次操作并记录
+func (e *Engine) do(operation core.Operation, params map[string]interface{}, fn func() *core.OperationResult) *core.OperationResult {
+	start := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var result *core.OperationResult
+	if queued := e.failures[operation]; len(queued) > 0 {
+		e.failures[operation] = queued[1:]
+		result = core.NewErrorResult(fmt.Sprintf("%s failed", operation), queued[0])
+	} else {
+		result = fn()
+	}
+	result.SetDuration(start)
+
+	e.actions = append(e.actions, Action{
+		Seq:       len(e.actions) + 1,
+		Time:      e.config.Clock(),
+		Operation: operation,
+		Params:    params,
+		Success:   result.Success,
+		Error:     result.Error,
+	})
+	return result
+}
+
+// Initialize 初始化引擎
+func (e *Engine) Initialize() *core.OperationResult {
+	return core.NewSuccessResult("virtual desktop ready", map[string]interface{}{
+		"width":  e.config.Width,
+		"height": e.config.Height,
+		"root":   e.root,
+	})
+}
+
+// Cleanup 清理资源，删除引擎创建的临时目录
+func (e *Engine) Cleanup() *core.OperationResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.ownsRoot {
+		if err := os.RemoveAll(e.root); err != nil {
+			return core.NewErrorResult("failed to remove virtual file system", err)
+		}
+		e.ownsRoot = false
+	}
+	return core.NewSuccessResult("virtual desktop cleaned up", nil)
+}
+
+// Wait 等待指定毫秒数
+func (e *Engine) Wait(duration int) *core.OperationResult {
+	return e.WaitContext(context.Background(), duration)
+}
+
+// WaitContext 等待指定毫秒数；未开启RealSleep时只记录不等待
+func (e *Engine) WaitContext(ctx context.Context, duration int) *core.OperationResult {
+	if e.config.RealSleep {
+		if err := core.Sleep(ctx, time.Duration(duration)*time.Millisecond); err != nil {
+			return e.do(core.OpWait, map[string]interface{}{"duration_ms": duration}, func() *core.OperationResult {
+				return core.NewCancelledResult(ctx)
+			})
+		}
+	}
+
+	return e.do(core.OpWait, map[string]interface{}{"duration_ms": duration}, func() *core.OperationResult {
+		if result := core.CheckContext(ctx); result != nil {
+			return result
+		}
+		return core.NewSuccessResult(fmt.Sprintf("waited %d ms", duration), map[string]interface{}{
+			"duration_ms": duration,
+		})
+	})
+}
@@ -0,0 +1,184 @@
+package virtual
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"diandian/background/automation/core"
+)
+
+// Path 将自动化请求中的路径映射到虚拟文件系统根目录下
+// 盘符会被去掉，反斜杠视为分隔符，".."不会越出根目录：
+// "C:\Users\a.txt" 和 "/Users/a.txt" 都映射为 <root>/Users/a.txt
+func (e *Engine) Path(p string) string {
+	p = strings.ReplaceAll(p, `\`, "/")
+	if len(p) >= 2 && p[1] == ':' {
+		p = p[2:]
+	}
+	return filepath.Join(e.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// fileOperation 执行一次文件操作
+func (e *Engine) fileOperation(operation core.Operation, params map[string]interface{}, fn func() error) *core.OperationResult {
+	return e.do(operation, params, func() *core.OperationResult {
+		if err := fn(); err != nil {
+			return core.NewErrorResult(fmt.Sprintf("%s failed", operation), err)
+		}
+		return core.NewSuccessResult(fmt.Sprintf("%s succeeded", operation), params)
+	})
+}
+
+// CreateFile 创建文件，父目录不存在时自动创建
+func (e *Engine) CreateFile(p string, content []byte) *core.OperationResult {
+	return e.fileOperation(core.OpCreateFile, map[string]interface{}{"path": p, "size": len(content)}, func() error {
+		target := e.Path(p)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0o644)
+	})
+}
+
+// CreateDir 创建目录
+func (e *Engine) CreateDir(p string) *core.OperationResult {
+	return e.fileOperation(core.OpCreateDir, map[string]interface{}{"path": p}, func() error {
+		return os.MkdirAll(e.Path(p), 0o755)
+	})
+}
+
+// MoveFile 移动文件
+func (e *Engine) MoveFile(src, dst string) *core.OperationResult {
+	return e.fileOperation(core.OpMoveFile, map[string]interface{}{"src": src, "dst": dst}, func() error {
+		return e.rename(src, dst)
+	})
+}
+
+// CopyFile 复制文件
+func (e *Engine) CopyFile(src, dst string) *core.OperationResult {
+	return e.fileOperation(core.OpCopyFile, map[string]interface{}{"src": src, "dst": dst}, func() error {
+		in, err := os.Open(e.Path(src))
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		target := e.Path(dst)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// DeleteFile 删除文件
+func (e *Engine) DeleteFile(p string) *core.OperationResult {
+	return e.fileOperation(core.OpDeleteFile, map[string]interface{}{"path": p}, func() error {
+		target := e.Path(p)
+		info, err := os.Stat(target)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return core.NewError(core.ErrInvalidArgument, fmt.Sprintf("%s is a directory", p), nil)
+		}
+		return os.Remove(target)
+	})
+}
+
+// DeleteDir 删除目录及其内容，不允许删除根目录
+func (e *Engine) DeleteDir(p string) *core.OperationResult {
+	return e.fileOperation(core.OpDeleteDir, map[string]interface{}{"path": p}, func() error {
+		target := e.Path(p)
+		if target == filepath.Clean(e.root) {
+			return core.NewError(core.ErrPermissionDenied, "refusing to delete virtual root", nil)
+		}
+		if _, err := os.Stat(target); err != nil {
+			return err
+		}
+		return os.RemoveAll(target)
+	})
+}
+
+// RenameFile 重命名文件
+func (e *Engine) RenameFile(oldPath, newPath string) *core.OperationResult {
+	return e.fileOperation(core.OpRenameFile, map[string]interface{}{"old_path": oldPath, "new_path": newPath}, func() error {
+		return e.rename(oldPath, newPath)
+	})
+}
+
+// rename 移动或重命名，目标父目录不存在时自动创建
+func (e *Engine) rename(src, dst string) error {
+	target := e.Path(dst)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(e.Path(src), target)
+}
+
+// FileExists 检查文件是否存在
+func (e *Engine) FileExists(p string) (bool, *core.OperationResult) {
+	var exists bool
+	result := e.do(core.OpFileExists, map[string]interface{}{"path": p}, func() *core.OperationResult {
+		_, err := os.Stat(e.Path(p))
+		if err != nil && !os.IsNotExist(err) {
+			return core.NewErrorResult("file_exists failed", err)
+		}
+		exists = err == nil
+		return core.NewSuccessResult(fmt.Sprintf("exists: %v", exists), map[string]interface{}{
+			"path":   p,
+			"exists": exists,
+		})
+	})
+	return exists, result
+}
+
+// GetFileInfo 获取文件信息
+func (e *Engine) GetFileInfo(p string) (interface{}, *core.OperationResult) {
+	var info map[string]interface{}
+	result := e.do(core.OpGetFileInfo, map[string]interface{}{"path": p}, func() *core.OperationResult {
+		stat, err := os.Stat(e.Path(p))
+		if err != nil {
+			return core.NewErrorResult("get_file_info failed", err)
+		}
+		info = map[string]interface{}{
+			"name":     stat.Name(),
+			"size":     stat.Size(),
+			"is_dir":   stat.IsDir(),
+			"mode":     stat.Mode().String(),
+			"mod_time": stat.ModTime(),
+		}
+		return core.NewSuccessResult("file info", info)
+	})
+	if info == nil {
+		return nil, result
+	}
+	return info, result
+}
+
+// ListDir 列出目录内容
+func (e *Engine) ListDir(p string) ([]string, *core.OperationResult) {
+	var names []string
+	result := e.do(core.OpListDir, map[string]interface{}{"path": p}, func() *core.OperationResult {
+		entries, err := os.ReadDir(e.Path(p))
+		if err != nil {
+			return core.NewErrorResult("list_dir failed", err)
+		}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return core.NewSuccessResult(fmt.Sprintf("found %d entries", len(names)), names)
+	})
+	return names, result
+}
@@ -0,0 +1,361 @@
+package virtual
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"diandian/background/automation/core"
+)
+
+// Click 在指定位置点击
+// 点击会激活所在窗口；点击输入框获得焦点，点击按钮触发OnClick
+func (e *Engine) Click(x, y int, button core.MouseButton) *core.OperationResult {
+	params := map[string]interface{}{"x": x, "y": y, "button": string(button)}
+	return e.do(core.OpClick, params, func() *core.OperationResult {
+		return e.click(x, y, button, 1)
+	})
+}
+
+// DoubleClick 双击，双击输入框会全选其内容
+func (e *Engine) DoubleClick(x, y int) *core.OperationResult {
+	return e.do(core.OpDoubleClick, map[string]interface{}{"x": x, "y": y}, func() *core.OperationResult {
+		return e.click(x, y, core.LeftButton, 2)
+	})
+}
+
+// RightClick 右键点击
+func (e *Engine) RightClick(x, y int) *core.OperationResult {
+	return e.do(core.OpRightClick, map[string]interface{}{"x": x, "y": y}, func() *core.OperationResult {
+		return e.click(x, y, core.RightButton, 1)
+	})
+}
+
+// click 在锁内执行点击
+func (e *Engine) click(x, y int, button core.MouseButton, clicks int) *core.OperationResult {
+	if result := e.checkPoint(x, y); result != nil {
+		return result
+	}
+	e.mouse = core.Point{X: x, Y: y}
+
+	data := map[string]interface{}{"x": x, "y": y, "button": string(button)}
+	window := e.windowAt(x, y)
+	if window == nil {
+		return core.NewSuccessResult(fmt.Sprintf("clicked desktop at (%d, %d)", x, y), data)
+	}
+	e.raise(window)
+	data["window"] = window.Title
+
+	widget := window.widgetAt(x, y)
+	if widget == nil {
+		return core.NewSuccessResult(fmt.Sprintf("clicked window %s at (%d, %d)", window.Title, x, y), data)
+	}
+	data["widget"] = widget.ID
+	widget.Clicks += clicks
+
+	if button == core.LeftButton {
+		switch widget.Kind {
+		case WidgetInput:
+			window.Focus = widget.ID
+			widget.Selected = clicks >= 2
+		case WidgetButton:
+			if widget.OnClick != nil {
+				for i := 0; i < clicks; i++ {
+					widget.OnClick(window)
+				}
+			}
+		}
+	}
+
+	return core.NewSuccessResult(fmt.Sprintf("clicked %s in %s at (%d, %d)", widget.ID, window.Title, x, y), data)
+}
+
+// Drag 拖拽，从标题栏开始拖拽会移动窗口
+func (e *Engine) Drag(fromX, fromY, toX, toY int) *core.OperationResult {
+	params := map[string]interface{}{"from_x": fromX, "from_y": fromY, "to_x": toX, "to_y": toY}
+	return e.do(core.OpDrag, params, func() *core.OperationResult {
+		if result := e.checkPoint(fromX, fromY); result != nil {
+			return result
+		}
+		if result := e.checkPoint(toX, toY); result != nil {
+			return result
+		}
+		e.mouse = core.Point{X: toX, Y: toY}
+
+		window := e.windowAt(fromX, fromY)
+		if window != nil && fromY < window.Bounds.Y+TitleBarHeight && !window.Maximized {
+			window.Bounds.X += toX - fromX
+			window.Bounds.Y += toY - fromY
+			e.raise(window)
+			return core.NewSuccessResult(fmt.Sprintf("moved window %s", window.Title), window.info())
+		}
+		return core.NewSuccessResult(fmt.Sprintf("dragged from (%d, %d) to (%d, %d)", fromX, fromY, toX, toY), nil)
+	})
+}
+
+// Move 移动鼠标
+func (e *Engine) Move(x, y int) *core.OperationResult {
+	return e.do(core.OpMove, map[string]interface{}{"x": x, "y": y}, func() *core.OperationResult {
+		if result := e.checkPoint(x, y); result != nil {
+			return result
+		}
+		e.mouse = core.Point{X: x, Y: y}
+		return core.NewSuccessResult(fmt.Sprintf("moved mouse to (%d, %d)", x, y), e.mouse)
+	})
+}
+
+// GetPosition 获取鼠标位置
+func (e *Engine) GetPosition() (*core.Point, *core.OperationResult) {
+	var point *core.Point
+	result := e.do(core.OpGetPosition, nil, func() *core.OperationResult {
+		p := e.mouse
+		point = &p
+		return core.NewSuccessResult(fmt.Sprintf("mouse at (%d, %d)", p.X, p.Y), point)
+	})
+	return point, result
+}
+
+// Scroll 滚动，累计到鼠标所在窗口的滚动量上
+func (e *Engine) Scroll(x, y int, direction string, clicks int) *core.OperationResult {
+	params := map[string]interface{}{"x": x, "y": y, "direction": direction, "clicks": clicks}
+	return e.do(core.OpScroll, params, func() *core.OperationResult {
+		if result := e.checkPoint(x, y); result != nil {
+			return result
+		}
+		e.mouse = core.Point{X: x, Y: y}
+
+		dx, dy := 0, 0
+		switch strings.ToLower(direction) {
+		case "up":
+			dy = -clicks
+		case "down":
+			dy = clicks
+		case "left":
+			dx = -clicks
+		case "right":
+			dx = clicks
+		default:
+			return core.NewErrorResult("scroll failed", core.NewError(core.ErrInvalidArgument, fmt.Sprintf("unknown scroll direction %q", direction), nil))
+		}
+
+		if window := e.windowAt(x, y); window != nil {
+			window.ScrollX += dx
+			window.ScrollY += dy
+		}
+		return core.NewSuccessResult(fmt.Sprintf("scrolled %s %d clicks", direction, clicks), params)
+	})
+}
+
+// checkPoint 坐标超出屏幕时返回错误结果
+func (e *Engine) checkPoint(x, y int) *core.OperationResult {
+	if x < 0 || y < 0 || x >= e.config.Width || y >= e.config.Height {
+		return core.NewErrorResult("coordinates out of screen",
+			core.NewError(core.ErrInvalidArgument, fmt.Sprintf("point (%d, %d) outside %dx%d", x, y, e.config.Width, e.config.Height), nil))
+	}
+	return nil
+}
+
+// Type 向活动窗口中获得焦点的输入框输入文本
+func (e *Engine) Type(text string) *core.OperationResult {
+	return e.do(core.OpType, map[string]interface{}{"text": text}, func() *core.OperationResult {
+		widget := e.insert(text)
+		data := map[string]interface{}{"length": utf8.RuneCountInString(text)}
+		if widget != nil {
+			data["widget"] = widget.ID
+		}
+		return core.NewSuccessResult(fmt.Sprintf("typed %d characters", utf8.RuneCountInString(text)), data)
+	})
+}
+
+// insert 在锁内向焦点输入框插入文本，没有焦点时返回nil
+func (e *Engine) insert(text string) *Widget {
+	window := e.active()
+	if window == nil {
+		return nil
+	}
+	widget := window.focused()
+	if widget == nil {
+		return nil
+	}
+	if widget.Selected {
+		widget.Text = ""
+		widget.Selected = false
+	}
+	widget.Text += text
+	return widget
+}
+
+// KeyPress 按键；已按下的修饰键（KeyDown）会与之组成快捷键
+func (e *Engine) KeyPress(key string) *core.OperationResult {
+	return e.do(core.OpKeyPress, map[string]interface{}{"key": key}, func() *core.OperationResult {
+		var modifiers []core.KeyModifier
+		for _, modifier := range []core.KeyModifier{core.ModCtrl, core.ModAlt, core.ModShift, core.ModWin} {
+			if e.pressedKeys[string(modifier)] {
+				modifiers = append(modifiers, modifier)
+			}
+		}
+		if len(modifiers) > 0 {
+			return e.hotkey(modifiers, key)
+		}
+		return e.press(key)
+	})
+}
+
+// press 在锁内处理单个按键
+func (e *Engine) press(key string) *core.OperationResult {
+	key = strings.ToLower(key)
+	var widget *Widget
+	if window := e.active(); window != nil {
+		widget = window.focused()
+		if key == "tab" {
+			e.focusNext(window)
+			return core.NewSuccessResult("pressed tab", map[string]interface{}{"focus": window.Focus})
+		}
+	}
+
+	switch key {
+	case "backspace":
+		if widget != nil {
+			if widget.Selected {
+				widget.Text = ""
+				widget.Selected = false
+			} else if _, size := utf8.DecodeLastRuneInString(widget.Text); size > 0 {
+				widget.Text = widget.Text[:len(widget.Text)-size]
+			}
+		}
+	case "delete":
+		if widget != nil && widget.Selected {
+			widget.Text = ""
+			widget.Selected = false
+		}
+	case "enter", "return":
+		e.insert("\n")
+	case "space":
+		e.insert(" ")
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			e.insert(key)
+		}
+	}
+	return core.NewSuccessResult(fmt.Sprintf("pressed %s", key), nil)
+}
+
+// focusNext 焦点移动到下一个输入框
+func (e *Engine) focusNext(window *Window) {
+	var inputs []string
+	for _, widget := range window.Widgets {
+		if widget.Kind == WidgetInput {
+			inputs = append(inputs, widget.ID)
+		}
+	}
+	if len(inputs) == 0 {
+		return
+	}
+	next := 0
+	for i, id := range inputs {
+		if id == window.Focus {
+			next = (i + 1) % len(inputs)
+		}
+	}
+	window.Focus = inputs[next]
+}
+
+// KeyDown 按下按键
+func (e *Engine) KeyDown(key string) *core.OperationResult {
+	return e.do(core.OpKeyDown, map[string]interface{}{"key": key}, func() *core.OperationResult {
+		e.pressedKeys[strings.ToLower(key)] = true
+		return core.NewSuccessResult(fmt.Sprintf("key %s down", key), nil)
+	})
+}
+
+// KeyUp 释放按键
+func (e *Engine) KeyUp(key string) *core.OperationResult {
+	return e.do(core.OpKeyUp, map[string]interface{}{"key": key}, func() *core.OperationResult {
+		delete(e.pressedKeys, strings.ToLower(key))
+		return core.NewSuccessResult(fmt.Sprintf("key %s up", key), nil)
+	})
+}
+
+// Hotkey 快捷键
+// 支持Ctrl+A/C/X/V/S和Alt+F4，Win修饰键按Ctrl处理（对应macOS的Command）
+func (e *Engine) Hotkey(modifiers []core.KeyModifier, key string) *core.OperationResult {
+	names := make([]string, len(modifiers))
+	for i, modifier := range modifiers {
+		names[i] = string(modifier)
+	}
+	params := map[string]interface{}{"modifiers": names, "key": key}
+	return e.do(core.OpHotkey, params, func() *core.OperationResult {
+		return e.hotkey(modifiers, key)
+	})
+}
+
+// hotkey 在锁内处理快捷键
+func (e *Engine) hotkey(modifiers []core.KeyModifier, key string) *core.OperationResult {
+	var primary, alt bool
+	parts := make([]string, 0, len(modifiers)+1)
+	for _, modifier := range modifiers {
+		parts = append(parts, string(modifier))
+		switch modifier {
+		case core.ModCtrl, core.ModWin:
+			primary = true
+		case core.ModAlt:
+			alt = true
+		}
+	}
+
+	key = strings.ToLower(key)
+	combo := strings.Join(append(parts, key), "+")
+	window := e.active()
+
+	if alt && key == "f4" {
+		if window != nil {
+			e.remove(window)
+		}
+		return core.NewSuccessResult("closed active window", nil)
+	}
+	if !primary || window == nil {
+		return core.NewSuccessResult(fmt.Sprintf("pressed %s", combo), nil)
+	}
+
+	widget := window.focused()
+	switch key {
+	case "a":
+		if widget != nil {
+			widget.Selected = true
+		}
+	case "c", "x":
+		if widget != nil && widget.Selected {
+			e.clipboard = widget.Text
+			if key == "x" {
+				widget.Text = ""
+				widget.Selected = false
+			}
+		}
+	case "v":
+		e.insert(e.clipboard)
+	case "s":
+		window.Saves++
+	}
+	return core.NewSuccessResult(fmt.Sprintf("pressed %s", combo), nil)
+}
+
+// Copy 复制（Ctrl+C）
+func (e *Engine) Copy() *core.OperationResult {
+	return e.do(core.OpCopy, nil, func() *core.OperationResult {
+		return e.hotkey([]core.KeyModifier{core.ModCtrl}, "c")
+	})
+}
+
+// Paste 粘贴（Ctrl+V）
+func (e *Engine) Paste() *core.OperationResult {
+	return e.do(core.OpPaste, nil, func() *core.OperationResult {
+		return e.hotkey([]core.KeyModifier{core.ModCtrl}, "v")
+	})
+}
+
+// SelectAll 全选（Ctrl+A）
+func (e *Engine) SelectAll() *core.OperationResult {
+	return e.do(core.OpSelectAll, nil, func() *core.OperationResult {
+		return e.hotkey([]core.KeyModifier{core.ModCtrl}, "a")
+	})
+}
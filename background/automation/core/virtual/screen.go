@@ -0,0 +1,278 @@
+package virtual
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/imagematch"
+)
+
+// 渲染使用的颜色
+var (
+	desktopColor       = color.RGBA{R: 0, G: 120, B: 215, A: 255}
+	frameColor         = color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	clientColor        = color.RGBA{R: 250, G: 250, B: 250, A: 255}
+	activeTitleColor   = color.RGBA{R: 0, G: 90, B: 160, A: 255}
+	inactiveTitleColor = color.RGBA{R: 160, G: 160, B: 160, A: 255}
+	buttonColor        = color.RGBA{R: 225, G: 225, B: 225, A: 255}
+	inputColor         = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	focusedBorderColor = color.RGBA{R: 0, G: 120, B: 215, A: 255}
+	widgetBorderColor  = color.RGBA{R: 90, G: 90, B: 90, A: 255}
+	selectedInputColor = color.RGBA{R: 200, G: 225, B: 250, A: 255}
+)
+
+// 文字以每个字符一个色块的方式渲染：内容变化时截图一定变化，但不依赖字体
+const (
+	glyphWidth   = 6
+	glyphHeight  = 10
+	glyphAdvance = 8
+	lineHeight   = 14
+	textPadding  = 4
+)
+
+// canvas 按缩放比例绘制逻辑坐标矩形的画布
+type canvas struct {
+	img   *image.RGBA
+	scale float64
+}
+
+// fill 填充逻辑坐标矩形，超出clip的部分被裁剪
+func (c *canvas) fill(rect, clip core.Rect, col color.Color) {
+	visible := intersect(rect, clip)
+	if visible.Width == 0 || visible.Height == 0 {
+		return
+	}
+
+	r := image.Rect(
+		int(float64(visible.X)*c.scale), int(float64(visible.Y)*c.scale),
+		int(float64(visible.X+visible.Width)*c.scale), int(float64(visible.Y+visible.Height)*c.scale),
+	).Intersect(c.img.Bounds())
+	rgba := color.RGBAModel.Convert(col).(color.RGBA)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c.img.SetRGBA(x, y, rgba)
+		}
+	}
+}
+
+// border 绘制1像素边框
+func (c *canvas) border(rect, clip core.Rect, col color.Color) {
+	c.fill(core.Rect{X: rect.X, Y: rect.Y, Width: rect.Width, Height: 1}, clip, col)
+	c.fill(core.Rect{X: rect.X, Y: rect.Y + rect.Height - 1, Width: rect.Width, Height: 1}, clip, col)
+	c.fill(core.Rect{X: rect.X, Y: rect.Y, Width: 1, Height: rect.Height}, clip, col)
+	c.fill(core.Rect{X: rect.X + rect.Width - 1, Y: rect.Y, Width: 1, Height: rect.Height}, clip, col)
+}
+
+// text 在区域内绘制文字色块，超出区域或clip的部分被裁剪，light为true时使用浅色
+func (c *canvas) text(rect, clip core.Rect, text string, light bool) {
+	clip = intersect(rect, clip)
+	x, y := rect.X+textPadding, rect.Y+textPadding
+	for _, r := range text {
+		if r == '\n' {
+			x, y = rect.X+textPadding, y+lineHeight
+			continue
+		}
+		if r != ' ' {
+			shade := uint8(20 + int(r)*37%100)
+			col := color.RGBA{R: shade, G: shade, B: shade, A: 255}
+			if light {
+				col = color.RGBA{R: 255 - shade/2, G: 255 - shade/2, B: 255 - shade/2, A: 255}
+			}
+			c.fill(core.Rect{X: x, Y: y, Width: glyphWidth, Height: glyphHeight}, clip, col)
+		}
+		x += glyphAdvance
+	}
+}
+
+// render 在锁内渲染整个虚拟桌面
+func (e *Engine) render() *image.RGBA {
+	scale := e.config.ScaleFactor
+	screen := core.Rect{Width: e.config.Width, Height: e.config.Height}
+	c := &canvas{
+		img:   image.NewRGBA(image.Rect(0, 0, int(float64(screen.Width)*scale), int(float64(screen.Height)*scale))),
+		scale: scale,
+	}
+	c.fill(screen, screen, desktopColor)
+
+	active := e.active()
+	for _, window := range e.windows {
+		if window.Minimized {
+			continue
+		}
+		bounds := window.Bounds
+		c.fill(bounds, screen, clientColor)
+
+		titleBar := core.Rect{X: bounds.X, Y: bounds.Y, Width: bounds.Width, Height: TitleBarHeight}
+		titleColor := inactiveTitleColor
+		if window == active {
+			titleColor = activeTitleColor
+		}
+		c.fill(titleBar, screen, titleColor)
+		c.text(titleBar, screen, window.Title, true)
+		c.border(bounds, screen, frameColor)
+
+		for _, widget := range window.Widgets {
+			c.widget(window, widget, screen)
+		}
+	}
+	return c.img
+}
+
+// widget 绘制控件
+func (c *canvas) widget(window *Window, widget *Widget, screen core.Rect) {
+	rect := window.absolute(widget.Bounds)
+	clip := intersect(screen, window.Bounds)
+
+	fill := widget.Color
+	if fill == nil {
+		switch widget.Kind {
+		case WidgetButton:
+			fill = buttonColor
+		case WidgetInput:
+			fill = inputColor
+			if widget.Selected {
+				fill = selectedInputColor
+			}
+		}
+	}
+	if fill != nil {
+		c.fill(rect, clip, fill)
+	}
+
+	switch widget.Kind {
+	case WidgetButton:
+		c.border(rect, clip, widgetBorderColor)
+	case WidgetInput:
+		borderColor := color.Color(widgetBorderColor)
+		if window.Focus == widget.ID {
+			borderColor = focusedBorderColor
+		}
+		c.border(rect, clip, borderColor)
+	}
+
+	c.text(rect, clip, widget.Text, false)
+}
+
+// intersect 两个矩形的交集，不相交时宽高为0
+func intersect(a, b core.Rect) core.Rect {
+	x0, y0 := max(a.X, b.X), max(a.Y, b.Y)
+	x1, y1 := min(a.X+a.Width, b.X+b.Width), min(a.Y+a.Height, b.Y+b.Height)
+	if x0 >= x1 || y0 >= y1 {
+		return core.Rect{X: x0, Y: y0}
+	}
+	return core.Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+// encode 将图像编码为PNG并包装为截图结果
+func encode(img image.Image, bounds core.Rect) (*core.ScreenCapture, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode screenshot: %w", err)
+	}
+	return core.NewScreenCapture(buf.Bytes(), 0, bounds)
+}
+
+// Screenshot 渲染整个虚拟桌面
+func (e *Engine) Screenshot() ([]byte, *core.OperationResult) {
+	var data []byte
+	result := e.do(core.OpScreenshot, nil, func() *core.OperationResult {
+		capture, err := encode(e.render(), core.Rect{Width: e.config.Width, Height: e.config.Height})
+		if err != nil {
+			return core.NewErrorResult("screenshot failed", err)
+		}
+		data = capture.ImageData
+		return core.NewCaptureResult("screenshot captured", capture)
+	})
+	return data, result
+}
+
+// ScreenshotArea 渲染虚拟桌面的指定区域
+func (e *Engine) ScreenshotArea(rect core.Rect) ([]byte, *core.OperationResult) {
+	var data []byte
+	params := map[string]interface{}{"x": rect.X, "y": rect.Y, "width": rect.Width, "height": rect.Height}
+	result := e.do(core.OpScreenshotArea, params, func() *core.OperationResult {
+		if rect.Width <= 0 || rect.Height <= 0 || rect.X < 0 || rect.Y < 0 ||
+			rect.X+rect.Width > e.config.Width || rect.Y+rect.Height > e.config.Height {
+			return core.NewErrorResult("screenshot area failed",
+				core.NewError(core.ErrInvalidArgument, fmt.Sprintf("area %+v outside %dx%d", rect, e.config.Width, e.config.Height), nil))
+		}
+
+		scale := e.config.ScaleFactor
+		area := image.Rect(
+			int(float64(rect.X)*scale), int(float64(rect.Y)*scale),
+			int(float64(rect.X+rect.Width)*scale), int(float64(rect.Y+rect.Height)*scale),
+		)
+		capture, err := encode(e.render().SubImage(area), rect)
+		if err != nil {
+			return core.NewErrorResult("screenshot area failed", err)
+		}
+		capture.DisplayIndex = core.DisplayIndexVirtual
+		data = capture.ImageData
+		return core.NewCaptureResult("screenshot area captured", capture)
+	})
+	return data, result
+}
+
+// GetScreenSize 获取屏幕尺寸
+func (e *Engine) GetScreenSize() (*core.Size, *core.OperationResult) {
+	var size *core.Size
+	result := e.do(core.OpGetScreenSize, nil, func() *core.OperationResult {
+		size = &core.Size{Width: e.config.Width, Height: e.config.Height}
+		return core.NewSuccessResult(fmt.Sprintf("screen size %dx%d", size.Width, size.Height), size)
+	})
+	return size, result
+}
+
+// FindImage 在渲染出的虚拟桌面上查找模板图像，返回最佳匹配的中心坐标
+func (e *Engine) FindImage(templatePath string) (*core.Point, *core.OperationResult) {
+	return e.FindImageWithOptions(templatePath, imagematch.DefaultOptions())
+}
+
+// FindImageWithOptions 按指定的阈值、缩放比例和区域查找模板图像
+func (e *Engine) FindImageWithOptions(templatePath string, options imagematch.Options) (*core.Point, *core.OperationResult) {
+	var point *core.Point
+	result := e.do(core.OpFindImage, map[string]interface{}{"template": templatePath, "threshold": options.Threshold}, func() *core.OperationResult {
+		template, err := imagematch.LoadTemplate(templatePath)
+		if err != nil {
+			return core.NewErrorResult("find image failed", err)
+		}
+		matches := imagematch.FindInImage(e.render(), core.Rect{Width: e.config.Width, Height: e.config.Height}, template, options)
+		var result *core.OperationResult
+		point, result = imagematch.NewResult(templatePath, matches, options)
+		return result
+	})
+	return point, result
+}
+
+// FindText 在可见窗口的控件文字和标题中查找文本（不区分大小写），返回中心坐标
+func (e *Engine) FindText(text string) (*core.Point, *core.OperationResult) {
+	var point *core.Point
+	result := e.do(core.OpFindText, map[string]interface{}{"text": text}, func() *core.OperationResult {
+		needle := strings.ToLower(text)
+		for i := len(e.windows) - 1; i >= 0; i-- {
+			window := e.windows[i]
+			if window.Minimized {
+				continue
+			}
+			for _, widget := range window.Widgets {
+				if strings.Contains(strings.ToLower(widget.Text), needle) {
+					p := center(window.absolute(widget.Bounds))
+					point = &p
+					return core.NewSuccessResult(fmt.Sprintf("found %q in %s", text, widget.ID), point)
+				}
+			}
+			if strings.Contains(strings.ToLower(window.Title), needle) {
+				p := center(core.Rect{X: window.Bounds.X, Y: window.Bounds.Y, Width: window.Bounds.Width, Height: TitleBarHeight})
+				point = &p
+				return core.NewSuccessResult(fmt.Sprintf("found %q in title of %s", text, window.Title), point)
+			}
+		}
+		return core.NewErrorResult("text not found", core.NewError(core.ErrNotFound, fmt.Sprintf("%q not found on screen", text), nil))
+	})
+	return point, result
+}
@@ -0,0 +1,407 @@
+// Package wait 提供可组合的等待条件：图像出现或消失、文本可见、像素颜色、窗口存在、屏幕静止，
+// 以及它们的与、或、非组合。条件通过轮询引擎的查询操作判断，取代固定时长的等待。
+package wait
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// Probe 判断等待条件所需的查询操作，HybridEngine实现了全部方法
+type Probe interface {
+	FindImageContext(ctx context.Context, templatePath string) (*core.Point, *core.OperationResult)
+	FindTextContext(ctx context.Context, text string) (*core.Point, *core.OperationResult)
+	GetWindowsContext(ctx context.Context) ([]*core.WindowInfo, *core.OperationResult)
+	GetPixelColorContext(ctx context.Context, x, y int) (string, *core.OperationResult)
+	ScreenshotContext(ctx context.Context) ([]byte, *core.OperationResult)
+}
+
+// Condition 等待条件
+type Condition interface {
+	// Check 检查一次条件是否满足
+	// 暂时无法判断时（如截图失败）返回可重试的错误，等待会继续；不可重试的错误会结束等待
+	Check(ctx context.Context, probe Probe) (bool, error)
+
+	// String 条件的表达式形式，可以被Parse解析
+	String() string
+}
+
+// resetter 带有状态的条件，每次开始等待前重置
+type resetter interface {
+	reset()
+}
+
+// reset 重置条件及其子条件的状态
+func reset(condition Condition) {
+	if r, ok := condition.(resetter); ok {
+		r.reset()
+	}
+}
+
+// found 将查找类操作的结果转换为条件是否满足：找到为true，not_found为false，其他失败返回错误
+func found(result *core.OperationResult) (bool, error) {
+	if result.Success {
+		return true, nil
+	}
+	err := result.Err()
+	if core.CodeOf(err) == core.ErrNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+// ===== 图像 =====
+
+type imageCondition struct {
+	path string
+}
+
+// ImageVisible 模板图像出现在屏幕上
+func ImageVisible(templatePath string) Condition {
+	return &imageCondition{path: templatePath}
+}
+
+// ImageGone 模板图像从屏幕上消失
+func ImageGone(templatePath string) Condition {
+	return Not(ImageVisible(templatePath))
+}
+
+func (c *imageCondition) Check(ctx context.Context, probe Probe) (bool, error) {
+	_, result := probe.FindImageContext(ctx, c.path)
+	return found(result)
+}
+
+func (c *imageCondition) String() string {
+	return "image:" + quote(c.path)
+}
+
+// ===== 文本 =====
+
+type textCondition struct {
+	text string
+}
+
+// TextVisible 屏幕上出现指定文本
+func TextVisible(text string) Condition {
+	return &textCondition{text: text}
+}
+
+// TextGone 指定文本从屏幕上消失
+func TextGone(text string) Condition {
+	return Not(TextVisible(text))
+}
+
+func (c *textCondition) Check(ctx context.Context, probe Probe) (bool, error) {
+	_, result := probe.FindTextContext(ctx, c.text)
+	return found(result)
+}
+
+func (c *textCondition) String() string {
+	return "text:" + quote(c.text)
+}
+
+// ===== 窗口 =====
+
+type windowCondition struct {
+	title string
+}
+
+// WindowExists 存在标题包含title的窗口（不区分大小写）
+func WindowExists(title string) Condition {
+	return &windowCondition{title: title}
+}
+
+// WindowGone 标题包含title的窗口都已关闭
+func WindowGone(title string) Condition {
+	return Not(WindowExists(title))
+}
+
+func (c *windowCondition) Check(ctx context.Context, probe Probe) (bool, error) {
+	windows, result := probe.GetWindowsContext(ctx)
+	if !result.Success {
+		return false, result.Err()
+	}
+	return FindWindow(windows, c.title) != nil, nil
+}
+
+func (c *windowCondition) String() string {
+	return "window:" + quote(c.title)
+}
+
+// FindWindow 查找标题包含title的窗口（不区分大小写），优先返回标题完全相同的窗口
+func FindWindow(windows []*core.WindowInfo, title string) *core.WindowInfo {
+	needle := strings.ToLower(title)
+	var partial *core.WindowInfo
+	for _, window := range windows {
+		if window == nil {
+			continue
+		}
+		name := strings.ToLower(window.Title)
+		if name == needle {
+			return window
+		}
+		if partial == nil && strings.Contains(name, needle) {
+			partial = window
+		}
+	}
+	return partial
+}
+
+// ===== 像素颜色 =====
+
+type pixelCondition struct {
+	x, y      int
+	color     [3]int
+	tolerance int
+}
+
+// PixelColor 指定位置的像素颜色与color（#rrggbb或rrggbb）相同，每个通道允许tolerance的误差
+func PixelColor(x, y int, color string, tolerance int) (Condition, error) {
+	rgb, err := ParseColor(color)
+	if err != nil {
+		return nil, err
+	}
+	return &pixelCondition{x: x, y: y, color: rgb, tolerance: tolerance}, nil
+}
+
+func (c *pixelCondition) Check(ctx context.Context, probe Probe) (bool, error) {
+	color, result := probe.GetPixelColorContext(ctx, c.x, c.y)
+	if !result.Success {
+		return false, result.Err()
+	}
+	rgb, err := ParseColor(color)
+	if err != nil {
+		return false, err
+	}
+	for i := range rgb {
+		if abs(rgb[i]-c.color[i]) > c.tolerance {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (c *pixelCondition) String() string {
+	s := fmt.Sprintf("pixel:%d,%d=#%02x%02x%02x", c.x, c.y, c.color[0], c.color[1], c.color[2])
+	if c.tolerance > 0 {
+		s += fmt.Sprintf("~%d", c.tolerance)
+	}
+	return s
+}
+
+// ParseColor 解析#rrggbb或rrggbb格式的颜色
+func ParseColor(color string) ([3]int, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(color), "#")
+	if len(hex) != 6 {
+		return [3]int{}, core.NewError(core.ErrInvalidArgument, fmt.Sprintf("invalid color %q, want #rrggbb", color), nil)
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return [3]int{}, core.NewError(core.ErrInvalidArgument, fmt.Sprintf("invalid color %q, want #rrggbb", color), err)
+	}
+	return [3]int{int(value >> 16 & 0xff), int(value >> 8 & 0xff), int(value & 0xff)}, nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// ===== 屏幕静止 =====
+
+type stableCondition struct {
+	duration time.Duration
+	last     uint64    // 上一次截图的哈希
+	since    time.Time // 截图最近一次变化的时间
+}
+
+// ScreenStable 屏幕内容持续duration没有变化（如页面加载完成、动画结束）
+func ScreenStable(duration time.Duration) Condition {
+	return &stableCondition{duration: duration}
+}
+
+func (c *stableCondition) reset() {
+	c.last = 0
+	c.since = time.Time{}
+}
+
+func (c *stableCondition) Check(ctx context.Context, probe Probe) (bool, error) {
+	_, result := probe.ScreenshotContext(ctx)
+	capture, ok := core.CaptureFromResult(result)
+	if !ok {
+		if err := result.Err(); err != nil {
+			return false, err
+		}
+		return false, core.NewError(core.ErrUnknown, "screenshot returned no image", nil)
+	}
+
+	h := fnv.New64a()
+	h.Write(capture.ImageData)
+	sum := h.Sum64()
+
+	now := time.Now()
+	if c.since.IsZero() || sum != c.last {
+		c.last = sum
+		c.since = now
+	}
+	return now.Sub(c.since) >= c.duration, nil
+}
+
+func (c *stableCondition) String() string {
+	return fmt.Sprintf("stable:%d", c.duration.Milliseconds())
+}
+
+// ===== 组合 =====
+
+type allCondition struct {
+	conditions []Condition
+}
+
+// All 所有条件同时满足
+func All(conditions ...Condition) Condition {
+	return &allCondition{conditions: conditions}
+}
+
+func (c *allCondition) reset() {
+	for _, condition := range c.conditions {
+		reset(condition)
+	}
+}
+
+func (c *allCondition) Check(ctx context.Context, probe Probe) (bool, error) {
+	for _, condition := range c.conditions {
+		ok, err := condition.Check(ctx, probe)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (c *allCondition) String() string {
+	return join(c.conditions, " && ")
+}
+
+type anyCondition struct {
+	conditions []Condition
+}
+
+// Any 任一条件满足
+// 某个条件暂时无法判断时继续检查其余条件，都不满足时才返回该错误
+func Any(conditions ...Condition) Condition {
+	return &anyCondition{conditions: conditions}
+}
+
+func (c *anyCondition) reset() {
+	for _, condition := range c.conditions {
+		reset(condition)
+	}
+}
+
+func (c *anyCondition) Check(ctx context.Context, probe Probe) (bool, error) {
+	var firstErr error
+	for _, condition := range c.conditions {
+		ok, err := condition.Check(ctx, probe)
+		if ok {
+			return true, nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return false, firstErr
+}
+
+func (c *anyCondition) String() string {
+	return join(c.conditions, " || ")
+}
+
+type notCondition struct {
+	condition Condition
+}
+
+// Not 条件不满足
+func Not(condition Condition) Condition {
+	return &notCondition{condition: condition}
+}
+
+func (c *notCondition) reset() {
+	reset(c.condition)
+}
+
+func (c *notCondition) Check(ctx context.Context, probe Probe) (bool, error) {
+	ok, err := c.condition.Check(ctx, probe)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+func (c *notCondition) String() string {
+	switch c.condition.(type) {
+	case *allCondition, *anyCondition:
+		return "!(" + c.condition.String() + ")"
+	}
+	return "!" + c.condition.String()
+}
+
+// join 拼接子条件，组合条件加括号以保持优先级
+func join(conditions []Condition, separator string) string {
+	parts := make([]string, len(conditions))
+	for i, condition := range conditions {
+		parts[i] = condition.String()
+		switch condition.(type) {
+		case *allCondition, *anyCondition:
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, separator)
+}
+
+// RequiredOperations 判断条件需要引擎支持的操作（去重，按出现顺序）
+func RequiredOperations(condition Condition) []core.Operation {
+	var operations []core.Operation
+	seen := make(map[core.Operation]bool)
+	add := func(operation core.Operation) {
+		if !seen[operation] {
+			seen[operation] = true
+			operations = append(operations, operation)
+		}
+	}
+
+	var walk func(Condition)
+	walk = func(condition Condition) {
+		switch c := condition.(type) {
+		case *imageCondition:
+			add(core.OpFindImage)
+		case *textCondition:
+			add(core.OpFindText)
+		case *windowCondition:
+			add(core.OpGetWindows)
+		case *pixelCondition:
+			add(core.OpGetPixelColor)
+		case *stableCondition:
+			add(core.OpScreenshot)
+		case *allCondition:
+			for _, child := range c.conditions {
+				walk(child)
+			}
+		case *anyCondition:
+			for _, child := range c.conditions {
+				walk(child)
+			}
+		case *notCondition:
+			walk(c.condition)
+		}
+	}
+	walk(condition)
+	return operations
+}
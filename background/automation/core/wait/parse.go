@@ -0,0 +1,248 @@
+package wait
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"diandian/background/automation/core"
+)
+
+// Parse 解析等待条件表达式
+//
+// 基本条件：
+//
+//	window:另存为          标题包含“另存为”的窗口存在
+//	text:保存              屏幕上出现文字“保存”
+//	image:C:\icons\ok.png  屏幕上出现模板图像
+//	pixel:100,200=#ffffff  像素颜色匹配，可加 ~10 表示每个通道允许的误差
+//	stable:1000            屏幕持续1000毫秒没有变化（也可写作 stable:1s）
+//
+// 条件可以用 !（不满足，如 !window:正在保存 表示窗口消失）、&&、|| 和括号组合，&& 优先于 ||。
+// 参数中包含 &、|、( 或 ) 时用双引号括起来，如 window:"保存 (2)"。
+func Parse(expr string) (Condition, error) {
+	p := &parser{input: []rune(expr)}
+	condition, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", string(p.input[p.pos:]))
+	}
+	return condition, nil
+}
+
+// parser 条件表达式的递归下降解析器
+type parser struct {
+	input []rune
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return core.NewError(core.ErrInvalidArgument,
+		fmt.Sprintf("invalid wait condition %q at %d: %s", string(p.input), p.pos, fmt.Sprintf(format, args...)), nil)
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// consume 跳过空白后如果接下来是token则读取它
+func (p *parser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(string(p.input[p.pos:]), token) {
+		p.pos += len([]rune(token))
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (Condition, error) {
+	var conditions []Condition
+	for {
+		condition, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		if !p.consume("||") {
+			break
+		}
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return Any(conditions...), nil
+}
+
+func (p *parser) parseAnd() (Condition, error) {
+	var conditions []Condition
+	for {
+		condition, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		if !p.consume("&&") {
+			break
+		}
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return All(conditions...), nil
+}
+
+func (p *parser) parseUnary() (Condition, error) {
+	if p.consume("!") {
+		condition, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(condition), nil
+	}
+	if p.consume("(") {
+		condition, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing )")
+		}
+		return condition, nil
+	}
+	return p.parseAtom()
+}
+
+// parseAtom 解析 kind:argument 形式的基本条件
+func (p *parser) parseAtom() (Condition, error) {
+	p.skipSpace()
+	start := p.pos
+	for !p.done() && p.input[p.pos] != ':' {
+		p.pos++
+	}
+	if p.done() {
+		return nil, p.errorf("expected kind:argument")
+	}
+	kind := strings.ToLower(strings.TrimSpace(string(p.input[start:p.pos])))
+	p.pos++ // 跳过冒号
+
+	arg, err := p.parseArgument()
+	if err != nil {
+		return nil, err
+	}
+	if arg == "" {
+		return nil, p.errorf("%s condition needs an argument", kind)
+	}
+
+	switch kind {
+	case "image":
+		return ImageVisible(arg), nil
+	case "text":
+		return TextVisible(arg), nil
+	case "window":
+		return WindowExists(arg), nil
+	case "pixel":
+		return parsePixel(arg)
+	case "stable":
+		duration, err := parseDuration(arg)
+		if err != nil {
+			return nil, p.errorf("invalid duration %q", arg)
+		}
+		return ScreenStable(duration), nil
+	default:
+		return nil, p.errorf("unknown condition kind %q", kind)
+	}
+}
+
+// parseArgument 读取参数：双引号字符串，或直到 &&、|| 或 ) 之前的文本
+func (p *parser) parseArgument() (string, error) {
+	p.skipSpace()
+	if !p.done() && p.input[p.pos] == '"' {
+		start := p.pos
+		p.pos++
+		for !p.done() && p.input[p.pos] != '"' {
+			if p.input[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.done() {
+			return "", p.errorf("unterminated string")
+		}
+		p.pos++
+		arg, err := strconv.Unquote(string(p.input[start:p.pos]))
+		if err != nil {
+			return "", p.errorf("invalid string %s", string(p.input[start:p.pos]))
+		}
+		return arg, nil
+	}
+
+	start := p.pos
+	for !p.done() {
+		rest := string(p.input[p.pos:])
+		if p.input[p.pos] == ')' || strings.HasPrefix(rest, "&&") || strings.HasPrefix(rest, "||") {
+			break
+		}
+		p.pos++
+	}
+	return strings.TrimSpace(string(p.input[start:p.pos])), nil
+}
+
+// parsePixel 解析 x,y=#rrggbb[~tolerance]
+func parsePixel(arg string) (Condition, error) {
+	invalid := core.NewError(core.ErrInvalidArgument, fmt.Sprintf("invalid pixel condition %q, want x,y=#rrggbb[~tolerance]", arg), nil)
+
+	position, color, ok := strings.Cut(arg, "=")
+	if !ok {
+		return nil, invalid
+	}
+	xs, ys, ok := strings.Cut(position, ",")
+	if !ok {
+		return nil, invalid
+	}
+	x, errX := strconv.Atoi(strings.TrimSpace(xs))
+	y, errY := strconv.Atoi(strings.TrimSpace(ys))
+	if errX != nil || errY != nil {
+		return nil, invalid
+	}
+
+	tolerance := 0
+	if c, t, ok := strings.Cut(color, "~"); ok {
+		value, err := strconv.Atoi(strings.TrimSpace(t))
+		if err != nil || value < 0 {
+			return nil, invalid
+		}
+		color, tolerance = c, value
+	}
+	return PixelColor(x, y, color, tolerance)
+}
+
+// parseDuration 解析毫秒数或Go的时长格式（如1s、800ms）
+func parseDuration(s string) (time.Duration, error) {
+	if ms, err := strconv.Atoi(s); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return duration, nil
+}
+
+// quote 参数包含表达式中的特殊字符时加引号
+func quote(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, "&|()\"") || strings.TrimSpace(arg) != arg {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
@@ -0,0 +1,141 @@
+package wait
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"window:另存为", "window:另存为"},
+		{"  text: 保存  ", "text:保存"},
+		{`image:C:\icons\ok.png`, `image:C:\icons\ok.png`},
+		{"pixel:100, 200=#FFFFFF", "pixel:100,200=#ffffff"},
+		{"pixel:1,2=00ff00~10", "pixel:1,2=#00ff00~10"},
+		{"stable:1s", "stable:1000"},
+		{"stable:800", "stable:800"},
+		{"!window:正在保存", "!window:正在保存"},
+		{"window:a && text:b || text:c", "(window:a && text:b) || text:c"},
+		{"window:a && (text:b || text:c)", "window:a && (text:b || text:c)"},
+		{"!(window:a || window:b)", "!(window:a || window:b)"},
+		{`window:"保存 (2)" && text:"a&&b"`, `window:"保存 (2)" && text:"a&&b"`},
+	}
+	for _, tt := range tests {
+		condition, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) 出错: %v", tt.expr, err)
+			continue
+		}
+		if got := condition.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %q，期望 %q", tt.expr, got, tt.want)
+		}
+		// 输出的表达式可以再次解析为相同的条件
+		again, err := Parse(condition.String())
+		if err != nil || again.String() != condition.String() {
+			t.Errorf("重新解析 %q 得到 %v, %v", condition.String(), again, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"window",
+		"window:",
+		"button:ok",
+		"(window:a",
+		"window:a)",
+		`text:"未结束`,
+		"pixel:1,2",
+		"pixel:a,b=#ffffff",
+		"pixel:1,2=#ffffff~-1",
+		"pixel:1,2=#zzzzzz",
+		"stable:soon",
+		"window:a &&",
+	} {
+		_, err := Parse(expr)
+		if err == nil {
+			t.Errorf("Parse(%q) 应该返回错误", expr)
+			continue
+		}
+		if core.CodeOf(err) != core.ErrInvalidArgument {
+			t.Errorf("Parse(%q) 错误码 = %s，期望 invalid_argument", expr, core.CodeOf(err))
+		}
+	}
+}
+
+// fakeProbe 返回固定窗口列表的探测器，每次查询后执行onPoll
+type fakeProbe struct {
+	windows []*core.WindowInfo
+	onPoll  func(p *fakeProbe)
+}
+
+func (p *fakeProbe) FindImageContext(ctx context.Context, templatePath string) (*core.Point, *core.OperationResult) {
+	return nil, core.NewErrorResult("not found", core.NewError(core.ErrNotFound, templatePath, nil))
+}
+
+func (p *fakeProbe) FindTextContext(ctx context.Context, text string) (*core.Point, *core.OperationResult) {
+	return nil, core.NewErrorResult("not found", core.NewError(core.ErrNotFound, text, nil))
+}
+
+func (p *fakeProbe) GetWindowsContext(ctx context.Context) ([]*core.WindowInfo, *core.OperationResult) {
+	windows := p.windows
+	if p.onPoll != nil {
+		p.onPoll(p)
+	}
+	return windows, core.NewSuccessResult("windows", nil)
+}
+
+func (p *fakeProbe) GetPixelColorContext(ctx context.Context, x, y int) (string, *core.OperationResult) {
+	return "#fafafa", core.NewSuccessResult("pixel", nil)
+}
+
+func (p *fakeProbe) ScreenshotContext(ctx context.Context) ([]byte, *core.OperationResult) {
+	return nil, core.NewErrorResult("no screen", core.NewError(core.ErrUnsupported, "no screen", nil))
+}
+
+func TestUntilWindowGone(t *testing.T) {
+	probe := &fakeProbe{
+		windows: []*core.WindowInfo{{Title: "正在保存..."}, {Title: "记事本"}},
+	}
+	polls := 0
+	probe.onPoll = func(p *fakeProbe) {
+		polls++
+		if polls == 3 {
+			p.windows = p.windows[1:]
+		}
+	}
+
+	condition, err := Parse("!window:正在保存 && pixel:0,0=#ffffff~5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := Until(context.Background(), probe, condition, Options{Timeout: time.Second, Interval: time.Millisecond})
+	if !result.Success {
+		t.Fatalf("等待失败: %s", result.Error)
+	}
+	if got := result.Data.(map[string]interface{})["polls"]; got != 4 {
+		t.Errorf("检查次数 = %v，期望 4", got)
+	}
+}
+
+func TestUntilTimeoutAndUnsupported(t *testing.T) {
+	probe := &fakeProbe{windows: []*core.WindowInfo{{Title: "记事本"}}}
+	options := Options{Timeout: 20 * time.Millisecond, Interval: time.Millisecond}
+
+	result := Until(context.Background(), probe, WindowExists("另存为"), options)
+	if result.Success || result.Code != core.ErrTimeout {
+		t.Errorf("条件一直不满足时 = %v %s，期望 timeout", result.Success, result.Code)
+	}
+
+	result = Until(context.Background(), probe, ScreenStable(10*time.Millisecond), options)
+	if result.Success || result.Code != core.ErrUnsupported {
+		t.Errorf("无法截图时 = %v %s，期望 unsupported", result.Success, result.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// Options 等待选项
+type Options struct {
+	Timeout  time.Duration // 最长等待时间
+	Interval time.Duration // 两次检查之间的间隔
+}
+
+// DefaultOptions 默认等待选项：最多等待10秒，每500毫秒检查一次
+func DefaultOptions() Options {
+	return Options{
+		Timeout:  10 * time.Second,
+		Interval: 500 * time.Millisecond,
+	}
+}
+
+// Until 轮询直到条件满足、超时或ctx取消
+// 超时返回timeout错误；条件检查返回不可重试的错误时（如模板文件不存在、没有OCR后端）立即失败
+func Until(ctx context.Context, probe Probe, condition Condition, options Options) *core.OperationResult {
+	start := time.Now()
+	defaults := DefaultOptions()
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaults.Interval
+	}
+
+	reset(condition)
+	deadline := start.Add(options.Timeout)
+	polls := 0
+	var lastErr error
+	for {
+		if result := core.CheckContext(ctx); result != nil {
+			result.SetDuration(start)
+			return result
+		}
+
+		polls++
+		ok, err := condition.Check(ctx, probe)
+		switch {
+		case ok:
+			result := core.NewSuccessResult(
+				fmt.Sprintf("condition %s met after %d checks", condition, polls),
+				map[string]interface{}{
+					"condition":  condition.String(),
+					"polls":      polls,
+					"elapsed_ms": time.Since(start).Milliseconds(),
+				})
+			result.SetDuration(start)
+			return result
+		case err != nil && ctx.Err() != nil:
+			result := core.NewCancelledResult(ctx)
+			result.SetDuration(start)
+			return result
+		case err != nil && !core.IsRetryable(err):
+			result := core.NewErrorResult(fmt.Sprintf("cannot check condition %s", condition), err)
+			result.SetDuration(start)
+			return result
+		case err != nil:
+			lastErr = err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			result := core.NewErrorResult("wait timed out",
+				core.NewError(core.ErrTimeout, fmt.Sprintf("condition %s not met within %v", condition, options.Timeout), lastErr))
+			result.SetDuration(start)
+			return result
+		}
+		if err := core.Sleep(ctx, min(options.Interval, remaining)); err != nil {
+			result := core.NewCancelledResult(ctx)
+			result.SetDuration(start)
+			return result
+		}
+	}
+}
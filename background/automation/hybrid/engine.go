@@ -1,33 +1,73 @@
 package hybrid
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"image"
+	"log/slog"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"diandian/background/automation/core"
+	"diandian/background/automation/core/imagematch"
+	"diandian/background/automation/core/ocr"
+	"diandian/background/automation/core/wait"
 )
 
 // HybridEngine 混合自动化引擎
-// 优先使用纯Go实现，必要时回退到外部程序
+// 实现完整的core.AutomationEngine：每个操作按优先级路由到支持它的后端，
+// 某个后端失败时自动尝试下一个（纯Go → 外部worker → 附加后端，如legacy实现）
 type HybridEngine struct {
-	pureGoEngine   AutomationEngine
-	externalEngine AutomationEngine
+	pureGoEngine   *PureGoEngine
+	externalEngine *ExternalEngine
+	extraBackends  []*backend
 	preferPureGo   bool
+
+	mu     sync.Mutex
+	routes map[core.Operation]string // 每个操作最近一次成功使用的后端
+	ocr    core.OCRProvider          // 文字识别后端，为nil时FindText路由到后端自身的实现
 }
 
-// AutomationEngine 自动化引擎接口
+// AutomationEngine 后端引擎的最小接口
+// 后端只需实现自己支持的core.AutomationEngine方法，混合引擎按方法签名判断是否支持；
+// 同时实现XxxContext方法的后端会优先以context方式调用
 type AutomationEngine interface {
-	Click(x, y int) *core.OperationResult
-	Type(text string) *core.OperationResult
-	KeyPress(key string) *core.OperationResult
-	Screenshot() *core.OperationResult
 	IsAvailable() bool
 }
 
+// backend 已注册的后端
+type backend struct {
+	name   string
+	engine interface{}
+}
+
+// available 后端是否可用（未实现IsAvailable的后端视为始终可用）
+func (b *backend) available() bool {
+	if checker, ok := b.engine.(AutomationEngine); ok {
+		return checker.IsAvailable()
+	}
+	return true
+}
+
+var (
+	// errNoBackend 没有后端支持该操作
+	errNoBackend = core.NewError(core.ErrUnsupported, "no backend supports this operation", nil)
+	// errBackendUnavailable 可用的后端都不支持该操作，且有后端不可用
+	errBackendUnavailable = core.NewError(core.ErrEngineUnavailable, "no backend is available", nil)
+)
+
+// 确保HybridEngine实现了完整的自动化引擎接口
+var _ core.ContextAutomationEngine = (*HybridEngine)(nil)
+
 // NewHybridEngine 创建混合引擎
 func NewHybridEngine() (*HybridEngine, error) {
 	engine := &HybridEngine{
 		preferPureGo: true,
+		routes:       make(map[core.Operation]string),
 	}
 
 	// 初始化纯Go引擎
@@ -47,92 +87,1633 @@ func NewHybridEngine() (*HybridEngine, error) {
 		return nil, fmt.Errorf("no available automation engine")
 	}
 
+	// 本机安装了tesseract时默认用它识别文字
+	if tesseract := ocr.NewTesseract(ocr.DefaultTesseractConfig()); tesseract.Available() {
+		engine.ocr = tesseract
+	}
+
 	return engine, nil
 }
 
-// getEngine 获取当前应该使用的引擎
-func (h *HybridEngine) getEngine() AutomationEngine {
-	if h.preferPureGo && h.pureGoEngine != nil && h.pureGoEngine.IsAvailable() {
-		return h.pureGoEngine
+// NewHybridEngineWithBackend 创建只使用指定后端的混合引擎
+// 不探测纯Go引擎和外部worker，用于在虚拟桌面等替身引擎上运行完整的路由逻辑
+func NewHybridEngineWithBackend(name string, engine interface{}) *HybridEngine {
+	h := &HybridEngine{
+		preferPureGo: true,
+		routes:       make(map[core.Operation]string),
+	}
+	h.AddBackend(name, engine)
+	return h
+}
+
+// AddBackend 注册附加后端，优先级低于纯Go引擎和外部worker
+// backend可以只实现core.AutomationEngine的部分方法（例如只实现FileOperator）
+func (h *HybridEngine) AddBackend(name string, engine interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.extraBackends = append(h.extraBackends, &backend{name: name, engine: engine})
+}
+
+// SetOCRProvider 设置文字识别后端，设置后FindText通过截图和文字识别实现
+func (h *HybridEngine) SetOCRProvider(provider core.OCRProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ocr = provider
+}
+
+// OCRProvider 当前的文字识别后端
+func (h *HybridEngine) OCRProvider() core.OCRProvider {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ocr
+}
+
+// backends 按当前优先级返回后端列表
+func (h *HybridEngine) backends() []*backend {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var pureGo, external *backend
+	if h.pureGoEngine != nil {
+		pureGo = &backend{name: "pure_go", engine: h.pureGoEngine}
 	}
-	if h.externalEngine != nil && h.externalEngine.IsAvailable() {
-		return h.externalEngine
+	if h.externalEngine != nil {
+		external = &backend{name: "external", engine: h.externalEngine}
 	}
-	// 如果首选不可用，尝试另一个
-	if h.pureGoEngine != nil && h.pureGoEngine.IsAvailable() {
-		return h.pureGoEngine
+
+	list := make([]*backend, 0, 2+len(h.extraBackends))
+	if h.preferPureGo {
+		list = appendBackend(list, pureGo, external)
+	} else {
+		list = appendBackend(list, external, pureGo)
 	}
-	return nil
+	return append(list, h.extraBackends...)
 }
 
-// Click 点击操作
-func (h *HybridEngine) Click(x, y int) *core.OperationResult {
-	engine := h.getEngine()
-	if engine == nil {
-		return core.NewErrorResult("no available engine for click operation", fmt.Errorf("no engine"))
+func appendBackend(list []*backend, backends ...*backend) []*backend {
+	for _, b := range backends {
+		if b != nil {
+			list = append(list, b)
+		}
 	}
-	return engine.Click(x, y)
+	return list
+}
+
+// route 将操作路由到第一个支持它且执行成功的后端
+// call返回false表示后端未实现该操作；只读操作失败后总是尝试下一个后端，
+// 其他操作只在后端不支持或不可用时回退，超时、部分执行等失败直接返回，
+// 避免输入文字、移动文件等不可重复的操作被执行两次；ctx取消后不再回退
+func route(h *HybridEngine, ctx context.Context, operation core.Operation, call func(engine interface{}) (*core.OperationResult, bool)) *core.OperationResult {
+	start := time.Now()
+
+	var failures []string
+	var lastResult *core.OperationResult
+	retryable := false
+	unavailable := false
+	for _, b := range h.backends() {
+		if result := core.CheckContext(ctx); result != nil {
+			result.SetDuration(start)
+			return result
+		}
+		if !b.available() {
+			unavailable = true
+			continue
+		}
+
+		result, ok := call(b.engine)
+		if !ok {
+			continue
+		}
+		if result.Success {
+			h.recordRoute(operation, b.name)
+			return result
+		}
+
+		lastResult = result
+		retryable = retryable || result.Retryable
+		failures = append(failures, fmt.Sprintf("%s: %s", b.name, describeFailure(result)))
+		if ctx.Err() != nil || !canFallback(operation, result) {
+			if len(failures) > 1 {
+				break
+			}
+			return result
+		}
+		slog.Warn("automation backend failed, trying next",
+			"operation", operation,
+			"backend", b.name,
+			"error", describeFailure(result))
+	}
+
+	if lastResult == nil {
+		err := errNoBackend
+		if unavailable {
+			// 存在不可用的后端时无法确定该操作是否被支持，按引擎不可用处理（可重试）
+			err = errBackendUnavailable
+		}
+		result := core.NewErrorResult(fmt.Sprintf("no available engine for %s operation", operation), err)
+		result.SetDuration(start)
+		return result
+	}
+	if len(failures) == 1 {
+		return lastResult
+	}
+
+	// 错误码取最后一个后端的失败原因，任一后端的失败可重试时整体可重试
+	err := core.NewError(lastResult.Code, strings.Join(failures, "; "), nil)
+	err.Retryable = retryable
+	result := core.NewErrorResult(fmt.Sprintf("%s failed on all engines", operation), err)
+	result.SetDuration(start)
+	return result
+}
+
+// canFallback 失败后是否可以换下一个后端执行
+// 只读操作重复执行没有副作用，任何失败都可以回退；
+// 其他操作只有确定后端没有执行（不支持或不可用）时才回退
+func canFallback(operation core.Operation, result *core.OperationResult) bool {
+	if operation.ReadOnly() {
+		return true
+	}
+	return result.Code == core.ErrUnsupported || result.Code == core.ErrEngineUnavailable
+}
+
+// describeFailure 获取失败结果的描述
+func describeFailure(result *core.OperationResult) string {
+	if result.Error != "" {
+		return result.Error
+	}
+	return result.Message
+}
+
+// recordRoute 记录操作使用的后端
+func (h *HybridEngine) recordRoute(operation core.Operation, name string) {
+	h.mu.Lock()
+	h.routes[operation] = name
+	h.mu.Unlock()
+}
+
+// shortcutModifier 平台的快捷键修饰键（macOS为Command，其余为Ctrl）
+func shortcutModifier() core.KeyModifier {
+	if runtime.GOOS == "darwin" {
+		return core.ModWin
+	}
+	return core.ModCtrl
+}
+
+// Initialize 初始化引擎
+func (h *HybridEngine) Initialize() *core.OperationResult {
+	return core.NewSuccessResult("hybrid engine initialized", h.GetEngineInfo())
+}
+
+// Cleanup 清理资源
+func (h *HybridEngine) Cleanup() *core.OperationResult {
+	start := time.Now()
+	if err := h.Close(); err != nil {
+		result := core.NewErrorResult("failed to cleanup hybrid engine", err)
+		result.SetDuration(start)
+		return result
+	}
+	result := core.NewSuccessResult("hybrid engine cleaned up", nil)
+	result.SetDuration(start)
+	return result
+}
+
+// Wait 等待指定毫秒数
+func (h *HybridEngine) Wait(duration int) *core.OperationResult {
+	return h.WaitContext(context.Background(), duration)
+}
+
+// WaitContext 等待指定毫秒数，ctx取消时立即返回
+func (h *HybridEngine) WaitContext(ctx context.Context, duration int) *core.OperationResult {
+	start := time.Now()
+	if err := core.Sleep(ctx, time.Duration(duration)*time.Millisecond); err != nil {
+		result := core.NewCancelledResult(ctx)
+		result.SetDuration(start)
+		return result
+	}
+	result := core.NewSuccessResult(
+		fmt.Sprintf("waited %d ms", duration),
+		map[string]interface{}{
+			"duration_ms": duration,
+		},
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// ===== 鼠标操作 =====
+
+// Click 点击操作
+func (h *HybridEngine) Click(x, y int, button core.MouseButton) *core.OperationResult {
+	return h.ClickContext(context.Background(), x, y, button)
+}
+
+// ClickContext 支持取消的点击操作
+func (h *HybridEngine) ClickContext(ctx context.Context, x, y int, button core.MouseButton) *core.OperationResult {
+	return route(h, ctx, core.OpClick, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			ClickContext(context.Context, int, int, core.MouseButton) *core.OperationResult
+		}:
+			return e.ClickContext(ctx, x, y, button), true
+		case interface {
+			Click(int, int, core.MouseButton) *core.OperationResult
+		}:
+			return e.Click(x, y, button), true
+		}
+		return nil, false
+	})
+}
+
+// DoubleClick 双击
+func (h *HybridEngine) DoubleClick(x, y int) *core.OperationResult {
+	return h.DoubleClickContext(context.Background(), x, y)
+}
+
+// DoubleClickContext 支持取消的双击
+func (h *HybridEngine) DoubleClickContext(ctx context.Context, x, y int) *core.OperationResult {
+	return route(h, ctx, core.OpDoubleClick, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			DoubleClickContext(context.Context, int, int) *core.OperationResult
+		}:
+			return e.DoubleClickContext(ctx, x, y), true
+		case interface {
+			DoubleClick(int, int) *core.OperationResult
+		}:
+			return e.DoubleClick(x, y), true
+		}
+		return nil, false
+	})
+}
+
+// RightClick 右键点击
+func (h *HybridEngine) RightClick(x, y int) *core.OperationResult {
+	return h.RightClickContext(context.Background(), x, y)
+}
+
+// RightClickContext 支持取消的右键点击
+func (h *HybridEngine) RightClickContext(ctx context.Context, x, y int) *core.OperationResult {
+	return route(h, ctx, core.OpRightClick, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			RightClickContext(context.Context, int, int) *core.OperationResult
+		}:
+			return e.RightClickContext(ctx, x, y), true
+		case interface {
+			RightClick(int, int) *core.OperationResult
+		}:
+			return e.RightClick(x, y), true
+		}
+		return nil, false
+	})
+}
+
+// Drag 拖拽
+func (h *HybridEngine) Drag(fromX, fromY, toX, toY int) *core.OperationResult {
+	return h.DragContext(context.Background(), fromX, fromY, toX, toY)
+}
+
+// DragContext 支持取消的拖拽
+func (h *HybridEngine) DragContext(ctx context.Context, fromX, fromY, toX, toY int) *core.OperationResult {
+	return route(h, ctx, core.OpDrag, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			DragContext(context.Context, int, int, int, int) *core.OperationResult
+		}:
+			return e.DragContext(ctx, fromX, fromY, toX, toY), true
+		case interface {
+			Drag(int, int, int, int) *core.OperationResult
+		}:
+			return e.Drag(fromX, fromY, toX, toY), true
+		}
+		return nil, false
+	})
+}
+
+// Move 移动鼠标
+func (h *HybridEngine) Move(x, y int) *core.OperationResult {
+	return h.MoveContext(context.Background(), x, y)
+}
+
+// MoveContext 支持取消的移动鼠标
+func (h *HybridEngine) MoveContext(ctx context.Context, x, y int) *core.OperationResult {
+	return route(h, ctx, core.OpMove, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			MoveContext(context.Context, int, int) *core.OperationResult
+		}:
+			return e.MoveContext(ctx, x, y), true
+		case interface {
+			Move(int, int) *core.OperationResult
+		}:
+			return e.Move(x, y), true
+		}
+		return nil, false
+	})
+}
+
+// GetPosition 获取鼠标位置
+func (h *HybridEngine) GetPosition() (*core.Point, *core.OperationResult) {
+	return h.GetPositionContext(context.Background())
 }
 
+// GetPositionContext 支持取消的获取鼠标位置
+func (h *HybridEngine) GetPositionContext(ctx context.Context) (*core.Point, *core.OperationResult) {
+	var point *core.Point
+	result := route(h, ctx, core.OpGetPosition, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetPositionContext(context.Context) (*core.Point, *core.OperationResult)
+		}:
+			value, result := e.GetPositionContext(ctx)
+			point = value
+			return result, true
+		case interface {
+			GetPosition() (*core.Point, *core.OperationResult)
+		}:
+			value, result := e.GetPosition()
+			point = value
+			return result, true
+		}
+		return nil, false
+	})
+	return point, result
+}
+
+// Scroll 滚动
+func (h *HybridEngine) Scroll(x, y int, direction string, clicks int) *core.OperationResult {
+	return h.ScrollContext(context.Background(), x, y, direction, clicks)
+}
+
+// ScrollContext 支持取消的滚动
+func (h *HybridEngine) ScrollContext(ctx context.Context, x, y int, direction string, clicks int) *core.OperationResult {
+	return route(h, ctx, core.OpScroll, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			ScrollContext(context.Context, int, int, string, int) *core.OperationResult
+		}:
+			return e.ScrollContext(ctx, x, y, direction, clicks), true
+		case interface {
+			Scroll(int, int, string, int) *core.OperationResult
+		}:
+			return e.Scroll(x, y, direction, clicks), true
+		}
+		return nil, false
+	})
+}
+
+// ===== 键盘操作 =====
+
 // Type 输入文本
 func (h *HybridEngine) Type(text string) *core.OperationResult {
-	engine := h.getEngine()
-	if engine == nil {
-		return core.NewErrorResult("no available engine for type operation", fmt.Errorf("no engine"))
+	return h.TypeContext(context.Background(), text)
+}
+
+// TypeContext 支持取消的输入文本，使用默认输入选项
+func (h *HybridEngine) TypeContext(ctx context.Context, text string) *core.OperationResult {
+	return h.TypeWithOptionsContext(ctx, text, core.DefaultTypeOptions())
+}
+
+// TypeWithOptions 按指定策略输入文本
+func (h *HybridEngine) TypeWithOptions(text string, options core.TypeOptions) *core.OperationResult {
+	return h.TypeWithOptionsContext(context.Background(), text, options)
+}
+
+// TypeWithOptionsContext 按指定策略输入文本，结果的Data中记录实际使用的策略
+// auto策略下逐键输入不被任何后端支持时（例如纯Go引擎遇到标点）改用粘贴
+func (h *HybridEngine) TypeWithOptionsContext(ctx context.Context, text string, options core.TypeOptions) *core.OperationResult {
+	strategy := options.Resolve(text)
+	if strategy == core.TypeStrategyPaste {
+		return h.pasteText(ctx, text, options)
+	}
+
+	result := h.typeKeys(ctx, text)
+	if !result.Success && result.Code == core.ErrUnsupported && options.Strategy != core.TypeStrategyKeys && ctx.Err() == nil {
+		slog.Info("keyboard typing unsupported, falling back to paste", "error", describeFailure(result))
+		return h.pasteText(ctx, text, options)
+	}
+	return withStrategy(result, core.TypeStrategyKeys)
+}
+
+// withStrategy 在结果的Data中记录输入策略
+func withStrategy(result *core.OperationResult, strategy core.TypeStrategy) *core.OperationResult {
+	data, ok := result.Data.(map[string]interface{})
+	if !ok {
+		data = make(map[string]interface{})
+		if result.Data != nil {
+			data["result"] = result.Data
+		}
 	}
-	return engine.Type(text)
+	data["strategy"] = strategy
+	result.Data = data
+	return result
+}
+
+// pasteText 通过剪贴板粘贴输入文本，按选项在粘贴后恢复原剪贴板内容
+// 恢复剪贴板不受ctx取消影响，避免任务被停止时丢失用户的剪贴板内容
+func (h *HybridEngine) pasteText(ctx context.Context, text string, options core.TypeOptions) *core.OperationResult {
+	start := time.Now()
+
+	var original string
+	saved := false
+	if options.RestoreClipboard {
+		value, result := h.GetClipboardContext(ctx)
+		if result.Success {
+			original, saved = value, true
+		} else if ctx.Err() != nil {
+			return result
+		} else {
+			slog.Warn("failed to save clipboard before paste", "error", describeFailure(result))
+		}
+	}
+
+	fail := func(message string, result *core.OperationResult) *core.OperationResult {
+		failed := core.NewErrorResult(message, result.Err())
+		failed.SetDuration(start)
+		return withStrategy(failed, core.TypeStrategyPaste)
+	}
+
+	if result := h.SetClipboardContext(ctx, text); !result.Success {
+		return fail("failed to put text on clipboard", result)
+	}
+
+	pasted := h.HotkeyContext(ctx, []core.KeyModifier{shortcutModifier()}, "v")
+	if pasted.Success {
+		// 目标程序处理粘贴键时才读取剪贴板，过早恢复会粘贴出原来的内容
+		core.Sleep(ctx, options.PasteDelay)
+	}
+
+	restored := false
+	if saved {
+		if result := h.SetClipboardContext(context.WithoutCancel(ctx), original); result.Success {
+			restored = true
+		} else {
+			slog.Warn("failed to restore clipboard after paste", "error", describeFailure(result))
+		}
+	}
+
+	if !pasted.Success {
+		return fail("failed to paste text", pasted)
+	}
+
+	result := core.NewSuccessResult(
+		fmt.Sprintf("pasted text: %s", text),
+		map[string]interface{}{
+			"text":               text,
+			"length":             len([]rune(text)),
+			"clipboard_restored": restored,
+		},
+	)
+	result.SetDuration(start)
+	return withStrategy(result, core.TypeStrategyPaste)
+}
+
+// typeKeys 由后端逐键输入文本
+func (h *HybridEngine) typeKeys(ctx context.Context, text string) *core.OperationResult {
+	return route(h, ctx, core.OpType, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			TypeContext(context.Context, string) *core.OperationResult
+		}:
+			return e.TypeContext(ctx, text), true
+		case interface {
+			Type(string) *core.OperationResult
+		}:
+			return e.Type(text), true
+		}
+		return nil, false
+	})
 }
 
 // KeyPress 按键操作
 func (h *HybridEngine) KeyPress(key string) *core.OperationResult {
-	engine := h.getEngine()
-	if engine == nil {
-		return core.NewErrorResult("no available engine for keypress operation", fmt.Errorf("no engine"))
+	return h.KeyPressContext(context.Background(), key)
+}
+
+// KeyPressContext 支持取消的按键操作
+func (h *HybridEngine) KeyPressContext(ctx context.Context, key string) *core.OperationResult {
+	return route(h, ctx, core.OpKeyPress, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			KeyPressContext(context.Context, string) *core.OperationResult
+		}:
+			return e.KeyPressContext(ctx, key), true
+		case interface {
+			KeyPress(string) *core.OperationResult
+		}:
+			return e.KeyPress(key), true
+		}
+		return nil, false
+	})
+}
+
+// KeyDown 按下按键
+func (h *HybridEngine) KeyDown(key string) *core.OperationResult {
+	return h.KeyDownContext(context.Background(), key)
+}
+
+// KeyDownContext 支持取消的按下按键
+func (h *HybridEngine) KeyDownContext(ctx context.Context, key string) *core.OperationResult {
+	return route(h, ctx, core.OpKeyDown, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			KeyDownContext(context.Context, string) *core.OperationResult
+		}:
+			return e.KeyDownContext(ctx, key), true
+		case interface {
+			KeyDown(string) *core.OperationResult
+		}:
+			return e.KeyDown(key), true
+		}
+		return nil, false
+	})
+}
+
+// KeyUp 释放按键
+func (h *HybridEngine) KeyUp(key string) *core.OperationResult {
+	return h.KeyUpContext(context.Background(), key)
+}
+
+// KeyUpContext 支持取消的释放按键
+func (h *HybridEngine) KeyUpContext(ctx context.Context, key string) *core.OperationResult {
+	return route(h, ctx, core.OpKeyUp, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			KeyUpContext(context.Context, string) *core.OperationResult
+		}:
+			return e.KeyUpContext(ctx, key), true
+		case interface {
+			KeyUp(string) *core.OperationResult
+		}:
+			return e.KeyUp(key), true
+		}
+		return nil, false
+	})
+}
+
+// Hotkey 组合键
+func (h *HybridEngine) Hotkey(modifiers []core.KeyModifier, key string) *core.OperationResult {
+	return h.HotkeyContext(context.Background(), modifiers, key)
+}
+
+// HotkeyContext 支持取消的组合键
+func (h *HybridEngine) HotkeyContext(ctx context.Context, modifiers []core.KeyModifier, key string) *core.OperationResult {
+	return route(h, ctx, core.OpHotkey, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			HotkeyContext(context.Context, []core.KeyModifier, string) *core.OperationResult
+		}:
+			return e.HotkeyContext(ctx, modifiers, key), true
+		case interface {
+			Hotkey([]core.KeyModifier, string) *core.OperationResult
+		}:
+			return e.Hotkey(modifiers, key), true
+		}
+		return nil, false
+	})
+}
+
+// Copy 复制（Ctrl+C / Cmd+C）
+func (h *HybridEngine) Copy() *core.OperationResult {
+	return h.Hotkey([]core.KeyModifier{shortcutModifier()}, "c")
+}
+
+// Paste 粘贴（Ctrl+V / Cmd+V）
+func (h *HybridEngine) Paste() *core.OperationResult {
+	return h.Hotkey([]core.KeyModifier{shortcutModifier()}, "v")
+}
+
+// SelectAll 全选（Ctrl+A / Cmd+A）
+func (h *HybridEngine) SelectAll() *core.OperationResult {
+	return h.Hotkey([]core.KeyModifier{shortcutModifier()}, "a")
+}
+
+// ===== 应用程序 =====
+
+// Launch 启动应用程序
+func (h *HybridEngine) Launch(appName string) *core.OperationResult {
+	return h.LaunchContext(context.Background(), appName)
+}
+
+// LaunchContext 支持取消的启动应用程序
+func (h *HybridEngine) LaunchContext(ctx context.Context, appName string) *core.OperationResult {
+	return route(h, ctx, core.OpLaunch, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			LaunchContext(context.Context, string) *core.OperationResult
+		}:
+			return e.LaunchContext(ctx, appName), true
+		case interface {
+			Launch(string) *core.OperationResult
+		}:
+			return e.Launch(appName), true
+		}
+		return nil, false
+	})
+}
+
+// LaunchWithPath 通过路径启动应用程序
+func (h *HybridEngine) LaunchWithPath(path string, args ...string) *core.OperationResult {
+	return h.LaunchWithPathContext(context.Background(), path, args...)
+}
+
+// LaunchWithPathContext 支持取消的通过路径启动应用程序
+func (h *HybridEngine) LaunchWithPathContext(ctx context.Context, path string, args ...string) *core.OperationResult {
+	return route(h, ctx, core.OpLaunchWithPath, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			LaunchWithPathContext(context.Context, string, ...string) *core.OperationResult
+		}:
+			return e.LaunchWithPathContext(ctx, path, args...), true
+		case interface {
+			LaunchWithPath(string, ...string) *core.OperationResult
+		}:
+			return e.LaunchWithPath(path, args...), true
+		}
+		return nil, false
+	})
+}
+
+// LaunchApp 启动预定义的应用程序
+func (h *HybridEngine) LaunchApp(app *core.AppInfo) *core.OperationResult {
+	return h.LaunchAppContext(context.Background(), app)
+}
+
+// LaunchAppContext 支持取消的启动预定义应用程序
+func (h *HybridEngine) LaunchAppContext(ctx context.Context, app *core.AppInfo) *core.OperationResult {
+	return route(h, ctx, core.OpLaunchApp, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			LaunchAppContext(context.Context, *core.AppInfo) *core.OperationResult
+		}:
+			return e.LaunchAppContext(ctx, app), true
+		case interface {
+			LaunchApp(*core.AppInfo) *core.OperationResult
+		}:
+			return e.LaunchApp(app), true
+		}
+		return nil, false
+	})
+}
+
+// GetInstalledApps 获取已安装的应用程序
+func (h *HybridEngine) GetInstalledApps() ([]*core.AppInfo, *core.OperationResult) {
+	var apps []*core.AppInfo
+	result := route(h, context.Background(), core.OpGetInstalledApps, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetInstalledApps() ([]*core.AppInfo, *core.OperationResult)
+		}:
+			value, result := e.GetInstalledApps()
+			apps = value
+			return result, true
+		}
+		return nil, false
+	})
+	return apps, result
+}
+
+// FindApp 查找应用程序
+func (h *HybridEngine) FindApp(name string) (*core.AppInfo, *core.OperationResult) {
+	var app *core.AppInfo
+	result := route(h, context.Background(), core.OpFindApp, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			FindApp(string) (*core.AppInfo, *core.OperationResult)
+		}:
+			value, result := e.FindApp(name)
+			app = value
+			return result, true
+		}
+		return nil, false
+	})
+	return app, result
+}
+
+// ===== 文件操作 =====
+
+// CreateFile 创建文件
+func (h *HybridEngine) CreateFile(path string, content []byte) *core.OperationResult {
+	return h.CreateFileContext(context.Background(), path, content)
+}
+
+// CreateFileContext 支持取消的创建文件
+func (h *HybridEngine) CreateFileContext(ctx context.Context, path string, content []byte) *core.OperationResult {
+	return route(h, ctx, core.OpCreateFile, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			CreateFileContext(context.Context, string, []byte) *core.OperationResult
+		}:
+			return e.CreateFileContext(ctx, path, content), true
+		case interface {
+			CreateFile(string, []byte) *core.OperationResult
+		}:
+			return e.CreateFile(path, content), true
+		}
+		return nil, false
+	})
+}
+
+// CreateDir 创建目录
+func (h *HybridEngine) CreateDir(path string) *core.OperationResult {
+	return h.CreateDirContext(context.Background(), path)
+}
+
+// CreateDirContext 支持取消的创建目录
+func (h *HybridEngine) CreateDirContext(ctx context.Context, path string) *core.OperationResult {
+	return route(h, ctx, core.OpCreateDir, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			CreateDirContext(context.Context, string) *core.OperationResult
+		}:
+			return e.CreateDirContext(ctx, path), true
+		case interface {
+			CreateDir(string) *core.OperationResult
+		}:
+			return e.CreateDir(path), true
+		}
+		return nil, false
+	})
+}
+
+// MoveFile 移动文件
+func (h *HybridEngine) MoveFile(src, dst string) *core.OperationResult {
+	return h.MoveFileContext(context.Background(), src, dst)
+}
+
+// MoveFileContext 支持取消的移动文件
+func (h *HybridEngine) MoveFileContext(ctx context.Context, src, dst string) *core.OperationResult {
+	return route(h, ctx, core.OpMoveFile, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			MoveFileContext(context.Context, string, string) *core.OperationResult
+		}:
+			return e.MoveFileContext(ctx, src, dst), true
+		case interface {
+			MoveFile(string, string) *core.OperationResult
+		}:
+			return e.MoveFile(src, dst), true
+		}
+		return nil, false
+	})
+}
+
+// CopyFile 复制文件
+func (h *HybridEngine) CopyFile(src, dst string) *core.OperationResult {
+	return h.CopyFileContext(context.Background(), src, dst)
+}
+
+// CopyFileContext 支持取消的复制文件
+func (h *HybridEngine) CopyFileContext(ctx context.Context, src, dst string) *core.OperationResult {
+	return route(h, ctx, core.OpCopyFile, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			CopyFileContext(context.Context, string, string) *core.OperationResult
+		}:
+			return e.CopyFileContext(ctx, src, dst), true
+		case interface {
+			CopyFile(string, string) *core.OperationResult
+		}:
+			return e.CopyFile(src, dst), true
+		}
+		return nil, false
+	})
+}
+
+// DeleteFile 删除文件
+func (h *HybridEngine) DeleteFile(path string) *core.OperationResult {
+	return h.DeleteFileContext(context.Background(), path)
+}
+
+// DeleteFileContext 支持取消的删除文件
+func (h *HybridEngine) DeleteFileContext(ctx context.Context, path string) *core.OperationResult {
+	return route(h, ctx, core.OpDeleteFile, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			DeleteFileContext(context.Context, string) *core.OperationResult
+		}:
+			return e.DeleteFileContext(ctx, path), true
+		case interface {
+			DeleteFile(string) *core.OperationResult
+		}:
+			return e.DeleteFile(path), true
+		}
+		return nil, false
+	})
+}
+
+// DeleteDir 删除目录
+func (h *HybridEngine) DeleteDir(path string) *core.OperationResult {
+	return h.DeleteDirContext(context.Background(), path)
+}
+
+// DeleteDirContext 支持取消的删除目录
+func (h *HybridEngine) DeleteDirContext(ctx context.Context, path string) *core.OperationResult {
+	return route(h, ctx, core.OpDeleteDir, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			DeleteDirContext(context.Context, string) *core.OperationResult
+		}:
+			return e.DeleteDirContext(ctx, path), true
+		case interface {
+			DeleteDir(string) *core.OperationResult
+		}:
+			return e.DeleteDir(path), true
+		}
+		return nil, false
+	})
+}
+
+// RenameFile 重命名文件
+func (h *HybridEngine) RenameFile(oldPath, newPath string) *core.OperationResult {
+	return h.RenameFileContext(context.Background(), oldPath, newPath)
+}
+
+// RenameFileContext 支持取消的重命名文件
+func (h *HybridEngine) RenameFileContext(ctx context.Context, oldPath, newPath string) *core.OperationResult {
+	return route(h, ctx, core.OpRenameFile, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			RenameFileContext(context.Context, string, string) *core.OperationResult
+		}:
+			return e.RenameFileContext(ctx, oldPath, newPath), true
+		case interface {
+			RenameFile(string, string) *core.OperationResult
+		}:
+			return e.RenameFile(oldPath, newPath), true
+		}
+		return nil, false
+	})
+}
+
+// FileExists 检查文件是否存在
+func (h *HybridEngine) FileExists(path string) (bool, *core.OperationResult) {
+	return h.FileExistsContext(context.Background(), path)
+}
+
+// FileExistsContext 支持取消的检查文件是否存在
+func (h *HybridEngine) FileExistsContext(ctx context.Context, path string) (bool, *core.OperationResult) {
+	var exists bool
+	result := route(h, ctx, core.OpFileExists, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			FileExistsContext(context.Context, string) (bool, *core.OperationResult)
+		}:
+			value, result := e.FileExistsContext(ctx, path)
+			exists = value
+			return result, true
+		case interface {
+			FileExists(string) (bool, *core.OperationResult)
+		}:
+			value, result := e.FileExists(path)
+			exists = value
+			return result, true
+		}
+		return nil, false
+	})
+	return exists, result
+}
+
+// GetFileInfo 获取文件信息
+func (h *HybridEngine) GetFileInfo(path string) (interface{}, *core.OperationResult) {
+	return h.GetFileInfoContext(context.Background(), path)
+}
+
+// GetFileInfoContext 支持取消的获取文件信息
+func (h *HybridEngine) GetFileInfoContext(ctx context.Context, path string) (interface{}, *core.OperationResult) {
+	var info interface{}
+	result := route(h, ctx, core.OpGetFileInfo, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetFileInfoContext(context.Context, string) (interface{}, *core.OperationResult)
+		}:
+			value, result := e.GetFileInfoContext(ctx, path)
+			info = value
+			return result, true
+		case interface {
+			GetFileInfo(string) (interface{}, *core.OperationResult)
+		}:
+			value, result := e.GetFileInfo(path)
+			info = value
+			return result, true
+		}
+		return nil, false
+	})
+	return info, result
+}
+
+// ListDir 列出目录内容
+func (h *HybridEngine) ListDir(path string) ([]string, *core.OperationResult) {
+	return h.ListDirContext(context.Background(), path)
+}
+
+// ListDirContext 支持取消的列出目录内容
+func (h *HybridEngine) ListDirContext(ctx context.Context, path string) ([]string, *core.OperationResult) {
+	var entries []string
+	result := route(h, ctx, core.OpListDir, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			ListDirContext(context.Context, string) ([]string, *core.OperationResult)
+		}:
+			value, result := e.ListDirContext(ctx, path)
+			entries = value
+			return result, true
+		case interface {
+			ListDir(string) ([]string, *core.OperationResult)
+		}:
+			value, result := e.ListDir(path)
+			entries = value
+			return result, true
+		}
+		return nil, false
+	})
+	return entries, result
+}
+
+// ===== 屏幕操作 =====
+
+// Screenshot 截屏，result.Data为*core.ScreenCapture
+func (h *HybridEngine) Screenshot() ([]byte, *core.OperationResult) {
+	return h.ScreenshotContext(context.Background())
+}
+
+// ScreenshotContext 支持取消的截屏
+func (h *HybridEngine) ScreenshotContext(ctx context.Context) ([]byte, *core.OperationResult) {
+	var imageData []byte
+	result := route(h, ctx, core.OpScreenshot, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			ScreenshotContext(context.Context) ([]byte, *core.OperationResult)
+		}:
+			value, result := e.ScreenshotContext(ctx)
+			imageData = value
+			return result, true
+		case interface {
+			Screenshot() ([]byte, *core.OperationResult)
+		}:
+			value, result := e.Screenshot()
+			imageData = value
+			return result, true
+		}
+		return nil, false
+	})
+	return imageData, result
+}
+
+// ScreenshotArea 截取指定区域
+func (h *HybridEngine) ScreenshotArea(rect core.Rect) ([]byte, *core.OperationResult) {
+	return h.ScreenshotAreaContext(context.Background(), rect)
+}
+
+// ScreenshotAreaContext 支持取消的截取指定区域
+func (h *HybridEngine) ScreenshotAreaContext(ctx context.Context, rect core.Rect) ([]byte, *core.OperationResult) {
+	var imageData []byte
+	result := route(h, ctx, core.OpScreenshotArea, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			ScreenshotAreaContext(context.Context, core.Rect) ([]byte, *core.OperationResult)
+		}:
+			value, result := e.ScreenshotAreaContext(ctx, rect)
+			imageData = value
+			return result, true
+		case interface {
+			ScreenshotArea(core.Rect) ([]byte, *core.OperationResult)
+		}:
+			value, result := e.ScreenshotArea(rect)
+			imageData = value
+			return result, true
+		}
+		return nil, false
+	})
+	return imageData, result
+}
+
+// GetScreenSize 获取屏幕尺寸
+func (h *HybridEngine) GetScreenSize() (*core.Size, *core.OperationResult) {
+	return h.GetScreenSizeContext(context.Background())
+}
+
+// GetScreenSizeContext 支持取消的获取屏幕尺寸
+func (h *HybridEngine) GetScreenSizeContext(ctx context.Context) (*core.Size, *core.OperationResult) {
+	var size *core.Size
+	result := route(h, ctx, core.OpGetScreenSize, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetScreenSizeContext(context.Context) (*core.Size, *core.OperationResult)
+		}:
+			value, result := e.GetScreenSizeContext(ctx)
+			size = value
+			return result, true
+		case interface {
+			GetScreenSize() (*core.Size, *core.OperationResult)
+		}:
+			value, result := e.GetScreenSize()
+			size = value
+			return result, true
+		}
+		return nil, false
+	})
+	return size, result
+}
+
+// GetDisplays 获取所有显示器的范围和缩放比例
+func (h *HybridEngine) GetDisplays() ([]core.Display, *core.OperationResult) {
+	return h.GetDisplaysContext(context.Background())
+}
+
+// GetDisplaysContext 支持取消的获取所有显示器
+// 没有后端能枚举显示器时，把截图覆盖的区域视为缩放比例为1的单个主显示器
+func (h *HybridEngine) GetDisplaysContext(ctx context.Context) ([]core.Display, *core.OperationResult) {
+	var displays []core.Display
+	result := route(h, ctx, core.OpGetDisplays, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetDisplaysContext(context.Context) ([]core.Display, *core.OperationResult)
+		}:
+			value, result := e.GetDisplaysContext(ctx)
+			displays = value
+			return result, true
+		case interface {
+			GetDisplays() ([]core.Display, *core.OperationResult)
+		}:
+			value, result := e.GetDisplays()
+			displays = value
+			return result, true
+		}
+		return nil, false
+	})
+	if result.Success || core.CodeOf(result.Err()) != core.ErrUnsupported {
+		return displays, result
 	}
-	return engine.KeyPress(key)
+
+	start := time.Now()
+	capture, shot := h.captureScreen(ctx)
+	if capture == nil {
+		return nil, shot
+	}
+	displays = []core.Display{{
+		Index:       0,
+		Bounds:      capture.Bounds,
+		ScaleFactor: 1,
+		Primary:     true,
+	}}
+	result = core.NewSuccessResult("display derived from screenshot", displays)
+	result.SetDuration(start)
+	return displays, result
 }
 
-// Screenshot 截屏
-func (h *HybridEngine) Screenshot() *core.OperationResult {
-	engine := h.getEngine()
-	if engine == nil {
-		return core.NewErrorResult("no available engine for screenshot operation", fmt.Errorf("no engine"))
+// FindImage 在屏幕上查找图像
+func (h *HybridEngine) FindImage(templatePath string) (*core.Point, *core.OperationResult) {
+	return h.FindImageContext(context.Background(), templatePath)
+}
+
+// FindImageContext 支持取消的在屏幕上查找图像
+func (h *HybridEngine) FindImageContext(ctx context.Context, templatePath string) (*core.Point, *core.OperationResult) {
+	return h.FindImageWithOptionsContext(ctx, templatePath, imagematch.DefaultOptions())
+}
+
+// FindImageWithOptionsContext 按指定的阈值、缩放比例和区域在屏幕上查找图像
+// 通过任一支持截图的后端截屏，再在本地做模板匹配，因此不依赖后端自身的找图实现
+func (h *HybridEngine) FindImageWithOptionsContext(ctx context.Context, templatePath string, options imagematch.Options) (*core.Point, *core.OperationResult) {
+	start := time.Now()
+	template, err := imagematch.LoadTemplate(templatePath)
+	if err != nil {
+		result := core.NewErrorResult("find image failed", err)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	point, result := h.findTemplate(ctx, templatePath, template, options)
+	result.SetDuration(start)
+	return point, result
+}
+
+// WaitForImageContext 等待图像出现在屏幕上，超时返回timeout错误
+func (h *HybridEngine) WaitForImageContext(ctx context.Context, templatePath string, timeout time.Duration, options imagematch.Options) (*core.Point, *core.OperationResult) {
+	start := time.Now()
+	template, err := imagematch.LoadTemplate(templatePath)
+	if err != nil {
+		result := core.NewErrorResult("wait for image failed", err)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	deadline := start.Add(timeout)
+	for {
+		point, result := h.findTemplate(ctx, templatePath, template, options)
+		if result.Success || core.CodeOf(result.Err()) != core.ErrNotFound {
+			result.SetDuration(start)
+			return point, result
+		}
+		if time.Now().After(deadline) {
+			result := core.NewErrorResult("wait for image timed out",
+				core.NewError(core.ErrTimeout, fmt.Sprintf("%s did not appear within %v", templatePath, timeout), nil))
+			result.SetDuration(start)
+			return nil, result
+		}
+		if err := core.Sleep(ctx, imagePollInterval); err != nil {
+			result := core.NewCancelledResult(ctx)
+			result.SetDuration(start)
+			return nil, result
+		}
 	}
-	return engine.Screenshot()
+}
+
+// imagePollInterval 等待图像出现时的截图间隔
+const imagePollInterval = 500 * time.Millisecond
+
+// findTemplate 截屏并查找模板
+func (h *HybridEngine) findTemplate(ctx context.Context, templatePath string, template image.Image, options imagematch.Options) (*core.Point, *core.OperationResult) {
+	capture, result := h.captureScreen(ctx)
+	if capture == nil {
+		return nil, result
+	}
+
+	matches, err := imagematch.FindInCapture(capture, template, options)
+	if err != nil {
+		return nil, core.NewErrorResult("find image failed", err)
+	}
+	return imagematch.NewResult(templatePath, matches, options)
+}
+
+// FindText 在屏幕上查找文本
+func (h *HybridEngine) FindText(text string) (*core.Point, *core.OperationResult) {
+	return h.FindTextContext(context.Background(), text)
+}
+
+// FindTextContext 支持取消的在屏幕上查找文本
+// 设置了文字识别后端时截屏识别，否则路由到实现了FindText的后端
+func (h *HybridEngine) FindTextContext(ctx context.Context, text string) (*core.Point, *core.OperationResult) {
+	if h.OCRProvider() != nil {
+		return h.FindTextWithOptionsContext(ctx, text, ocr.DefaultFindOptions())
+	}
+
+	var point *core.Point
+	result := route(h, ctx, core.OpFindText, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			FindTextContext(context.Context, string) (*core.Point, *core.OperationResult)
+		}:
+			value, result := e.FindTextContext(ctx, text)
+			point = value
+			return result, true
+		case interface {
+			FindText(string) (*core.Point, *core.OperationResult)
+		}:
+			value, result := e.FindText(text)
+			point = value
+			return result, true
+		}
+		return nil, false
+	})
+	return point, result
+}
+
+// FindTextWithOptionsContext 截屏并通过文字识别查找文本，返回匹配文字的中心点
+func (h *HybridEngine) FindTextWithOptionsContext(ctx context.Context, text string, options ocr.FindOptions) (*core.Point, *core.OperationResult) {
+	start := time.Now()
+	capture, result := h.captureScreen(ctx)
+	if capture == nil {
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	point, result := ocr.Locate(ctx, h.OCRProvider(), capture, text, options)
+	result.SetDuration(start)
+	return point, result
+}
+
+// RecognizeTextContext 截屏并识别屏幕上的所有文本
+func (h *HybridEngine) RecognizeTextContext(ctx context.Context, options core.OCROptions) ([]core.TextBox, *core.OperationResult) {
+	start := time.Now()
+	provider := h.OCRProvider()
+	if provider == nil {
+		result := core.NewErrorResult("recognize text failed", core.NewError(core.ErrNotConfigured, "no OCR provider configured", nil))
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	capture, result := h.captureScreen(ctx)
+	if capture == nil {
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	boxes, err := provider.Recognize(ctx, capture, options)
+	if err != nil {
+		result = core.NewErrorResult(fmt.Sprintf("%s text recognition failed", provider.Name()), err)
+	} else {
+		result = core.NewSuccessResult(fmt.Sprintf("recognized %d text boxes", len(boxes)), map[string]interface{}{
+			"provider": provider.Name(),
+			"boxes":    boxes,
+		})
+	}
+	result.SetDuration(start)
+	return boxes, result
+}
+
+// GetPixelColor 获取指定位置的像素颜色（rrggbb）
+func (h *HybridEngine) GetPixelColor(x, y int) (string, *core.OperationResult) {
+	return h.GetPixelColorContext(context.Background(), x, y)
+}
+
+// GetPixelColorContext 支持取消的获取像素颜色
+// 没有后端直接支持取色时截取该点所在的1x1区域读取颜色
+func (h *HybridEngine) GetPixelColorContext(ctx context.Context, x, y int) (string, *core.OperationResult) {
+	var color string
+	result := route(h, ctx, core.OpGetPixelColor, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetPixelColorContext(context.Context, int, int) (string, *core.OperationResult)
+		}:
+			value, result := e.GetPixelColorContext(ctx, x, y)
+			color = value
+			return result, true
+		case interface {
+			GetPixelColor(int, int) (string, *core.OperationResult)
+		}:
+			value, result := e.GetPixelColor(x, y)
+			color = value
+			return result, true
+		}
+		return nil, false
+	})
+	if result.Success || core.CodeOf(result.Err()) != core.ErrUnsupported {
+		return color, result
+	}
+
+	start := time.Now()
+	_, shot := h.ScreenshotAreaContext(ctx, core.Rect{X: x, Y: y, Width: 1, Height: 1})
+	capture, ok := core.CaptureFromResult(shot)
+	if !ok {
+		return "", shot
+	}
+	img, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		result := core.NewErrorResult("get pixel color failed", err)
+		result.SetDuration(start)
+		return "", result
+	}
+	r, g, b, _ := img.At(img.Bounds().Min.X, img.Bounds().Min.Y).RGBA()
+	color = fmt.Sprintf("%02x%02x%02x", r>>8, g>>8, b>>8)
+	result = core.NewSuccessResult(fmt.Sprintf("pixel (%d, %d) = #%s", x, y, color), map[string]interface{}{
+		"x":     x,
+		"y":     y,
+		"color": color,
+	})
+	result.SetDuration(start)
+	return color, result
+}
+
+// WaitUntilContext 等待条件满足，条件和选项的含义见wait包
+func (h *HybridEngine) WaitUntilContext(ctx context.Context, condition wait.Condition, options wait.Options) *core.OperationResult {
+	return wait.Until(ctx, h, condition, options)
+}
+
+// WaitForWindowContext 等待标题包含title的窗口出现
+func (h *HybridEngine) WaitForWindowContext(ctx context.Context, title string, timeout time.Duration) (*core.WindowInfo, *core.OperationResult) {
+	options := wait.DefaultOptions()
+	options.Timeout = timeout
+	result := h.WaitUntilContext(ctx, wait.WindowExists(title), options)
+	if !result.Success {
+		return nil, result
+	}
+
+	windows, list := h.GetWindowsContext(ctx)
+	if !list.Success {
+		return nil, list
+	}
+	window := wait.FindWindow(windows, title)
+	if window == nil {
+		// 窗口在两次查询之间关闭
+		return nil, core.NewErrorResult("wait for window failed",
+			core.NewError(core.ErrNotFound, fmt.Sprintf("window %q closed", title), nil))
+	}
+	result.Message = fmt.Sprintf("window %q appeared", window.Title)
+	if data, ok := result.Data.(map[string]interface{}); ok {
+		data["window"] = window
+	}
+	return window, result
+}
+
+// captureScreen 截屏并取出截图，失败时返回nil和失败结果
+func (h *HybridEngine) captureScreen(ctx context.Context) (*core.ScreenCapture, *core.OperationResult) {
+	_, shot := h.ScreenshotContext(ctx)
+	if !shot.Success {
+		return nil, shot
+	}
+	capture, ok := core.CaptureFromResult(shot)
+	if !ok {
+		return nil, core.NewErrorResult("screenshot failed", core.NewError(core.ErrUnknown, "screenshot returned no image", nil))
+	}
+	return capture, shot
+}
+
+// ===== 系统操作 =====
+
+// GetClipboard 获取剪贴板内容
+func (h *HybridEngine) GetClipboard() (string, *core.OperationResult) {
+	return h.GetClipboardContext(context.Background())
+}
+
+// GetClipboardContext 支持取消的获取剪贴板内容
+func (h *HybridEngine) GetClipboardContext(ctx context.Context) (string, *core.OperationResult) {
+	var text string
+	result := route(h, ctx, core.OpGetClipboard, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetClipboardContext(context.Context) (string, *core.OperationResult)
+		}:
+			value, result := e.GetClipboardContext(ctx)
+			text = value
+			return result, true
+		case interface {
+			GetClipboard() (string, *core.OperationResult)
+		}:
+			value, result := e.GetClipboard()
+			text = value
+			return result, true
+		}
+		return nil, false
+	})
+	return text, result
+}
+
+// SetClipboard 设置剪贴板内容
+func (h *HybridEngine) SetClipboard(text string) *core.OperationResult {
+	return h.SetClipboardContext(context.Background(), text)
+}
+
+// SetClipboardContext 支持取消的设置剪贴板内容
+func (h *HybridEngine) SetClipboardContext(ctx context.Context, text string) *core.OperationResult {
+	return route(h, ctx, core.OpSetClipboard, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			SetClipboardContext(context.Context, string) *core.OperationResult
+		}:
+			return e.SetClipboardContext(ctx, text), true
+		case interface {
+			SetClipboard(string) *core.OperationResult
+		}:
+			return e.SetClipboard(text), true
+		}
+		return nil, false
+	})
+}
+
+// GetActiveWindow 获取当前活动窗口
+func (h *HybridEngine) GetActiveWindow() (*core.WindowInfo, *core.OperationResult) {
+	return h.GetActiveWindowContext(context.Background())
+}
+
+// GetActiveWindowContext 支持取消的获取当前活动窗口
+func (h *HybridEngine) GetActiveWindowContext(ctx context.Context) (*core.WindowInfo, *core.OperationResult) {
+	var window *core.WindowInfo
+	result := route(h, ctx, core.OpGetActiveWindow, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetActiveWindowContext(context.Context) (*core.WindowInfo, *core.OperationResult)
+		}:
+			value, result := e.GetActiveWindowContext(ctx)
+			window = value
+			return result, true
+		case interface {
+			GetActiveWindow() (*core.WindowInfo, *core.OperationResult)
+		}:
+			value, result := e.GetActiveWindow()
+			window = value
+			return result, true
+		}
+		return nil, false
+	})
+	return window, result
+}
+
+// GetWindows 获取所有窗口
+func (h *HybridEngine) GetWindows() ([]*core.WindowInfo, *core.OperationResult) {
+	return h.GetWindowsContext(context.Background())
+}
+
+// GetWindowsContext 支持取消的获取所有窗口
+func (h *HybridEngine) GetWindowsContext(ctx context.Context) ([]*core.WindowInfo, *core.OperationResult) {
+	var windows []*core.WindowInfo
+	result := route(h, ctx, core.OpGetWindows, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			GetWindowsContext(context.Context) ([]*core.WindowInfo, *core.OperationResult)
+		}:
+			value, result := e.GetWindowsContext(ctx)
+			windows = value
+			return result, true
+		case interface {
+			GetWindows() ([]*core.WindowInfo, *core.OperationResult)
+		}:
+			value, result := e.GetWindows()
+			windows = value
+			return result, true
+		}
+		return nil, false
+	})
+	return windows, result
+}
+
+// ActivateWindow 激活窗口
+func (h *HybridEngine) ActivateWindow(handle uintptr) *core.OperationResult {
+	return h.ActivateWindowContext(context.Background(), handle)
+}
+
+// ActivateWindowContext 支持取消的激活窗口
+func (h *HybridEngine) ActivateWindowContext(ctx context.Context, handle uintptr) *core.OperationResult {
+	return route(h, ctx, core.OpActivateWindow, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			ActivateWindowContext(context.Context, uintptr) *core.OperationResult
+		}:
+			return e.ActivateWindowContext(ctx, handle), true
+		case interface {
+			ActivateWindow(uintptr) *core.OperationResult
+		}:
+			return e.ActivateWindow(handle), true
+		}
+		return nil, false
+	})
+}
+
+// CloseWindow 关闭窗口
+func (h *HybridEngine) CloseWindow(handle uintptr) *core.OperationResult {
+	return h.CloseWindowContext(context.Background(), handle)
+}
+
+// CloseWindowContext 支持取消的关闭窗口
+func (h *HybridEngine) CloseWindowContext(ctx context.Context, handle uintptr) *core.OperationResult {
+	return route(h, ctx, core.OpCloseWindow, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			CloseWindowContext(context.Context, uintptr) *core.OperationResult
+		}:
+			return e.CloseWindowContext(ctx, handle), true
+		case interface {
+			CloseWindow(uintptr) *core.OperationResult
+		}:
+			return e.CloseWindow(handle), true
+		}
+		return nil, false
+	})
+}
+
+// MinimizeWindow 最小化窗口
+func (h *HybridEngine) MinimizeWindow(handle uintptr) *core.OperationResult {
+	return h.MinimizeWindowContext(context.Background(), handle)
+}
+
+// MinimizeWindowContext 支持取消的最小化窗口
+func (h *HybridEngine) MinimizeWindowContext(ctx context.Context, handle uintptr) *core.OperationResult {
+	return route(h, ctx, core.OpMinimizeWindow, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			MinimizeWindowContext(context.Context, uintptr) *core.OperationResult
+		}:
+			return e.MinimizeWindowContext(ctx, handle), true
+		case interface {
+			MinimizeWindow(uintptr) *core.OperationResult
+		}:
+			return e.MinimizeWindow(handle), true
+		}
+		return nil, false
+	})
+}
+
+// MaximizeWindow 最大化窗口
+func (h *HybridEngine) MaximizeWindow(handle uintptr) *core.OperationResult {
+	return h.MaximizeWindowContext(context.Background(), handle)
+}
+
+// MaximizeWindowContext 支持取消的最大化窗口
+func (h *HybridEngine) MaximizeWindowContext(ctx context.Context, handle uintptr) *core.OperationResult {
+	return route(h, ctx, core.OpMaximizeWindow, func(engine interface{}) (*core.OperationResult, bool) {
+		switch e := engine.(type) {
+		case interface {
+			MaximizeWindowContext(context.Context, uintptr) *core.OperationResult
+		}:
+			return e.MaximizeWindowContext(ctx, handle), true
+		case interface {
+			MaximizeWindow(uintptr) *core.OperationResult
+		}:
+			return e.MaximizeWindow(handle), true
+		}
+		return nil, false
+	})
+}
+
+// ===== 引擎管理 =====
+
+// Close 释放引擎资源，优雅关闭常驻的worker进程
+func (h *HybridEngine) Close() error {
+	if h.externalEngine != nil {
+		return h.externalEngine.Close()
+	}
+	return nil
 }
 
 // SetPreferPureGo 设置是否优先使用纯Go引擎
 func (h *HybridEngine) SetPreferPureGo(prefer bool) {
+	h.mu.Lock()
 	h.preferPureGo = prefer
+	h.mu.Unlock()
+}
+
+// Capabilities 合并所有可用后端的能力，不会为此启动worker
+func (h *HybridEngine) Capabilities() *core.Capabilities {
+	return h.capabilities(func(b *backend) *core.Capabilities {
+		return core.CapabilitiesOf(b.name, b.engine)
+	})
+}
+
+// CapabilitiesContext 合并所有可用后端的能力，需要时启动worker获取其能力，ctx取消时停止等待
+func (h *HybridEngine) CapabilitiesContext(ctx context.Context) *core.Capabilities {
+	return h.capabilities(func(b *backend) *core.Capabilities {
+		return core.CapabilitiesOfContext(ctx, b.name, b.engine)
+	})
+}
+
+// capabilities 合并各后端的能力
+// 复制、粘贴、全选由组合键实现，等待由混合引擎自身实现，非ASCII文本通过剪贴板粘贴输入
+func (h *HybridEngine) capabilities(describe func(b *backend) *core.Capabilities) *core.Capabilities {
+	var backends []*core.Capabilities
+	for _, b := range h.backends() {
+		if !b.available() {
+			continue
+		}
+		caps := describe(b)
+		if caps.Engine == "" {
+			caps.Engine = b.name
+		}
+		backends = append(backends, caps)
+	}
+
+	merged := core.MergeCapabilities("hybrid", backends...)
+	extra := []core.Operation{core.OpWait}
+	if merged.Supports(core.OpScreenshotArea) {
+		extra = append(extra, core.OpGetPixelColor)
+	}
+	if merged.Supports(core.OpScreenshot) {
+		extra = append(extra, core.OpFindImage, core.OpGetDisplays)
+		if h.OCRProvider() != nil {
+			extra = append(extra, core.OpFindText)
+		}
+	}
+	if merged.Supports(core.OpHotkey) {
+		extra = append(extra, core.OpCopy, core.OpPaste, core.OpSelectAll)
+	}
+	for _, operation := range extra {
+		if !merged.Supports(operation) {
+			merged.Operations = append(merged.Operations, operation)
+		}
+	}
+	sort.Slice(merged.Operations, func(i, j int) bool { return merged.Operations[i] < merged.Operations[j] })
+
+	// 非ASCII文本可以通过剪贴板粘贴输入
+	if len(merged.Missing(core.OpSetClipboard, core.OpHotkey)) == 0 {
+		merged.UnicodeText = true
+	}
+	return merged
 }
 
 // GetEngineInfo 获取当前引擎信息
 func (h *HybridEngine) GetEngineInfo() map[string]interface{} {
+	backends := h.backends()
+
+	h.mu.Lock()
 	info := map[string]interface{}{
 		"platform":       runtime.GOOS,
 		"prefer_pure_go": h.preferPureGo,
 	}
-
-	if h.pureGoEngine != nil {
-		info["pure_go_available"] = h.pureGoEngine.IsAvailable()
-	} else {
-		info["pure_go_available"] = false
+	routes := make(map[core.Operation]string, len(h.routes))
+	for operation, name := range h.routes {
+		routes[operation] = name
+	}
+	if h.ocr != nil {
+		info["ocr"] = h.ocr.Name()
 	}
+	h.mu.Unlock()
 
+	info["pure_go_available"] = h.pureGoEngine != nil && h.pureGoEngine.IsAvailable()
+	info["external_available"] = h.externalEngine != nil && h.externalEngine.IsAvailable()
 	if h.externalEngine != nil {
-		info["external_available"] = h.externalEngine.IsAvailable()
-	} else {
-		info["external_available"] = false
+		info["worker"] = h.externalEngine.GetWorkerStatus()
 	}
 
-	currentEngine := h.getEngine()
-	if currentEngine == h.pureGoEngine {
-		info["current_engine"] = "pure_go"
-	} else if currentEngine == h.externalEngine {
-		info["current_engine"] = "external"
-	} else {
-		info["current_engine"] = "none"
+	order := make([]string, 0, len(backends))
+	current := "none"
+	for _, b := range backends {
+		order = append(order, b.name)
+		if current == "none" && b.available() {
+			current = b.name
+		}
 	}
+	info["current_engine"] = current
+	info["backends"] = order
+	info["routes"] = routes
 
 	return info
 }
@@ -0,0 +1,171 @@
+package hybrid
+
+import (
+	"context"
+	"testing"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/virtual"
+)
+
+// newVirtualDesktop 创建测试用的虚拟桌面，测试结束时清理临时目录
+func newVirtualDesktop(t *testing.T) *virtual.Engine {
+	t.Helper()
+	desktop, err := virtual.NewEngine(virtual.DefaultConfig())
+	if err != nil {
+		t.Fatalf("创建虚拟桌面失败: %v", err)
+	}
+	t.Cleanup(func() { desktop.Cleanup() })
+	return desktop
+}
+
+func TestHybridEngineNotepadTask(t *testing.T) {
+	desktop := newVirtualDesktop(t)
+	engine := NewHybridEngineWithBackend("virtual", desktop)
+	ctx := context.Background()
+
+	if result := engine.Launch("notepad"); !result.Success {
+		t.Fatalf("启动记事本失败: %s", result.Error)
+	}
+	window, ok := desktop.FindWindow("记事本")
+	if !ok {
+		t.Fatal("启动后没有找到记事本窗口")
+	}
+	editor := window.Widget("editor")
+	if result := engine.ClickContext(ctx, window.Bounds.X+editor.Bounds.X+10, window.Bounds.Y+editor.Bounds.Y+10, core.LeftButton); !result.Success {
+		t.Fatalf("点击输入框失败: %s", result.Error)
+	}
+	if result := engine.TypeContext(ctx, "你好, diandian"); !result.Success {
+		t.Fatalf("输入文字失败: %s", result.Error)
+	}
+	if result := engine.HotkeyContext(ctx, []core.KeyModifier{core.ModCtrl}, "s"); !result.Success {
+		t.Fatalf("保存快捷键失败: %s", result.Error)
+	}
+
+	window, _ = desktop.FindWindow("记事本")
+	if got := window.Widget("editor").Text; got != "你好, diandian" {
+		t.Errorf("输入框内容 = %q，期望 %q", got, "你好, diandian")
+	}
+	if window.Saves != 1 {
+		t.Errorf("保存次数 = %d，期望 1", window.Saves)
+	}
+
+	_, result := engine.ScreenshotContext(ctx)
+	capture, ok := core.CaptureFromResult(result)
+	if !ok {
+		t.Fatalf("截图没有返回图像数据: %s", result.Error)
+	}
+	if capture.Width != 1920 || capture.Height != 1080 {
+		t.Errorf("截图尺寸 = %dx%d，期望 1920x1080", capture.Width, capture.Height)
+	}
+}
+
+func TestHybridEngineFileOperations(t *testing.T) {
+	desktop := newVirtualDesktop(t)
+	engine := NewHybridEngineWithBackend("virtual", desktop)
+	ctx := context.Background()
+
+	src, dst := desktop.Path("report.txt"), desktop.Path("archive/report.txt")
+	if result := engine.CreateFileContext(ctx, src, []byte("周报")); !result.Success {
+		t.Fatalf("创建文件失败: %s", result.Error)
+	}
+	if result := engine.CreateDirContext(ctx, desktop.Path("archive")); !result.Success {
+		t.Fatalf("创建目录失败: %s", result.Error)
+	}
+	if result := engine.MoveFileContext(ctx, src, dst); !result.Success {
+		t.Fatalf("移动文件失败: %s", result.Error)
+	}
+
+	if exists, _ := engine.FileExistsContext(ctx, src); exists {
+		t.Error("移动后源文件仍然存在")
+	}
+	if exists, _ := engine.FileExistsContext(ctx, dst); !exists {
+		t.Error("移动后目标文件不存在")
+	}
+}
+
+func TestHybridEngineFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         core.ErrorCode
+		wantSuccess  bool
+		wantFallback bool
+	}{
+		{"不支持时回退", core.ErrUnsupported, true, true},
+		{"不可用时回退", core.ErrEngineUnavailable, true, true},
+		{"超时不回退", core.ErrTimeout, false, false},
+		{"参数错误不回退", core.ErrInvalidArgument, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, secondary := newVirtualDesktop(t), newVirtualDesktop(t)
+			engine := NewHybridEngineWithBackend("primary", primary)
+			engine.AddBackend("secondary", secondary)
+			for _, desktop := range []*virtual.Engine{primary, secondary} {
+				desktop.Launch("notepad")
+				desktop.ClearActions()
+			}
+
+			primary.FailNext(core.OpType, core.NewError(tt.code, "injected", nil))
+			result := engine.TypeContext(context.Background(), "abc")
+
+			if result.Success != tt.wantSuccess {
+				t.Fatalf("Success = %v，期望 %v（%s）", result.Success, tt.wantSuccess, result.Error)
+			}
+			if !tt.wantSuccess && result.Code != tt.code {
+				t.Errorf("Code = %s，期望 %s", result.Code, tt.code)
+			}
+			if fellBack := len(secondary.Actions()) > 0; fellBack != tt.wantFallback {
+				t.Errorf("是否回退到第二个后端 = %v，期望 %v", fellBack, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestHybridEngineReadOnlyFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation core.Operation
+		call      func(engine *HybridEngine) *core.OperationResult
+	}{
+		{"截图", core.OpScreenshot, func(engine *HybridEngine) *core.OperationResult {
+			_, result := engine.ScreenshotContext(context.Background())
+			return result
+		}},
+		{"读取剪贴板", core.OpGetClipboard, func(engine *HybridEngine) *core.OperationResult {
+			_, result := engine.GetClipboardContext(context.Background())
+			return result
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, secondary := newVirtualDesktop(t), newVirtualDesktop(t)
+			engine := NewHybridEngineWithBackend("primary", primary)
+			engine.AddBackend("secondary", secondary)
+
+			// 只读操作遇到未分类的错误也应回退
+			primary.FailNext(tt.operation, core.NewError(core.ErrUnknown, "injected", nil))
+			if result := tt.call(engine); !result.Success {
+				t.Fatalf("只读操作没有回退到第二个后端: %s", result.Error)
+			}
+		})
+	}
+}
+
+func TestHybridEngineCancelled(t *testing.T) {
+	desktop := newVirtualDesktop(t)
+	engine := NewHybridEngineWithBackend("virtual", desktop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := engine.ClickContext(ctx, 10, 10, core.LeftButton)
+	if result.Success || result.Code != core.ErrCancelled {
+		t.Fatalf("取消后的点击 = %v %s，期望 cancelled", result.Success, result.Code)
+	}
+	if result := engine.LaunchContext(ctx, "notepad"); result.Success || result.Code != core.ErrCancelled {
+		t.Fatalf("取消后的启动 = %v %s，期望 cancelled", result.Success, result.Code)
+	}
+	if actions := desktop.Actions(); len(actions) != 0 {
+		t.Errorf("取消后仍然执行了 %d 个操作", len(actions))
+	}
+}
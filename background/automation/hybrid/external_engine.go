@@ -1,20 +1,24 @@
 package hybrid
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"diandian/background/automation/core"
 )
 
 // ExternalEngine 外部程序引擎（使用robotgo的独立程序）
+// worker以常驻子进程运行，通过stdin/stdout上的行分隔JSON-RPC通信，由supervisor负责监管
 type ExternalEngine struct {
 	workerPath string
 	available  bool
+	supervisor *workerSupervisor
 }
 
 // AutomationRequest 自动化请求
@@ -29,12 +33,13 @@ type AutomationResponse struct {
 	Message string                 `json:"message"`
 	Data    map[string]interface{} `json:"data,omitempty"`
 	Error   string                 `json:"error,omitempty"`
+	Code    core.ErrorCode         `json:"code,omitempty"`
 }
 
 // NewExternalEngine 创建外部程序引擎
 func NewExternalEngine() (*ExternalEngine, error) {
 	engine := &ExternalEngine{}
-	
+
 	// 查找worker程序
 	workerPath, err := engine.findWorkerPath()
 	if err != nil {
@@ -43,6 +48,7 @@ func NewExternalEngine() (*ExternalEngine, error) {
 
 	engine.workerPath = workerPath
 	engine.available = true
+	engine.supervisor = newWorkerSupervisor(workerPath, DefaultWorkerSupervisorConfig())
 	return engine, nil
 }
 
@@ -70,107 +76,521 @@ func (e *ExternalEngine) findWorkerPath() (string, error) {
 	return "", fmt.Errorf("automation worker not found")
 }
 
-// IsAvailable 检查引擎是否可用
+// IsAvailable 检查引擎是否可用（worker连续重启失败后，冷却期内视为不可用）
 func (e *ExternalEngine) IsAvailable() bool {
-	return e.available && e.workerPath != ""
+	return e.available && e.workerPath != "" && !e.supervisor.gaveUp()
 }
 
-// executeCommand 执行外部命令
+// executeCommand 通过会话执行一次请求
 func (e *ExternalEngine) executeCommand(request AutomationRequest) *core.OperationResult {
+	return e.executeCommandContext(context.Background(), request)
+}
+
+// executeCommandContext 通过会话执行一次请求，ctx取消时中止等待并结束正在执行的worker操作
+func (e *ExternalEngine) executeCommandContext(ctx context.Context, request AutomationRequest) *core.OperationResult {
 	start := time.Now()
 
 	if !e.IsAvailable() {
-		result := core.NewErrorResult("external engine not available", fmt.Errorf("worker not found"))
+		result := core.NewErrorResult("external engine not available",
+			core.NewError(core.ErrEngineUnavailable, "worker not found", nil))
 		result.SetDuration(start)
 		return result
 	}
 
-	// 序列化请求
-	requestData, err := json.Marshal(request)
-	if err != nil {
-		result := core.NewErrorResult("failed to marshal request", err)
+	response, err := e.supervisor.Call(ctx, request.Action, request.Parameters)
+	if err != nil && ctx.Err() != nil {
+		result := core.NewCancelledResult(ctx)
 		result.SetDuration(start)
 		return result
 	}
-
-	// 执行外部程序
-	cmd := exec.Command(e.workerPath)
-	cmd.Stdin = nil
-	
-	// 通过命令行参数传递请求
-	cmd.Args = append(cmd.Args, string(requestData))
-
-	output, err := cmd.Output()
 	if err != nil {
 		result := core.NewErrorResult(
 			fmt.Sprintf("external worker failed: %v", err),
-			err,
+			workerError(err),
 		)
 		result.SetDuration(start)
 		return result
 	}
 
-	// 解析响应
-	var response AutomationResponse
-	if err := json.Unmarshal(output, &response); err != nil {
-		result := core.NewErrorResult("failed to parse worker response", err)
-		result.SetDuration(start)
-		return result
-	}
-
 	// 转换为OperationResult
 	if response.Success {
 		result := core.NewSuccessResult(response.Message, response.Data)
 		result.SetDuration(start)
 		return result
 	} else {
-		result := core.NewErrorResult(response.Message, fmt.Errorf(response.Error))
+		code := response.Code
+		if code == "" {
+			code = core.ErrUnknown
+		}
+		result := core.NewErrorResult(response.Message, core.NewError(code, response.Error, nil))
 		result.SetDuration(start)
 		return result
 	}
 }
 
+// workerError 为会话层错误补充错误码
+// worker不认识的方法视为不支持；请求发出后会话中断或worker拒绝请求时无法确定操作是否已执行，按未知错误处理以免回退后重复执行；
+// 其余会话失败（进程启动、握手失败、请求发出前会话已关闭等）视为引擎不可用
+func workerError(err error) error {
+	var coded *core.Error
+	if errors.As(err, &coded) || core.CodeOf(err) != core.ErrUnknown {
+		return err
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		if rpcErr.Code == rpcCodeMethodNotFound {
+			return core.NewError(core.ErrUnsupported, "operation not supported by worker", err)
+		}
+		return core.NewError(core.ErrUnknown, "automation worker rejected the request", err)
+	}
+	if errors.Is(err, errSessionLost) {
+		return core.NewError(core.ErrUnknown, "automation worker exited during the request", err)
+	}
+	return core.NewError(core.ErrEngineUnavailable, "automation worker unavailable", err)
+}
+
+// Close 关闭worker会话
+func (e *ExternalEngine) Close() error {
+	if e.supervisor == nil {
+		return nil
+	}
+	return e.supervisor.Close()
+}
+
+// GetWorkerStatus 获取worker进程的监管状态（不会触发启动）
+func (e *ExternalEngine) GetWorkerStatus() map[string]interface{} {
+	if e.supervisor == nil {
+		return map[string]interface{}{"state": WorkerStateStopped}
+	}
+	return e.supervisor.Status()
+}
+
 // Click 点击操作
-func (e *ExternalEngine) Click(x, y int) *core.OperationResult {
+func (e *ExternalEngine) Click(x, y int, button core.MouseButton) *core.OperationResult {
+	return e.ClickContext(context.Background(), x, y, button)
+}
+
+// ClickContext 支持取消的点击操作
+func (e *ExternalEngine) ClickContext(ctx context.Context, x, y int, button core.MouseButton) *core.OperationResult {
+	request := AutomationRequest{
+		Action: "click",
+		Parameters: map[string]interface{}{
+			"x":      x,
+			"y":      y,
+			"button": string(button),
+		},
+	}
+	return e.executeCommandContext(ctx, request)
+}
+
+// DoubleClick 双击操作
+func (e *ExternalEngine) DoubleClick(x, y int) *core.OperationResult {
+	return e.DoubleClickContext(context.Background(), x, y)
+}
+
+// DoubleClickContext 支持取消的双击操作
+func (e *ExternalEngine) DoubleClickContext(ctx context.Context, x, y int) *core.OperationResult {
 	request := AutomationRequest{
 		Action: "click",
+		Parameters: map[string]interface{}{
+			"x":      x,
+			"y":      y,
+			"button": string(core.LeftButton),
+			"double": true,
+		},
+	}
+	return e.executeCommandContext(ctx, request)
+}
+
+// RightClick 右键点击
+func (e *ExternalEngine) RightClick(x, y int) *core.OperationResult {
+	return e.RightClickContext(context.Background(), x, y)
+}
+
+// RightClickContext 支持取消的右键点击
+func (e *ExternalEngine) RightClickContext(ctx context.Context, x, y int) *core.OperationResult {
+	return e.ClickContext(ctx, x, y, core.RightButton)
+}
+
+// Drag 拖拽操作
+func (e *ExternalEngine) Drag(fromX, fromY, toX, toY int) *core.OperationResult {
+	return e.DragContext(context.Background(), fromX, fromY, toX, toY)
+}
+
+// DragContext 支持取消的拖拽操作
+func (e *ExternalEngine) DragContext(ctx context.Context, fromX, fromY, toX, toY int) *core.OperationResult {
+	request := AutomationRequest{
+		Action: "drag",
+		Parameters: map[string]interface{}{
+			"from_x": fromX,
+			"from_y": fromY,
+			"to_x":   toX,
+			"to_y":   toY,
+		},
+	}
+	return e.executeCommandContext(ctx, request)
+}
+
+// Move 移动鼠标
+func (e *ExternalEngine) Move(x, y int) *core.OperationResult {
+	return e.MoveContext(context.Background(), x, y)
+}
+
+// MoveContext 支持取消的移动鼠标
+func (e *ExternalEngine) MoveContext(ctx context.Context, x, y int) *core.OperationResult {
+	request := AutomationRequest{
+		Action: "move",
 		Parameters: map[string]interface{}{
 			"x": x,
 			"y": y,
 		},
 	}
-	return e.executeCommand(request)
+	return e.executeCommandContext(ctx, request)
+}
+
+// GetPosition 获取鼠标位置
+func (e *ExternalEngine) GetPosition() (*core.Point, *core.OperationResult) {
+	return e.GetPositionContext(context.Background())
+}
+
+// GetPositionContext 支持取消的获取鼠标位置
+func (e *ExternalEngine) GetPositionContext(ctx context.Context) (*core.Point, *core.OperationResult) {
+	result := e.executeCommandContext(ctx, AutomationRequest{Action: "get_position"})
+	if !result.Success {
+		return nil, result
+	}
+
+	data, _ := result.Data.(map[string]interface{})
+	point := &core.Point{X: intValue(data["x"]), Y: intValue(data["y"])}
+	result.Data = point
+	return point, result
+}
+
+// Scroll 滚动操作
+func (e *ExternalEngine) Scroll(x, y int, direction string, clicks int) *core.OperationResult {
+	return e.ScrollContext(context.Background(), x, y, direction, clicks)
+}
+
+// ScrollContext 支持取消的滚动操作
+func (e *ExternalEngine) ScrollContext(ctx context.Context, x, y int, direction string, clicks int) *core.OperationResult {
+	request := AutomationRequest{
+		Action: "scroll",
+		Parameters: map[string]interface{}{
+			"x":         x,
+			"y":         y,
+			"direction": direction,
+			"clicks":    clicks,
+		},
+	}
+	return e.executeCommandContext(ctx, request)
 }
 
 // Type 输入文本
 func (e *ExternalEngine) Type(text string) *core.OperationResult {
+	return e.TypeContext(context.Background(), text)
+}
+
+// TypeContext 支持取消的输入文本
+func (e *ExternalEngine) TypeContext(ctx context.Context, text string) *core.OperationResult {
 	request := AutomationRequest{
 		Action: "type",
 		Parameters: map[string]interface{}{
 			"text": text,
 		},
 	}
-	return e.executeCommand(request)
+	return e.executeCommandContext(ctx, request)
 }
 
 // KeyPress 按键操作
 func (e *ExternalEngine) KeyPress(key string) *core.OperationResult {
+	return e.KeyPressContext(context.Background(), key)
+}
+
+// KeyPressContext 支持取消的按键操作
+func (e *ExternalEngine) KeyPressContext(ctx context.Context, key string) *core.OperationResult {
 	request := AutomationRequest{
 		Action: "keypress",
 		Parameters: map[string]interface{}{
 			"key": key,
 		},
 	}
-	return e.executeCommand(request)
+	return e.executeCommandContext(ctx, request)
+}
+
+// KeyDown 按下按键
+func (e *ExternalEngine) KeyDown(key string) *core.OperationResult {
+	return e.KeyDownContext(context.Background(), key)
+}
+
+// KeyDownContext 支持取消的按下按键
+func (e *ExternalEngine) KeyDownContext(ctx context.Context, key string) *core.OperationResult {
+	request := AutomationRequest{
+		Action: "key_down",
+		Parameters: map[string]interface{}{
+			"key": key,
+		},
+	}
+	return e.executeCommandContext(ctx, request)
+}
+
+// KeyUp 释放按键
+func (e *ExternalEngine) KeyUp(key string) *core.OperationResult {
+	return e.KeyUpContext(context.Background(), key)
+}
+
+// KeyUpContext 支持取消的释放按键
+func (e *ExternalEngine) KeyUpContext(ctx context.Context, key string) *core.OperationResult {
+	request := AutomationRequest{
+		Action: "key_up",
+		Parameters: map[string]interface{}{
+			"key": key,
+		},
+	}
+	return e.executeCommandContext(ctx, request)
+}
+
+// Hotkey 组合键操作
+func (e *ExternalEngine) Hotkey(modifiers []core.KeyModifier, key string) *core.OperationResult {
+	return e.HotkeyContext(context.Background(), modifiers, key)
+}
+
+// HotkeyContext 支持取消的组合键操作
+func (e *ExternalEngine) HotkeyContext(ctx context.Context, modifiers []core.KeyModifier, key string) *core.OperationResult {
+	names := make([]string, len(modifiers))
+	for i, modifier := range modifiers {
+		names[i] = string(modifier)
+	}
+
+	request := AutomationRequest{
+		Action: "hotkey",
+		Parameters: map[string]interface{}{
+			"key":       key,
+			"modifiers": names,
+		},
+	}
+	return e.executeCommandContext(ctx, request)
+}
+
+// Screenshot 截取主显示器
+func (e *ExternalEngine) Screenshot() ([]byte, *core.OperationResult) {
+	return e.ScreenshotContext(context.Background())
+}
+
+// ScreenshotContext 支持取消的截取主显示器
+func (e *ExternalEngine) ScreenshotContext(ctx context.Context) ([]byte, *core.OperationResult) {
+	return e.ScreenshotDisplayContext(ctx, 0)
+}
+
+// ScreenshotDisplay 截取指定显示器
+func (e *ExternalEngine) ScreenshotDisplay(displayIndex int) ([]byte, *core.OperationResult) {
+	return e.ScreenshotDisplayContext(context.Background(), displayIndex)
+}
+
+// ScreenshotDisplayContext 支持取消的截取指定显示器
+func (e *ExternalEngine) ScreenshotDisplayContext(ctx context.Context, displayIndex int) ([]byte, *core.OperationResult) {
+	request := AutomationRequest{
+		Action: "screenshot",
+		Parameters: map[string]interface{}{
+			"display": displayIndex,
+		},
+	}
+	return e.toCaptureResult(e.executeCommandContext(ctx, request))
+}
+
+// ScreenshotArea 截取指定区域
+func (e *ExternalEngine) ScreenshotArea(rect core.Rect) ([]byte, *core.OperationResult) {
+	return e.ScreenshotAreaContext(context.Background(), rect)
+}
+
+// ScreenshotAreaContext 支持取消的截取指定区域
+func (e *ExternalEngine) ScreenshotAreaContext(ctx context.Context, rect core.Rect) ([]byte, *core.OperationResult) {
+	request := AutomationRequest{
+		Action: "screenshot_area",
+		Parameters: map[string]interface{}{
+			"x":      rect.X,
+			"y":      rect.Y,
+			"width":  rect.Width,
+			"height": rect.Height,
+		},
+	}
+	return e.toCaptureResult(e.executeCommandContext(ctx, request))
+}
+
+// GetScreenSize 获取主屏幕尺寸
+func (e *ExternalEngine) GetScreenSize() (*core.Size, *core.OperationResult) {
+	return e.GetScreenSizeContext(context.Background())
+}
+
+// GetScreenSizeContext 支持取消的获取主屏幕尺寸
+func (e *ExternalEngine) GetScreenSizeContext(ctx context.Context) (*core.Size, *core.OperationResult) {
+	result := e.executeCommandContext(ctx, AutomationRequest{Action: "screen_size"})
+	if !result.Success {
+		return nil, result
+	}
+
+	data, _ := result.Data.(map[string]interface{})
+	size := &core.Size{Width: intValue(data["width"]), Height: intValue(data["height"])}
+	result.Data = size
+	return size, result
+}
+
+// GetClipboard 获取剪贴板内容
+func (e *ExternalEngine) GetClipboard() (string, *core.OperationResult) {
+	return e.GetClipboardContext(context.Background())
+}
+
+// GetClipboardContext 支持取消的获取剪贴板内容
+func (e *ExternalEngine) GetClipboardContext(ctx context.Context) (string, *core.OperationResult) {
+	result := e.executeCommandContext(ctx, AutomationRequest{Action: "get_clipboard"})
+	if !result.Success {
+		return "", result
+	}
+
+	data, _ := result.Data.(map[string]interface{})
+	text, _ := data["text"].(string)
+	return text, result
+}
+
+// SetClipboard 设置剪贴板内容
+func (e *ExternalEngine) SetClipboard(text string) *core.OperationResult {
+	return e.SetClipboardContext(context.Background(), text)
 }
 
-// Screenshot 截屏
-func (e *ExternalEngine) Screenshot() *core.OperationResult {
+// SetClipboardContext 支持取消的设置剪贴板内容
+func (e *ExternalEngine) SetClipboardContext(ctx context.Context, text string) *core.OperationResult {
 	request := AutomationRequest{
-		Action:     "screenshot",
-		Parameters: map[string]interface{}{},
+		Action: "set_clipboard",
+		Parameters: map[string]interface{}{
+			"text": text,
+		},
 	}
-	return e.executeCommand(request)
+	return e.executeCommandContext(ctx, request)
+}
+
+// toCaptureResult 将worker返回的base64图像转换为统一的截图结果
+func (e *ExternalEngine) toCaptureResult(result *core.OperationResult) ([]byte, *core.OperationResult) {
+	if !result.Success {
+		return nil, result
+	}
+
+	data, _ := result.Data.(map[string]interface{})
+	encoded, _ := data["image"].(string)
+	imageData, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(imageData) == 0 {
+		if err == nil {
+			err = fmt.Errorf("worker returned no image data")
+		}
+		failed := core.NewErrorResult("invalid screenshot data from worker", err)
+		failed.Duration = result.Duration
+		return nil, failed
+	}
+
+	displayIndex := core.DisplayIndexVirtual
+	if value, ok := data["display_index"].(float64); ok {
+		displayIndex = int(value)
+	}
+	var bounds core.Rect
+	if value, ok := data["bounds"].(map[string]interface{}); ok {
+		bounds = core.Rect{
+			X:      intValue(value["x"]),
+			Y:      intValue(value["y"]),
+			Width:  intValue(value["width"]),
+			Height: intValue(value["height"]),
+		}
+	}
+
+	capture, err := core.NewScreenCapture(imageData, displayIndex, bounds)
+	if err != nil {
+		failed := core.NewErrorResult("invalid screenshot data from worker", err)
+		failed.Duration = result.Duration
+		return nil, failed
+	}
+
+	captured := core.NewCaptureResult(result.Message, capture)
+	captured.Duration = result.Duration
+	return imageData, captured
+}
+
+// intValue 将JSON数字转换为int
+func intValue(value interface{}) int {
+	if number, ok := value.(float64); ok {
+		return int(number)
+	}
+	return 0
+}
+
+// workerActions ExternalEngine的操作及其依赖的worker动作
+var workerActions = map[core.Operation]string{
+	core.OpClick:          "click",
+	core.OpDoubleClick:    "click",
+	core.OpRightClick:     "click",
+	core.OpDrag:           "drag",
+	core.OpMove:           "move",
+	core.OpGetPosition:    "get_position",
+	core.OpScroll:         "scroll",
+	core.OpType:           "type",
+	core.OpKeyPress:       "keypress",
+	core.OpKeyDown:        "key_down",
+	core.OpKeyUp:          "key_up",
+	core.OpHotkey:         "hotkey",
+	core.OpScreenshot:     "screenshot",
+	core.OpScreenshotArea: "screenshot_area",
+	core.OpGetScreenSize:  "screen_size",
+	core.OpGetClipboard:   "get_clipboard",
+	core.OpSetClipboard:   "set_clipboard",
+}
+
+// Capabilities 根据最近一次握手信息描述外部引擎的能力
+// 不会启动worker，worker尚未启动或不可用时不报告任何操作
+func (e *ExternalEngine) Capabilities() *core.Capabilities {
+	if !e.IsAvailable() {
+		return &core.Capabilities{Engine: "external"}
+	}
+	return handshakeCapabilities(e.supervisor.Handshake())
+}
+
+// CapabilitiesContext 描述外部引擎的能力，worker尚未启动时先启动并完成握手
+// 启动受ctx控制，ctx取消或启动失败时不报告任何操作
+func (e *ExternalEngine) CapabilitiesContext(ctx context.Context) *core.Capabilities {
+	if !e.IsAvailable() {
+		return &core.Capabilities{Engine: "external"}
+	}
+	if e.supervisor.Handshake() == nil {
+		if _, err := e.supervisor.ensureClient(ctx); err != nil {
+			return &core.Capabilities{Engine: "external"}
+		}
+	}
+	return handshakeCapabilities(e.supervisor.Handshake())
+}
+
+// handshakeCapabilities 将握手信息转换为能力描述
+func handshakeCapabilities(handshake *WorkerHandshake) *core.Capabilities {
+	caps := &core.Capabilities{Engine: "external"}
+	if handshake == nil {
+		return caps
+	}
+
+	actions := make(map[string]bool, len(handshake.Capabilities))
+	for _, action := range handshake.Capabilities {
+		actions[action] = true
+	}
+	for operation, action := range workerActions {
+		if actions[action] {
+			caps.Operations = append(caps.Operations, operation)
+		}
+	}
+	sort.Slice(caps.Operations, func(i, j int) bool { return caps.Operations[i] < caps.Operations[j] })
+
+	if d := handshake.Descriptor; d != nil {
+		caps.Platforms = d.Platforms
+		caps.Keys = d.Keys
+		caps.Modifiers = d.Modifiers
+		caps.MouseButtons = d.MouseButtons
+		caps.MultiMonitor = d.MultiMonitor
+		caps.UnicodeText = d.UnicodeText
+	}
+	return caps
 }
 
 // GetWorkerInfo 获取worker信息
@@ -180,13 +600,21 @@ func (e *ExternalEngine) GetWorkerInfo() map[string]interface{} {
 		"worker_path": e.workerPath,
 	}
 
+	if e.supervisor != nil {
+		info["status"] = e.supervisor.Status()
+		if handshake := e.supervisor.Handshake(); handshake != nil {
+			info["protocol_version"] = handshake.ProtocolVersion
+			info["capabilities"] = handshake.Capabilities
+		}
+	}
+
 	if e.IsAvailable() {
 		// 尝试获取worker版本信息
 		request := AutomationRequest{
 			Action:     "info",
 			Parameters: map[string]interface{}{},
 		}
-		
+
 		result := e.executeCommand(request)
 		if result.Success {
 			if data, ok := result.Data.(map[string]interface{}); ok {
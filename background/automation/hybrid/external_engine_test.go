@@ -0,0 +1,103 @@
+package hybrid
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"diandian/background/automation/core"
+)
+
+// fakeWorkerEnv 设置该环境变量时测试程序作为worker运行
+const fakeWorkerEnv = "HYBRID_FAKE_WORKER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeWorkerEnv) != "" {
+		runFakeWorker()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeWorker 只完成握手和心跳，收到其他请求时不响应直接退出，模拟执行中崩溃的worker
+func runFakeWorker() {
+	scanner := bufio.NewScanner(os.Stdin)
+	encoder := json.NewEncoder(os.Stdout)
+	for scanner.Scan() {
+		var request RPCRequest
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			continue
+		}
+
+		response := RPCResponse{JSONRPC: "2.0", ID: request.ID, Result: &AutomationResponse{Success: true}}
+		switch request.Method {
+		case workerMethodHandshake:
+			response.Result.Data = map[string]interface{}{
+				"version":          "fake",
+				"protocol_version": WorkerProtocolVersion,
+				"engine":           "fake",
+			}
+		case workerMethodPing:
+		default:
+			os.Exit(1)
+		}
+		encoder.Encode(response)
+	}
+}
+
+// newFakeExternalEngine 创建以测试程序为worker的外部引擎
+func newFakeExternalEngine(t *testing.T) *ExternalEngine {
+	t.Helper()
+	t.Setenv(fakeWorkerEnv, "1")
+	workerPath, err := os.Executable()
+	if err != nil {
+		t.Fatalf("获取测试程序路径失败: %v", err)
+	}
+	engine := &ExternalEngine{
+		workerPath: workerPath,
+		available:  true,
+		supervisor: newWorkerSupervisor(workerPath, DefaultWorkerSupervisorConfig()),
+	}
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+func TestExternalEngineWorkerDiesMidRequest(t *testing.T) {
+	external := newFakeExternalEngine(t)
+	fallback := newVirtualDesktop(t)
+	engine := NewHybridEngineWithBackend("external", external)
+	engine.AddBackend("virtual", fallback)
+
+	result := engine.ClickContext(context.Background(), 10, 10, core.LeftButton)
+	if result.Success {
+		t.Fatal("worker中途退出后点击不应成功")
+	}
+	if result.Code == core.ErrEngineUnavailable || result.Code == core.ErrUnsupported {
+		t.Errorf("Code = %s，请求发出后会话中断不应视为引擎不可用", result.Code)
+	}
+	if actions := fallback.Actions(); len(actions) != 0 {
+		t.Errorf("worker中途退出后回退到第二个后端执行了 %d 个操作", len(actions))
+	}
+}
+
+func TestExternalEngineCapabilitiesStartup(t *testing.T) {
+	external := newFakeExternalEngine(t)
+
+	external.Capabilities()
+	if state := external.supervisor.State(); state != WorkerStateStopped {
+		t.Fatalf("Capabilities后worker状态 = %s，期望不启动worker", state)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if caps := external.CapabilitiesContext(ctx); len(caps.Operations) != 0 {
+		t.Errorf("取消后的能力 = %v，期望为空", caps.Operations)
+	}
+
+	external.CapabilitiesContext(context.Background())
+	if state := external.supervisor.State(); state != WorkerStateReady {
+		t.Errorf("CapabilitiesContext后worker状态 = %s，期望 %s", state, WorkerStateReady)
+	}
+}
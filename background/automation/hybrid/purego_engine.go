@@ -1,8 +1,13 @@
 package hybrid
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/png"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"diandian/background/automation/core"
@@ -10,6 +15,9 @@ import (
 	"github.com/micmonay/keybd_event"
 )
 
+// errUnsupported 当前平台没有可用的实现
+var errUnsupported = core.NewError(core.ErrUnsupported, "unsupported platform", nil)
+
 // PureGoEngine 纯Go实现的自动化引擎
 type PureGoEngine struct {
 	keybd *keybd_event.KeyBonding
@@ -37,29 +45,31 @@ func (p *PureGoEngine) IsAvailable() bool {
 	return p.keybd != nil
 }
 
-// Click 点击操作 - 纯Go版本有限制
-func (p *PureGoEngine) Click(x, y int) *core.OperationResult {
+// Click 点击操作 - 通过平台工具实现
+func (p *PureGoEngine) Click(x, y int, button core.MouseButton) *core.OperationResult {
+	return p.click(x, y, button, 1)
+}
+
+// click 按平台执行点击，count为连续点击次数
+func (p *PureGoEngine) click(x, y int, button core.MouseButton, count int) *core.OperationResult {
 	start := time.Now()
 
-	// 纯Go版本暂时不支持鼠标点击
-	// 这里可以根据平台使用不同的实现
+	if button == "" {
+		button = core.LeftButton
+	}
+
 	switch runtime.GOOS {
 	case "windows":
-		// Windows下可以使用go-hook或系统调用
-		return p.clickWindows(x, y)
+		// Windows下通过PowerShell调用user32
+		return p.clickWindows(x, y, button, count)
 	case "linux":
-		// Linux下可以使用xdotool命令或X11调用
-		return p.clickLinux(x, y)
+		// Linux下使用xdotool
+		return p.clickLinux(x, y, button, count)
 	case "darwin":
-		// macOS下可以使用CGEvent
-		return p.clickMacOS(x, y)
+		// macOS下使用AppleScript
+		return p.clickMacOS(x, y, button, count)
 	default:
-		result := core.NewErrorResult(
-			fmt.Sprintf("click not supported on %s", runtime.GOOS),
-			fmt.Errorf("unsupported platform"),
-		)
-		result.SetDuration(start)
-		return result
+		return unsupportedResult(start, core.OpClick)
 	}
 }
 
@@ -67,6 +77,18 @@ func (p *PureGoEngine) Click(x, y int) *core.OperationResult {
 func (p *PureGoEngine) Type(text string) *core.OperationResult {
 	start := time.Now()
 
+	// 存在无法映射为按键的字符时整体失败，由混合引擎交给支持Unicode输入的后端
+	for _, char := range text {
+		if p.charToVK(char) == 0 {
+			result := core.NewErrorResult(
+				fmt.Sprintf("cannot type character %q", char),
+				core.NewError(core.ErrUnsupported, "pure go engine only types letters, digits, space, tab and newline", nil),
+			)
+			result.SetDuration(start)
+			return result
+		}
+	}
+
 	// 清除之前的按键设置
 	p.keybd.Clear()
 
@@ -74,9 +96,6 @@ func (p *PureGoEngine) Type(text string) *core.OperationResult {
 	for _, char := range text {
 		// 将字符转换为按键码
 		vk := p.charToVK(char)
-		if vk == 0 {
-			continue // 跳过不支持的字符
-		}
 
 		p.keybd.SetKeys(vk)
 
@@ -121,7 +140,7 @@ func (p *PureGoEngine) KeyPress(key string) *core.OperationResult {
 	if vk == 0 {
 		result := core.NewErrorResult(
 			fmt.Sprintf("unsupported key: %s", key),
-			fmt.Errorf("unknown key"),
+			core.NewError(core.ErrUnsupported, "unknown key", nil),
 		)
 		result.SetDuration(start)
 		return result
@@ -151,25 +170,216 @@ func (p *PureGoEngine) KeyPress(key string) *core.OperationResult {
 	return result
 }
 
+// KeyDown 按下按键
+func (p *PureGoEngine) KeyDown(key string) *core.OperationResult {
+	return p.toggleKey(key, true)
+}
+
+// KeyUp 释放按键
+func (p *PureGoEngine) KeyUp(key string) *core.OperationResult {
+	return p.toggleKey(key, false)
+}
+
+// toggleKey 按下或释放单个按键
+func (p *PureGoEngine) toggleKey(key string, down bool) *core.OperationResult {
+	start := time.Now()
+
+	vk := p.keyNameToVK(key)
+	if vk == 0 {
+		result := core.NewErrorResult(
+			fmt.Sprintf("unsupported key: %s", key),
+			core.NewError(core.ErrUnsupported, "unknown key", nil),
+		)
+		result.SetDuration(start)
+		return result
+	}
+
+	p.keybd.Clear()
+	p.keybd.SetKeys(vk)
+
+	var err error
+	state := "up"
+	if down {
+		state = "down"
+		err = p.keybd.Press()
+	} else {
+		err = p.keybd.Release()
+	}
+	if err != nil {
+		result := core.NewErrorResult(
+			fmt.Sprintf("failed to toggle key %s %s", key, state),
+			err,
+		)
+		result.SetDuration(start)
+		return result
+	}
+
+	result := core.NewSuccessResult(
+		fmt.Sprintf("key %s: %s", state, key),
+		map[string]interface{}{
+			"key":   key,
+			"state": state,
+		},
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// Hotkey 组合键操作，如 Ctrl+S
+func (p *PureGoEngine) Hotkey(modifiers []core.KeyModifier, key string) *core.OperationResult {
+	start := time.Now()
+
+	vk := p.keyNameToVK(strings.ToLower(key))
+	if vk == 0 {
+		result := core.NewErrorResult(
+			fmt.Sprintf("unsupported key: %s", key),
+			core.NewError(core.ErrUnsupported, "unknown key", nil),
+		)
+		result.SetDuration(start)
+		return result
+	}
+
+	p.keybd.Clear()
+	p.keybd.SetKeys(vk)
+	for _, modifier := range modifiers {
+		switch modifier {
+		case core.ModCtrl:
+			p.keybd.HasCTRL(true)
+		case core.ModAlt:
+			p.keybd.HasALT(true)
+		case core.ModShift:
+			p.keybd.HasSHIFT(true)
+		case core.ModWin:
+			// macOS上对应Command键
+			p.keybd.HasSuper(true)
+		default:
+			p.keybd.Clear()
+			result := core.NewErrorResult(
+				fmt.Sprintf("unsupported modifier: %s", modifier),
+				core.NewError(core.ErrUnsupported, "unknown modifier", nil),
+			)
+			result.SetDuration(start)
+			return result
+		}
+	}
+
+	err := p.keybd.Launching()
+	p.keybd.Clear()
+	if err != nil {
+		result := core.NewErrorResult(
+			fmt.Sprintf("failed to press hotkey: %v+%s", modifiers, key),
+			err,
+		)
+		result.SetDuration(start)
+		return result
+	}
+
+	result := core.NewSuccessResult(
+		fmt.Sprintf("pressed hotkey: %v+%s", modifiers, key),
+		map[string]interface{}{
+			"key":       key,
+			"modifiers": modifiers,
+		},
+	)
+	result.SetDuration(start)
+	return result
+}
+
 // Screenshot 截屏 - 使用系统调用
-func (p *PureGoEngine) Screenshot() *core.OperationResult {
+func (p *PureGoEngine) Screenshot() ([]byte, *core.OperationResult) {
 	start := time.Now()
 
+	var result *core.OperationResult
 	switch runtime.GOOS {
 	case "windows":
-		return p.screenshotWindows()
+		result = p.screenshotWindows()
 	case "linux":
-		return p.screenshotLinux()
+		result = p.screenshotLinux()
 	case "darwin":
-		return p.screenshotMacOS()
+		result = p.screenshotMacOS()
 	default:
+		return nil, unsupportedResult(start, core.OpScreenshot)
+	}
+
+	if capture, ok := core.CaptureFromResult(result); ok {
+		return capture.ImageData, result
+	}
+	return nil, result
+}
+
+// ScreenshotArea 截取指定区域：先截取整个屏幕，再按缩放比例裁剪
+func (p *PureGoEngine) ScreenshotArea(rect core.Rect) ([]byte, *core.OperationResult) {
+	start := time.Now()
+
+	_, full := p.Screenshot()
+	capture, ok := core.CaptureFromResult(full)
+	if !ok {
+		return nil, full
+	}
+
+	imageData, err := cropCapture(capture, rect)
+	if err != nil {
 		result := core.NewErrorResult(
-			fmt.Sprintf("screenshot not supported on %s", runtime.GOOS),
-			fmt.Errorf("unsupported platform"),
+			fmt.Sprintf("failed to capture area (%d,%d,%d,%d)", rect.X, rect.Y, rect.Width, rect.Height),
+			err,
 		)
 		result.SetDuration(start)
+		return nil, result
+	}
+
+	return imageData, p.captureResult(start, imageData, core.DisplayIndexVirtual, rect)
+}
+
+// captureResult 创建统一的截图结果
+func (p *PureGoEngine) captureResult(start time.Time, imageData []byte, displayIndex int, bounds core.Rect) *core.OperationResult {
+	capture, err := core.NewScreenCapture(imageData, displayIndex, bounds)
+	if err != nil {
+		result := core.NewErrorResult("failed to decode screenshot", err)
+		result.SetDuration(start)
 		return result
 	}
+
+	result := core.NewCaptureResult(
+		fmt.Sprintf("screenshot taken (%dx%d)", capture.Width, capture.Height),
+		capture,
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// cropCapture 从截图中裁剪出桌面坐标下的区域，返回PNG数据
+func cropCapture(capture *core.ScreenCapture, rect core.Rect) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		return nil, err
+	}
+
+	scale := capture.ScaleFactor
+	if scale <= 0 {
+		scale = 1
+	}
+	area := image.Rect(
+		int(float64(rect.X-capture.Bounds.X)*scale),
+		int(float64(rect.Y-capture.Bounds.Y)*scale),
+		int(float64(rect.X-capture.Bounds.X+rect.Width)*scale),
+		int(float64(rect.Y-capture.Bounds.Y+rect.Height)*scale),
+	).Intersect(img.Bounds())
+	if area.Empty() {
+		return nil, fmt.Errorf("area is outside of the captured screen")
+	}
+
+	subImager, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, fmt.Errorf("image type %T does not support cropping", img)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, subImager.SubImage(area)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // charToVK 将字符转换为虚拟键码
@@ -188,38 +398,44 @@ func (p *PureGoEngine) charToVK(char rune) int {
 	case char == '\t':
 		return keybd_event.VK_TAB
 	default:
-		return 0 // 不支持的字符
+		return 0 // 不支持的字符，见core.IsBasicTextRune
 	}
 }
 
+// pureGoKeys 纯Go引擎支持的具名按键及其虚拟键码
+var pureGoKeys = map[string]int{
+	"enter":     keybd_event.VK_ENTER,
+	"space":     keybd_event.VK_SPACE,
+	"tab":       keybd_event.VK_TAB,
+	"escape":    keybd_event.VK_ESC,
+	"backspace": keybd_event.VK_BACKSPACE,
+	"delete":    keybd_event.VK_DELETE,
+	"up":        keybd_event.VK_UP,
+	"down":      keybd_event.VK_DOWN,
+	"left":      keybd_event.VK_LEFT,
+	"right":     keybd_event.VK_RIGHT,
+	"home":      keybd_event.VK_HOME,
+	"end":       keybd_event.VK_END,
+	"pageup":    keybd_event.VK_PAGEUP,
+	"pagedown":  keybd_event.VK_PAGEDOWN,
+	"esc":       keybd_event.VK_ESC,
+	"f1":        keybd_event.VK_F1,
+	"f2":        keybd_event.VK_F2,
+	"f3":        keybd_event.VK_F3,
+	"f4":        keybd_event.VK_F4,
+	"f5":        keybd_event.VK_F5,
+	"f6":        keybd_event.VK_F6,
+	"f7":        keybd_event.VK_F7,
+	"f8":        keybd_event.VK_F8,
+	"f9":        keybd_event.VK_F9,
+	"f10":       keybd_event.VK_F10,
+	"f11":       keybd_event.VK_F11,
+	"f12":       keybd_event.VK_F12,
+}
+
 // keyNameToVK 将按键名称转换为虚拟键码
 func (p *PureGoEngine) keyNameToVK(keyName string) int {
-	keyMap := map[string]int{
-		"enter":     keybd_event.VK_ENTER,
-		"space":     keybd_event.VK_SPACE,
-		"tab":       keybd_event.VK_TAB,
-		"escape":    keybd_event.VK_ESC,
-		"backspace": keybd_event.VK_BACKSPACE,
-		"delete":    keybd_event.VK_DELETE,
-		"up":        keybd_event.VK_UP,
-		"down":      keybd_event.VK_DOWN,
-		"left":      keybd_event.VK_LEFT,
-		"right":     keybd_event.VK_RIGHT,
-		"f1":        keybd_event.VK_F1,
-		"f2":        keybd_event.VK_F2,
-		"f3":        keybd_event.VK_F3,
-		"f4":        keybd_event.VK_F4,
-		"f5":        keybd_event.VK_F5,
-		"f6":        keybd_event.VK_F6,
-		"f7":        keybd_event.VK_F7,
-		"f8":        keybd_event.VK_F8,
-		"f9":        keybd_event.VK_F9,
-		"f10":       keybd_event.VK_F10,
-		"f11":       keybd_event.VK_F11,
-		"f12":       keybd_event.VK_F12,
-	}
-
-	if vk, exists := keyMap[keyName]; exists {
+	if vk, exists := pureGoKeys[keyName]; exists {
 		return vk
 	}
 
@@ -230,3 +446,32 @@ func (p *PureGoEngine) keyNameToVK(keyName string) int {
 
 	return 0
 }
+
+// Capabilities 纯Go引擎的能力描述
+// 键盘输入基于虚拟键码，只能输入字母、数字、空格、Tab和换行；macOS上只能模拟单次左键点击
+func (p *PureGoEngine) Capabilities() *core.Capabilities {
+	keys := make([]string, 0, len(pureGoKeys))
+	for key := range pureGoKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	caps := &core.Capabilities{
+		Engine:       "pure_go",
+		Platforms:    []string{"windows", "linux", "darwin"},
+		Keys:         keys,
+		Modifiers:    []core.KeyModifier{core.ModAlt, core.ModCtrl, core.ModShift, core.ModWin},
+		MouseButtons: []core.MouseButton{core.LeftButton, core.MiddleButton, core.RightButton},
+	}
+
+	for _, operation := range core.ImplementedOperations(p) {
+		if runtime.GOOS == "darwin" && (operation == core.OpDoubleClick || operation == core.OpRightClick) {
+			continue
+		}
+		caps.Operations = append(caps.Operations, operation)
+	}
+	if runtime.GOOS == "darwin" {
+		caps.MouseButtons = []core.MouseButton{core.LeftButton}
+	}
+	return caps
+}
@@ -0,0 +1,220 @@
+package hybrid
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// DoubleClick 双击
+func (p *PureGoEngine) DoubleClick(x, y int) *core.OperationResult {
+	return p.click(x, y, core.LeftButton, 2)
+}
+
+// RightClick 右键点击
+func (p *PureGoEngine) RightClick(x, y int) *core.OperationResult {
+	return p.click(x, y, core.RightButton, 1)
+}
+
+// Move 移动鼠标
+func (p *PureGoEngine) Move(x, y int) *core.OperationResult {
+	start := time.Now()
+
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		_, err = runPowerShell(fmt.Sprintf(`
+Add-Type -AssemblyName System.Windows.Forms
+[System.Windows.Forms.Cursor]::Position = New-Object System.Drawing.Point(%d, %d)
+`, x, y))
+	case "linux":
+		_, err = runCommand("xdotool", "mousemove", strconv.Itoa(x), strconv.Itoa(y))
+	default:
+		return unsupportedResult(start, core.OpMove)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult(fmt.Sprintf("failed to move mouse to (%d, %d)", x, y), err)
+		result.SetDuration(start)
+		return result
+	}
+
+	result := core.NewSuccessResult(
+		fmt.Sprintf("moved to (%d, %d)", x, y),
+		map[string]interface{}{
+			"x": x,
+			"y": y,
+		},
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// Drag 拖拽：在起点按下左键，移动到终点后释放
+func (p *PureGoEngine) Drag(fromX, fromY, toX, toY int) *core.OperationResult {
+	start := time.Now()
+
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		_, err = runPowerShell(windowsMouseType + fmt.Sprintf(`
+[System.Windows.Forms.Cursor]::Position = New-Object System.Drawing.Point(%d, %d)
+[Mouse]::mouse_event(0x02, 0, 0, 0, [IntPtr]::Zero)
+Start-Sleep -Milliseconds 100
+[System.Windows.Forms.Cursor]::Position = New-Object System.Drawing.Point(%d, %d)
+Start-Sleep -Milliseconds 100
+[Mouse]::mouse_event(0x04, 0, 0, 0, [IntPtr]::Zero)
+`, fromX, fromY, toX, toY))
+	case "linux":
+		_, err = runCommand("xdotool",
+			"mousemove", strconv.Itoa(fromX), strconv.Itoa(fromY), "mousedown", "1",
+			"sleep", "0.1",
+			"mousemove", strconv.Itoa(toX), strconv.Itoa(toY),
+			"sleep", "0.1", "mouseup", "1")
+	default:
+		return unsupportedResult(start, core.OpDrag)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult(
+			fmt.Sprintf("failed to drag from (%d, %d) to (%d, %d)", fromX, fromY, toX, toY),
+			err,
+		)
+		result.SetDuration(start)
+		return result
+	}
+
+	result := core.NewSuccessResult(
+		fmt.Sprintf("dragged from (%d, %d) to (%d, %d)", fromX, fromY, toX, toY),
+		map[string]interface{}{
+			"from_x": fromX,
+			"from_y": fromY,
+			"to_x":   toX,
+			"to_y":   toY,
+		},
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// GetPosition 获取当前鼠标位置
+func (p *PureGoEngine) GetPosition() (*core.Point, *core.OperationResult) {
+	start := time.Now()
+
+	var point core.Point
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		var output string
+		output, err = runPowerShell(`
+Add-Type -AssemblyName System.Windows.Forms
+$p = [System.Windows.Forms.Cursor]::Position
+Write-Output ("{0},{1}" -f $p.X, $p.Y)
+`)
+		if err == nil {
+			_, err = fmt.Sscanf(output, "%d,%d", &point.X, &point.Y)
+		}
+	case "linux":
+		var output string
+		output, err = runCommand("xdotool", "getmouselocation", "--shell")
+		if err == nil {
+			values := parseShellVars(output)
+			point.X, _ = strconv.Atoi(values["X"])
+			point.Y, _ = strconv.Atoi(values["Y"])
+		}
+	default:
+		return nil, unsupportedResult(start, core.OpGetPosition)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult("failed to get mouse position", err)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	result := core.NewSuccessResult(
+		fmt.Sprintf("mouse position: (%d, %d)", point.X, point.Y),
+		&point,
+	)
+	result.SetDuration(start)
+	return &point, result
+}
+
+// Scroll 在指定位置滚动，direction为up/down/left/right
+func (p *PureGoEngine) Scroll(x, y int, direction string, clicks int) *core.OperationResult {
+	start := time.Now()
+
+	if clicks <= 0 {
+		clicks = 1
+	}
+
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		// MOUSEEVENTF_WHEEL / MOUSEEVENTF_HWHEEL，每格120
+		flag, delta := 0x0800, 0
+		switch direction {
+		case "up":
+			delta = 120 * clicks
+		case "down":
+			delta = -120 * clicks
+		case "left":
+			flag, delta = 0x01000, -120*clicks
+		case "right":
+			flag, delta = 0x01000, 120*clicks
+		default:
+			err = fmt.Errorf("invalid scroll direction: %s", direction)
+		}
+		if err == nil {
+			_, err = runPowerShell(windowsMouseType + fmt.Sprintf(`
+[System.Windows.Forms.Cursor]::Position = New-Object System.Drawing.Point(%d, %d)
+[Mouse]::mouse_event(%d, 0, 0, %d, [IntPtr]::Zero)
+`, x, y, flag, delta))
+		}
+	case "linux":
+		// X11中滚轮对应按键4-7
+		buttons := map[string]string{"up": "4", "down": "5", "left": "6", "right": "7"}
+		button, ok := buttons[direction]
+		if !ok {
+			err = fmt.Errorf("invalid scroll direction: %s", direction)
+			break
+		}
+		_, err = runCommand("xdotool", "mousemove", strconv.Itoa(x), strconv.Itoa(y),
+			"click", "--repeat", strconv.Itoa(clicks), button)
+	default:
+		return unsupportedResult(start, core.OpScroll)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult(fmt.Sprintf("failed to scroll %s at (%d, %d)", direction, x, y), err)
+		result.SetDuration(start)
+		return result
+	}
+
+	result := core.NewSuccessResult(
+		fmt.Sprintf("scrolled %s %d clicks at (%d, %d)", direction, clicks, x, y),
+		map[string]interface{}{
+			"x":         x,
+			"y":         y,
+			"direction": direction,
+			"clicks":    clicks,
+		},
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// parseShellVars 解析xdotool --shell输出的KEY=VALUE行
+func parseShellVars(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
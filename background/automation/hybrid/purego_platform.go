@@ -1,9 +1,14 @@
 package hybrid
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"diandian/background/automation/core"
@@ -12,29 +17,46 @@ import (
 // 平台特定的实现
 
 // Windows平台实现
-func (p *PureGoEngine) clickWindows(x, y int) *core.OperationResult {
-	start := time.Now()
-	
-	// 使用PowerShell调用Windows API
-	cmd := exec.Command("powershell", "-Command", 
-		fmt.Sprintf(`
+
+// windowsMouseType 通过PowerShell调用user32鼠标API的类型定义
+const windowsMouseType = `
 Add-Type -AssemblyName System.Windows.Forms
-[System.Windows.Forms.Cursor]::Position = New-Object System.Drawing.Point(%d, %d)
 Add-Type -TypeDefinition '
 using System;
 using System.Runtime.InteropServices;
 public class Mouse {
     [DllImport("user32.dll")]
-    public static extern void mouse_event(uint dwFlags, uint dx, uint dy, uint dwData, IntPtr dwExtraInfo);
-    public const uint MOUSEEVENTF_LEFTDOWN = 0x02;
-    public const uint MOUSEEVENTF_LEFTUP = 0x04;
+    public static extern void mouse_event(uint dwFlags, int dx, int dy, int dwData, IntPtr dwExtraInfo);
 }
 '
-[Mouse]::mouse_event(0x02, 0, 0, 0, [IntPtr]::Zero)
-[Mouse]::mouse_event(0x04, 0, 0, 0, [IntPtr]::Zero)
-`, x, y))
+`
 
-	err := cmd.Run()
+// windowsButtonFlags 鼠标按键对应的按下/抬起标志
+func windowsButtonFlags(button core.MouseButton) (down, up uint32) {
+	switch button {
+	case core.RightButton:
+		return 0x08, 0x10
+	case core.MiddleButton:
+		return 0x20, 0x40
+	default:
+		return 0x02, 0x04
+	}
+}
+
+func (p *PureGoEngine) clickWindows(x, y int, button core.MouseButton, count int) *core.OperationResult {
+	start := time.Now()
+
+	// 使用PowerShell调用Windows API
+	down, up := windowsButtonFlags(button)
+	script := windowsMouseType + fmt.Sprintf(`
+[System.Windows.Forms.Cursor]::Position = New-Object System.Drawing.Point(%d, %d)
+for ($i = 0; $i -lt %d; $i++) {
+    [Mouse]::mouse_event(%d, 0, 0, 0, [IntPtr]::Zero)
+    [Mouse]::mouse_event(%d, 0, 0, 0, [IntPtr]::Zero)
+}
+`, x, y, count, down, up)
+
+	_, err := runPowerShell(script)
 	if err != nil {
 		result := core.NewErrorResult(
 			fmt.Sprintf("failed to click at (%d, %d)", x, y),
@@ -47,8 +69,10 @@ public class Mouse {
 	result := core.NewSuccessResult(
 		fmt.Sprintf("clicked at (%d, %d)", x, y),
 		map[string]interface{}{
-			"x": x,
-			"y": y,
+			"x":      x,
+			"y":      y,
+			"button": button,
+			"count":  count,
 		},
 	)
 	result.SetDuration(start)
@@ -57,9 +81,9 @@ public class Mouse {
 
 func (p *PureGoEngine) screenshotWindows() *core.OperationResult {
 	start := time.Now()
-	
-	// 使用PowerShell截屏
-	cmd := exec.Command("powershell", "-Command", `
+
+	// 使用PowerShell截屏，第一行输出主屏幕边界，第二行输出base64编码的PNG
+	cmd := exec.Command("powershell", "-Command", windowsDPIAware+`
 Add-Type -AssemblyName System.Windows.Forms
 Add-Type -AssemblyName System.Drawing
 $bounds = [System.Windows.Forms.Screen]::PrimaryScreen.Bounds
@@ -68,6 +92,7 @@ $graphics = [System.Drawing.Graphics]::FromImage($bitmap)
 $graphics.CopyFromScreen($bounds.Location, [System.Drawing.Point]::Empty, $bounds.Size)
 $ms = New-Object System.IO.MemoryStream
 $bitmap.Save($ms, [System.Drawing.Imaging.ImageFormat]::Png)
+Write-Output ("{0},{1},{2},{3}" -f $bounds.X, $bounds.Y, $bounds.Width, $bounds.Height)
 [System.Convert]::ToBase64String($ms.ToArray())
 `)
 
@@ -78,24 +103,46 @@ $bitmap.Save($ms, [System.Drawing.Imaging.ImageFormat]::Png)
 		return result
 	}
 
-	result := core.NewSuccessResult(
-		"screenshot taken",
-		map[string]interface{}{
-			"format": "png",
-			"data":   string(output),
-		},
-	)
-	result.SetDuration(start)
-	return result
+	lines := strings.Fields(string(output))
+	if len(lines) < 2 {
+		result := core.NewErrorResult("failed to take screenshot", fmt.Errorf("unexpected powershell output"))
+		result.SetDuration(start)
+		return result
+	}
+
+	var bounds core.Rect
+	fmt.Sscanf(lines[0], "%d,%d,%d,%d", &bounds.X, &bounds.Y, &bounds.Width, &bounds.Height)
+
+	imageData, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil {
+		result := core.NewErrorResult("failed to decode screenshot", err)
+		result.SetDuration(start)
+		return result
+	}
+
+	return p.captureResult(start, imageData, 0, bounds)
 }
 
 // Linux平台实现
-func (p *PureGoEngine) clickLinux(x, y int) *core.OperationResult {
+
+// xdotoolButton 鼠标按键对应的xdotool按键编号
+func xdotoolButton(button core.MouseButton) string {
+	switch button {
+	case core.RightButton:
+		return "3"
+	case core.MiddleButton:
+		return "2"
+	default:
+		return "1"
+	}
+}
+
+func (p *PureGoEngine) clickLinux(x, y int, button core.MouseButton, count int) *core.OperationResult {
 	start := time.Now()
-	
+
 	// 使用xdotool
-	cmd := exec.Command("xdotool", "mousemove", fmt.Sprintf("%d", x), fmt.Sprintf("%d", y), "click", "1")
-	err := cmd.Run()
+	_, err := runCommand("xdotool", "mousemove", strconv.Itoa(x), strconv.Itoa(y),
+		"click", "--repeat", strconv.Itoa(count), xdotoolButton(button))
 	if err != nil {
 		result := core.NewErrorResult(
 			fmt.Sprintf("failed to click at (%d, %d)", x, y),
@@ -108,8 +155,10 @@ func (p *PureGoEngine) clickLinux(x, y int) *core.OperationResult {
 	result := core.NewSuccessResult(
 		fmt.Sprintf("clicked at (%d, %d)", x, y),
 		map[string]interface{}{
-			"x": x,
-			"y": y,
+			"x":      x,
+			"y":      y,
+			"button": button,
+			"count":  count,
 		},
 	)
 	result.SetDuration(start)
@@ -118,15 +167,23 @@ func (p *PureGoEngine) clickLinux(x, y int) *core.OperationResult {
 
 func (p *PureGoEngine) screenshotLinux() *core.OperationResult {
 	start := time.Now()
-	
-	// 使用scrot或gnome-screenshot
+
+	// 使用scrot或gnome-screenshot，截取整个X屏幕
+	path, cleanup, err := tempScreenshotPath()
+	if err != nil {
+		result := core.NewErrorResult("failed to take screenshot", err)
+		result.SetDuration(start)
+		return result
+	}
+	defer cleanup()
+
 	var cmd *exec.Cmd
-	
+
 	// 尝试scrot
 	if _, err := exec.LookPath("scrot"); err == nil {
-		cmd = exec.Command("scrot", "-z", "-")
+		cmd = exec.Command("scrot", "-z", "-o", path)
 	} else if _, err := exec.LookPath("gnome-screenshot"); err == nil {
-		cmd = exec.Command("gnome-screenshot", "-f", "/dev/stdout")
+		cmd = exec.Command("gnome-screenshot", "-f", path)
 	} else {
 		result := core.NewErrorResult(
 			"no screenshot tool available (scrot or gnome-screenshot required)",
@@ -136,28 +193,32 @@ func (p *PureGoEngine) screenshotLinux() *core.OperationResult {
 		return result
 	}
 
-	output, err := cmd.Output()
-	if err != nil {
+	if err := cmd.Run(); err != nil {
 		result := core.NewErrorResult("failed to take screenshot", err)
 		result.SetDuration(start)
 		return result
 	}
 
-	result := core.NewSuccessResult(
-		"screenshot taken",
-		map[string]interface{}{
-			"format": "png",
-			"data":   output,
-		},
-	)
-	result.SetDuration(start)
-	return result
+	imageData, err := os.ReadFile(path)
+	if err != nil {
+		result := core.NewErrorResult("failed to read screenshot", err)
+		result.SetDuration(start)
+		return result
+	}
+
+	// X11下截图与桌面坐标一致，区域由图像尺寸决定
+	return p.captureResult(start, imageData, core.DisplayIndexVirtual, core.Rect{})
 }
 
 // macOS平台实现
-func (p *PureGoEngine) clickMacOS(x, y int) *core.OperationResult {
+func (p *PureGoEngine) clickMacOS(x, y int, button core.MouseButton, count int) *core.OperationResult {
 	start := time.Now()
-	
+
+	// AppleScript只能模拟单次左键点击
+	if button != core.LeftButton || count != 1 {
+		return unsupportedResult(start, core.OpClick)
+	}
+
 	// 使用osascript调用AppleScript
 	script := fmt.Sprintf(`
 tell application "System Events"
@@ -189,22 +250,103 @@ end tell
 
 func (p *PureGoEngine) screenshotMacOS() *core.OperationResult {
 	start := time.Now()
-	
-	// 使用screencapture
-	cmd := exec.Command("screencapture", "-t", "png", "-")
-	output, err := cmd.Output()
+
+	path, cleanup, err := tempScreenshotPath()
 	if err != nil {
 		result := core.NewErrorResult("failed to take screenshot", err)
 		result.SetDuration(start)
 		return result
 	}
+	defer cleanup()
 
-	result := core.NewSuccessResult(
-		"screenshot taken",
-		map[string]interface{}{
-			"format": "png",
-			"data":   output,
-		},
+	// 使用screencapture截取主显示器（-x 静音，-m 仅主显示器）
+	cmd := exec.Command("screencapture", "-x", "-m", "-t", "png", path)
+	if err := cmd.Run(); err != nil {
+		result := core.NewErrorResult("failed to take screenshot", err)
+		result.SetDuration(start)
+		return result
+	}
+
+	imageData, err := os.ReadFile(path)
+	if err != nil {
+		result := core.NewErrorResult("failed to read screenshot", err)
+		result.SetDuration(start)
+		return result
+	}
+
+	// Retina屏幕上图像为物理像素，边界使用逻辑坐标，以便计算缩放比例
+	var bounds core.Rect
+	script := `ObjC.import("AppKit"); var f = $.NSScreen.mainScreen.frame; f.size.width + "," + f.size.height`
+	if output, err := exec.Command("osascript", "-l", "JavaScript", "-e", script).Output(); err == nil {
+		var width, height float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%g,%g", &width, &height); err == nil {
+			bounds = core.Rect{Width: int(width), Height: int(height)}
+		}
+	}
+
+	return p.captureResult(start, imageData, 0, bounds)
+}
+
+// tempScreenshotPath 生成临时截图文件路径
+func tempScreenshotPath() (string, func(), error) {
+	file, err := os.CreateTemp("", "diandian-screenshot-*.png")
+	if err != nil {
+		return "", nil, err
+	}
+	path := file.Name()
+	file.Close()
+	return path, func() { os.Remove(path) }, nil
+}
+
+// runCommand 执行外部命令，返回去除首尾空白的标准输出
+func runCommand(name string, args ...string) (string, error) {
+	return runCommandInput("", name, args...)
+}
+
+// runCommandInput 执行外部命令并通过标准输入传入数据
+func runCommandInput(input string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// windowsDPIAware 让PowerShell进程开启Per-Monitor DPI感知
+// 否则在缩放比例不是100%的显示器上，光标位置和截图使用系统换算后的逻辑坐标，
+// 与主进程使用的虚拟桌面物理像素坐标不一致，点击会偏移
+const windowsDPIAware = `
+Add-Type -TypeDefinition '
+using System;
+using System.Runtime.InteropServices;
+public class DpiAwareness {
+    [DllImport("user32.dll")]
+    public static extern bool SetProcessDpiAwarenessContext(IntPtr value);
+}
+'
+try { [void][DpiAwareness]::SetProcessDpiAwarenessContext([IntPtr](-4)) } catch {}
+`
+
+// runPowerShell 执行PowerShell脚本，输出统一使用UTF-8编码，坐标使用物理像素
+func runPowerShell(script string) (string, error) {
+	script = "[Console]::OutputEncoding = [System.Text.Encoding]::UTF8\n" + windowsDPIAware + script
+	return runCommand("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+}
+
+// unsupportedResult 当前平台不支持该操作
+func unsupportedResult(start time.Time, operation core.Operation) *core.OperationResult {
+	result := core.NewErrorResult(
+		fmt.Sprintf("%s not supported on %s", operation, runtime.GOOS),
+		errUnsupported,
 	)
 	result.SetDuration(start)
 	return result
@@ -0,0 +1,471 @@
+package hybrid
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// windowsWindowType 通过PowerShell调用user32窗口API的类型定义
+const windowsWindowType = `
+Add-Type -TypeDefinition '
+using System;
+using System.Text;
+using System.Collections.Generic;
+using System.Runtime.InteropServices;
+public class WindowInfo {
+    public string Title { get; set; }
+    public string Class { get; set; }
+    public uint PID { get; set; }
+    public string Process { get; set; }
+    public long Handle { get; set; }
+    public int X { get; set; }
+    public int Y { get; set; }
+    public int Width { get; set; }
+    public int Height { get; set; }
+}
+public class Win {
+    public struct RECT { public int Left, Top, Right, Bottom; }
+    public delegate bool EnumProc(IntPtr hWnd, IntPtr lParam);
+    [DllImport("user32.dll")] public static extern IntPtr GetForegroundWindow();
+    [DllImport("user32.dll")] public static extern bool SetForegroundWindow(IntPtr hWnd);
+    [DllImport("user32.dll")] public static extern bool ShowWindow(IntPtr hWnd, int nCmdShow);
+    [DllImport("user32.dll")] public static extern bool PostMessage(IntPtr hWnd, uint msg, IntPtr wParam, IntPtr lParam);
+    [DllImport("user32.dll")] public static extern bool IsWindowVisible(IntPtr hWnd);
+    [DllImport("user32.dll")] public static extern bool EnumWindows(EnumProc callback, IntPtr lParam);
+    [DllImport("user32.dll")] public static extern bool GetWindowRect(IntPtr hWnd, out RECT rect);
+    [DllImport("user32.dll")] public static extern uint GetWindowThreadProcessId(IntPtr hWnd, out uint pid);
+    [DllImport("user32.dll", CharSet = CharSet.Unicode)] public static extern int GetWindowText(IntPtr hWnd, StringBuilder text, int count);
+    [DllImport("user32.dll", CharSet = CharSet.Unicode)] public static extern int GetClassName(IntPtr hWnd, StringBuilder text, int count);
+    public static string ProcessName(uint pid) {
+        try { return System.Diagnostics.Process.GetProcessById((int)pid).ProcessName; } catch { return ""; }
+    }
+    public static WindowInfo Describe(IntPtr hWnd) {
+        var title = new StringBuilder(512);
+        var cls = new StringBuilder(256);
+        GetWindowText(hWnd, title, title.Capacity);
+        GetClassName(hWnd, cls, cls.Capacity);
+        uint pid;
+        GetWindowThreadProcessId(hWnd, out pid);
+        RECT r;
+        GetWindowRect(hWnd, out r);
+        return new WindowInfo { Title = title.ToString(), Class = cls.ToString(), PID = pid, Process = ProcessName(pid), Handle = hWnd.ToInt64(),
+            X = r.Left, Y = r.Top, Width = r.Right - r.Left, Height = r.Bottom - r.Top };
+    }
+    public static List<WindowInfo> List() {
+        var list = new List<WindowInfo>();
+        EnumWindows((hWnd, lParam) => {
+            if (IsWindowVisible(hWnd)) {
+                var info = Describe(hWnd);
+                if (info.Title.Length > 0) { list.Add(info); }
+            }
+            return true;
+        }, IntPtr.Zero);
+        return list;
+    }
+}
+'
+`
+
+// windowJSON 平台脚本输出的窗口信息
+type windowJSON struct {
+	Title   string `json:"Title"`
+	Class   string `json:"Class"`
+	PID     int    `json:"PID"`
+	Process string `json:"Process"`
+	Handle  int64  `json:"Handle"`
+	X       int    `json:"X"`
+	Y       int    `json:"Y"`
+	Width   int    `json:"Width"`
+	Height  int    `json:"Height"`
+}
+
+func (w windowJSON) toWindowInfo() *core.WindowInfo {
+	return &core.WindowInfo{
+		Title:   w.Title,
+		Class:   w.Class,
+		PID:     w.PID,
+		Process: w.Process,
+		Handle:  uintptr(w.Handle),
+		Rect:    core.Rect{X: w.X, Y: w.Y, Width: w.Width, Height: w.Height},
+	}
+}
+
+// GetScreenSize 获取主屏幕尺寸
+func (p *PureGoEngine) GetScreenSize() (*core.Size, *core.OperationResult) {
+	start := time.Now()
+
+	var size core.Size
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		var output string
+		output, err = runPowerShell(`
+Add-Type -AssemblyName System.Windows.Forms
+$b = [System.Windows.Forms.Screen]::PrimaryScreen.Bounds
+Write-Output ("{0},{1}" -f $b.Width, $b.Height)
+`)
+		if err == nil {
+			_, err = fmt.Sscanf(output, "%d,%d", &size.Width, &size.Height)
+		}
+	case "linux":
+		var output string
+		output, err = runCommand("xdotool", "getdisplaygeometry")
+		if err == nil {
+			_, err = fmt.Sscanf(output, "%d %d", &size.Width, &size.Height)
+		}
+	case "darwin":
+		var output string
+		output, err = runCommand("osascript", "-l", "JavaScript", "-e",
+			`ObjC.import("AppKit"); var f = $.NSScreen.mainScreen.frame; f.size.width + "," + f.size.height`)
+		if err == nil {
+			var width, height float64
+			_, err = fmt.Sscanf(output, "%g,%g", &width, &height)
+			size = core.Size{Width: int(width), Height: int(height)}
+		}
+	default:
+		return nil, unsupportedResult(start, core.OpGetScreenSize)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult("failed to get screen size", err)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	result := core.NewSuccessResult(fmt.Sprintf("screen size: %dx%d", size.Width, size.Height), &size)
+	result.SetDuration(start)
+	return &size, result
+}
+
+// GetClipboard 获取剪贴板文本
+func (p *PureGoEngine) GetClipboard() (string, *core.OperationResult) {
+	start := time.Now()
+
+	var text string
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		text, err = runPowerShell("Get-Clipboard -Raw")
+	case "linux":
+		name, args := linuxClipboardCommand(false)
+		if name == "" {
+			return "", p.missingClipboardTool(start)
+		}
+		text, err = runCommand(name, args...)
+	case "darwin":
+		text, err = runCommand("pbpaste")
+	default:
+		return "", unsupportedResult(start, core.OpGetClipboard)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult("failed to read clipboard", err)
+		result.SetDuration(start)
+		return "", result
+	}
+
+	result := core.NewSuccessResult(
+		"clipboard read",
+		map[string]interface{}{
+			"text":   text,
+			"length": len([]rune(text)),
+		},
+	)
+	result.SetDuration(start)
+	return text, result
+}
+
+// SetClipboard 设置剪贴板文本，文本通过标准输入传递以避免转义问题
+func (p *PureGoEngine) SetClipboard(text string) *core.OperationResult {
+	start := time.Now()
+
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		_, err = runCommandInput(text, "powershell", "-NoProfile", "-NonInteractive", "-Command",
+			"[Console]::InputEncoding = [System.Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())")
+	case "linux":
+		name, args := linuxClipboardCommand(true)
+		if name == "" {
+			return p.missingClipboardTool(start)
+		}
+		_, err = runCommandInput(text, name, args...)
+	case "darwin":
+		_, err = runCommandInput(text, "pbcopy")
+	default:
+		return unsupportedResult(start, core.OpSetClipboard)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult("failed to write clipboard", err)
+		result.SetDuration(start)
+		return result
+	}
+
+	result := core.NewSuccessResult(
+		"clipboard updated",
+		map[string]interface{}{
+			"length": len([]rune(text)),
+		},
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// linuxClipboardCommand 选择可用的剪贴板工具（wl-clipboard、xclip或xsel）
+func linuxClipboardCommand(write bool) (string, []string) {
+	candidates := []struct {
+		name        string
+		read, write []string
+	}{
+		{"wl-paste", []string{"--no-newline"}, nil},
+		{"xclip", []string{"-selection", "clipboard", "-o"}, []string{"-selection", "clipboard", "-i"}},
+		{"xsel", []string{"--clipboard", "--output"}, []string{"--clipboard", "--input"}},
+	}
+	if write {
+		candidates[0].name = "wl-copy"
+	}
+
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate.name); err != nil {
+			continue
+		}
+		if write {
+			return candidate.name, candidate.write
+		}
+		return candidate.name, candidate.read
+	}
+	return "", nil
+}
+
+func (p *PureGoEngine) missingClipboardTool(start time.Time) *core.OperationResult {
+	result := core.NewErrorResult(
+		"no clipboard tool available (wl-clipboard, xclip or xsel required)",
+		fmt.Errorf("missing clipboard tool"),
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// GetActiveWindow 获取当前活动窗口
+func (p *PureGoEngine) GetActiveWindow() (*core.WindowInfo, *core.OperationResult) {
+	start := time.Now()
+
+	var window *core.WindowInfo
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		var output string
+		output, err = runPowerShell(windowsWindowType +
+			"[Win]::Describe([Win]::GetForegroundWindow()) | ConvertTo-Json -Compress")
+		if err == nil {
+			var info windowJSON
+			if err = json.Unmarshal([]byte(output), &info); err == nil {
+				window = info.toWindowInfo()
+			}
+		}
+	case "linux":
+		var id string
+		id, err = runCommand("xdotool", "getactivewindow")
+		if err == nil {
+			window, err = describeX11Window(id)
+		}
+	default:
+		return nil, unsupportedResult(start, core.OpGetActiveWindow)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult("failed to get active window", err)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	result := core.NewSuccessResult(fmt.Sprintf("active window: %s", window.Title), window)
+	result.SetDuration(start)
+	return window, result
+}
+
+// GetWindows 获取所有可见的顶层窗口
+func (p *PureGoEngine) GetWindows() ([]*core.WindowInfo, *core.OperationResult) {
+	start := time.Now()
+
+	var windows []*core.WindowInfo
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		var output string
+		output, err = runPowerShell(windowsWindowType +
+			"ConvertTo-Json -Compress -InputObject @([Win]::List())")
+		if err == nil {
+			var infos []windowJSON
+			if err = json.Unmarshal([]byte(output), &infos); err == nil {
+				for _, info := range infos {
+					windows = append(windows, info.toWindowInfo())
+				}
+			}
+		}
+	case "linux":
+		windows, err = listX11Windows()
+	default:
+		return nil, unsupportedResult(start, core.OpGetWindows)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult("failed to list windows", err)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	result := core.NewSuccessResult(fmt.Sprintf("found %d windows", len(windows)), windows)
+	result.SetDuration(start)
+	return windows, result
+}
+
+// ActivateWindow 激活窗口
+func (p *PureGoEngine) ActivateWindow(handle uintptr) *core.OperationResult {
+	return p.windowCommand(core.OpActivateWindow, handle,
+		"[Win]::ShowWindow($h, 9) | Out-Null; [Win]::SetForegroundWindow($h) | Out-Null",
+		[]string{"xdotool", "windowactivate", "--sync", "%d"})
+}
+
+// CloseWindow 关闭窗口（发送WM_CLOSE，应用可以提示保存）
+func (p *PureGoEngine) CloseWindow(handle uintptr) *core.OperationResult {
+	return p.windowCommand(core.OpCloseWindow, handle,
+		"[Win]::PostMessage($h, 0x0010, [IntPtr]::Zero, [IntPtr]::Zero) | Out-Null",
+		[]string{"wmctrl", "-i", "-c", "%d"})
+}
+
+// MinimizeWindow 最小化窗口
+func (p *PureGoEngine) MinimizeWindow(handle uintptr) *core.OperationResult {
+	return p.windowCommand(core.OpMinimizeWindow, handle,
+		"[Win]::ShowWindow($h, 6) | Out-Null",
+		[]string{"xdotool", "windowminimize", "%d"})
+}
+
+// MaximizeWindow 最大化窗口
+func (p *PureGoEngine) MaximizeWindow(handle uintptr) *core.OperationResult {
+	return p.windowCommand(core.OpMaximizeWindow, handle,
+		"[Win]::ShowWindow($h, 3) | Out-Null",
+		[]string{"wmctrl", "-i", "-r", "%d", "-b", "add,maximized_vert,maximized_horz"})
+}
+
+// windowCommand 对窗口执行平台命令，linuxArgs中的%d替换为窗口ID
+func (p *PureGoEngine) windowCommand(operation core.Operation, handle uintptr, windowsScript string, linuxArgs []string) *core.OperationResult {
+	start := time.Now()
+
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		_, err = runPowerShell(windowsWindowType +
+			fmt.Sprintf("$h = [IntPtr]%d\n", handle) + windowsScript)
+	case "linux":
+		args := make([]string, len(linuxArgs)-1)
+		for i, arg := range linuxArgs[1:] {
+			if arg == "%d" {
+				arg = strconv.FormatUint(uint64(handle), 10)
+			}
+			args[i] = arg
+		}
+		_, err = runCommand(linuxArgs[0], args...)
+	default:
+		return unsupportedResult(start, operation)
+	}
+
+	if err != nil {
+		result := core.NewErrorResult(fmt.Sprintf("%s failed for window %d", operation, handle), err)
+		result.SetDuration(start)
+		return result
+	}
+
+	result := core.NewSuccessResult(
+		fmt.Sprintf("%s: window %d", operation, handle),
+		map[string]interface{}{
+			"handle": handle,
+		},
+	)
+	result.SetDuration(start)
+	return result
+}
+
+// describeX11Window 通过xdotool获取窗口标题、进程和位置
+func describeX11Window(id string) (*core.WindowInfo, error) {
+	handle, err := strconv.ParseUint(strings.TrimSpace(id), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window id %q: %w", id, err)
+	}
+
+	title, err := runCommand("xdotool", "getwindowname", id)
+	if err != nil {
+		return nil, err
+	}
+	window := &core.WindowInfo{Title: title, Handle: uintptr(handle)}
+
+	if pid, err := runCommand("xdotool", "getwindowpid", id); err == nil {
+		window.PID, _ = strconv.Atoi(pid)
+		window.Process = linuxProcessName(window.PID)
+	}
+	if geometry, err := runCommand("xdotool", "getwindowgeometry", "--shell", id); err == nil {
+		values := parseShellVars(geometry)
+		window.Rect.X, _ = strconv.Atoi(values["X"])
+		window.Rect.Y, _ = strconv.Atoi(values["Y"])
+		window.Rect.Width, _ = strconv.Atoi(values["WIDTH"])
+		window.Rect.Height, _ = strconv.Atoi(values["HEIGHT"])
+	}
+	return window, nil
+}
+
+// listX11Windows 通过wmctrl列出窗口
+// 输出格式：ID 桌面 PID X Y 宽 高 WM_CLASS 主机名 标题
+func listX11Windows() ([]*core.WindowInfo, error) {
+	output, err := runCommand("wmctrl", "-lpGx")
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []*core.WindowInfo
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 {
+			continue
+		}
+
+		handle, err := strconv.ParseUint(fields[0], 0, 64)
+		if err != nil {
+			continue
+		}
+		numbers := make([]int, 5)
+		for i := range numbers {
+			numbers[i], _ = strconv.Atoi(fields[i+2])
+		}
+
+		windows = append(windows, &core.WindowInfo{
+			Title:   strings.Join(fields[9:], " "),
+			Class:   fields[7],
+			PID:     numbers[0],
+			Process: linuxProcessName(numbers[0]),
+			Handle:  uintptr(handle),
+			Rect:    core.Rect{X: numbers[1], Y: numbers[2], Width: numbers[3], Height: numbers[4]},
+		})
+	}
+	return windows, nil
+}
+
+// linuxProcessName 从/proc读取进程名，获取失败时返回空
+func linuxProcessName(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
@@ -0,0 +1,411 @@
+package hybrid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// WorkerState worker进程状态
+type WorkerState string
+
+const (
+	WorkerStateStopped    WorkerState = "stopped"    // 尚未启动或已关闭
+	WorkerStateStarting   WorkerState = "starting"   // 正在启动并握手
+	WorkerStateReady      WorkerState = "ready"      // 可以处理请求
+	WorkerStateRestarting WorkerState = "restarting" // 进程异常退出，等待重启
+	WorkerStateFailed     WorkerState = "failed"     // 连续重启失败，冷却期内不再尝试
+)
+
+// WorkerSupervisorConfig worker监管配置
+type WorkerSupervisorConfig struct {
+	RequestTimeout     time.Duration            // 默认单次请求超时
+	MethodTimeouts     map[string]time.Duration // 按方法覆盖的超时
+	StartTimeout       time.Duration            // 启动并完成握手的超时
+	HeartbeatInterval  time.Duration            // 心跳间隔
+	HeartbeatTimeout   time.Duration            // 心跳响应超时
+	RestartBackoffMin  time.Duration            // 首次重启等待时间
+	RestartBackoffMax  time.Duration            // 重启等待时间上限
+	MaxRestartAttempts int                      // 连续重启失败次数上限
+	FailedCooldown     time.Duration            // 进入失败状态后，经过该时间允许重新尝试启动
+}
+
+// DefaultWorkerSupervisorConfig 默认监管配置
+func DefaultWorkerSupervisorConfig() WorkerSupervisorConfig {
+	return WorkerSupervisorConfig{
+		RequestTimeout: 10 * time.Second,
+		MethodTimeouts: map[string]time.Duration{
+			"type":            60 * time.Second,
+			"screenshot":      20 * time.Second,
+			"screenshot_area": 20 * time.Second,
+		},
+		StartTimeout:       10 * time.Second,
+		HeartbeatInterval:  5 * time.Second,
+		HeartbeatTimeout:   2 * time.Second,
+		RestartBackoffMin:  500 * time.Millisecond,
+		RestartBackoffMax:  30 * time.Second,
+		MaxRestartAttempts: 5,
+		FailedCooldown:     5 * time.Minute,
+	}
+}
+
+// errWorkerFailed worker已放弃重启
+var errWorkerFailed = core.NewError(core.ErrEngineUnavailable, "automation worker failed permanently", nil)
+
+// workerSupervisor 负责worker进程的启动、健康检查、超时处理和自动重启
+type workerSupervisor struct {
+	workerPath string
+	config     WorkerSupervisorConfig
+
+	startMu sync.Mutex // 串行化启动过程
+
+	mu                  sync.Mutex
+	client              *workerClient
+	state               WorkerState
+	closing             bool
+	restarts            int
+	consecutiveFailures int
+	nextStartAt         time.Time
+	failedAt            time.Time
+	lastError           string
+	lastHeartbeat       time.Time
+	heartbeatStarted    bool
+	stopCh              chan struct{}
+}
+
+// newWorkerSupervisor 创建监管器
+func newWorkerSupervisor(workerPath string, config WorkerSupervisorConfig) *workerSupervisor {
+	return &workerSupervisor{
+		workerPath: workerPath,
+		config:     config,
+		state:      WorkerStateStopped,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Call 在请求超时限制内执行一次worker调用
+// 请求超时说明worker可能已卡死，会强制结束进程并触发重启
+func (s *workerSupervisor) Call(ctx context.Context, method string, params map[string]interface{}) (*AutomationResponse, error) {
+	client, err := s.ensureClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, s.timeoutFor(method))
+	defer cancel()
+
+	response, err := client.call(callCtx, method, params)
+	if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		s.fail(client, fmt.Errorf("request %s timed out after %v", method, s.timeoutFor(method)))
+		return nil, core.NewError(core.ErrTimeout, fmt.Sprintf("worker request %s timed out", method), err)
+	}
+	if err != nil && ctx.Err() != nil {
+		// 调用方取消时worker可能仍在执行（例如长文本输入），结束进程以中止操作
+		s.abandon(client, method)
+		return nil, fmt.Errorf("worker request %s cancelled: %w", method, ctx.Err())
+	}
+	return response, err
+}
+
+// timeoutFor 获取方法对应的超时时间
+func (s *workerSupervisor) timeoutFor(method string) time.Duration {
+	if timeout, ok := s.config.MethodTimeouts[method]; ok {
+		return timeout
+	}
+	return s.config.RequestTimeout
+}
+
+// ensureClient 获取可用会话，必要时按退避策略启动新进程
+func (s *workerSupervisor) ensureClient(ctx context.Context) (*workerClient, error) {
+	s.mu.Lock()
+	if s.client != nil && s.client.Alive() {
+		client := s.client
+		s.mu.Unlock()
+		return client, nil
+	}
+	s.mu.Unlock()
+
+	return s.start(ctx)
+}
+
+// start 启动worker进程（遵循退避时间）
+func (s *workerSupervisor) start(ctx context.Context) (*workerClient, error) {
+	s.startMu.Lock()
+	defer s.startMu.Unlock()
+
+	s.mu.Lock()
+	if s.client != nil && s.client.Alive() {
+		client := s.client
+		s.mu.Unlock()
+		return client, nil
+	}
+	if s.closing {
+		s.mu.Unlock()
+		return nil, core.NewError(core.ErrEngineUnavailable, "automation worker is shutting down", nil)
+	}
+	if s.state == WorkerStateFailed {
+		if time.Since(s.failedAt) < s.config.FailedCooldown {
+			lastError := s.lastError
+			s.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", errWorkerFailed, lastError)
+		}
+		// 冷却期已过，故障可能只是暂时的，重新给一轮重启机会
+		slog.Info("automation worker cooldown elapsed, retrying", "last_error", s.lastError)
+		s.consecutiveFailures = 0
+		s.state = WorkerStateRestarting
+		s.nextStartAt = time.Time{}
+	}
+	wait := time.Until(s.nextStartAt)
+	s.mu.Unlock()
+
+	// 等待退避时间
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-s.stopCh:
+			timer.Stop()
+			return nil, core.NewError(core.ErrEngineUnavailable, "automation worker is shutting down", nil)
+		}
+	}
+
+	s.setState(WorkerStateStarting)
+
+	startCtx, cancel := context.WithTimeout(ctx, s.config.StartTimeout)
+	defer cancel()
+
+	client, err := startWorkerClient(startCtx, s.workerPath)
+	if err != nil {
+		s.recordFailure(err)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.client = client
+	s.state = WorkerStateReady
+	s.consecutiveFailures = 0
+	s.nextStartAt = time.Time{}
+	s.lastHeartbeat = time.Now()
+	startHeartbeat := !s.heartbeatStarted
+	if s.heartbeatStarted {
+		s.restarts++
+	}
+	s.heartbeatStarted = true
+	s.mu.Unlock()
+
+	slog.Info("automation worker started",
+		"pid", client.cmd.Process.Pid,
+		"version", client.handshake.Version,
+		"capabilities", client.handshake.Capabilities)
+
+	go s.watch(client)
+	if startHeartbeat {
+		go s.heartbeatLoop()
+	}
+	return client, nil
+}
+
+// watch 等待进程退出，非主动关闭时安排重启
+func (s *workerSupervisor) watch(client *workerClient) {
+	<-client.exited
+	s.fail(client, fmt.Errorf("worker exited unexpectedly: %v", client.exitErr))
+}
+
+// fail 处理当前会话的故障：摘除会话、结束进程并在后台重启
+// 同一会话只处理一次，已被摘除的会话直接忽略
+func (s *workerSupervisor) fail(client *workerClient, err error) {
+	s.mu.Lock()
+	if s.client != client || s.closing {
+		s.mu.Unlock()
+		return
+	}
+	s.client = nil
+	s.mu.Unlock()
+
+	client.kill()
+	s.recordFailure(err)
+	go s.restart()
+}
+
+// abandon 因请求被取消而结束当前会话
+// 与fail不同，取消不计入失败次数，worker在下一次请求时按需重新启动
+func (s *workerSupervisor) abandon(client *workerClient, method string) {
+	s.mu.Lock()
+	if s.client != client || s.closing {
+		s.mu.Unlock()
+		return
+	}
+	s.client = nil
+	s.state = WorkerStateStopped
+	s.mu.Unlock()
+
+	slog.Info("automation worker stopped to abort cancelled request", "method", method)
+	client.kill()
+}
+
+// restart 后台按退避策略重启worker，直到成功或进入失败状态
+func (s *workerSupervisor) restart() {
+	for {
+		if _, err := s.start(context.Background()); err == nil {
+			return
+		}
+
+		s.mu.Lock()
+		retry := s.state == WorkerStateRestarting && !s.closing
+		s.mu.Unlock()
+		if !retry {
+			return
+		}
+	}
+}
+
+// recordFailure 记录失败并计算下一次允许启动的时间
+func (s *workerSupervisor) recordFailure(err error) {
+	s.mu.Lock()
+	s.lastError = err.Error()
+	if s.closing {
+		s.mu.Unlock()
+		return
+	}
+
+	s.consecutiveFailures++
+	if s.consecutiveFailures > s.config.MaxRestartAttempts {
+		s.state = WorkerStateFailed
+		s.failedAt = time.Now()
+	} else {
+		s.state = WorkerStateRestarting
+		s.nextStartAt = time.Now().Add(s.backoff(s.consecutiveFailures))
+	}
+	state := s.state
+	s.mu.Unlock()
+
+	slog.Error("automation worker failure", "error", err, "state", state)
+}
+
+// backoff 指数退避
+func (s *workerSupervisor) backoff(failures int) time.Duration {
+	delay := s.config.RestartBackoffMin
+	for i := 1; i < failures && delay < s.config.RestartBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > s.config.RestartBackoffMax {
+		delay = s.config.RestartBackoffMax
+	}
+	return delay
+}
+
+// heartbeatLoop 定期发送ping检测worker是否存活
+func (s *workerSupervisor) heartbeatLoop() {
+	ticker := time.NewTicker(s.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		client := s.client
+		s.mu.Unlock()
+		if client == nil || !client.Alive() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.HeartbeatTimeout)
+		_, err := client.call(ctx, workerMethodPing, nil)
+		cancel()
+
+		if err != nil {
+			s.fail(client, fmt.Errorf("heartbeat failed: %w", err))
+			continue
+		}
+
+		s.mu.Lock()
+		s.lastHeartbeat = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// setState 更新状态
+func (s *workerSupervisor) setState(state WorkerState) {
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+}
+
+// State 当前状态
+func (s *workerSupervisor) State() WorkerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// gaveUp 是否处于失败状态且尚在冷却期内
+func (s *workerSupervisor) gaveUp() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state == WorkerStateFailed && time.Since(s.failedAt) < s.config.FailedCooldown
+}
+
+// Handshake 当前会话的握手信息
+func (s *workerSupervisor) Handshake() *WorkerHandshake {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	return s.client.handshake
+}
+
+// Status 监管状态，用于展示和诊断
+func (s *workerSupervisor) Status() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := map[string]interface{}{
+		"state":                s.state,
+		"restarts":             s.restarts,
+		"consecutive_failures": s.consecutiveFailures,
+	}
+	if s.lastError != "" {
+		status["last_error"] = s.lastError
+	}
+	if !s.lastHeartbeat.IsZero() {
+		status["last_heartbeat"] = s.lastHeartbeat
+	}
+	if s.client != nil {
+		status["pid"] = s.client.cmd.Process.Pid
+		status["in_flight"] = s.client.inFlight()
+	}
+	return status
+}
+
+// Close 停止心跳并优雅关闭worker
+func (s *workerSupervisor) Close() error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closing = true
+	close(s.stopCh)
+	client := s.client
+	s.client = nil
+	s.state = WorkerStateStopped
+	s.mu.Unlock()
+
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
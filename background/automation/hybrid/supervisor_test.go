@@ -0,0 +1,42 @@
+package hybrid
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorkerSupervisorFailedCooldown(t *testing.T) {
+	config := DefaultWorkerSupervisorConfig()
+	config.MaxRestartAttempts = 0
+	config.FailedCooldown = time.Hour
+	supervisor := newWorkerSupervisor(filepath.Join(t.TempDir(), "missing-worker"), config)
+	defer supervisor.Close()
+	ctx := context.Background()
+
+	if _, err := supervisor.Call(ctx, "ping", nil); err == nil || errors.Is(err, errWorkerFailed) {
+		t.Fatalf("首次启动错误 = %v，期望启动失败", err)
+	}
+	if state := supervisor.State(); state != WorkerStateFailed {
+		t.Fatalf("状态 = %s，期望 %s", state, WorkerStateFailed)
+	}
+	if _, err := supervisor.Call(ctx, "ping", nil); !errors.Is(err, errWorkerFailed) {
+		t.Fatalf("冷却期内错误 = %v，期望 errWorkerFailed", err)
+	}
+	if !supervisor.gaveUp() {
+		t.Error("冷却期内应当视为已放弃")
+	}
+
+	// 冷却期结束后重新尝试启动
+	supervisor.mu.Lock()
+	supervisor.failedAt = time.Now().Add(-2 * config.FailedCooldown)
+	supervisor.mu.Unlock()
+	if supervisor.gaveUp() {
+		t.Error("冷却期结束后不应视为已放弃")
+	}
+	if _, err := supervisor.Call(ctx, "ping", nil); err == nil || errors.Is(err, errWorkerFailed) {
+		t.Fatalf("冷却期后错误 = %v，期望重新尝试启动", err)
+	}
+}
@@ -0,0 +1,329 @@
+package hybrid
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+const (
+	// WorkerProtocolVersion 与automation-worker约定的会话协议版本
+	WorkerProtocolVersion = 1
+
+	workerMethodHandshake = "handshake"
+	workerMethodPing      = "ping"
+	workerMethodShutdown  = "shutdown"
+
+	// 优雅退出时等待worker自行结束的时间
+	workerShutdownTimeout = 3 * time.Second
+
+	// worker不支持请求的方法时返回的JSON-RPC错误码
+	rpcCodeMethodNotFound = -32601
+)
+
+// errSessionLost 请求已发出但会话在响应前结束，worker可能已经执行了部分操作
+var errSessionLost = errors.New("worker session lost after the request was sent")
+
+// RPCRequest 发往worker的JSON-RPC请求（每行一条）
+type RPCRequest struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	ID      uint64                 `json:"id"`
+	Method  string                 `json:"method"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// RPCResponse worker返回的JSON-RPC响应（每行一条）
+type RPCResponse struct {
+	JSONRPC string              `json:"jsonrpc"`
+	ID      uint64              `json:"id"`
+	Result  *AutomationResponse `json:"result,omitempty"`
+	Error   *RPCError           `json:"error,omitempty"`
+}
+
+// RPCError 协议级错误
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// WorkerHandshake 握手得到的worker信息
+type WorkerHandshake struct {
+	Version         string            `json:"version"`
+	ProtocolVersion int               `json:"protocol_version"`
+	Engine          string            `json:"engine"`
+	Capabilities    []string          `json:"capabilities"`
+	Descriptor      *WorkerDescriptor `json:"descriptor,omitempty"` // 旧版本worker没有该字段
+}
+
+// WorkerDescriptor worker握手时报告的按键、鼠标按键等能力
+type WorkerDescriptor struct {
+	Platforms    []string           `json:"platforms"`
+	Keys         []string           `json:"keys"`
+	Modifiers    []core.KeyModifier `json:"modifiers"`
+	MouseButtons []core.MouseButton `json:"mouse_buttons"`
+	MultiMonitor bool               `json:"multi_monitor"`
+	UnicodeText  bool               `json:"unicode_text"`
+}
+
+// workerClient 与常驻worker进程的会话
+// 请求通过stdin逐行写入，响应由读协程按ID分发，支持多个请求同时在途
+type workerClient struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	nextID atomic.Uint64
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[uint64]chan *RPCResponse
+	closed  bool
+	readErr error
+
+	exited    chan struct{} // 进程退出并回收后关闭
+	exitErr   error
+	readers   sync.WaitGroup
+	handshake *WorkerHandshake
+}
+
+// startWorkerClient 启动worker进程并完成握手
+func startWorkerClient(ctx context.Context, workerPath string) (*workerClient, error) {
+	cmd := exec.Command(workerPath)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worker stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worker stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worker stderr: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start worker: %w", err)
+	}
+
+	client := &workerClient{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[uint64]chan *RPCResponse),
+		exited:  make(chan struct{}),
+	}
+	client.readers.Add(2)
+	go client.readLoop(stdout)
+	go client.logStderr(stderr)
+	go client.reap()
+
+	handshake, err := client.doHandshake(ctx)
+	if err != nil {
+		client.kill()
+		<-client.exited
+		return nil, err
+	}
+	client.handshake = handshake
+
+	return client, nil
+}
+
+// doHandshake 校验协议版本并获取能力列表
+func (c *workerClient) doHandshake(ctx context.Context) (*WorkerHandshake, error) {
+	response, err := c.call(ctx, workerMethodHandshake, nil)
+	if err != nil {
+		return nil, fmt.Errorf("worker handshake failed: %w", err)
+	}
+
+	data, err := json.Marshal(response.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid handshake data: %w", err)
+	}
+	var handshake WorkerHandshake
+	if err := json.Unmarshal(data, &handshake); err != nil {
+		return nil, fmt.Errorf("invalid handshake data: %w", err)
+	}
+
+	if handshake.ProtocolVersion != WorkerProtocolVersion {
+		return nil, fmt.Errorf("worker protocol version mismatch: want %d, got %d",
+			WorkerProtocolVersion, handshake.ProtocolVersion)
+	}
+	return &handshake, nil
+}
+
+// call 发送请求并等待对应ID的响应
+func (c *workerClient) call(ctx context.Context, method string, params map[string]interface{}) (*AutomationResponse, error) {
+	id := c.nextID.Add(1)
+	ch := make(chan *RPCResponse, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		err := c.readErr
+		c.mu.Unlock()
+		return nil, fmt.Errorf("worker session closed: %v", err)
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.send(RPCRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		c.removePending(id)
+		return nil, err
+	}
+
+	select {
+	case response, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("%w: worker exited before responding: %v", errSessionLost, c.sessionErr())
+		}
+		if response.Error != nil {
+			return nil, response.Error
+		}
+		if response.Result == nil {
+			return nil, core.NewError(core.ErrUnknown, "worker returned empty result", nil)
+		}
+		return response.Result, nil
+	case <-ctx.Done():
+		c.removePending(id)
+		return nil, ctx.Err()
+	}
+}
+
+// send 写出一行请求
+func (c *workerClient) send(request RPCRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write request: %w", err)
+	}
+	return nil
+}
+
+// readLoop 读取响应并分发给等待者
+func (c *workerClient) readLoop(stdout io.Reader) {
+	defer c.readers.Done()
+
+	scanner := bufio.NewScanner(stdout)
+	// 截图等响应可能很大
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var response RPCResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[response.ID]
+		delete(c.pending, response.ID)
+		c.mu.Unlock()
+
+		if ok {
+			ch <- &response
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+
+	// 会话结束，唤醒所有等待者
+	c.mu.Lock()
+	c.closed = true
+	c.readErr = err
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+// logStderr 将worker的标准错误输出转发到日志
+func (c *workerClient) logStderr(stderr io.Reader) {
+	defer c.readers.Done()
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		slog.Warn("automation-worker stderr", "pid", c.cmd.Process.Pid, "line", scanner.Text())
+	}
+}
+
+// reap 等待输出读取完毕后回收进程
+func (c *workerClient) reap() {
+	c.readers.Wait()
+	c.exitErr = c.cmd.Wait()
+	close(c.exited)
+}
+
+// removePending 移除未完成的请求
+func (c *workerClient) removePending(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// sessionErr 会话结束的原因
+func (c *workerClient) sessionErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readErr
+}
+
+// inFlight 当前在途的请求数
+func (c *workerClient) inFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// Alive 会话是否仍然可用
+func (c *workerClient) Alive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.closed
+}
+
+// Close 优雅关闭：发送shutdown并等待进程退出，超时则强制结束
+func (c *workerClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), workerShutdownTimeout)
+	defer cancel()
+
+	if c.Alive() {
+		c.call(ctx, workerMethodShutdown, nil)
+	}
+	c.stdin.Close()
+
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		c.kill()
+		<-c.exited
+	}
+	return c.exitErr
+}
+
+// kill 强制结束worker进程
+func (c *workerClient) kill() {
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+}
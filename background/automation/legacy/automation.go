@@ -32,7 +32,7 @@ package automation
 
 import (
 	"diandian/background/automation/core"
-	"diandian/background/automation/engine"
+	"diandian/background/automation/legacy/engine"
 )
 
 // 导出核心类型
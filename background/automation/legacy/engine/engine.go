@@ -4,13 +4,13 @@ import (
 	"fmt"
 	"time"
 
-	"diandian/background/automation/app"
 	"diandian/background/automation/core"
-	"diandian/background/automation/file"
-	"diandian/background/automation/keyboard"
-	"diandian/background/automation/mouse"
-	"diandian/background/automation/screen"
-	"diandian/background/automation/system"
+	"diandian/background/automation/legacy/app"
+	"diandian/background/automation/legacy/file"
+	"diandian/background/automation/legacy/keyboard"
+	"diandian/background/automation/legacy/mouse"
+	"diandian/background/automation/legacy/screen"
+	"diandian/background/automation/legacy/system"
 )
 
 // Engine 自动化引擎实现
@@ -66,6 +66,35 @@ func (e *Engine) Cleanup() *core.OperationResult {
 	return result
 }
 
+// legacyKeys robotgo支持的具名按键
+var legacyKeys = []string{
+	"backspace", "capslock", "delete", "down", "end", "enter", "esc", "escape",
+	"f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9", "f10", "f11", "f12",
+	"home", "insert", "left", "pagedown", "pageup", "printscreen", "right", "space", "tab", "up",
+}
+
+// Capabilities 获取legacy引擎的能力描述
+// 未配置OCR后端时FindText不可用，不计入支持的操作
+func (e *Engine) Capabilities() *core.Capabilities {
+	var operations []core.Operation
+	for _, operation := range core.ImplementedOperations(e) {
+		if operation != core.OpFindText || e.screen.OCRProvider() != nil {
+			operations = append(operations, operation)
+		}
+	}
+	
+	return &core.Capabilities{
+		Engine:       "legacy",
+		Platforms:    []string{"windows", "linux", "darwin"},
+		Operations:   operations,
+		Keys:         legacyKeys,
+		Modifiers:    []core.KeyModifier{core.ModAlt, core.ModCtrl, core.ModShift, core.ModWin},
+		MouseButtons: []core.MouseButton{core.LeftButton, core.MiddleButton, core.RightButton},
+		MultiMonitor: true,
+		UnicodeText:  true,
+	}
+}
+
 // Wait 等待指定时间
 func (e *Engine) Wait(duration int) *core.OperationResult {
 	start := time.Now()
@@ -219,6 +248,10 @@ func (e *Engine) GetScreenSize() (*core.Size, *core.OperationResult) {
 	return e.screen.GetScreenSize()
 }
 
+func (e *Engine) GetDisplays() ([]core.Display, *core.OperationResult) {
+	return e.screen.GetDisplays()
+}
+
 func (e *Engine) FindImage(templatePath string) (*core.Point, *core.OperationResult) {
 	return e.screen.FindImage(templatePath)
 }
@@ -227,6 +260,10 @@ func (e *Engine) FindText(text string) (*core.Point, *core.OperationResult) {
 	return e.screen.FindText(text)
 }
 
+func (e *Engine) GetPixelColor(x, y int) (string, *core.OperationResult) {
+	return e.screen.GetPixelColor(x, y)
+}
+
 // 系统操作方法
 func (e *Engine) GetClipboard() (string, *core.OperationResult) {
 	return e.system.GetClipboard()
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package screen
+
+import "diandian/background/automation/core"
+
+// displayScale 显示器的缩放比例，非Windows平台截图和鼠标坐标由系统统一换算，视为1
+func displayScale(bounds core.Rect) float64 {
+	return 1
+}
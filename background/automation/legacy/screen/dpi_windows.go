@@ -0,0 +1,55 @@
+//go:build windows
+
+package screen
+
+import (
+	"syscall"
+	"unsafe"
+
+	"diandian/background/automation/core"
+)
+
+var (
+	user32 = syscall.NewLazyDLL("user32.dll")
+	shcore = syscall.NewLazyDLL("shcore.dll")
+
+	procMonitorFromRect  = user32.NewProc("MonitorFromRect")
+	procGetDpiForMonitor = shcore.NewProc("GetDpiForMonitor")
+)
+
+const (
+	monitorDefaultToNearest = 2 // MONITOR_DEFAULTTONEAREST
+	mdtEffectiveDPI         = 0 // MDT_EFFECTIVE_DPI，包含用户设置的缩放比例
+)
+
+// rect Win32 RECT结构
+type rect struct {
+	Left, Top, Right, Bottom int32
+}
+
+// displayScale 显示器的缩放比例（DPI/96）
+// 通过GetDpiForMonitor获取每个显示器各自的DPI，需要Windows 8.1及以上，失败时返回1
+func displayScale(bounds core.Rect) float64 {
+	if procGetDpiForMonitor.Find() != nil {
+		return 1
+	}
+
+	r := rect{
+		Left:   int32(bounds.X),
+		Top:    int32(bounds.Y),
+		Right:  int32(bounds.X + bounds.Width),
+		Bottom: int32(bounds.Y + bounds.Height),
+	}
+	monitor, _, _ := procMonitorFromRect.Call(uintptr(unsafe.Pointer(&r)), monitorDefaultToNearest)
+	if monitor == 0 {
+		return 1
+	}
+
+	var dpiX, dpiY uint32
+	hr, _, _ := procGetDpiForMonitor.Call(monitor, mdtEffectiveDPI,
+		uintptr(unsafe.Pointer(&dpiX)), uintptr(unsafe.Pointer(&dpiY)))
+	if hr != 0 || dpiX == 0 {
+		return 1
+	}
+	return float64(dpiX) / 96
+}
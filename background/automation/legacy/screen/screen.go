@@ -2,26 +2,46 @@ package screen
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"image"
 	"image/png"
 	"log/slog"
 	"os"
+	"sort"
 	"time"
 
 	"diandian/background/automation/core"
+	"diandian/background/automation/core/imagematch"
+	"diandian/background/automation/core/ocr"
 
 	"github.com/go-vgo/robotgo"
 	"github.com/kbinani/screenshot"
 )
 
 // Screen 屏幕操作实现
-type Screen struct{}
+type Screen struct {
+	ocr core.OCRProvider // 文字识别后端，为nil时FindText不可用
+}
 
-// NewScreen 创建屏幕操作实例
+// NewScreen 创建屏幕操作实例，本机安装了tesseract时用它识别文字
 func NewScreen() *Screen {
-	return &Screen{}
+	s := &Screen{}
+	if tesseract := ocr.NewTesseract(ocr.DefaultTesseractConfig()); tesseract.Available() {
+		s.ocr = tesseract
+	}
+	return s
+}
+
+// SetOCRProvider 设置文字识别后端
+func (s *Screen) SetOCRProvider(provider core.OCRProvider) {
+	s.ocr = provider
+}
+
+// OCRProvider 当前的文字识别后端
+func (s *Screen) OCRProvider() core.OCRProvider {
+	return s.ocr
 }
 
 // Screenshot 截取屏幕（智能多屏幕支持）
@@ -63,19 +83,13 @@ func (s *Screen) ScreenshotPrimary() ([]byte, *core.OperationResult) {
 		return nil, result
 	}
 
-	imageData := buf.Bytes()
-
-	result := core.NewSuccessResult(
+	return s.captureResult(
+		start,
 		fmt.Sprintf("主屏幕截屏成功 (%dx%d)", width, height),
-		map[string]interface{}{
-			"width":  width,
-			"height": height,
-			"size":   len(imageData),
-			"screen": "primary",
-		},
+		buf.Bytes(),
+		0,
+		core.Rect{Width: width, Height: height},
 	)
-	result.SetDuration(start)
-	return imageData, result
 }
 
 // SmartScreenshot 智能截图：尝试截取活动窗口所在的屏幕
@@ -137,20 +151,25 @@ func (s *Screen) screenshotDisplay(display image.Rectangle, displayIndex int) ([
 		return nil, result
 	}
 
-	imageData := buf.Bytes()
-	width := display.Dx()
-	height := display.Dy()
-
-	result := core.NewSuccessResult(
-		fmt.Sprintf("显示器截屏成功 (%dx%d, 显示器 %d)", width, height, displayIndex),
-		map[string]interface{}{
-			"width":         width,
-			"height":        height,
-			"size":          len(imageData),
-			"display_index": displayIndex,
-			"display_rect":  display,
-		},
+	return s.captureResult(
+		start,
+		fmt.Sprintf("显示器截屏成功 (%dx%d, 显示器 %d)", display.Dx(), display.Dy(), displayIndex),
+		buf.Bytes(),
+		displayIndex,
+		core.Rect{X: display.Min.X, Y: display.Min.Y, Width: display.Dx(), Height: display.Dy()},
 	)
+}
+
+// captureResult 创建统一的截图结果，Data为*core.ScreenCapture
+func (s *Screen) captureResult(start time.Time, message string, imageData []byte, displayIndex int, bounds core.Rect) ([]byte, *core.OperationResult) {
+	capture, err := core.NewScreenCapture(imageData, displayIndex, bounds)
+	if err != nil {
+		result := core.NewErrorResult("图像解析失败", err)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	result := core.NewCaptureResult(message, capture)
 	result.SetDuration(start)
 	return imageData, result
 }
@@ -371,13 +390,16 @@ func (s *Screen) GetDisplayInfo() ([]map[string]interface{}, *core.OperationResu
 	var displayInfos []map[string]interface{}
 	for i := 0; i < numDisplays; i++ {
 		bounds := screenshot.GetDisplayBounds(i)
+		rect := core.Rect{X: bounds.Min.X, Y: bounds.Min.Y, Width: bounds.Dx(), Height: bounds.Dy()}
 		displayInfo := map[string]interface{}{
-			"index":  i,
-			"x":      bounds.Min.X,
-			"y":      bounds.Min.Y,
-			"width":  bounds.Dx(),
-			"height": bounds.Dy(),
-			"bounds": bounds,
+			"index":        i,
+			"x":            bounds.Min.X,
+			"y":            bounds.Min.Y,
+			"width":        bounds.Dx(),
+			"height":       bounds.Dy(),
+			"bounds":       bounds,
+			"scale_factor": displayScale(rect),
+			"primary":      bounds.Min == image.Point{},
 		}
 		displayInfos = append(displayInfos, displayInfo)
 	}
@@ -432,20 +454,13 @@ func (s *Screen) ScreenshotArea(rect core.Rect) ([]byte, *core.OperationResult)
 		return nil, result
 	}
 
-	imageData := buf.Bytes()
-
-	result := core.NewSuccessResult(
+	return s.captureResult(
+		start,
 		fmt.Sprintf("截取区域成功 (%dx%d)", rect.Width, rect.Height),
-		map[string]interface{}{
-			"x":      rect.X,
-			"y":      rect.Y,
-			"width":  rect.Width,
-			"height": rect.Height,
-			"size":   len(imageData),
-		},
+		buf.Bytes(),
+		core.DisplayIndexVirtual,
+		rect,
 	)
-	result.SetDuration(start)
-	return imageData, result
 }
 
 // GetScreenSize 获取屏幕尺寸
@@ -466,31 +481,100 @@ func (s *Screen) GetScreenSize() (*core.Size, *core.OperationResult) {
 	return size, result
 }
 
-// FindImage 在屏幕上查找图像
+// FindImage 在所有显示器上查找图像，返回置信度最高的匹配中心点
 func (s *Screen) FindImage(templatePath string) (*core.Point, *core.OperationResult) {
+	return s.FindImageWithOptions(templatePath, imagematch.DefaultOptions())
+}
+
+// FindImageWithOptions 按指定的阈值、缩放比例和区域查找图像
+func (s *Screen) FindImageWithOptions(templatePath string, options imagematch.Options) (*core.Point, *core.OperationResult) {
 	start := time.Now()
 
-	// 注意：robotgo的图像识别API在不同版本中可能不同
-	// 这里返回未实现错误，实际使用时需要根据具体版本调整
-	result := core.NewErrorResult(
-		"图像识别功能需要根据robotgo版本调整",
-		fmt.Errorf("not implemented"),
-	)
+	template, err := imagematch.LoadTemplate(templatePath)
+	if err != nil {
+		result := core.NewErrorResult(fmt.Sprintf("读取模板图像失败: %s", templatePath), err)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	point, result := s.findTemplate(templatePath, template, options)
 	result.SetDuration(start)
-	return nil, result
+	return point, result
 }
 
-// FindText 在屏幕上查找文本（OCR）
+// findTemplate 逐个截取显示器并查找模板
+// 直接匹配截图的像素，不经过PNG编码
+func (s *Screen) findTemplate(templatePath string, template image.Image, options imagematch.Options) (*core.Point, *core.OperationResult) {
+	numDisplays := screenshot.NumActiveDisplays()
+	if numDisplays == 0 {
+		return nil, core.NewErrorResult("无法获取显示器信息", core.NewError(core.ErrUnsupported, "no active displays found", nil))
+	}
+
+	var matches []imagematch.Match
+	captured := 0
+	for i := 0; i < numDisplays; i++ {
+		bounds := screenshot.GetDisplayBounds(i)
+		desktop := core.Rect{X: bounds.Min.X, Y: bounds.Min.Y, Width: bounds.Dx(), Height: bounds.Dy()}
+		if options.Region != nil && !overlaps(*options.Region, desktop) {
+			continue
+		}
+
+		img, err := screenshot.CaptureRect(bounds)
+		if err != nil {
+			slog.Warn("截取显示器失败", "display", i, "error", err)
+			continue
+		}
+		captured++
+		matches = append(matches, imagematch.FindInImage(img, desktop, template, options)...)
+	}
+	if captured == 0 && options.Region == nil {
+		return nil, core.NewErrorResult("所有显示器截取失败", fmt.Errorf("failed to capture any display"))
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Confidence > matches[j].Confidence })
+	if options.MaxResults > 0 && len(matches) > options.MaxResults {
+		matches = matches[:options.MaxResults]
+	}
+	return imagematch.NewResult(templatePath, matches, options)
+}
+
+// overlaps 两个矩形是否相交
+func overlaps(a, b core.Rect) bool {
+	return a.X < b.X+b.Width && b.X < a.X+a.Width && a.Y < b.Y+b.Height && b.Y < a.Y+a.Height
+}
+
+// FindText 在屏幕上查找文本（OCR），返回匹配文字的中心点
 func (s *Screen) FindText(text string) (*core.Point, *core.OperationResult) {
+	return s.FindTextWithOptions(context.Background(), text, ocr.DefaultFindOptions())
+}
+
+// FindTextWithOptions 截取活动屏幕并按指定的语言、区域和相似度查找文本
+func (s *Screen) FindTextWithOptions(ctx context.Context, text string, options ocr.FindOptions) (*core.Point, *core.OperationResult) {
 	start := time.Now()
 
-	// 注意：这里需要集成OCR功能，目前返回未实现错误
-	result := core.NewErrorResult(
-		"OCR文本识别功能尚未实现",
-		fmt.Errorf("OCR not implemented"),
-	)
+	if s.ocr == nil {
+		result := core.NewErrorResult(
+			"未配置OCR文本识别",
+			core.NewError(core.ErrNotConfigured, "no OCR provider configured", nil),
+		)
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	_, shot := s.Screenshot()
+	if !shot.Success {
+		return nil, shot
+	}
+	capture, ok := core.CaptureFromResult(shot)
+	if !ok {
+		result := core.NewErrorResult("截图结果中没有图像数据", fmt.Errorf("empty screenshot"))
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	point, result := ocr.Locate(ctx, s.ocr, capture, text, options)
 	result.SetDuration(start)
-	return nil, result
+	return point, result
 }
 
 // SaveScreenshot 保存截屏到文件
@@ -583,46 +667,91 @@ func (s *Screen) GetPixelColor(x, y int) (string, *core.OperationResult) {
 func (s *Screen) WaitForImage(templatePath string, timeout time.Duration) (*core.Point, *core.OperationResult) {
 	start := time.Now()
 
-	deadline := time.Now().Add(timeout)
+	template, err := imagematch.LoadTemplate(templatePath)
+	if err != nil {
+		result := core.NewErrorResult(fmt.Sprintf("读取模板图像失败: %s", templatePath), err)
+		result.SetDuration(start)
+		return nil, result
+	}
 
+	deadline := time.Now().Add(timeout)
+	options := imagematch.DefaultOptions()
 	for time.Now().Before(deadline) {
-		point, result := s.FindImage(templatePath)
+		point, result := s.findTemplate(templatePath, template, options)
 		if result.Success {
 			result.Message = fmt.Sprintf("等待图像出现成功: %s", templatePath)
 			result.SetDuration(start)
 			return point, result
 		}
+		if core.CodeOf(result.Err()) != core.ErrNotFound {
+			result.SetDuration(start)
+			return nil, result
+		}
 
 		time.Sleep(500 * time.Millisecond) // 每500ms检查一次
 	}
 
 	result := core.NewErrorResult(
 		fmt.Sprintf("等待图像超时: %s", templatePath),
-		fmt.Errorf("timeout waiting for image"),
+		core.NewError(core.ErrTimeout, "timeout waiting for image", nil),
 	)
 	result.SetDuration(start)
 	return nil, result
 }
 
-// GetScreenInfo 获取屏幕信息
+// GetScreenInfo 获取主显示器的尺寸和DPI
 func (s *Screen) GetScreenInfo() (*core.ScreenInfo, *core.OperationResult) {
 	start := time.Now()
 
 	width, height := robotgo.GetScreenSize()
-
-	// 获取DPI（这里使用默认值，实际可能需要系统API）
-	dpi := 96 // Windows默认DPI
-
 	screenInfo := &core.ScreenInfo{
-		Width:  width,
-		Height: height,
-		DPI:    dpi,
+		Width:       width,
+		Height:      height,
+		DPI:         96,
+		ScaleFactor: 1,
+	}
+	if primary := core.PrimaryDisplay(listDisplays()); primary != nil {
+		screenInfo.Width, screenInfo.Height = primary.Bounds.Width, primary.Bounds.Height
+		screenInfo.DPI, screenInfo.ScaleFactor = primary.DPI(), primary.ScaleFactor
 	}
 
 	result := core.NewSuccessResult(
-		fmt.Sprintf("获取屏幕信息: %dx%d, DPI: %d", width, height, dpi),
+		fmt.Sprintf("获取屏幕信息: %dx%d, DPI: %d", screenInfo.Width, screenInfo.Height, screenInfo.DPI),
 		screenInfo,
 	)
 	result.SetDuration(start)
 	return screenInfo, result
 }
+
+// GetDisplays 获取所有显示器在虚拟桌面中的范围和各自的缩放比例
+func (s *Screen) GetDisplays() ([]core.Display, *core.OperationResult) {
+	start := time.Now()
+
+	displays := listDisplays()
+	if len(displays) == 0 {
+		result := core.NewErrorResult("无法获取显示器信息", core.NewError(core.ErrNotFound, "no active displays found", nil))
+		result.SetDuration(start)
+		return nil, result
+	}
+
+	result := core.NewSuccessResult(fmt.Sprintf("获取到 %d 个显示器", len(displays)), displays)
+	result.SetDuration(start)
+	return displays, result
+}
+
+// listDisplays 枚举显示器，主显示器是左上角位于虚拟桌面原点的显示器
+func listDisplays() []core.Display {
+	numDisplays := screenshot.NumActiveDisplays()
+	displays := make([]core.Display, 0, numDisplays)
+	for i := 0; i < numDisplays; i++ {
+		b := screenshot.GetDisplayBounds(i)
+		bounds := core.Rect{X: b.Min.X, Y: b.Min.Y, Width: b.Dx(), Height: b.Dy()}
+		displays = append(displays, core.Display{
+			Index:       i,
+			Bounds:      bounds,
+			ScaleFactor: displayScale(bounds),
+			Primary:     b.Min == image.Point{},
+		})
+	}
+	return displays
+}
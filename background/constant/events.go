@@ -8,6 +8,7 @@ const (
 	EventMouseLeaveFloating = "mouse-leave-floating"
 
 	EventMessageResponsed  = "message-responsed"
+	EventMessageDelta      = "message-delta" // 流式回复的增量内容，service.MessageDelta
 	EventTaskStatusChanged = "task-status-changed"
 	EventOperateFailed     = "operate-failed" // model.Step
 
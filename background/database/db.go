@@ -22,6 +22,7 @@ func Initialize() error {
 		&model.Task{},
 		&model.Step{},
 		&model.Setting{},
+		&model.RedactionAudit{},
 	)
 
 	return nil
@@ -16,23 +16,68 @@ type AutomationTaskDecomposition struct {
 	EstimatedTime   int                  `json:"estimated_time"`   // 预估时间(秒)
 }
 
+// 重新规划的结论
+const (
+	ReplanContinue   = "continue"   // 按新的步骤继续执行
+	ReplanDone       = "done"       // 任务目标已经达成
+	ReplanImpossible = "impossible" // 任务无法完成
+)
+
+// AutomationReplan 执行过程中出现偏差后重新规划的结果，Steps替换尚未执行的步骤
+type AutomationReplan struct {
+	Status string               `json:"status" enum:"continue,done,impossible"` // 见ReplanContinue等
+	Reason string               `json:"reason"`                                 // 偏差原因和新计划的说明
+	Steps  []AutomationStepPlan `json:"steps"`                                  // 剩余的步骤，Status不是continue时为空
+}
+
+// StepVerification 步骤执行后的结果验证
+type StepVerification struct {
+	Achieved    bool    `json:"achieved"`    // 预期结果是否达成
+	Observation string  `json:"observation"` // 截图中与该步骤相关的界面状态
+	Reason      string  `json:"reason"`      // 判断理由，未达成时说明与预期的差异
+	Confidence  float64 `json:"confidence"`  // 置信度
+}
+
 // AutomationStepPlan 自动化步骤计划（高级步骤，不包含具体参数）
 type AutomationStepPlan struct {
-	Type                   string `json:"type"`                     // click, type, launch_app, file, screenshot, clipboard, wait, key_press
+	Type                   string `json:"type"`                     // click, type, launch_app, file, screenshot, clipboard, wait, key_press, scroll
 	Description            string `json:"description"`              // 步骤描述
 	RequiresScreenAnalysis bool   `json:"requires_screen_analysis"` // 是否需要屏幕分析
 	Context                string `json:"context"`                  // 上下文信息，用于第二阶段生成具体操作
 	Priority               int    `json:"priority"`                 // 优先级 1-10
 	Optional               bool   `json:"optional"`                 // 是否可选
+	WaitFor                string `json:"wait_for"`                 // wait步骤的等待条件表达式（见wait.Parse），为空时按context中的时长等待
+	Grounding              string `json:"grounding"`                // click步骤定位目标的方式：coordinates（默认）/ marks
+	Template               string `json:"template,omitempty"`       // click步骤目标的模板图片路径，marks方式下作为候选元素来源
+	Refine                 bool   `json:"refine"`                   // click步骤是否在目标附近放大截图再次精确定位，适合工具栏图标等小目标
+	ExpectedOutcome        string `json:"expected_outcome"`         // 步骤完成后界面上应出现的状态，执行后据此验证，为空时按步骤描述验证
 }
 
 // ===== 具体操作结构体 =====
 
 // ClickOperation 点击操作
 type ClickOperation struct {
-	X      int    `json:"x"`      // X坐标
-	Y      int    `json:"y"`      // Y坐标
-	Button string `json:"button"` // "left", "right", "middle"
+	X         int    `json:"x"`                   // X坐标
+	Y         int    `json:"y"`                   // Y坐标
+	Button    string `json:"button"`              // "left", "right", "middle"
+	Grounding string `json:"grounding,omitempty"` // 实际使用的定位方式
+	Mark      int    `json:"mark,omitempty"`      // marks方式下选中的候选编号
+	Label     string `json:"label,omitempty"`     // marks方式下选中的候选描述
+
+	Target     *Coordinates  `json:"target,omitempty"`     // 点击目标的范围（虚拟桌面坐标），坐标方式下为空
+	Refinement []RefineStage `json:"refinement,omitempty"` // 放大精确定位的每一轮记录
+}
+
+// RefineStage 放大精确定位的一轮记录
+type RefineStage struct {
+	Stage      int         `json:"stage"`           // 第几轮，从1开始
+	Region     Coordinates `json:"region"`          // 截取的区域（虚拟桌面坐标）
+	Crop       []byte      `json:"crop"`            // 截取的原始局部截图
+	Zoomed     []byte      `json:"zoomed"`          // 放大后发送给模型的截图
+	Zoom       float64     `json:"zoom"`            // 放大倍数（放大图像素/桌面坐标）
+	Target     Coordinates `json:"target"`          // 本轮定位到的目标范围（虚拟桌面坐标）
+	Confidence float64     `json:"confidence"`      // 本轮定位的置信度
+	Error      string      `json:"error,omitempty"` // 本轮定位失败的原因
 }
 
 // TypeOperation 输入操作
@@ -129,20 +174,24 @@ type TaskExecutionResult struct {
 	Duration    time.Duration          `json:"duration"`
 	ErrorCount  int                    `json:"error_count"`
 	SuccessRate float64                `json:"success_rate"`
+	ErrorCode   string                 `json:"error_code,omitempty"` // 任务在执行前被拒绝时的错误码
 }
 
 // StepExecutionResult 步骤执行结果
 type StepExecutionResult struct {
-	StepIndex      int           `json:"step_index"`
-	StepType       string        `json:"step_type"`
-	Success        bool          `json:"success"`
-	Message        string        `json:"message"`
-	Error          string        `json:"error,omitempty"`
-	StartTime      time.Time     `json:"start_time"`
-	EndTime        time.Time     `json:"end_time"`
-	Duration       time.Duration `json:"duration"`
-	RetryCount     int           `json:"retry_count"`
-	ScreenshotPath string        `json:"screenshot_path,omitempty"` // 执行前的截图路径
+	StepIndex      int                    `json:"step_index"`
+	StepType       string                 `json:"step_type"`
+	Success        bool                   `json:"success"`
+	Message        string                 `json:"message"`
+	Error          string                 `json:"error,omitempty"`
+	ErrorCode      string                 `json:"error_code,omitempty"` // 失败时的错误码，取值见core.ErrorCode
+	Retryable      bool                   `json:"retryable,omitempty"`  // 失败是否值得重试
+	StartTime      time.Time              `json:"start_time"`
+	EndTime        time.Time              `json:"end_time"`
+	Duration       time.Duration          `json:"duration"`
+	RetryCount     int                    `json:"retry_count"`
+	ScreenshotPath string                 `json:"screenshot_path,omitempty"` // 执行前的截图路径
+	Data           map[string]interface{} `json:"data,omitempty"`            // 步骤数据，如操作前后的屏幕差异指标
 }
 
 // ===== 统一的操作响应结构 =====
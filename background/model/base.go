@@ -12,5 +12,10 @@ type Base struct {
 	UpdatedAt int64  `json:"updated_at,omitempty" gorm:"autoUpdateTime:milli"`
 }
 
-// 创建时的钩子
-func (b *Base) BeforeCreate(tx *gorm.DB) (err error) { b.ID = util.NextID(); return }
+// 创建时的钩子，已预先分配ID（如流式回复的消息）时保留原ID
+func (b *Base) BeforeCreate(tx *gorm.DB) (err error) {
+	if b.ID == 0 {
+		b.ID = util.NextID()
+	}
+	return
+}
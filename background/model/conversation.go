@@ -2,7 +2,10 @@ package model
 
 type Conversation struct {
 	Base
-	Name string `json:"name" gorm:"size:200"`
+	Name           string `json:"name" gorm:"size:200"`
+	Summary        string `json:"summary,omitempty" gorm:"type:text"` // 较早消息的滚动摘要，对话超出上下文预算时代替这些消息发送给模型
+	SummaryUntil   int64  `json:"summary_until,omitempty"`            // 已合并到摘要中的最后一条消息的创建时间
+	SummaryUntilID uint64 `json:"summary_until_id,string,omitempty"`  // 已合并到摘要中的最后一条消息的ID，与创建时间相同的消息按ID区分
 }
 
 type Message struct {
@@ -0,0 +1,11 @@
+package model
+
+// RedactionAudit 截图发送给视觉模型前的脱敏记录，只记录遮挡的位置和原因，不保存截图和识别出的文字
+type RedactionAudit struct {
+	Base
+	Purpose  string `json:"purpose" gorm:"size:50"`   // 截图用途：analyze, locate, marks, ocr
+	Strategy string `json:"strategy" gorm:"size:20"`  // 遮挡方式：blackout, blur
+	Bounds   string `json:"bounds" gorm:"size:100"`   // 截图范围（虚拟桌面坐标）
+	Count    int    `json:"count"`                    // 遮挡的区域数量
+	Regions  string `json:"regions" gorm:"type:text"` // 遮挡的区域及原因，JSON数组
+}
@@ -19,10 +19,25 @@ const (
 	SettingKeyAutoStart = "auto_start" // 是否开机自启，值为true或false
 	SettingKeyLanguage  = "language"   // 语言，值为auto/zh-CN/en-US
 
-	SettingKeyLlmTextModel   = "llm_text_model"    // 文本模型，值为gpt-3.5-turbo/gpt-4等
-	SettingKeyLlmTextToken   = "llm_text_token"    // LLM访问Token
-	SettingKeyLlmTextBaseUrl = "llm_text_base_url" // LLM访问基础URL
-	SettingKeyLlmVlModel     = "llm_vl_model"      // 多模态模型，值为gpt-4-vision-preview等
-	SettingKeyLlmVlToken     = "llm_vl_token"
-	SettingKeyLlmVlBaseUrl   = "llm_vl_base_url"
+	SettingKeyAutomationMode = "automation_mode" // 自动化任务的执行方式，值为plan（先分解再执行，默认）/agent（工具调用）
+
+	SettingKeyLlmTextProvider = "llm_text_provider" // 文本模型服务，值为openai/anthropic/gemini/ollama
+	SettingKeyLlmTextModel    = "llm_text_model"    // 文本模型，值为gpt-3.5-turbo/gpt-4等
+	SettingKeyLlmTextToken    = "llm_text_token"    // LLM访问Token
+	SettingKeyLlmTextBaseUrl  = "llm_text_base_url" // LLM访问基础URL
+	SettingKeyLlmTextContext  = "llm_text_context"  // 发送给文本模型的对话上下文预算（token数），超出时较早的消息合并为摘要
+	SettingKeyLlmVlProvider   = "llm_vl_provider"   // 多模态模型服务，取值同文本模型服务
+	SettingKeyLlmVlModel      = "llm_vl_model"      // 多模态模型，值为gpt-4-vision-preview等
+	SettingKeyLlmVlToken      = "llm_vl_token"
+	SettingKeyLlmVlBaseUrl    = "llm_vl_base_url"
+
+	SettingKeyLlmVlImageMaxEdge = "llm_vl_image_max_edge" // 发送给视觉模型的截图最长边像素，0为不缩放
+	SettingKeyLlmVlImageFormat  = "llm_vl_image_format"   // 发送给视觉模型的截图格式，值为jpeg/png/webp
+	SettingKeyLlmVlImageQuality = "llm_vl_image_quality"  // 有损格式的压缩质量，1-100
+
+	SettingKeyRedactionEnabled        = "redaction_enabled"         // 截图发送给视觉模型前是否遮挡敏感区域，值为true或false
+	SettingKeyRedactionBlockedTitles  = "redaction_blocked_titles"  // 需要遮挡的窗口标题关键字，逗号或换行分隔
+	SettingKeyRedactionBlockedApps    = "redaction_blocked_apps"    // 需要遮挡的应用进程名，逗号或换行分隔
+	SettingKeyRedactionPasswordFields = "redaction_password_fields" // 是否通过文字识别遮挡密码输入框和密钥，值为true或false
+	SettingKeyRedactionStrategy       = "redaction_strategy"        // 遮挡方式，值为blackout/blur
 )
@@ -4,7 +4,7 @@ type Step struct {
 	Base
 	TaskID      uint64 `json:"task_id,string"`
 	Content     string `json:"content"`     // 展示给用户的消息内容
-	StepType    string `json:"step_type"`   // message, action, screenshot, analysis
+	StepType    string `json:"step_type"`   // message, action, screenshot, analysis, replan
 	Status      string `json:"status"`      // pending, running, completed, failed
 	ActionType  string `json:"action_type"` // click, type, key, scroll, wait
 	Coordinates string `json:"coordinates"` // 操作坐标 (x,y)
@@ -20,6 +20,7 @@ const (
 	StepTypeAction     = "action"     // 操作执行
 	StepTypeScreenshot = "screenshot" // 截图
 	StepTypeAnalysis   = "analysis"   // 分析
+	StepTypeReplan     = "replan"     // 执行出现偏差后重新规划
 )
 
 // 步骤状态常量
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/imageprep"
+	"diandian/background/automation/hybrid"
+	"diandian/background/constant"
+	"diandian/background/database"
+	"diandian/background/model"
+	"diandian/background/service/llm"
+	"diandian/background/service/operation"
+)
+
+// 自动化任务的执行方式
+const (
+	AutomationModeAgent = "agent" // 工具调用代理，每一步观察屏幕后再决定下一步
+	AutomationModePlan  = "plan"  // 先分解全部步骤，再由EnhancedTaskExecutionEngine依次执行
+)
+
+// GetAutomationMode 从设置读取自动化任务的执行方式，未设置或取值未知时使用预先规划，工具调用代理需要用户主动开启
+func GetAutomationMode() string {
+	var setting model.Setting
+	err := database.DB.Where("key = ?", model.SettingKeyAutomationMode).First(&setting).Error
+	if err != nil || setting.Value == nil || *setting.Value != AutomationModeAgent {
+		return AutomationModePlan
+	}
+	return AutomationModeAgent
+}
+
+// AgentOptions 自动化代理的运行选项
+type AgentOptions struct {
+	MaxTurns        int           // 最多与模型交互的轮数
+	KeepScreenshots int           // 对话中保留的截图数量，更早的截图替换为文字说明以控制请求大小
+	SettleDelay     time.Duration // 界面操作后截图前的等待时间，等待动画和重绘完成
+	CallTimeout     time.Duration // 单次模型调用的超时时间
+}
+
+// DefaultAgentOptions 默认代理选项：最多30轮，保留最近2张截图
+func DefaultAgentOptions() AgentOptions {
+	return AgentOptions{
+		MaxTurns:        30,
+		KeepScreenshots: 2,
+		SettleDelay:     500 * time.Millisecond,
+		CallTimeout:     90 * time.Second,
+	}
+}
+
+// AutomationAgent 工具调用模式的自动化代理
+// 模型每一轮观察最新截图并调用工具，工具结果和操作后的截图在下一轮反馈给模型，直到模型调用finish
+type AutomationAgent struct {
+	automationService *AutomationService
+	visionService     *EnhancedVisionService
+	engine            *hybrid.HybridEngine
+	options           AgentOptions
+
+	provider llm.Provider
+	image    imageprep.Options   // 截图发送前的预处理选项
+	raw      *core.ScreenCapture // 最近一次截取的原始截图，用于元素定位
+	screen   *core.ScreenCapture // 最近一次发送给模型的截图，模型给出的坐标基于这张截图
+}
+
+// NewAutomationAgent 创建自动化代理
+func NewAutomationAgent(automationService *AutomationService) *AutomationAgent {
+	return &AutomationAgent{
+		automationService: automationService,
+		visionService:     NewEnhancedVisionService(&LLMService{}, automationService),
+		engine:            automationService.engine,
+		options:           DefaultAgentOptions(),
+	}
+}
+
+// SetOptions 设置代理选项
+func (a *AutomationAgent) SetOptions(options AgentOptions) {
+	a.options = options
+}
+
+// Run 执行自动化任务，goal为用户描述的任务目标
+func (a *AutomationAgent) Run(ctx context.Context, taskID uint, goal string) *TaskExecutionResult {
+	result := &TaskExecutionResult{
+		TaskID:    taskID,
+		StartTime: time.Now(),
+		Data:      map[string]interface{}{},
+	}
+
+	// 登记任务，使StopTask能够中止正在进行的模型调用和界面操作
+	ctx, done, err := a.automationService.BeginTask(ctx, taskID)
+	if err != nil {
+		return a.failed(result, err)
+	}
+	defer done()
+
+	slog.Info("自动化代理开始执行任务", "task_id", taskID)
+	a.automationService.sendEvent(AutomationEvent{
+		Type:    "task_started",
+		TaskID:  taskID,
+		Message: "开始执行任务",
+		Data:    map[string]interface{}{"mode": AutomationModeAgent},
+	})
+
+	provider, image, err := operation.NewVisionProvider()
+	if err != nil {
+		return a.failed(result, err)
+	}
+	if !provider.Capabilities().Tools {
+		return a.failed(result, core.NewError(core.ErrUnsupported, fmt.Sprintf("模型服务%s不支持工具调用", provider.Name()), nil))
+	}
+	a.provider, a.image = provider, image
+
+	tools, err := agentToolDefinitions()
+	if err != nil {
+		return a.failed(result, err)
+	}
+
+	observation, err := a.observe(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return a.cancelled(result, 0)
+		}
+		return a.failed(result, err)
+	}
+	messages := []llm.Message{
+		llm.SystemMessage(constant.PromptAutomationAgent),
+		{Role: llm.RoleUser, Content: "任务：" + goal + "\n\n" + observation.Content, Images: observation.Images},
+	}
+
+	for turn := 1; turn <= a.options.MaxTurns; turn++ {
+		if ctx.Err() != nil {
+			return a.cancelled(result, turn)
+		}
+		a.trimScreenshots(messages)
+
+		resp, err := a.chat(ctx, messages, tools)
+		if err != nil {
+			if ctx.Err() != nil {
+				return a.cancelled(result, turn)
+			}
+			return a.failed(result, fmt.Errorf("调用模型失败: %w", err))
+		}
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+		result.Data["turns"] = turn
+
+		// 没有调用工具时视为模型直接给出了最终答复
+		if len(resp.ToolCalls) == 0 {
+			return a.finished(result, finishArgs{Success: true, Summary: resp.Content})
+		}
+
+		interactive, skip := false, false
+		for _, call := range resp.ToolCalls {
+			// 前一个操作失败后界面状态未知，同一轮中后续的调用不再执行
+			if skip {
+				skipped := (&StepExecutionResult{}).fail(core.NewError(core.ErrCancelled, "前一个工具调用失败，未执行", nil))
+				content, _ := json.Marshal(skipped)
+				messages = append(messages, llm.ToolMessage(call.ID, call.Name, string(content)))
+				continue
+			}
+			if call.Name == finishToolName {
+				var args finishArgs
+				if err := decodeToolArgs(call.Arguments, &args); err != nil {
+					args = finishArgs{Summary: call.Arguments}
+				}
+				return a.finished(result, args)
+			}
+
+			stepResult := a.runTool(ctx, taskID, result.TotalSteps, call)
+			result.TotalSteps++
+			if stepResult.Success {
+				result.CompletedSteps++
+			} else {
+				skip = true
+			}
+			if ctx.Err() != nil {
+				return a.cancelled(result, turn)
+			}
+			if tool, ok := findAgentTool(call.Name); ok && tool.interactive {
+				interactive = true
+			}
+			content, _ := json.Marshal(stepResult)
+			messages = append(messages, llm.ToolMessage(call.ID, call.Name, string(content)))
+		}
+
+		// 界面可能已经变化，在最后一个工具结果中附带新截图
+		if interactive {
+			if err := core.Sleep(ctx, a.options.SettleDelay); err != nil {
+				return a.cancelled(result, turn)
+			}
+			observation, err := a.observe(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return a.cancelled(result, turn)
+				}
+				observation = llm.Message{Content: fmt.Sprintf("截屏失败: %v", err)}
+			}
+			last := &messages[len(messages)-1]
+			last.Content += "\n\n" + observation.Content
+			last.Images = observation.Images
+		}
+	}
+
+	return a.failed(result, core.NewError(core.ErrTimeout, fmt.Sprintf("超过最大轮数%d仍未完成任务", a.options.MaxTurns), nil))
+}
+
+// chat 调用模型，可重试的错误按错误码退避后重试
+func (a *AutomationAgent) chat(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	var lastErr error
+	for attempt := 1; attempt <= maxStepAttempts; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, a.options.CallTimeout)
+		resp, err := a.provider.Chat(callCtx, llm.Request{Messages: messages, Tools: tools})
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = operation.ClassifyLLMError(err)
+		if ctx.Err() != nil || !core.IsRetryable(lastErr) {
+			break
+		}
+		slog.Warn("代理调用模型失败，准备重试", "attempt", attempt, "error", lastErr)
+		if err := core.Sleep(ctx, core.RetryDelay(core.CodeOf(lastErr), attempt)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// runTool 执行一次工具调用并通知前端
+func (a *AutomationAgent) runTool(ctx context.Context, taskID uint, stepIndex int, call llm.ToolCall) *StepExecutionResult {
+	slog.Info("代理调用工具", "task_id", taskID, "tool", call.Name, "arguments", call.Arguments)
+	a.automationService.sendEvent(AutomationEvent{
+		Type:    "step_started",
+		TaskID:  taskID,
+		Message: fmt.Sprintf("调用工具 %s", call.Name),
+		Data: map[string]interface{}{
+			"step_index": stepIndex,
+			"tool":       call.Name,
+			"arguments":  call.Arguments,
+		},
+	})
+
+	var result *StepExecutionResult
+	if tool, ok := findAgentTool(call.Name); ok {
+		result = tool.run(a, ctx, call.Arguments)
+	} else {
+		result = (&StepExecutionResult{}).fail(core.NewError(core.ErrUnsupported, fmt.Sprintf("不存在的工具: %s", call.Name), nil))
+	}
+	result.Attempts = 1
+
+	eventType, message := "step_completed", fmt.Sprintf("工具 %s 执行成功", call.Name)
+	if !result.Success {
+		eventType, message = "step_failed", fmt.Sprintf("工具 %s 执行失败: %s", call.Name, result.Error)
+		slog.Warn("代理工具执行失败", "task_id", taskID, "tool", call.Name, "code", result.Code, "error", result.Error)
+	}
+	a.automationService.sendEvent(AutomationEvent{
+		Type:    eventType,
+		TaskID:  taskID,
+		Message: message,
+		Data: map[string]interface{}{
+			"step_index": stepIndex,
+			"tool":       call.Name,
+			"result":     result,
+		},
+	})
+	return result
+}
+
+// observe 截取屏幕，脱敏和预处理后作为发送给模型的观察结果
+func (a *AutomationAgent) observe(ctx context.Context) (llm.Message, error) {
+	_, opResult := a.engine.ScreenshotContext(ctx)
+	capture, ok := core.CaptureFromResult(opResult)
+	if !ok {
+		if err := opResult.Err(); err != nil {
+			return llm.Message{}, err
+		}
+		return llm.Message{}, core.NewError(core.ErrUnknown, "截屏失败: 未获取到图像数据", nil)
+	}
+	prepared, err := operation.PrepareForVision(ctx, capture, a.image, operation.PurposeAgent)
+	if err != nil {
+		return llm.Message{}, err
+	}
+	a.raw, a.screen = capture, prepared
+
+	return llm.Message{
+		Content: fmt.Sprintf("当前屏幕截图（%dx%d像素）：", prepared.Width, prepared.Height),
+		Images:  []llm.Image{{Data: prepared.ImageData}},
+	}, nil
+}
+
+// trimScreenshots 只保留最近的几张截图，更早的截图替换为文字说明
+func (a *AutomationAgent) trimScreenshots(messages []llm.Message) {
+	kept := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if len(messages[i].Images) == 0 {
+			continue
+		}
+		if kept < a.options.KeepScreenshots {
+			kept++
+			continue
+		}
+		messages[i].Images = nil
+		messages[i].Content += "（截图已省略）"
+	}
+}
+
+// finished 模型结束任务时填充结果
+func (a *AutomationAgent) finished(result *TaskExecutionResult, args finishArgs) *TaskExecutionResult {
+	result.Success = args.Success
+	result.Message = args.Summary
+	if !args.Success {
+		result.Error = args.Summary
+		result.Code = core.ErrUnknown
+	}
+	result.Duration = time.Since(result.StartTime)
+
+	slog.Info("自动化代理结束任务", "task_id", result.TaskID, "success", result.Success, "summary", args.Summary)
+	a.automationService.sendEvent(AutomationEvent{
+		Type:    "task_completed",
+		TaskID:  result.TaskID,
+		Message: args.Summary,
+		Data: map[string]interface{}{
+			"success":         result.Success,
+			"step_count":      result.TotalSteps,
+			"completed_steps": result.CompletedSteps,
+			"duration_ms":     result.Duration.Milliseconds(),
+		},
+	})
+	return result
+}
+
+// failed 代理无法继续时填充结果
+func (a *AutomationAgent) failed(result *TaskExecutionResult, err error) *TaskExecutionResult {
+	slog.Error("自动化代理执行失败", "task_id", result.TaskID, "error", err)
+	result.Success = false
+	result.Message = "任务执行失败"
+	result.Error = err.Error()
+	result.Code = core.CodeOf(err)
+	result.Duration = time.Since(result.StartTime)
+
+	a.automationService.sendEvent(AutomationEvent{
+		Type:    "task_completed",
+		TaskID:  result.TaskID,
+		Message: result.Message,
+		Data: map[string]interface{}{
+			"success": false,
+			"error":   result.Error,
+			"code":    result.Code,
+		},
+	})
+	return result
+}
+
+// cancelled 任务被取消时填充结果
+func (a *AutomationAgent) cancelled(result *TaskExecutionResult, turn int) *TaskExecutionResult {
+	result.Message = "任务被取消"
+	result.Error = "context cancelled"
+	result.Code = core.ErrCancelled
+	result.Cancelled = true
+	result.Duration = time.Since(result.StartTime)
+
+	slog.Info("任务被取消", "task_id", result.TaskID, "turn", turn)
+	a.automationService.sendEvent(AutomationEvent{
+		Type:    "task_cancelled",
+		TaskID:  result.TaskID,
+		Message: "任务被取消",
+		Data: map[string]interface{}{
+			"turn": turn,
+		},
+	})
+	return result
+}
@@ -0,0 +1,508 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/wait"
+	"diandian/background/domain"
+	"diandian/background/service/llm"
+	"diandian/background/service/operation"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// agentTool 自动化代理可以调用的工具
+type agentTool struct {
+	name        string
+	description string
+	params      interface{} // 参数结构体，按字段标签生成JSON Schema
+	interactive bool        // 是否操作界面，执行后需要附带新截图
+	run         func(a *AutomationAgent, ctx context.Context, arguments string) *StepExecutionResult
+}
+
+// 工具参数，字段标签同时用于生成JSON Schema
+
+type clickArgs struct {
+	Target string `json:"target,omitempty" description:"要点击的元素描述，如\"保存按钮\"；提供时由定位模块在截图上定位"`
+	X      *int   `json:"x,omitempty" description:"未提供target时必填，最新截图中的像素横坐标"`
+	Y      *int   `json:"y,omitempty" description:"未提供target时必填，最新截图中的像素纵坐标"`
+	Button string `json:"button,omitempty" enum:"left,right,middle" description:"鼠标按键，默认left"`
+	Double bool   `json:"double,omitempty" description:"是否双击"`
+	Refine bool   `json:"refine,omitempty" description:"目标很小时设为true，放大目标附近区域再次精确定位"`
+}
+
+type typeArgs struct {
+	Text string `json:"text" description:"要输入的文本，输入到当前获得焦点的控件"`
+}
+
+type hotkeyArgs struct {
+	Keys []string `json:"keys" description:"按键列表，修饰键在前，如[\"ctrl\",\"s\"]；单个按键如[\"enter\"]"`
+}
+
+type scrollArgs struct {
+	Direction string `json:"direction" enum:"up,down,left,right" description:"滚动方向"`
+	Clicks    int    `json:"clicks,omitempty" description:"滚动格数，默认3"`
+	X         *int   `json:"x,omitempty" description:"滚动位置在最新截图中的像素横坐标，不提供时在当前鼠标位置滚动"`
+	Y         *int   `json:"y,omitempty" description:"滚动位置在最新截图中的像素纵坐标"`
+}
+
+type launchArgs struct {
+	App  string `json:"app,omitempty" description:"应用名称，如notepad、记事本"`
+	Path string `json:"path,omitempty" description:"可执行文件的完整路径，提供时优先使用"`
+}
+
+type fileArgs struct {
+	Operation  string `json:"operation" enum:"create,delete,move,copy,rename,mkdir" description:"文件操作"`
+	SourcePath string `json:"source_path" description:"源文件或目录路径"`
+	TargetPath string `json:"target_path,omitempty" description:"目标路径，move、copy、rename时必填"`
+	Content    string `json:"content,omitempty" description:"文件内容，create时使用"`
+}
+
+type clipboardArgs struct {
+	Operation string `json:"operation" enum:"get,set" description:"读取或写入剪贴板"`
+	Text      string `json:"text,omitempty" description:"写入的文本，set时必填"`
+}
+
+type screenshotArgs struct {
+	Path string `json:"path,omitempty" description:"保存截图的文件路径，不提供时只观察屏幕不保存"`
+}
+
+type waitArgs struct {
+	Condition  string `json:"condition,omitempty" description:"等待条件表达式，如window:另存为、text:保存、stable:1000"`
+	DurationMs int    `json:"duration_ms,omitempty" description:"未提供condition时固定等待的毫秒数"`
+	TimeoutMs  int    `json:"timeout_ms,omitempty" description:"等待条件的最长时间（毫秒），默认10000"`
+}
+
+type finishArgs struct {
+	Success bool   `json:"success" description:"任务是否完成"`
+	Summary string `json:"summary" description:"完成情况或无法完成的原因，会展示给用户"`
+}
+
+// finishToolName 结束任务的工具
+const finishToolName = "finish"
+
+// agentTools 代理可以调用的全部工具
+var agentTools = []agentTool{
+	{
+		name:        "click",
+		description: "点击屏幕上的元素或位置",
+		params:      clickArgs{},
+		interactive: true,
+		run:         (*AutomationAgent).runClick,
+	},
+	{
+		name:        "type",
+		description: "在当前获得焦点的控件中输入文本，需要先点击输入框",
+		params:      typeArgs{},
+		interactive: true,
+		run:         (*AutomationAgent).runType,
+	},
+	{
+		name:        "hotkey",
+		description: "按下单个按键或组合键，如回车、ctrl+s、alt+tab",
+		params:      hotkeyArgs{},
+		interactive: true,
+		run:         (*AutomationAgent).runHotkey,
+	},
+	{
+		name:        "scroll",
+		description: "滚动鼠标滚轮",
+		params:      scrollArgs{},
+		interactive: true,
+		run:         (*AutomationAgent).runScroll,
+	},
+	{
+		name:        "launch_app",
+		description: "启动应用程序",
+		params:      launchArgs{},
+		interactive: true,
+		run:         (*AutomationAgent).runLaunch,
+	},
+	{
+		name:        "file",
+		description: "创建、删除、移动、复制、重命名文件或创建目录，不经过界面直接操作文件系统",
+		params:      fileArgs{},
+		run:         (*AutomationAgent).runFile,
+	},
+	{
+		name:        "clipboard",
+		description: "读取或写入剪贴板文本",
+		params:      clipboardArgs{},
+		run:         (*AutomationAgent).runClipboard,
+	},
+	{
+		name:        "screenshot",
+		description: "重新截取屏幕进行观察，可以同时保存到文件",
+		params:      screenshotArgs{},
+		interactive: true,
+		run:         (*AutomationAgent).runScreenshot,
+	},
+	{
+		name:        "wait_for",
+		description: "等待界面达到指定状态或等待固定时长",
+		params:      waitArgs{},
+		interactive: true,
+		run:         (*AutomationAgent).runWait,
+	},
+	{
+		name:        finishToolName,
+		description: "结束任务并报告结果，任务完成或确认无法完成时调用",
+		params:      finishArgs{},
+	},
+}
+
+// findAgentTool 按名称查找工具
+func findAgentTool(name string) (agentTool, bool) {
+	for _, tool := range agentTools {
+		if tool.name == name {
+			return tool, true
+		}
+	}
+	return agentTool{}, false
+}
+
+// agentToolDefinitions 生成提供给模型的工具定义
+func agentToolDefinitions() ([]llm.Tool, error) {
+	tools := make([]llm.Tool, 0, len(agentTools))
+	for _, tool := range agentTools {
+		schema, err := jsonschema.GenerateSchemaForType(tool.params)
+		if err != nil {
+			return nil, fmt.Errorf("生成工具%s的参数schema失败: %w", tool.name, err)
+		}
+		tools = append(tools, llm.Tool{Name: tool.name, Description: tool.description, Parameters: schema})
+	}
+	return tools, nil
+}
+
+// decodeToolArgs 解析工具参数，参数为空时视为空对象
+func decodeToolArgs(arguments string, args interface{}) error {
+	if strings.TrimSpace(arguments) == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(arguments), args); err != nil {
+		return core.NewError(core.ErrInvalidArgument, "工具参数不是有效的JSON", err)
+	}
+	return nil
+}
+
+// screenPoint 将模型给出的截图像素坐标转换为虚拟桌面坐标
+func (a *AutomationAgent) screenPoint(x, y int) (core.Point, error) {
+	if a.screen == nil {
+		return core.Point{}, core.NewError(core.ErrInvalidArgument, "还没有截图，无法换算坐标", nil)
+	}
+	if x < 0 || y < 0 || x >= a.screen.Width || y >= a.screen.Height {
+		return core.Point{}, core.NewError(core.ErrInvalidArgument,
+			fmt.Sprintf("坐标(%d,%d)超出截图范围%dx%d", x, y, a.screen.Width, a.screen.Height), nil)
+	}
+	return a.screen.PointToDesktop(core.Point{X: x, Y: y}), nil
+}
+
+// runClick 按元素描述定位或按截图坐标点击
+func (a *AutomationAgent) runClick(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args clickArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+
+	var point core.Point
+	switch {
+	case args.Target != "":
+		stepPlan := &domain.AutomationStepPlan{
+			Type:      "click",
+			Context:   args.Target,
+			Grounding: operation.GroundingMarks,
+			Refine:    args.Refine,
+		}
+		clickOp, err := a.visionService.GenerateClick(ctx, stepPlan, a.raw, nil)
+		if err != nil {
+			return result.fail(fmt.Errorf("定位点击目标失败: %w", err))
+		}
+		point = core.Point{X: clickOp.X, Y: clickOp.Y}
+		result.Data["grounding"] = clickOp.Grounding
+		if clickOp.Label != "" {
+			result.Data["label"] = clickOp.Label
+		}
+	case args.X != nil && args.Y != nil:
+		var err error
+		if point, err = a.screenPoint(*args.X, *args.Y); err != nil {
+			return result.fail(err)
+		}
+	default:
+		return result.fail(core.NewError(core.ErrInvalidArgument, "点击需要target或x、y", nil))
+	}
+
+	button := core.LeftButton
+	if args.Button != "" {
+		button = core.MouseButton(args.Button)
+	}
+	opResult := a.automationService.observeEffect(ctx, "click", nil, &point, func() *core.OperationResult {
+		if args.Double {
+			return a.engine.DoubleClickContext(ctx, point.X, point.Y)
+		}
+		return a.engine.ClickContext(ctx, point.X, point.Y, button)
+	})
+	result.Data["x"] = point.X
+	result.Data["y"] = point.Y
+	copyEffect(result, opResult)
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = fmt.Sprintf("已点击桌面坐标(%d,%d)", point.X, point.Y)
+	return result
+}
+
+// runType 输入文本
+func (a *AutomationAgent) runType(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args typeArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+	if args.Text == "" {
+		return result.fail(core.NewError(core.ErrInvalidArgument, "输入的文本不能为空", nil))
+	}
+
+	opResult := a.automationService.observeEffect(ctx, "type", nil, nil, func() *core.OperationResult {
+		return a.engine.TypeContext(ctx, args.Text)
+	})
+	result.Data["length"] = len([]rune(args.Text))
+	copyEffect(result, opResult)
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = "已输入文本"
+	return result
+}
+
+// runHotkey 按键或组合键，最后一个按键为主键，其余为修饰键
+func (a *AutomationAgent) runHotkey(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args hotkeyArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+	// 兼容模型把组合键写成一个字符串，如["ctrl+s"]
+	var keys []string
+	for _, key := range args.Keys {
+		for _, part := range strings.Split(key, "+") {
+			if part = strings.TrimSpace(part); part != "" {
+				keys = append(keys, part)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return result.fail(core.NewError(core.ErrInvalidArgument, "按键不能为空", nil))
+	}
+
+	key, modifiers := keys[len(keys)-1], keys[:len(keys)-1]
+	var opResult *core.OperationResult
+	if len(modifiers) > 0 {
+		opResult = a.engine.HotkeyContext(ctx, keyModifiers(modifiers), key)
+	} else {
+		opResult = a.engine.KeyPressContext(ctx, key)
+	}
+	result.Data["keys"] = strings.Join(keys, "+")
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = fmt.Sprintf("已按下%s", strings.Join(keys, "+"))
+	return result
+}
+
+// runScroll 在指定位置或当前鼠标位置滚动
+func (a *AutomationAgent) runScroll(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args scrollArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+	if args.Clicks <= 0 {
+		args.Clicks = 3
+	}
+
+	var point core.Point
+	switch {
+	case args.X != nil && args.Y != nil:
+		var err error
+		if point, err = a.screenPoint(*args.X, *args.Y); err != nil {
+			return result.fail(err)
+		}
+	case args.X != nil || args.Y != nil:
+		return result.fail(core.NewError(core.ErrInvalidArgument, "滚动位置需要同时提供x和y", nil))
+	default:
+		position, opResult := a.engine.GetPositionContext(ctx)
+		if !opResult.Success {
+			return result.failResult(opResult)
+		}
+		point = core.Point{X: position.X, Y: position.Y}
+	}
+
+	opResult := a.engine.ScrollContext(ctx, point.X, point.Y, args.Direction, args.Clicks)
+	result.Data["direction"] = args.Direction
+	result.Data["clicks"] = args.Clicks
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = fmt.Sprintf("已向%s滚动%d格", args.Direction, args.Clicks)
+	return result
+}
+
+// runLaunch 启动应用
+func (a *AutomationAgent) runLaunch(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args launchArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+
+	opResult := a.automationService.executeLaunchStep(ctx, AutomationStep{
+		Type:       "launch",
+		Parameters: map[string]interface{}{"app": args.App, "path": args.Path},
+	})
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = "应用已启动，窗口可能需要一段时间才会出现"
+	return result
+}
+
+// runFile 执行文件操作
+func (a *AutomationAgent) runFile(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args fileArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+
+	opResult := a.automationService.executeFileStep(ctx, AutomationStep{
+		Type: "file",
+		Parameters: map[string]interface{}{
+			"operation":   args.Operation,
+			"source_path": args.SourcePath,
+			"target_path": args.TargetPath,
+			"content":     args.Content,
+		},
+	})
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = fmt.Sprintf("文件操作%s已完成", args.Operation)
+	return result
+}
+
+// runClipboard 读取或写入剪贴板，读取的文本在结果中返回给模型
+func (a *AutomationAgent) runClipboard(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args clipboardArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+
+	switch args.Operation {
+	case "get":
+		text, opResult := a.engine.GetClipboardContext(ctx)
+		if !opResult.Success {
+			return result.failResult(opResult)
+		}
+		result.Success = true
+		result.Message = "已读取剪贴板"
+		result.Data["text"] = text
+		return result
+	case "set":
+		if args.Text == "" {
+			return result.fail(core.NewError(core.ErrInvalidArgument, "写入剪贴板的文本不能为空", nil))
+		}
+	default:
+		return result.fail(core.NewError(core.ErrInvalidArgument,
+			fmt.Sprintf("不支持的剪贴板操作: %q，应为get或set", args.Operation), nil))
+	}
+
+	opResult := a.engine.SetClipboardContext(ctx, args.Text)
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = "已写入剪贴板"
+	return result
+}
+
+// runScreenshot 保存截图，观察用的新截图在本轮结束时统一附带
+func (a *AutomationAgent) runScreenshot(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args screenshotArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+	if args.Path == "" {
+		result.Success = true
+		result.Message = "将附带最新截图"
+		return result
+	}
+
+	_, opResult := a.engine.ScreenshotContext(ctx)
+	capture, ok := core.CaptureFromResult(opResult)
+	if !ok {
+		if !opResult.Success {
+			return result.failResult(opResult)
+		}
+		return result.fail(core.NewError(core.ErrUnknown, "截屏失败: 未获取到图像数据", nil))
+	}
+	if opResult := a.engine.CreateFileContext(ctx, args.Path, capture.ImageData); !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = "截图已保存"
+	result.Data["path"] = args.Path
+	return result
+}
+
+// runWait 等待条件满足或固定时长
+func (a *AutomationAgent) runWait(ctx context.Context, arguments string) *StepExecutionResult {
+	result := &StepExecutionResult{Data: map[string]interface{}{}}
+	var args waitArgs
+	if err := decodeToolArgs(arguments, &args); err != nil {
+		return result.fail(err)
+	}
+
+	if args.Condition != "" {
+		condition, err := wait.Parse(args.Condition)
+		if err != nil {
+			return result.fail(err)
+		}
+		options := wait.DefaultOptions()
+		if args.TimeoutMs > 0 {
+			options.Timeout = time.Duration(args.TimeoutMs) * time.Millisecond
+		}
+		opResult := a.engine.WaitUntilContext(ctx, condition, options)
+		result.Data["condition"] = condition.String()
+		if !opResult.Success {
+			return result.failResult(opResult)
+		}
+		result.Success = true
+		result.Message = fmt.Sprintf("条件已满足，等待了%d毫秒", opResult.Duration.Milliseconds())
+		return result
+	}
+
+	duration := args.DurationMs
+	if duration <= 0 {
+		duration = 1000
+	}
+	if opResult := a.engine.WaitContext(ctx, duration); !opResult.Success {
+		return result.failResult(opResult)
+	}
+	result.Success = true
+	result.Message = fmt.Sprintf("已等待%d毫秒", duration)
+	return result
+}
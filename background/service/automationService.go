@@ -5,10 +5,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"diandian/background/automation/core"
+	"diandian/background/automation/core/screendiff"
+	"diandian/background/automation/core/wait"
 	"diandian/background/automation/hybrid"
+	"diandian/background/automation/legacy/app"
+	"diandian/background/automation/legacy/file"
+	"diandian/background/domain"
+	"diandian/background/service/operation"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
 )
@@ -19,8 +28,11 @@ type AutomationService struct {
 	engine *hybrid.HybridEngine
 
 	// 当前执行状态
+	mu            sync.Mutex
 	isRunning     bool
 	currentTaskID uint
+	cancels       map[uint]context.CancelFunc // 正在执行的任务及其取消函数
+	effectPolicy  screendiff.Policy           // 判断界面操作是否产生可见效果的策略
 
 	// 事件通道
 	eventChan chan AutomationEvent
@@ -67,11 +79,135 @@ func NewAutomationService(app *application.App) *AutomationService {
 		log.Printf("创建混合引擎失败: %v", err)
 		return nil
 	}
+	registerBackends(engine)
 
+	// 没有本地OCR时用视觉模型识别文字
+	if engine.OCRProvider() == nil {
+		engine.SetOCRProvider(operation.NewVisionOCR())
+	}
+	// 截图发送给视觉模型前遮挡敏感窗口和密码输入框
+	operation.SetRedactor(NewRedactionService(engine))
+
+	return NewAutomationServiceWithEngine(app, engine)
+}
+
+// NewAutomationServiceWithEngine 使用指定的引擎创建自动化服务
+// 不注册legacy后端，测试中可传入基于虚拟桌面的混合引擎：
+// hybrid.NewHybridEngineWithBackend("virtual", virtualEngine)
+func NewAutomationServiceWithEngine(app *application.App, engine *hybrid.HybridEngine) *AutomationService {
 	return &AutomationService{
-		app:       app,
-		engine:    engine,
-		eventChan: make(chan AutomationEvent, 100),
+		app:          app,
+		engine:       engine,
+		cancels:      make(map[uint]context.CancelFunc),
+		effectPolicy: screendiff.DefaultPolicy(),
+		eventChan:    make(chan AutomationEvent, 100),
+	}
+}
+
+// SetEffectPolicy 设置判断界面操作是否产生可见效果的策略
+// Action为screendiff.ActionNone时不再截图比较
+func (s *AutomationService) SetEffectPolicy(policy screendiff.Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.effectPolicy = policy
+}
+
+// EffectPolicy 当前的操作效果判断策略
+func (s *AutomationService) EffectPolicy() screendiff.Policy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.effectPolicy
+}
+
+// effectCheckedSteps 默认检查操作效果的步骤类型
+// 按键、快捷键和滚动经常没有可见变化（如滚动到底部），需要通过verify_effect参数显式开启
+var effectCheckedSteps = map[string]bool{
+	"click": true,
+	"type":  true,
+}
+
+// observeEffect 执行界面操作并比较前后截图，没有可见效果时按策略将步骤标记为失败
+// 参数verify_effect可以覆盖步骤类型的默认设置；focus为操作位置，可以为空
+func (s *AutomationService) observeEffect(ctx context.Context, stepType string, parameters map[string]interface{}, focus *core.Point, action func() *core.OperationResult) *core.OperationResult {
+	verify := effectCheckedSteps[stepType]
+	if v, ok := parameters["verify_effect"].(bool); ok {
+		verify = v
+	}
+	if !verify {
+		return action()
+	}
+
+	result := screendiff.Observe(ctx, s.engine, focus, s.EffectPolicy(), action)
+	if result.Code == core.ErrNoEffect {
+		log.Printf("步骤没有可见效果: %s %s", stepType, result.Error)
+	}
+	return result
+}
+
+// taskContextKey 任务ctx中保存任务ID的键，用于识别同一任务的嵌套登记
+type taskContextKey struct{}
+
+// BeginTask 登记一个正在执行的任务，返回可被StopTask取消的ctx
+// 检查和登记在同一把锁内完成，已有任务在执行时返回错误；
+// 传入的ctx已是该任务登记后的ctx时（如执行引擎在外层登记后再次登记）复用外层的登记
+// 调用方必须在任务结束时调用返回的done函数
+func (s *AutomationService) BeginTask(ctx context.Context, taskID uint) (context.Context, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isRunning {
+		if id, ok := ctx.Value(taskContextKey{}).(uint); ok && id == taskID && s.currentTaskID == taskID {
+			return ctx, func() {}, nil
+		}
+		return nil, nil, core.NewError(core.ErrEngineUnavailable, fmt.Sprintf("自动化引擎正在执行其他任务: %d", s.currentTaskID), nil)
+	}
+
+	taskCtx, cancel := context.WithCancel(context.WithValue(ctx, taskContextKey{}, taskID))
+	s.cancels[taskID] = cancel
+	s.isRunning = true
+	s.currentTaskID = taskID
+
+	return taskCtx, func() {
+		cancel()
+
+		s.mu.Lock()
+		delete(s.cancels, taskID)
+		if s.currentTaskID == taskID {
+			s.isRunning = false
+			s.currentTaskID = 0
+		}
+		s.mu.Unlock()
+	}, nil
+}
+
+// StopTask 取消指定任务，正在进行的LLM调用、等待和worker请求都会尽快返回
+func (s *AutomationService) StopTask(taskID uint) bool {
+	s.mu.Lock()
+	cancel, ok := s.cancels[taskID]
+	s.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// legacyEngineFactory 创建完整的legacy引擎（依赖robotgo，仅在robotgo构建标签下可用）
+var legacyEngineFactory func() (interface{}, error)
+
+// registerBackends 为混合引擎注册附加后端
+// 文件操作和应用启动由纯Go的legacy实现提供，robotgo构建时追加完整的legacy引擎作为最后的回退
+func registerBackends(engine *hybrid.HybridEngine) {
+	engine.AddBackend("legacy_file", file.NewOperator())
+	engine.AddBackend("legacy_app", app.NewLauncher())
+
+	if legacyEngineFactory != nil {
+		legacy, err := legacyEngineFactory()
+		if err != nil {
+			log.Printf("初始化legacy引擎失败: %v", err)
+			return
+		}
+		engine.AddBackend("legacy", legacy)
 	}
 }
 
@@ -94,13 +230,24 @@ func (s *AutomationService) Cleanup() {
 		close(s.eventChan)
 		s.eventChan = nil
 	}
+	s.mu.Lock()
+	for _, cancel := range s.cancels {
+		cancel()
+	}
 	s.isRunning = false
+	s.mu.Unlock()
+	if s.engine != nil {
+		if err := s.engine.Close(); err != nil {
+			log.Printf("关闭自动化引擎失败: %v", err)
+		}
+	}
 	s.engine = nil
 }
 
 // ExecuteAutomationTask 执行自动化任务
 func (s *AutomationService) ExecuteAutomationTask(ctx context.Context, request AutomationRequest) *AutomationResponse {
-	if s.isRunning {
+	ctx, done, err := s.BeginTask(ctx, request.TaskID)
+	if err != nil {
 		return &AutomationResponse{
 			Success: false,
 			Message: "已有任务正在执行",
@@ -108,13 +255,7 @@ func (s *AutomationService) ExecuteAutomationTask(ctx context.Context, request A
 			Error:   "automation_busy",
 		}
 	}
-
-	s.isRunning = true
-	s.currentTaskID = request.TaskID
-	defer func() {
-		s.isRunning = false
-		s.currentTaskID = 0
-	}()
+	defer done()
 
 	// 发送任务开始事件
 	s.sendEvent(AutomationEvent{
@@ -153,7 +294,16 @@ func (s *AutomationService) ExecuteAutomationTask(ctx context.Context, request A
 		})
 
 		// 执行步骤
-		result := s.executeStep(step)
+		result := s.executeStep(ctx, step)
+		if ctx.Err() != nil {
+			return &AutomationResponse{
+				Success:   false,
+				Message:   "任务被取消",
+				TaskID:    request.TaskID,
+				StepIndex: i,
+				Error:     "cancelled",
+			}
+		}
 		if !result.Success {
 			// 发送步骤失败事件
 			s.sendEvent(AutomationEvent{
@@ -187,7 +337,15 @@ func (s *AutomationService) ExecuteAutomationTask(ctx context.Context, request A
 		})
 
 		// 步骤间延迟
-		time.Sleep(500 * time.Millisecond)
+		if err := core.Sleep(ctx, 500*time.Millisecond); err != nil {
+			return &AutomationResponse{
+				Success:   false,
+				Message:   "任务被取消",
+				TaskID:    request.TaskID,
+				StepIndex: i + 1,
+				Error:     "cancelled",
+			}
+		}
 	}
 
 	// 发送任务完成事件
@@ -212,18 +370,32 @@ func (s *AutomationService) ExecuteAutomationTask(ctx context.Context, request A
 }
 
 // executeStep 执行单个步骤
-func (s *AutomationService) executeStep(step AutomationStep) *core.OperationResult {
+func (s *AutomationService) executeStep(ctx context.Context, step AutomationStep) *core.OperationResult {
+	if result := core.CheckContext(ctx); result != nil {
+		return result
+	}
+
 	switch step.Type {
 	case "click":
-		return s.executeClickStep(step)
+		return s.executeClickStep(ctx, step)
 	case "type":
-		return s.executeTypeStep(step)
+		return s.executeTypeStep(ctx, step)
 	case "key":
-		return s.executeKeyStep(step)
+		return s.executeKeyStep(ctx, step)
+	case "hotkey":
+		return s.executeHotkeyStep(ctx, step)
+	case "scroll":
+		return s.executeScrollStep(ctx, step)
+	case "launch":
+		return s.executeLaunchStep(ctx, step)
+	case "file":
+		return s.executeFileStep(ctx, step)
+	case "clipboard":
+		return s.executeClipboardStep(ctx, step)
 	case "screenshot":
-		return s.executeScreenshotStep(step)
+		return s.executeScreenshotStep(ctx, step)
 	case "wait":
-		return s.executeWaitStep(step)
+		return s.executeWaitStep(ctx, step)
 	default:
 		return core.NewErrorResult(
 			fmt.Sprintf("不支持的步骤类型: %s", step.Type),
@@ -233,39 +405,145 @@ func (s *AutomationService) executeStep(step AutomationStep) *core.OperationResu
 }
 
 // executeClickStep 执行点击步骤
-func (s *AutomationService) executeClickStep(step AutomationStep) *core.OperationResult {
+// 未提供坐标但提供了text时，先在屏幕上查找该文字再点击
+func (s *AutomationService) executeClickStep(ctx context.Context, step AutomationStep) *core.OperationResult {
 	x, ok1 := step.Parameters["x"].(float64)
 	y, ok2 := step.Parameters["y"].(float64)
 	if !ok1 || !ok2 {
-		return core.NewErrorResult("点击步骤缺少坐标参数", fmt.Errorf("missing coordinates"))
+		text, ok := step.Parameters["text"].(string)
+		if !ok || text == "" {
+			return core.NewErrorResult("点击步骤缺少坐标参数", fmt.Errorf("missing coordinates"))
+		}
+		point, result := s.engine.FindTextContext(ctx, text)
+		if !result.Success {
+			return result
+		}
+		x, y = float64(point.X), float64(point.Y)
+	}
+
+	button := core.LeftButton
+	if b, ok := step.Parameters["button"].(string); ok && b != "" {
+		button = core.MouseButton(b)
 	}
 
-	return s.engine.Click(int(x), int(y))
+	focus := &core.Point{X: int(x), Y: int(y)}
+	return s.observeEffect(ctx, step.Type, step.Parameters, focus, func() *core.OperationResult {
+		if clicks, ok := step.Parameters["clicks"].(float64); ok && clicks == 2 && button == core.LeftButton {
+			return s.engine.DoubleClickContext(ctx, focus.X, focus.Y)
+		}
+		return s.engine.ClickContext(ctx, focus.X, focus.Y, button)
+	})
+}
+
+// executeScrollStep 执行滚动步骤
+func (s *AutomationService) executeScrollStep(ctx context.Context, step AutomationStep) *core.OperationResult {
+	direction, ok := step.Parameters["direction"].(string)
+	if !ok || direction == "" {
+		direction = "down"
+	}
+
+	clicks := 3
+	if c, ok := step.Parameters["clicks"].(float64); ok && c > 0 {
+		clicks = int(c)
+	}
+
+	// 未指定坐标时在当前鼠标位置滚动
+	x, okX := step.Parameters["x"].(float64)
+	y, okY := step.Parameters["y"].(float64)
+	if !okX || !okY {
+		point, result := s.engine.GetPositionContext(ctx)
+		if !result.Success {
+			return result
+		}
+		x, y = float64(point.X), float64(point.Y)
+	}
+
+	focus := &core.Point{X: int(x), Y: int(y)}
+	return s.observeEffect(ctx, step.Type, step.Parameters, focus, func() *core.OperationResult {
+		return s.engine.ScrollContext(ctx, focus.X, focus.Y, direction, clicks)
+	})
 }
 
 // executeTypeStep 执行输入步骤
-func (s *AutomationService) executeTypeStep(step AutomationStep) *core.OperationResult {
+func (s *AutomationService) executeTypeStep(ctx context.Context, step AutomationStep) *core.OperationResult {
 	text, ok := step.Parameters["text"].(string)
 	if !ok {
 		return core.NewErrorResult("输入步骤缺少文本参数", fmt.Errorf("missing text parameter"))
 	}
 
-	return s.engine.Type(text)
+	// 可选参数：strategy（auto/keys/paste）和restore_clipboard
+	options := core.DefaultTypeOptions()
+	if name, ok := step.Parameters["strategy"].(string); ok {
+		strategy, err := core.ParseTypeStrategy(name)
+		if err != nil {
+			return core.NewErrorResult("输入策略无效", err)
+		}
+		options.Strategy = strategy
+	}
+	if restore, ok := step.Parameters["restore_clipboard"].(bool); ok {
+		options.RestoreClipboard = restore
+	}
+
+	return s.observeEffect(ctx, step.Type, step.Parameters, nil, func() *core.OperationResult {
+		return s.engine.TypeWithOptionsContext(ctx, text, options)
+	})
 }
 
 // executeScreenshotStep 执行截屏步骤
-func (s *AutomationService) executeScreenshotStep(step AutomationStep) *core.OperationResult {
-	return s.engine.Screenshot()
+// 如果参数中提供了path，则同时保存到文件
+func (s *AutomationService) executeScreenshotStep(ctx context.Context, step AutomationStep) *core.OperationResult {
+	start := time.Now()
+
+	_, result := s.engine.ScreenshotContext(ctx)
+	path, ok := step.Parameters["path"].(string)
+	if !ok || path == "" || !result.Success {
+		return result
+	}
+
+	capture, ok := core.CaptureFromResult(result)
+	if !ok {
+		errResult := core.NewErrorResult("截屏结果中没有图像数据", fmt.Errorf("empty screenshot"))
+		errResult.SetDuration(start)
+		return errResult
+	}
+
+	if err := os.WriteFile(path, capture.ImageData, 0644); err != nil {
+		errResult := core.NewErrorResult(fmt.Sprintf("保存截屏失败: %s", path), err)
+		errResult.SetDuration(start)
+		return errResult
+	}
+
+	result.Message = fmt.Sprintf("截屏已保存: %s", path)
+	result.SetDuration(start)
+	return result
 }
 
 // executeWaitStep 执行等待步骤
-func (s *AutomationService) executeWaitStep(step AutomationStep) *core.OperationResult {
+// 提供until条件表达式时等待条件满足（timeout和interval为毫秒），否则等待duration毫秒
+func (s *AutomationService) executeWaitStep(ctx context.Context, step AutomationStep) *core.OperationResult {
+	if expr, ok := step.Parameters["until"].(string); ok && expr != "" {
+		condition, err := wait.Parse(expr)
+		if err != nil {
+			return core.NewErrorResult("等待条件无效", err)
+		}
+		options := wait.DefaultOptions()
+		if timeout, ok := step.Parameters["timeout"].(float64); ok && timeout > 0 {
+			options.Timeout = time.Duration(timeout) * time.Millisecond
+		}
+		if interval, ok := step.Parameters["interval"].(float64); ok && interval > 0 {
+			options.Interval = time.Duration(interval) * time.Millisecond
+		}
+		return s.engine.WaitUntilContext(ctx, condition, options)
+	}
+
 	duration, ok := step.Parameters["duration"].(float64)
 	if !ok {
 		duration = 1000 // 默认等待1秒
 	}
 
-	time.Sleep(time.Duration(duration) * time.Millisecond)
+	if err := core.Sleep(ctx, time.Duration(duration)*time.Millisecond); err != nil {
+		return core.NewCancelledResult(ctx)
+	}
 	return core.NewSuccessResult(
 		fmt.Sprintf("等待 %d 毫秒", int(duration)),
 		map[string]interface{}{
@@ -275,13 +553,126 @@ func (s *AutomationService) executeWaitStep(step AutomationStep) *core.Operation
 }
 
 // executeKeyStep 执行按键步骤
-func (s *AutomationService) executeKeyStep(step AutomationStep) *core.OperationResult {
+func (s *AutomationService) executeKeyStep(ctx context.Context, step AutomationStep) *core.OperationResult {
 	key, ok := step.Parameters["key"].(string)
 	if !ok {
 		return core.NewErrorResult("按键步骤缺少按键参数", fmt.Errorf("missing key parameter"))
 	}
 
-	return s.engine.KeyPress(key)
+	return s.observeEffect(ctx, step.Type, step.Parameters, nil, func() *core.OperationResult {
+		if modifiers := keyModifiers(step.Parameters["modifiers"]); len(modifiers) > 0 {
+			return s.engine.HotkeyContext(ctx, modifiers, key)
+		}
+		return s.engine.KeyPressContext(ctx, key)
+	})
+}
+
+// executeHotkeyStep 执行组合键步骤
+func (s *AutomationService) executeHotkeyStep(ctx context.Context, step AutomationStep) *core.OperationResult {
+	key, ok := step.Parameters["key"].(string)
+	if !ok {
+		return core.NewErrorResult("组合键步骤缺少按键参数", fmt.Errorf("missing key parameter"))
+	}
+
+	return s.observeEffect(ctx, step.Type, step.Parameters, nil, func() *core.OperationResult {
+		return s.engine.HotkeyContext(ctx, keyModifiers(step.Parameters["modifiers"]), key)
+	})
+}
+
+// keyModifiers 将步骤参数中的修饰键转换为core.KeyModifier
+// 参数可能是[]string（内部构造）或[]interface{}（JSON解码）
+func keyModifiers(value interface{}) []core.KeyModifier {
+	var names []string
+	switch v := value.(type) {
+	case []string:
+		names = v
+	case []interface{}:
+		for _, item := range v {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	case string:
+		if v != "" {
+			names = strings.Split(v, "+")
+		}
+	}
+
+	modifiers := make([]core.KeyModifier, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "ctrl", "control":
+			modifiers = append(modifiers, core.ModCtrl)
+		case "alt", "option":
+			modifiers = append(modifiers, core.ModAlt)
+		case "shift":
+			modifiers = append(modifiers, core.ModShift)
+		case "win", "cmd", "command", "super", "meta":
+			modifiers = append(modifiers, core.ModWin)
+		}
+	}
+	return modifiers
+}
+
+// executeLaunchStep 执行启动应用步骤
+func (s *AutomationService) executeLaunchStep(ctx context.Context, step AutomationStep) *core.OperationResult {
+	if path, ok := step.Parameters["path"].(string); ok && path != "" {
+		return s.engine.LaunchWithPathContext(ctx, path)
+	}
+
+	appName, ok := step.Parameters["app"].(string)
+	if !ok || appName == "" {
+		return core.NewErrorResult("启动步骤缺少应用参数", fmt.Errorf("missing app parameter"))
+	}
+
+	return s.engine.LaunchContext(ctx, appName)
+}
+
+// executeFileStep 执行文件操作步骤
+func (s *AutomationService) executeFileStep(ctx context.Context, step AutomationStep) *core.OperationResult {
+	operation, _ := step.Parameters["operation"].(string)
+	source, _ := step.Parameters["source_path"].(string)
+	target, _ := step.Parameters["target_path"].(string)
+	content, _ := step.Parameters["content"].(string)
+
+	if source == "" {
+		return core.NewErrorResult("文件步骤缺少源路径", fmt.Errorf("missing source_path parameter"))
+	}
+
+	switch operation {
+	case "create":
+		return s.engine.CreateFileContext(ctx, source, []byte(content))
+	case "delete":
+		return s.engine.DeleteFileContext(ctx, source)
+	case "move":
+		return s.engine.MoveFileContext(ctx, source, target)
+	case "copy":
+		return s.engine.CopyFileContext(ctx, source, target)
+	case "rename":
+		return s.engine.RenameFileContext(ctx, source, target)
+	case "mkdir":
+		return s.engine.CreateDirContext(ctx, source)
+	default:
+		return core.NewErrorResult(
+			fmt.Sprintf("不支持的文件操作: %s", operation),
+			fmt.Errorf("unsupported file operation"),
+		)
+	}
+}
+
+// executeClipboardStep 执行剪贴板步骤
+func (s *AutomationService) executeClipboardStep(ctx context.Context, step AutomationStep) *core.OperationResult {
+	operation, _ := step.Parameters["operation"].(string)
+	if operation == "get" {
+		_, result := s.engine.GetClipboardContext(ctx)
+		return result
+	}
+
+	text, ok := step.Parameters["text"].(string)
+	if !ok {
+		return core.NewErrorResult("剪贴板步骤缺少文本参数", fmt.Errorf("missing text parameter"))
+	}
+	return s.engine.SetClipboardContext(ctx, text)
 }
 
 // sendEvent 发送事件
@@ -307,9 +698,13 @@ func (s *AutomationService) handleEvents() {
 
 // GetStatus 获取自动化服务状态
 func (s *AutomationService) GetStatus() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return map[string]interface{}{
 		"is_running":       s.isRunning,
 		"current_task_id":  s.currentTaskID,
+		"running_tasks":    len(s.cancels),
 		"engine_available": s.engine != nil,
 	}
 }
@@ -319,9 +714,86 @@ func (s *AutomationService) GetEngine() *hybrid.HybridEngine {
 	return s.engine
 }
 
+// Capabilities 获取当前自动化引擎的能力描述
+func (s *AutomationService) Capabilities() *core.Capabilities {
+	if s.engine == nil {
+		return &core.Capabilities{Engine: "none"}
+	}
+	return s.engine.Capabilities()
+}
+
+// CapabilitiesContext 获取当前自动化引擎的能力描述，需要时启动worker，ctx取消时停止等待
+func (s *AutomationService) CapabilitiesContext(ctx context.Context) *core.Capabilities {
+	if s.engine == nil {
+		return &core.Capabilities{Engine: "none"}
+	}
+	return s.engine.CapabilitiesContext(ctx)
+}
+
+// stepTypeOperations 任务分解的步骤类型及其依赖的操作
+// 文件步骤的具体操作在执行时才生成，这里只要求引擎能创建文件；等待由混合引擎自身实现
+var stepTypeOperations = map[string][]core.Operation{
+	"click":      {core.OpClick},
+	"type":       {core.OpType},
+	"launch_app": {core.OpLaunch},
+	"file":       {core.OpCreateFile},
+	"screenshot": {core.OpScreenshot},
+	"clipboard":  {core.OpGetClipboard, core.OpSetClipboard},
+	"wait":       {core.OpWait},
+	"key_press":  {core.OpKeyPress, core.OpHotkey},
+	"scroll":     {core.OpGetPosition, core.OpScroll},
+}
+
+// StepTypeSupported 引擎能否执行指定类型的步骤
+func StepTypeSupported(caps *core.Capabilities, stepType string) bool {
+	operations, ok := stepTypeOperations[stepType]
+	return ok && len(caps.Missing(operations...)) == 0
+}
+
+// CheckPlan 在执行前检查任务分解结果需要的操作是否都被引擎支持
+// 可选步骤不支持时只跳过，不会导致整个计划被拒绝
+func (s *AutomationService) CheckPlan(decomposition *domain.AutomationTaskDecomposition) error {
+	caps := s.Capabilities()
+
+	var problems []string
+	for i, step := range decomposition.Steps {
+		if step.Optional {
+			continue
+		}
+
+		operations, ok := stepTypeOperations[step.Type]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("步骤%d: 不支持的步骤类型 %s", i+1, step.Type))
+			continue
+		}
+		if step.RequiresScreenAnalysis {
+			operations = append(operations, core.OpScreenshot)
+		}
+		if step.Type == "wait" && step.WaitFor != "" {
+			condition, err := wait.Parse(step.WaitFor)
+			if err != nil {
+				return core.NewError(core.ErrInvalidArgument, fmt.Sprintf("步骤%d的等待条件无效", i+1), err)
+			}
+			operations = append(operations, wait.RequiredOperations(condition)...)
+		}
+		if missing := caps.Missing(operations...); len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("步骤%d(%s): 引擎不支持 %v", i+1, step.Type, missing))
+		}
+	}
+
+	if len(problems) > 0 {
+		return core.NewError(core.ErrUnsupported, "当前自动化引擎无法执行该计划: "+strings.Join(problems, "; "), nil)
+	}
+	return nil
+}
+
 // StopCurrentTask 停止当前任务
 func (s *AutomationService) StopCurrentTask() *AutomationResponse {
-	if !s.isRunning {
+	s.mu.Lock()
+	running, taskID := s.isRunning, s.currentTaskID
+	s.mu.Unlock()
+
+	if !running || !s.StopTask(taskID) {
 		return &AutomationResponse{
 			Success: false,
 			Message: "没有正在执行的任务",
@@ -332,18 +804,23 @@ func (s *AutomationService) StopCurrentTask() *AutomationResponse {
 	// 发送停止事件
 	s.sendEvent(AutomationEvent{
 		Type:    "task_stopped",
-		TaskID:  s.currentTaskID,
+		TaskID:  taskID,
 		Message: "任务被用户停止",
 	})
 
 	return &AutomationResponse{
 		Success: true,
-		Message: "任务停止请求已发送",
-		TaskID:  s.currentTaskID,
+		Message: "任务已停止",
+		TaskID:  taskID,
 	}
 }
 
 // ExecuteStep 公开的步骤执行方法
 func (s *AutomationService) ExecuteStep(step AutomationStep) *core.OperationResult {
-	return s.executeStep(step)
+	return s.executeStep(context.Background(), step)
+}
+
+// ExecuteStepContext 公开的步骤执行方法，ctx取消时中止步骤
+func (s *AutomationService) ExecuteStepContext(ctx context.Context, step AutomationStep) *core.OperationResult {
+	return s.executeStep(ctx, step)
 }
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/imagematch"
+	"diandian/background/domain"
+	"diandian/background/service/operation"
+)
+
+// GenerateClick 按步骤的定位方式生成点击操作
+// grounding为marks时收集候选元素并让模型选择编号，候选为空或模型没有选中时退回坐标方式；
+// 步骤要求精确定位时再在目标附近放大截图修正坐标，修正失败时保留原坐标。
+// capture为该步骤屏幕分析使用的截图，为nil时重新截屏。analysis中的坐标须已转换为虚拟桌面坐标
+func (evs *EnhancedVisionService) GenerateClick(ctx context.Context, stepPlan *domain.AutomationStepPlan, capture *core.ScreenCapture, analysis *domain.VisualAnalysisResponse) (*domain.ClickOperation, error) {
+	var clickOp *domain.ClickOperation
+	if stepPlan.Grounding == operation.GroundingMarks {
+		var err error
+		clickOp, err = evs.generateMarkedClick(ctx, stepPlan, capture, analysis)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			slog.Warn("编号定位失败，退回坐标定位", "error", err)
+		}
+	}
+
+	if clickOp == nil {
+		var err error
+		clickOp, err = evs.llmService.GenerateClickOperation(ctx, stepPlan.Context, analysis)
+		if err != nil {
+			return nil, err
+		}
+		clickOp.Grounding = operation.GroundingCoordinates
+	}
+
+	if stepPlan.Refine {
+		evs.refineClick(ctx, stepPlan, clickOp)
+	}
+	return clickOp, nil
+}
+
+// generateMarkedClick 收集候选元素并通过编号选择点击目标
+func (evs *EnhancedVisionService) generateMarkedClick(ctx context.Context, stepPlan *domain.AutomationStepPlan, capture *core.ScreenCapture, analysis *domain.VisualAnalysisResponse) (*domain.ClickOperation, error) {
+	if capture == nil {
+		_, result := evs.automationService.engine.ScreenshotContext(ctx)
+		screen, ok := core.CaptureFromResult(result)
+		if !ok {
+			if err := result.Err(); err != nil {
+				return nil, err
+			}
+			return nil, core.NewError(core.ErrUnknown, "截屏失败: 未获取到图像数据", nil)
+		}
+		capture = screen
+	}
+
+	marks := evs.automationService.ClickCandidates(ctx, capture, analysis, stepPlan.Template)
+	slog.Info("收集点击候选元素", "count", len(marks))
+	return evs.llmService.GenerateMarkedClickOperation(ctx, stepPlan.Context, capture, marks)
+}
+
+// refineClick 在点击目标附近放大截图精确定位，成功时更新点击坐标，每一轮的截图都记录在Refinement中
+func (evs *EnhancedVisionService) refineClick(ctx context.Context, stepPlan *domain.AutomationStepPlan, clickOp *domain.ClickOperation) {
+	target := stepPlan.Context
+	if clickOp.Label != "" {
+		target = fmt.Sprintf("%s（粗略定位结果：%s）", target, clickOp.Label)
+	}
+	var box core.Rect
+	if clickOp.Target != nil {
+		box = core.Rect{X: clickOp.Target.X, Y: clickOp.Target.Y, Width: clickOp.Target.Width, Height: clickOp.Target.Height}
+	} else {
+		box = core.Rect{X: clickOp.X, Y: clickOp.Y}
+	}
+
+	point, stages, err := evs.RefineElement(ctx, box, target)
+	clickOp.Refinement = stages
+	if err != nil {
+		slog.Warn("精确定位失败，使用粗略坐标", "x", clickOp.X, "y", clickOp.Y, "error", err)
+		return
+	}
+	slog.Info("精确定位完成", "from", fmt.Sprintf("(%d,%d)", clickOp.X, clickOp.Y),
+		"to", fmt.Sprintf("(%d,%d)", point.X, point.Y), "stages", len(stages))
+	clickOp.X, clickOp.Y = point.X, point.Y
+}
+
+// ClickCandidates 收集截图中可能的点击目标并编号
+// 来源依次为模板匹配、视觉分析结果和文字识别；视觉模型文字识别开销较大，已有视觉分析结果时不再调用
+func (s *AutomationService) ClickCandidates(ctx context.Context, capture *core.ScreenCapture, analysis *domain.VisualAnalysisResponse, template string) []operation.Mark {
+	var templateMarks, visionMarks, textMarks []operation.Mark
+
+	if template != "" {
+		if tpl, err := imagematch.LoadTemplate(template); err != nil {
+			slog.Warn("加载模板图片失败", "template", template, "error", err)
+		} else if matches, err := imagematch.FindInCapture(capture, tpl, imagematch.DefaultOptions()); err != nil {
+			slog.Warn("模板匹配失败", "template", template, "error", err)
+		} else {
+			templateMarks = operation.MarksFromMatches(matches, template)
+		}
+	}
+
+	if analysis != nil {
+		elements := make([]operation.VisualElement, len(analysis.ElementsFound))
+		for i, element := range analysis.ElementsFound {
+			elements[i] = operation.VisualElement{
+				Type:        element.Type,
+				Description: element.Description,
+				Coordinates: operation.Coordinates{
+					X:      element.Coordinates.X,
+					Y:      element.Coordinates.Y,
+					Width:  element.Coordinates.Width,
+					Height: element.Coordinates.Height,
+				},
+				Confidence:  element.Confidence,
+				TextContent: element.TextContent,
+				Clickable:   element.Clickable,
+			}
+		}
+		visionMarks = operation.MarksFromElements(elements)
+	}
+
+	if provider := s.engine.OCRProvider(); provider != nil && (provider.Name() != "vision" || len(visionMarks) == 0) {
+		if boxes, err := provider.Recognize(ctx, capture, core.OCROptions{}); err != nil {
+			slog.Warn("文字识别失败", "provider", provider.Name(), "error", err)
+		} else {
+			textMarks = operation.MarksFromTextBoxes(boxes)
+		}
+	}
+
+	return operation.MergeMarks(templateMarks, visionMarks, textMarks)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"diandian/background/constant"
+	"diandian/background/database"
+	"diandian/background/model"
+	"diandian/background/service/llm"
+	"diandian/background/service/operation"
+)
+
+const (
+	// historyKeepRatio 需要生成摘要时，最近的消息最多占用预算的比例
+	// 留出余量使之后的若干轮对话不必每次都重新生成摘要
+	historyKeepRatio = 0.5
+	// summaryMaxTokens 摘要的最大输出token数
+	summaryMaxTokens = 1000
+)
+
+// contextBudget 对话上下文预算，读取设置失败时使用默认值
+func contextBudget() int {
+	config, err := operation.GetTextModelConfig()
+	if err != nil {
+		return operation.DefaultContextBudget
+	}
+	return config.ContextBudget
+}
+
+// schemaTokens 结构化输出的Schema占用的token数，不支持原生Schema的服务会将其写入提示词
+func schemaTokens(tokenModel string, schema json.Marshaler) int {
+	data, err := schema.MarshalJSON()
+	if err != nil {
+		return 0
+	}
+	return llm.CountTokens(tokenModel, string(data))
+}
+
+// historyContent 消息发送给模型的内容
+// 助手消息保存的是完整的JSON结构，只发送其中的聊天回复，解析失败时原样发送
+func historyContent(msg *model.Message) string {
+	if msg.Role != model.MessageRoleAssistant {
+		return msg.Content
+	}
+	var response UnifiedMessageResponse
+	if err := json.Unmarshal([]byte(cleanMarkdownCodeBlock(msg.Content)), &response); err != nil || response.ChatResponse == "" {
+		return msg.Content
+	}
+	content := response.ChatResponse
+	if response.MessageType == "automation" && response.AutomationTask != nil && response.AutomationTask.TaskName != "" {
+		content += fmt.Sprintf("\n（已创建自动化任务：%s）", response.AutomationTask.TaskName)
+	}
+	return content
+}
+
+// historyMessage 将保存的消息转换为对话消息
+func historyMessage(msg *model.Message) llm.Message {
+	if msg.Role == model.MessageRoleAssistant {
+		return llm.AssistantMessage(historyContent(msg))
+	}
+	return llm.UserMessage(msg.Content)
+}
+
+// withSummary 摘要不为空时作为系统消息放在对话历史之前
+func withSummary(summary string, messages []llm.Message) []llm.Message {
+	if summary == "" {
+		return messages
+	}
+	return append([]llm.Message{llm.SystemMessage("之前对话的摘要：\n" + summary)}, messages...)
+}
+
+// ConversationHistory 构造发送给模型的对话历史，用于任务分解等不经过ProcessMessage的调用
+func (s *LLMService) ConversationHistory(ctx context.Context, conversationID uint64) ([]llm.Message, error) {
+	provider, err := s.createTextProvider()
+	if err != nil {
+		return nil, err
+	}
+	return s.conversationHistory(ctx, provider, conversationID, 0)
+}
+
+// conversationHistory 构造发送给模型的对话历史，reserved为系统提示等其他内容占用的token数
+// 超出上下文预算时，较早的消息由模型合并到会话的滚动摘要中并保存，摘要放在返回结果的最前面
+func (s *LLMService) conversationHistory(ctx context.Context, provider llm.Provider, conversationID uint64, reserved int) ([]llm.Message, error) {
+	var conversation model.Conversation
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return nil, err
+	}
+	var msgs []*model.Message
+	// 按(创建时间, ID)排序，与最后一条已摘要消息创建时间相同的消息按ID判断是否已摘要
+	err := database.DB.Where("conversation_id = ? AND (created_at > ? OR (created_at = ? AND id > ?))",
+		conversationID, conversation.SummaryUntil, conversation.SummaryUntil, conversation.SummaryUntilID).
+		Order("created_at asc, id asc").Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]llm.Message, len(msgs))
+	for i, msg := range msgs {
+		messages[i] = historyMessage(msg)
+	}
+
+	budget := contextBudget()
+	tokenModel := provider.Model()
+	history := withSummary(conversation.Summary, messages)
+	if budget <= 0 || llm.CountMessagesTokens(tokenModel, history)+reserved <= budget {
+		return history, nil
+	}
+
+	keep := llm.SplitHistory(tokenModel, messages, int(float64(budget-reserved)*historyKeepRatio))
+	if keep == 0 {
+		// 只剩最近一轮对话，无法再压缩
+		return history, nil
+	}
+
+	slog.Info("对话历史超出上下文预算，合并较早的消息",
+		"conversation_id", conversationID,
+		"budget", budget,
+		"tokens", llm.CountMessagesTokens(tokenModel, history)+reserved,
+		"summarized", keep,
+		"kept", len(messages)-keep)
+
+	summary, err := s.summarizeHistory(ctx, provider, conversation.Summary, messages[:keep], budget)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 摘要失败时本次只丢弃较早的消息，下次请求再尝试合并
+		slog.Warn("生成对话摘要失败", "conversation_id", conversationID, "error", err)
+		return withSummary(conversation.Summary, messages[keep:]), nil
+	}
+
+	err = database.DB.Model(&model.Conversation{}).Where("id = ?", conversationID).Updates(map[string]interface{}{
+		"summary":          summary,
+		"summary_until":    msgs[keep-1].CreatedAt,
+		"summary_until_id": msgs[keep-1].ID,
+	}).Error
+	if err != nil {
+		slog.Warn("保存对话摘要失败", "conversation_id", conversationID, "error", err)
+	}
+	return withSummary(summary, messages[keep:]), nil
+}
+
+// summarizeHistory 将已有摘要和较早的消息合并为新的摘要
+// 消息过多时分批合并，每批的大小不超过上下文预算
+func (s *LLMService) summarizeHistory(ctx context.Context, provider llm.Provider, summary string, messages []llm.Message, budget int) (string, error) {
+	tokenModel := provider.Model()
+	limit := budget - llm.CountTokens(tokenModel, constant.PromptSummarizeConversation) - summaryMaxTokens*2
+	if limit < summaryMaxTokens {
+		limit = summaryMaxTokens
+	}
+	for len(messages) > 0 {
+		var batch strings.Builder
+		tokens := 0
+		n := 0
+		for n < len(messages) {
+			line := describeHistoryMessage(messages[n])
+			lineTokens := llm.CountTokens(tokenModel, line)
+			if n > 0 && tokens+lineTokens > limit {
+				break
+			}
+			batch.WriteString(line)
+			tokens += lineTokens
+			n++
+		}
+		messages = messages[n:]
+
+		request := "之前的摘要：\n"
+		if summary == "" {
+			request += "无"
+		} else {
+			request += summary
+		}
+		request += "\n\n其后的对话：\n" + batch.String()
+
+		resp, err := provider.Chat(ctx, llm.Request{
+			Messages: []llm.Message{
+				llm.SystemMessage(constant.PromptSummarizeConversation),
+				llm.UserMessage(request),
+			},
+			MaxTokens:   summaryMaxTokens,
+			Temperature: 0.3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("生成对话摘要失败: %w", operation.ClassifyLLMError(err))
+		}
+		summary = strings.TrimSpace(cleanMarkdownCodeBlock(resp.Content))
+		if summary == "" {
+			return "", operation.InvalidOutput("生成对话摘要失败", fmt.Errorf("摘要为空"))
+		}
+	}
+	return summary, nil
+}
+
+// describeHistoryMessage 摘要请求中一条消息的文字形式
+func describeHistoryMessage(message llm.Message) string {
+	speaker := "用户"
+	if message.Role == llm.RoleAssistant {
+		speaker = "助手"
+	}
+	return fmt.Sprintf("%s：%s\n", speaker, message.Content)
+}
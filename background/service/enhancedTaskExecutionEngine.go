@@ -2,13 +2,21 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"diandian/background/automation/core"
+	"diandian/background/automation/core/wait"
 	"diandian/background/automation/hybrid"
+	"diandian/background/database"
 	"diandian/background/domain"
+	"diandian/background/model"
 )
 
 // TaskExecutionResult 任务执行结果
@@ -20,6 +28,8 @@ type TaskExecutionResult struct {
 	TotalSteps     int                    `json:"total_steps"`
 	Data           map[string]interface{} `json:"data"`
 	Error          string                 `json:"error,omitempty"`
+	Code           core.ErrorCode         `json:"code,omitempty"`
+	Cancelled      bool                   `json:"cancelled"`
 	Duration       time.Duration          `json:"duration"`
 	StartTime      time.Time              `json:"start_time"`
 }
@@ -30,24 +40,132 @@ type StepExecutionResult struct {
 	Message string                 `json:"message"`
 	Data    map[string]interface{} `json:"data"`
 	Error   string                 `json:"error,omitempty"`
+	// Code 失败时的错误码，Retryable表示该失败是否值得重试
+	Code      core.ErrorCode `json:"code,omitempty"`
+	Retryable bool           `json:"retryable,omitempty"`
+	Attempts  int            `json:"attempts,omitempty"`
+}
+
+// fail 记录步骤失败原因
+func (r *StepExecutionResult) fail(err error) *StepExecutionResult {
+	r.Success = false
+	r.Error = err.Error()
+	r.Code = core.CodeOf(err)
+	r.Retryable = core.IsRetryable(err)
+	return r
+}
+
+// failResult 记录自动化操作返回的失败结果
+func (r *StepExecutionResult) failResult(opResult *core.OperationResult) *StepExecutionResult {
+	r.Success = false
+	r.Error = opResult.Error
+	r.Code = opResult.Code
+	if r.Code == "" {
+		r.Code = core.ErrUnknown
+	}
+	r.Retryable = opResult.Retryable
+	return r
+}
+
+// copyEffect 将操作前后的屏幕差异指标复制到步骤结果，便于排查没有效果的操作
+func copyEffect(result *StepExecutionResult, opResult *core.OperationResult) {
+	data, ok := opResult.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range []string{"effect", "visible_effect", "effect_error"} {
+		if value, ok := data[key]; ok {
+			result.Data[key] = value
+		}
+	}
+}
+
+// maxStepAttempts 单个步骤的最大尝试次数，仅可重试的失败会再次尝试
+const maxStepAttempts = 3
+
+// idempotentSteps 重复执行不会产生额外效果的步骤类型，失败后可以直接重试
+// 输入、按键、文件、点击等步骤超时时可能已经部分执行，重试会重复输入或操作，
+// 这些步骤失败后交给重新规划，先截图确认当前状态再决定下一步
+var idempotentSteps = map[string]bool{
+	"screenshot": true,
+	"wait":       true,
+	"clipboard":  true,
+}
+
+// ReplanOptions 步骤执行后验证结果和出现偏差时重新规划的选项
+type ReplanOptions struct {
+	Verify      bool          // 界面操作步骤执行后截图，由视觉模型判断步骤的预期结果是否达成
+	MaxReplans  int           // 一次任务最多重新规划的次数，0为出现偏差时直接失败
+	SettleDelay time.Duration // 验证截图前等待动画和重绘完成的时间
+}
+
+// DefaultReplanOptions 默认选项：验证界面操作步骤，最多重新规划3次
+func DefaultReplanOptions() ReplanOptions {
+	return ReplanOptions{
+		Verify:      true,
+		MaxReplans:  3,
+		SettleDelay: 500 * time.Millisecond,
+	}
+}
+
+// verifiedSteps 执行后需要截图验证的步骤类型
+// 文件、剪贴板等操作的结果由操作本身确定，带条件的等待步骤已经确认了界面状态
+var verifiedSteps = map[string]bool{
+	"click":      true,
+	"type":       true,
+	"key_press":  true,
+	"launch_app": true,
+	"scroll":     true,
+}
+
+// outcomeFields 验证时提供给模型的步骤结果字段
+// 只取坐标、按键、文本长度等简短信息，精确定位的截图、屏幕差异指标等数据不放入提示词
+var outcomeFields = []string{"x", "y", "button", "label", "length", "strategy", "keys", "direction", "clicks"}
+
+// stepOutcome 将步骤结果整理为验证提示词中的简短描述
+func stepOutcome(stepResult *StepExecutionResult) string {
+	parts := []string{stepResult.Message}
+	for _, field := range outcomeFields {
+		if value, ok := stepResult.Data[field]; ok && value != nil && value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%v", field, value))
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// executedStep 已执行的步骤及其结果，重新规划时提供给模型
+type executedStep struct {
+	plan   domain.AutomationStepPlan
+	result *StepExecutionResult
 }
 
 // EnhancedTaskExecutionEngine 增强的任务执行引擎，支持两阶段架构
+// 每个界面操作步骤执行后截图验证预期结果，出现偏差时根据当前屏幕重新规划剩余步骤
 type EnhancedTaskExecutionEngine struct {
 	automationService *AutomationService
 	llmService        *LLMService
+	visionService     *EnhancedVisionService
 	engine            *hybrid.HybridEngine
+	replanOptions     ReplanOptions
 }
 
 // NewEnhancedTaskExecutionEngine 创建增强的任务执行引擎
 func NewEnhancedTaskExecutionEngine(automationService *AutomationService) *EnhancedTaskExecutionEngine {
+	llmService := &LLMService{}
 	return &EnhancedTaskExecutionEngine{
 		automationService: automationService,
-		llmService:        &LLMService{},
+		llmService:        llmService,
+		visionService:     NewEnhancedVisionService(llmService, automationService),
 		engine:            automationService.engine,
+		replanOptions:     DefaultReplanOptions(),
 	}
 }
 
+// SetReplanOptions 设置验证和重新规划的选项
+func (e *EnhancedTaskExecutionEngine) SetReplanOptions(options ReplanOptions) {
+	e.replanOptions = options
+}
+
 // ExecuteTaskDecomposition 执行任务分解结果
 func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Context, taskID uint, decomposition *domain.AutomationTaskDecomposition) *TaskExecutionResult {
 	startTime := time.Now()
@@ -57,11 +175,42 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 		Success:        false,
 		TotalSteps:     len(decomposition.Steps),
 		CompletedSteps: 0,
+		Data:           map[string]interface{}{},
 		StartTime:      startTime,
 	}
 
 	slog.Info("开始执行任务分解", "task_id", taskID, "step_count", len(decomposition.Steps))
 
+	// 执行前拒绝引擎无法完成的计划，避免执行到一半才失败
+	if err := e.automationService.CheckPlan(decomposition); err != nil {
+		slog.Warn("任务计划需要不支持的操作", "task_id", taskID, "error", err)
+		result.Message = "任务计划包含当前引擎不支持的操作"
+		result.Error = err.Error()
+		result.Code = core.CodeOf(err)
+		result.Duration = time.Since(startTime)
+		e.automationService.sendEvent(AutomationEvent{
+			Type:    "task_rejected",
+			TaskID:  taskID,
+			Message: result.Message,
+			Data: map[string]interface{}{
+				"error": result.Error,
+				"code":  result.Code,
+			},
+		})
+		return result
+	}
+
+	// 登记任务，使StopTask能够中止正在进行的LLM调用、等待和worker请求
+	ctx, done, err := e.automationService.BeginTask(ctx, taskID)
+	if err != nil {
+		result.Message = "已有任务正在执行"
+		result.Error = err.Error()
+		result.Code = core.CodeOf(err)
+		result.Duration = time.Since(startTime)
+		return result
+	}
+	defer done()
+
 	// 发送任务开始事件
 	e.automationService.sendEvent(AutomationEvent{
 		Type:    "task_started",
@@ -74,14 +223,14 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 		},
 	})
 
-	// 逐步执行
-	for i, stepPlan := range decomposition.Steps {
-		select {
-		case <-ctx.Done():
-			result.Message = "任务被取消"
-			result.Error = "context cancelled"
-			return result
-		default:
+	// 逐步执行，出现偏差时用重新规划的步骤替换尚未执行的步骤
+	steps := append([]domain.AutomationStepPlan(nil), decomposition.Steps...)
+	var progress []executedStep
+	replans := 0
+	for i := 0; i < len(steps); i++ {
+		stepPlan := steps[i]
+		if ctx.Err() != nil {
+			return e.cancelled(result, taskID, i)
 		}
 
 		slog.Info("执行步骤", "step", i+1, "type", stepPlan.Type, "description", stepPlan.Description)
@@ -98,9 +247,14 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 			},
 		})
 
-		// 执行单个步骤
-		stepResult := e.executeStepPlan(ctx, &stepPlan)
+		// 执行单个步骤，可重试的失败会退避后再次尝试
+		stepResult := e.executeStepWithRetry(ctx, taskID, i, &stepPlan)
+		if ctx.Err() != nil {
+			return e.cancelled(result, taskID, i)
+		}
 
+		var divergence string
+		var divergenceCode core.ErrorCode
 		if !stepResult.Success {
 			if stepPlan.Optional {
 				slog.Warn("可选步骤失败，继续执行", "step", i+1, "error", stepResult.Error)
@@ -112,14 +266,11 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 					Data: map[string]interface{}{
 						"step_index": i,
 						"error":      stepResult.Error,
+						"code":       stepResult.Code,
 					},
 				})
+				result.CompletedSteps++
 			} else {
-				result.Message = fmt.Sprintf("步骤 %d 执行失败: %s", i+1, stepResult.Error)
-				result.Error = stepResult.Error
-				result.CompletedSteps = i
-				result.Duration = time.Since(startTime)
-
 				// 发送步骤失败事件
 				e.automationService.sendEvent(AutomationEvent{
 					Type:    "step_failed",
@@ -128,10 +279,13 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 					Data: map[string]interface{}{
 						"step_index": i,
 						"error":      stepResult.Error,
+						"code":       stepResult.Code,
+						"attempts":   stepResult.Attempts,
+						"result":     stepResult.Data,
 					},
 				})
-
-				return result
+				divergence = fmt.Sprintf("步骤 %d 执行失败: %s", i+1, stepResult.Error)
+				divergenceCode = stepResult.Code
 			}
 		} else {
 			// 发送步骤完成事件
@@ -144,9 +298,48 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 					"result":     stepResult.Data,
 				},
 			})
+			result.CompletedSteps++
+
+			// 操作执行成功不代表达到了预期，截图确认后再继续
+			if verification := e.verifyStep(ctx, taskID, i, &stepPlan, stepResult); verification != nil && !verification.Achieved {
+				divergence = fmt.Sprintf("步骤 %d 的预期结果未达成: %s", i+1, verification.Reason)
+				divergenceCode = core.ErrNoEffect
+				stepResult.Message = "预期结果未达成: " + verification.Reason
+			}
+		}
+		progress = append(progress, executedStep{plan: stepPlan, result: stepResult})
+		if ctx.Err() != nil {
+			return e.cancelled(result, taskID, i)
+		}
+		if divergence == "" {
+			continue
 		}
 
-		result.CompletedSteps = i + 1
+		// 出现偏差时根据当前屏幕重新规划剩余步骤，超过次数或规划失败时任务失败
+		if replans >= e.replanOptions.MaxReplans {
+			if replans > 0 {
+				divergence = fmt.Sprintf("%s（已重新规划%d次）", divergence, replans)
+			}
+			return e.diverged(result, divergence, divergenceCode)
+		}
+		replans++
+		result.Data["replans"] = replans
+		replan, err := e.replan(ctx, taskID, i, replans, decomposition, progress, divergence)
+		if err != nil {
+			if ctx.Err() != nil {
+				return e.cancelled(result, taskID, i)
+			}
+			return e.diverged(result, fmt.Sprintf("%s，重新规划失败: %v", divergence, err), divergenceCode)
+		}
+		switch replan.Status {
+		case domain.ReplanDone:
+			steps = steps[:i+1]
+		case domain.ReplanImpossible:
+			return e.diverged(result, fmt.Sprintf("%s，任务无法完成: %s", divergence, replan.Reason), divergenceCode)
+		default:
+			steps = append(steps[:i+1], replan.Steps...)
+		}
+		result.TotalSteps = len(steps)
 	}
 
 	// 任务完成
@@ -160,7 +353,7 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 		TaskID:  taskID,
 		Message: "增强任务执行完成",
 		Data: map[string]interface{}{
-			"step_count":      len(decomposition.Steps),
+			"step_count":      result.TotalSteps,
 			"completed_steps": result.CompletedSteps,
 			"duration_ms":     result.Duration.Milliseconds(),
 		},
@@ -170,6 +363,203 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 	return result
 }
 
+// cancelled 填充任务被取消时的结果并通知前端
+func (e *EnhancedTaskExecutionEngine) cancelled(result *TaskExecutionResult, taskID uint, stepIndex int) *TaskExecutionResult {
+	result.Message = "任务被取消"
+	result.Error = "context cancelled"
+	result.Code = core.ErrCancelled
+	result.Cancelled = true
+	result.Duration = time.Since(result.StartTime)
+
+	slog.Info("任务被取消", "task_id", taskID, "step", stepIndex+1)
+	e.automationService.sendEvent(AutomationEvent{
+		Type:    "task_cancelled",
+		TaskID:  taskID,
+		Message: "任务被取消",
+		Data: map[string]interface{}{
+			"step_index": stepIndex,
+		},
+	})
+	return result
+}
+
+// diverged 出现偏差且无法通过重新规划恢复时填充失败结果
+func (e *EnhancedTaskExecutionEngine) diverged(result *TaskExecutionResult, message string, code core.ErrorCode) *TaskExecutionResult {
+	result.Message = message
+	result.Error = message
+	result.Code = code
+	if result.Code == "" {
+		result.Code = core.ErrUnknown
+	}
+	result.Duration = time.Since(result.StartTime)
+	slog.Warn("任务执行失败", "task_id", result.TaskID, "error", message)
+	return result
+}
+
+// verifyStep 截图判断步骤的预期结果是否达成，不需要验证或验证失败时返回nil
+// 验证本身出错（截屏失败、模型不可用）时不阻断任务，按步骤成功继续
+func (e *EnhancedTaskExecutionEngine) verifyStep(ctx context.Context, taskID uint, stepIndex int, stepPlan *domain.AutomationStepPlan, stepResult *StepExecutionResult) *domain.StepVerification {
+	if !e.replanOptions.Verify || !verifiedSteps[stepPlan.Type] {
+		return nil
+	}
+	if err := core.Sleep(ctx, e.replanOptions.SettleDelay); err != nil {
+		return nil
+	}
+
+	capture, err := e.capture(ctx)
+	if err != nil {
+		slog.Warn("验证步骤结果时截屏失败", "step", stepIndex+1, "error", err)
+		return nil
+	}
+	verification, err := e.llmService.VerifyStep(ctx, capture, stepPlan, stepOutcome(stepResult))
+	if err != nil {
+		slog.Warn("验证步骤结果失败，按成功继续", "step", stepIndex+1, "error", err)
+		return nil
+	}
+
+	slog.Info("步骤结果验证", "step", stepIndex+1, "achieved", verification.Achieved, "reason", verification.Reason)
+	e.automationService.sendEvent(AutomationEvent{
+		Type:    "step_verified",
+		TaskID:  taskID,
+		Message: fmt.Sprintf("步骤 %d 验证%s", stepIndex+1, map[bool]string{true: "通过", false: "未通过"}[verification.Achieved]),
+		Data: map[string]interface{}{
+			"step_index":   stepIndex,
+			"achieved":     verification.Achieved,
+			"observation":  verification.Observation,
+			"reason":       verification.Reason,
+			"confidence":   verification.Confidence,
+			"expected":     stepPlan.ExpectedOutcome,
+			"verification": verification,
+		},
+	})
+	return verification
+}
+
+// replan 根据当前屏幕重新规划剩余步骤，结果记录到任务历史并通知前端
+func (e *EnhancedTaskExecutionEngine) replan(ctx context.Context, taskID uint, stepIndex, count int, decomposition *domain.AutomationTaskDecomposition, progress []executedStep, divergence string) (*domain.AutomationReplan, error) {
+	slog.Info("执行出现偏差，重新规划剩余步骤", "task_id", taskID, "step", stepIndex+1, "replan", count, "divergence", divergence)
+
+	replan, err := e.requestReplan(ctx, decomposition, progress, divergence)
+	if err == nil && replan.Status == domain.ReplanContinue {
+		err = e.automationService.CheckPlan(&domain.AutomationTaskDecomposition{Steps: replan.Steps})
+	}
+	recordReplan(taskID, count, divergence, replan, err)
+	if err != nil {
+		slog.Warn("重新规划失败", "task_id", taskID, "error", err)
+		return nil, err
+	}
+
+	e.automationService.sendEvent(AutomationEvent{
+		Type:    "task_replanned",
+		TaskID:  taskID,
+		Message: fmt.Sprintf("第 %d 次重新规划: %s", count, replan.Reason),
+		Data: map[string]interface{}{
+			"step_index": stepIndex,
+			"replan":     count,
+			"divergence": divergence,
+			"status":     replan.Status,
+			"reason":     replan.Reason,
+			"steps":      replan.Steps,
+		},
+	})
+	return replan, nil
+}
+
+// requestReplan 截取当前屏幕并请求模型重新规划
+func (e *EnhancedTaskExecutionEngine) requestReplan(ctx context.Context, decomposition *domain.AutomationTaskDecomposition, progress []executedStep, divergence string) (*domain.AutomationReplan, error) {
+	capture, err := e.capture(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.llmService.ReplanAutomationTask(ctx, decomposition, describeProgress(progress), divergence, capture, e.automationService.CapabilitiesContext(ctx))
+}
+
+// capture 截取当前屏幕
+func (e *EnhancedTaskExecutionEngine) capture(ctx context.Context) (*core.ScreenCapture, error) {
+	_, opResult := e.engine.ScreenshotContext(ctx)
+	capture, ok := core.CaptureFromResult(opResult)
+	if !ok {
+		if err := opResult.Err(); err != nil {
+			return nil, err
+		}
+		return nil, core.NewError(core.ErrUnknown, "截屏失败: 未获取到图像数据", nil)
+	}
+	return capture, nil
+}
+
+// describeProgress 已执行步骤的文字说明
+func describeProgress(progress []executedStep) string {
+	if len(progress) == 0 {
+		return "无"
+	}
+	var b strings.Builder
+	for i, step := range progress {
+		status := "成功"
+		if !step.result.Success {
+			status = "失败: " + step.result.Error
+		} else if step.result.Message != "" {
+			status = "成功，" + step.result.Message
+		}
+		fmt.Fprintf(&b, "%d. [%s] %s —— %s\n", i+1, step.plan.Type, step.plan.Description, status)
+	}
+	return b.String()
+}
+
+// recordReplan 将重新规划记录到任务历史，写入失败不影响任务执行
+func recordReplan(taskID uint, count int, divergence string, replan *domain.AutomationReplan, err error) {
+	entry := &model.Step{
+		TaskID:     uint64(taskID),
+		Content:    fmt.Sprintf("第 %d 次重新规划: %s", count, divergence),
+		StepType:   model.StepTypeReplan,
+		Status:     model.StepStatusCompleted,
+		ActionType: domain.ReplanContinue,
+	}
+	if replan != nil {
+		entry.ActionType = replan.Status
+		entry.Result = replan.Reason
+		if data, marshalErr := json.Marshal(replan.Steps); marshalErr == nil {
+			entry.ActionData = string(data)
+		}
+	}
+	if err != nil {
+		entry.Status = model.StepStatusFailed
+		entry.ErrorMsg = err.Error()
+	}
+	if dbErr := database.DB.Create(entry).Error; dbErr != nil {
+		slog.Warn("保存重新规划记录失败", "task_id", taskID, "error", dbErr)
+	}
+}
+
+// executeStepWithRetry 执行步骤，幂等步骤的失败可重试时按错误码退避后重试
+func (e *EnhancedTaskExecutionEngine) executeStepWithRetry(ctx context.Context, taskID uint, stepIndex int, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+	var result *StepExecutionResult
+	for attempt := 1; ; attempt++ {
+		result = e.executeStepPlan(ctx, stepPlan)
+		result.Attempts = attempt
+		if result.Success || !result.Retryable || !idempotentSteps[stepPlan.Type] || attempt >= maxStepAttempts || ctx.Err() != nil {
+			return result
+		}
+
+		delay := core.RetryDelay(result.Code, attempt)
+		slog.Warn("步骤执行失败，准备重试", "step", stepIndex+1, "attempt", attempt, "code", result.Code, "error", result.Error, "delay", delay)
+		e.automationService.sendEvent(AutomationEvent{
+			Type:    "step_retry",
+			TaskID:  taskID,
+			Message: fmt.Sprintf("步骤 %d 执行失败，%s后重试", stepIndex+1, delay),
+			Data: map[string]interface{}{
+				"step_index": stepIndex,
+				"attempt":    attempt,
+				"code":       result.Code,
+				"error":      result.Error,
+				"result":     result.Data,
+			},
+		})
+		if err := core.Sleep(ctx, delay); err != nil {
+			return result
+		}
+	}
+}
+
 // executeStepPlan 执行单个步骤计划
 func (e *EnhancedTaskExecutionEngine) executeStepPlan(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{
@@ -178,25 +568,25 @@ func (e *EnhancedTaskExecutionEngine) executeStepPlan(ctx context.Context, stepP
 
 	// 如果需要屏幕分析，先进行截屏和分析
 	var screenAnalysis *domain.VisualAnalysisResponse
+	var capture *core.ScreenCapture
 	if stepPlan.RequiresScreenAnalysis {
-		screenshotResult := e.engine.Screenshot()
+		_, screenshotResult := e.engine.ScreenshotContext(ctx)
 		if !screenshotResult.Success {
+			result.failResult(screenshotResult)
 			result.Error = fmt.Sprintf("截屏失败: %s", screenshotResult.Error)
 			return result
 		}
 
 		// 从result中获取图像数据
-		var imageData []byte
-		if data, ok := screenshotResult.Data.(map[string]interface{}); ok {
-			if dataStr, exists := data["data"]; exists {
-				if dataBytes, ok := dataStr.([]byte); ok {
-					imageData = dataBytes
-				}
-			}
+		var ok bool
+		capture, ok = core.CaptureFromResult(screenshotResult)
+		if !ok {
+			return result.fail(core.NewError(core.ErrUnknown, "截屏失败: 未获取到图像数据", nil))
 		}
 
 		// 调用视觉分析
-		analysis, err := e.llmService.AnalyzeScreenshot(imageData, stepPlan.Context)
+		// 元素坐标为虚拟桌面坐标，多显示器或高DPI下截图像素与点击坐标不一致
+		analysis, err := e.llmService.AnalyzeCapture(ctx, capture, stepPlan.Context)
 		if err != nil {
 			slog.Warn("视觉分析失败，使用默认策略", "error", err)
 			// 不返回错误，继续执行，但没有屏幕分析结果
@@ -208,108 +598,160 @@ func (e *EnhancedTaskExecutionEngine) executeStepPlan(ctx context.Context, stepP
 	// 根据步骤类型生成具体操作并执行
 	switch stepPlan.Type {
 	case "click":
-		return e.executeClickStep(stepPlan, screenAnalysis)
+		return e.executeClickStep(ctx, stepPlan, capture, screenAnalysis)
 	case "type":
-		return e.executeTypeStep(stepPlan)
+		return e.executeTypeStep(ctx, stepPlan)
 	case "launch_app":
-		return e.executeLaunchAppStep(stepPlan)
+		return e.executeLaunchAppStep(ctx, stepPlan)
 	case "file":
-		return e.executeFileStep(stepPlan)
+		return e.executeFileStep(ctx, stepPlan)
 	case "screenshot":
-		return e.executeScreenshotStep(stepPlan)
+		return e.executeScreenshotStep(ctx, stepPlan)
 	case "clipboard":
-		return e.executeClipboardStep(stepPlan)
+		return e.executeClipboardStep(ctx, stepPlan)
 	case "wait":
-		return e.executeWaitStep(stepPlan)
+		return e.executeWaitStep(ctx, stepPlan)
 	case "key_press":
-		return e.executeKeyPressStep(stepPlan)
+		return e.executeKeyPressStep(ctx, stepPlan)
+	case "scroll":
+		return e.executeScrollStep(ctx, stepPlan)
 	default:
-		result.Error = fmt.Sprintf("不支持的步骤类型: %s", stepPlan.Type)
-		return result
+		return result.fail(core.NewError(core.ErrUnsupported, fmt.Sprintf("不支持的步骤类型: %s", stepPlan.Type), nil))
 	}
 }
 
 // executeClickStep 执行点击步骤
-func (e *EnhancedTaskExecutionEngine) executeClickStep(stepPlan *domain.AutomationStepPlan, screenAnalysis *domain.VisualAnalysisResponse) *StepExecutionResult {
+// capture为屏幕分析使用的截图，编号定位时在同一张截图上标注候选元素
+func (e *EnhancedTaskExecutionEngine) executeClickStep(ctx context.Context, stepPlan *domain.AutomationStepPlan, capture *core.ScreenCapture, screenAnalysis *domain.VisualAnalysisResponse) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
 	// 生成具体的点击操作
-	clickOp, err := e.llmService.GenerateClickOperation(stepPlan.Context, screenAnalysis)
+	clickOp, err := e.visionService.GenerateClick(ctx, stepPlan, capture, screenAnalysis)
 	if err != nil {
-		result.Error = fmt.Sprintf("生成点击操作失败: %v", err)
-		return result
+		return result.fail(fmt.Errorf("生成点击操作失败: %w", err))
 	}
 
 	// 执行点击操作
-	opResult := e.engine.Click(clickOp.X, clickOp.Y)
+	button := core.LeftButton
+	if clickOp.Button != "" {
+		button = core.MouseButton(clickOp.Button)
+	}
+	focus := &core.Point{X: clickOp.X, Y: clickOp.Y}
+	opResult := e.automationService.observeEffect(ctx, "click", nil, focus, func() *core.OperationResult {
+		return e.engine.ClickContext(ctx, clickOp.X, clickOp.Y, button)
+	})
+	result.Data = map[string]interface{}{
+		"x":         clickOp.X,
+		"y":         clickOp.Y,
+		"button":    clickOp.Button,
+		"grounding": clickOp.Grounding,
+	}
+	if clickOp.Mark > 0 {
+		result.Data["mark"] = clickOp.Mark
+		result.Data["label"] = clickOp.Label
+	}
+	if len(clickOp.Refinement) > 0 {
+		result.Data["refinement"] = clickOp.Refinement
+	}
+	copyEffect(result, opResult)
 	if !opResult.Success {
-		result.Error = opResult.Error
-		return result
+		return result.failResult(opResult)
 	}
 
 	result.Success = true
-	result.Data = map[string]interface{}{
-		"x":      clickOp.X,
-		"y":      clickOp.Y,
-		"button": clickOp.Button,
-	}
 	return result
 }
 
 // executeTypeStep 执行输入步骤
-func (e *EnhancedTaskExecutionEngine) executeTypeStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeTypeStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
 	// 生成具体的输入操作
-	typeOp, err := e.llmService.GenerateTypeOperation(stepPlan.Context)
+	typeOp, err := e.llmService.GenerateTypeOperation(ctx, stepPlan.Context)
 	if err != nil {
-		result.Error = fmt.Sprintf("生成输入操作失败: %v", err)
-		return result
+		return result.fail(fmt.Errorf("生成输入操作失败: %w", err))
 	}
 
 	// 执行输入操作
-	opResult := e.engine.Type(typeOp.Text)
+	opResult := e.automationService.observeEffect(ctx, "type", nil, nil, func() *core.OperationResult {
+		return e.engine.TypeContext(ctx, typeOp.Text)
+	})
+	result.Data = map[string]interface{}{
+		"text":   typeOp.Text,
+		"length": len(typeOp.Text),
+	}
+	copyEffect(result, opResult)
 	if !opResult.Success {
-		result.Error = opResult.Error
-		return result
+		return result.failResult(opResult)
 	}
 
 	result.Success = true
-	result.Data = map[string]interface{}{
-		"text":   typeOp.Text,
-		"length": len(typeOp.Text),
+	if data, ok := opResult.Data.(map[string]interface{}); ok {
+		result.Data["strategy"] = data["strategy"]
+		if restored, ok := data["clipboard_restored"]; ok {
+			result.Data["clipboard_restored"] = restored
+		}
 	}
 	return result
 }
 
 // executeLaunchAppStep 执行启动应用步骤
-func (e *EnhancedTaskExecutionEngine) executeLaunchAppStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeLaunchAppStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
 	// 从上下文中提取应用名称
 	// 这里可以使用简单的字符串匹配或者调用LLM来解析
 	appName := e.extractAppNameFromContext(stepPlan.Context)
 	if appName == "" {
-		result.Error = "无法从上下文中提取应用名称"
-		return result
+		return result.fail(core.NewError(core.ErrInvalidArgument, "无法从上下文中提取应用名称", nil))
+	}
+
+	opResult := e.engine.LaunchContext(ctx, appName)
+	if !opResult.Success {
+		return result.failResult(opResult)
 	}
 
-	// 启动应用功能暂不支持
-	result.Error = "启动应用功能暂不支持"
+	result.Success = true
+	result.Data = map[string]interface{}{
+		"app": appName,
+	}
 	return result
 }
 
 // executeFileStep 执行文件操作步骤
-func (e *EnhancedTaskExecutionEngine) executeFileStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeFileStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
-	// 文件操作功能暂不支持
-	result.Error = "文件操作功能暂不支持"
+	// 生成具体的文件操作
+	fileOp, err := e.llmService.GenerateFileOperation(ctx, stepPlan.Context)
+	if err != nil {
+		return result.fail(fmt.Errorf("生成文件操作失败: %w", err))
+	}
+
+	opResult := e.automationService.executeFileStep(ctx, AutomationStep{
+		Type: "file",
+		Parameters: map[string]interface{}{
+			"operation":   fileOp.Operation,
+			"source_path": fileOp.SourcePath,
+			"target_path": fileOp.TargetPath,
+			"content":     fileOp.Content,
+		},
+	})
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+
+	result.Success = true
+	result.Data = map[string]interface{}{
+		"operation":   fileOp.Operation,
+		"source_path": fileOp.SourcePath,
+		"target_path": fileOp.TargetPath,
+	}
 	return result
 }
 
 // executeScreenshotStep 执行截屏步骤
-func (e *EnhancedTaskExecutionEngine) executeScreenshotStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeScreenshotStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
 	// 从上下文中提取路径，或使用默认路径
@@ -319,43 +761,96 @@ func (e *EnhancedTaskExecutionEngine) executeScreenshotStep(stepPlan *domain.Aut
 	}
 
 	// 执行截屏操作
-	opResult := e.engine.Screenshot()
+	_, opResult := e.engine.ScreenshotContext(ctx)
 	if !opResult.Success {
-		result.Error = opResult.Error
-		return result
+		return result.failResult(opResult)
+	}
+
+	capture, ok := core.CaptureFromResult(opResult)
+	if !ok {
+		return result.fail(core.NewError(core.ErrUnknown, "截屏失败: 未获取到图像数据", nil))
 	}
 
-	// 截屏功能已完成，但保存到文件功能暂不支持
+	if err := os.WriteFile(path, capture.ImageData, 0644); err != nil {
+		return result.fail(fmt.Errorf("保存截屏失败: %w", err))
+	}
 
 	result.Success = true
 	result.Data = map[string]interface{}{
-		"path":    path,
-		"message": "截屏完成",
+		"path":          path,
+		"message":       "截屏完成",
+		"format":        capture.Format,
+		"width":         capture.Width,
+		"height":        capture.Height,
+		"display_index": capture.DisplayIndex,
 	}
 	return result
 }
 
 // executeClipboardStep 执行剪贴板步骤
-func (e *EnhancedTaskExecutionEngine) executeClipboardStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeClipboardStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
-	// 剪贴板功能暂不支持
-	result.Error = "剪贴板功能暂不支持"
+	if e.isGetClipboardOperation(stepPlan.Context) {
+		text, opResult := e.engine.GetClipboardContext(ctx)
+		if !opResult.Success {
+			return result.failResult(opResult)
+		}
+
+		result.Success = true
+		result.Data = map[string]interface{}{
+			"operation": "get",
+			"text":      text,
+		}
+		return result
+	}
+
+	text := e.extractTextFromContext(stepPlan.Context)
+	opResult := e.engine.SetClipboardContext(ctx, text)
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+
+	result.Success = true
+	result.Data = map[string]interface{}{
+		"operation": "set",
+		"length":    len(text),
+	}
 	return result
 }
 
 // executeWaitStep 执行等待步骤
-func (e *EnhancedTaskExecutionEngine) executeWaitStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeWaitStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
+	// 计划给出了等待条件时等待条件满足，不再按固定时长等待
+	if stepPlan.WaitFor != "" {
+		condition, err := wait.Parse(stepPlan.WaitFor)
+		if err != nil {
+			return result.fail(err)
+		}
+		opResult := e.engine.WaitUntilContext(ctx, condition, wait.DefaultOptions())
+		if !opResult.Success {
+			return result.failResult(opResult)
+		}
+		result.Success = true
+		result.Data = map[string]interface{}{
+			"condition":   condition.String(),
+			"duration_ms": opResult.Duration.Milliseconds(),
+		}
+		return result
+	}
+
 	// 从上下文中提取等待时间
 	duration := e.extractDurationFromContext(stepPlan.Context)
 	if duration <= 0 {
 		duration = 1000 // 默认1秒
 	}
 
-	// 执行等待操作
-	time.Sleep(time.Duration(duration) * time.Millisecond)
+	// 执行等待操作，任务取消时立即返回
+	if opResult := e.engine.WaitContext(ctx, duration); !opResult.Success {
+		return result.failResult(opResult)
+	}
 
 	result.Success = true
 	result.Data = map[string]interface{}{
@@ -365,28 +860,25 @@ func (e *EnhancedTaskExecutionEngine) executeWaitStep(stepPlan *domain.Automatio
 }
 
 // executeKeyPressStep 执行按键步骤
-func (e *EnhancedTaskExecutionEngine) executeKeyPressStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeKeyPressStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
 	// 从上下文中提取按键信息
 	key, modifiers := e.extractKeyFromContext(stepPlan.Context)
 	if key == "" {
-		result.Error = "无法从上下文中提取按键信息"
-		return result
+		return result.fail(core.NewError(core.ErrInvalidArgument, "无法从上下文中提取按键信息", nil))
 	}
 
-	// 执行按键操作
+	// 执行按键操作，带修饰键时按组合键处理
 	var opResult *core.OperationResult
-	// 目前只支持单个按键，组合键功能暂不支持
 	if len(modifiers) > 0 {
-		result.Error = "组合键功能暂不支持"
-		return result
+		opResult = e.engine.HotkeyContext(ctx, keyModifiers(modifiers), key)
+	} else {
+		opResult = e.engine.KeyPressContext(ctx, key)
 	}
-	opResult = e.engine.KeyPress(key)
 
 	if !opResult.Success {
-		result.Error = opResult.Error
-		return result
+		return result.failResult(opResult)
 	}
 
 	result.Success = true
@@ -397,6 +889,32 @@ func (e *EnhancedTaskExecutionEngine) executeKeyPressStep(stepPlan *domain.Autom
 	return result
 }
 
+// executeScrollStep 执行滚动步骤，在当前鼠标位置滚动
+func (e *EnhancedTaskExecutionEngine) executeScrollStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+	result := &StepExecutionResult{Success: false}
+
+	direction, clicks := e.extractScrollFromContext(stepPlan.Context)
+
+	position, opResult := e.engine.GetPositionContext(ctx)
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+
+	opResult = e.engine.ScrollContext(ctx, position.X, position.Y, direction, clicks)
+	if !opResult.Success {
+		return result.failResult(opResult)
+	}
+
+	result.Success = true
+	result.Data = map[string]interface{}{
+		"direction": direction,
+		"clicks":    clicks,
+		"x":         position.X,
+		"y":         position.Y,
+	}
+	return result
+}
+
 // 辅助方法：从上下文中提取信息
 func (e *EnhancedTaskExecutionEngine) extractAppNameFromContext(context string) string {
 	// 简单的字符串匹配，实际项目中可以使用更复杂的解析逻辑
@@ -410,8 +928,14 @@ func (e *EnhancedTaskExecutionEngine) extractPathFromContext(context string) str
 }
 
 func (e *EnhancedTaskExecutionEngine) isGetClipboardOperation(context string) bool {
-	// 判断是否是获取剪贴板操作
-	return false // 默认为设置操作
+	// 判断是否是获取剪贴板操作，默认为设置操作
+	context = strings.ToLower(context)
+	for _, keyword := range []string{"获取", "读取", "查看", "get", "read"} {
+		if strings.Contains(context, keyword) {
+			return true
+		}
+	}
+	return false
 }
 
 func (e *EnhancedTaskExecutionEngine) extractTextFromContext(context string) string {
@@ -424,7 +948,98 @@ func (e *EnhancedTaskExecutionEngine) extractDurationFromContext(context string)
 	return 1000 // 默认1秒
 }
 
+// comboKeyPattern 匹配ctrl+s、Ctrl + Shift + N之类的组合键
+var comboKeyPattern = regexp.MustCompile(`(?i)((?:ctrl|control|alt|shift|win|cmd|command)\s*\+\s*)+([a-z0-9]+)`)
+
+// namedKeys 上下文中的按键名称（按匹配优先级排列，长名称在前）
+var namedKeys = []struct {
+	keyword string
+	key     string
+}{
+	{"backspace", "backspace"}, {"退格", "backspace"},
+	{"pagedown", "pagedown"}, {"pageup", "pageup"},
+	{"escape", "esc"}, {"esc", "esc"},
+	{"delete", "delete"}, {"删除", "delete"},
+	{"enter", "enter"}, {"回车", "enter"},
+	{"space", "space"}, {"空格", "space"},
+	{"home", "home"}, {"end", "end"},
+	{"tab", "tab"},
+	{"up", "up"}, {"down", "down"}, {"left", "left"}, {"right", "right"},
+}
+
 func (e *EnhancedTaskExecutionEngine) extractKeyFromContext(context string) (string, []string) {
-	// 从上下文中提取按键和修饰键
+	// 从上下文中提取按键和修饰键，优先识别组合键
+	if match := comboKeyPattern.FindString(context); match != "" {
+		parts := strings.Split(strings.ToLower(match), "+")
+		modifiers := make([]string, 0, len(parts)-1)
+		for _, part := range parts[:len(parts)-1] {
+			modifiers = append(modifiers, strings.TrimSpace(part))
+		}
+		return strings.TrimSpace(parts[len(parts)-1]), modifiers
+	}
+
+	if match := functionKeyPattern.FindString(context); match != "" {
+		return strings.ToLower(match), []string{}
+	}
+
+	lower := strings.ToLower(context)
+	for _, named := range namedKeys {
+		if containsWord(lower, named.keyword) {
+			return named.key, []string{}
+		}
+	}
 	return "enter", []string{} // 默认回车键
 }
+
+// containsWord 判断text是否包含keyword；英文关键字需要完整单词匹配，避免"description"命中"esc"
+func containsWord(text, keyword string) bool {
+	for offset := 0; ; {
+		index := strings.Index(text[offset:], keyword)
+		if index < 0 {
+			return false
+		}
+		start := offset + index
+		end := start + len(keyword)
+		if !isASCIILetter(text, start-1) && !isASCIILetter(text, end) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isASCIILetter(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	c := text[i]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// functionKeyPattern 匹配F1-F12
+var functionKeyPattern = regexp.MustCompile(`(?i)\bf(1[0-2]|[1-9])\b`)
+
+// scrollClicksPattern 匹配滚动格数，如“滚动5次”“scroll 3”
+var scrollClicksPattern = regexp.MustCompile(`(\d+)\s*(?:次|格|下|clicks?|times?)?`)
+
+func (e *EnhancedTaskExecutionEngine) extractScrollFromContext(context string) (string, int) {
+	// 从上下文中提取滚动方向和格数，默认向下滚动3格
+	lower := strings.ToLower(context)
+
+	direction := "down"
+	switch {
+	case strings.Contains(lower, "向上") || strings.Contains(lower, "上滚") || containsWord(lower, "up"):
+		direction = "up"
+	case strings.Contains(lower, "向左") || containsWord(lower, "left"):
+		direction = "left"
+	case strings.Contains(lower, "向右") || containsWord(lower, "right"):
+		direction = "right"
+	}
+
+	clicks := 3
+	if matches := scrollClicksPattern.FindStringSubmatch(lower); len(matches) > 1 {
+		if n, err := strconv.Atoi(matches[1]); err == nil && n > 0 {
+			clicks = n
+		}
+	}
+	return direction, clicks
+}
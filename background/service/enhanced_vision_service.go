@@ -1,34 +1,110 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
+	"time"
 
 	"diandian/background/automation/core"
+	"diandian/background/automation/core/imageprep"
 	"diandian/background/domain"
+	"diandian/background/service/operation"
 )
 
 // EnhancedVisionService 增强的视觉分析服务
 type EnhancedVisionService struct {
 	llmService         *LLMService
 	automationService  *AutomationService
-	activeDisplayIndex int // 当前活动的显示器索引，-1表示未固定
+	activeDisplayIndex int                 // 当前活动的显示器索引，-1表示未固定
+	refine             RefineOptions       // 放大精确定位的选项
+	multiDisplay       MultiDisplayOptions // 多显示器并发分析的选项
 }
 
+// RefineOptions 放大精确定位的选项
+type RefineOptions struct {
+	Stages   int // 放大定位的轮数，每一轮的截取范围减半
+	CropSize int // 第一轮截取区域的边长（虚拟桌面坐标），目标较大时自动扩大
+	ZoomSize int // 局部截图放大后的最长边像素
+}
+
+// DefaultRefineOptions 默认选项：在目标附近截取320x320的区域放大到1024像素定位一轮
+func DefaultRefineOptions() RefineOptions {
+	return RefineOptions{
+		Stages:   1,
+		CropSize: 320,
+		ZoomSize: 1024,
+	}
+}
+
+// MultiDisplayOptions 多显示器并发分析的选项
+type MultiDisplayOptions struct {
+	Workers        int           // 同时分析的显示器数量上限，<=0时逐个分析
+	DisplayTimeout time.Duration // 单个显示器的分析超时，<=0表示不单独限制
+}
+
+// DefaultMultiDisplayOptions 默认选项：最多同时分析3个显示器，每个显示器限时90秒
+func DefaultMultiDisplayOptions() MultiDisplayOptions {
+	return MultiDisplayOptions{
+		Workers:        3,
+		DisplayTimeout: 90 * time.Second,
+	}
+}
+
+// minRefineCrop 截取区域的最小边长，避免多轮缩小后丢失目标周围的参照物
+const minRefineCrop = 64
+
 // NewEnhancedVisionService 创建增强视觉分析服务
 func NewEnhancedVisionService(llmService *LLMService, automationService *AutomationService) *EnhancedVisionService {
 	return &EnhancedVisionService{
 		llmService:         llmService,
 		automationService:  automationService,
 		activeDisplayIndex: -1, // 初始未固定
+		refine:             DefaultRefineOptions(),
+		multiDisplay:       DefaultMultiDisplayOptions(),
 	}
 }
 
+// SetRefineOptions 设置放大精确定位的选项
+func (evs *EnhancedVisionService) SetRefineOptions(options RefineOptions) {
+	evs.refine = options
+}
+
+// RefineOptions 当前放大精确定位的选项
+func (evs *EnhancedVisionService) RefineOptions() RefineOptions {
+	return evs.refine
+}
+
+// SetMultiDisplayOptions 设置多显示器并发分析的选项
+func (evs *EnhancedVisionService) SetMultiDisplayOptions(options MultiDisplayOptions) {
+	evs.multiDisplay = options
+}
+
+// MultiDisplayOptions 当前多显示器并发分析的选项
+func (evs *EnhancedVisionService) MultiDisplayOptions() MultiDisplayOptions {
+	return evs.multiDisplay
+}
+
 // MultiDisplayAnalysis 多显示器分析结果
+// Displays只包含分析成功的显示器，失败的显示器记录在Failures中
 type MultiDisplayAnalysis struct {
 	Displays              []DisplayAnalysisResult       `json:"displays"`
-	RecommendedDisplay    int                           `json:"recommended_display"`
+	RecommendedDisplay    int                           `json:"recommended_display"` // 推荐显示器在Displays中的下标
+	RecommendationReason  string                        `json:"recommendation_reason"`
+	ElementsFound         []domain.VisualElement        `json:"elements_found"` // 所有显示器的元素，虚拟桌面坐标
 	GlobalRecommendations []domain.ActionRecommendation `json:"global_recommendations"`
+	Failures              []DisplayFailure              `json:"failures,omitempty"`
+	Cache                 *operation.VisionCacheStats   `json:"cache,omitempty"` // 视觉分析缓存的累计命中统计
+}
+
+// DisplayFailure 分析失败的显示器
+type DisplayFailure struct {
+	DisplayIndex int       `json:"display_index"`
+	Bounds       core.Rect `json:"bounds"`
+	Error        string    `json:"error"`
 }
 
 // DisplayAnalysisResult 单个显示器分析结果
@@ -36,18 +112,21 @@ type DisplayAnalysisResult struct {
 	DisplayIndex      int                    `json:"display_index"`
 	Width             int                    `json:"width"`
 	Height            int                    `json:"height"`
+	Bounds            core.Rect              `json:"bounds"`       // 显示器在虚拟桌面中的范围
+	ScaleFactor       float64                `json:"scale_factor"` // 截图像素与桌面坐标的比例
 	ElementsFound     []domain.VisualElement `json:"elements_found"`
 	ScreenInfo        domain.ScreenInfo      `json:"screen_info"`
 	Confidence        float64                `json:"confidence"`
 	HasTargetElements bool                   `json:"has_target_elements"`
+	DurationMS        int64                  `json:"duration_ms"` // 该显示器的分析耗时
 }
 
 // AnalyzeAllDisplays 分析所有显示器
-func (evs *EnhancedVisionService) AnalyzeAllDisplays(context string) (*MultiDisplayAnalysis, error) {
-	slog.Info("开始多显示器视觉分析", "context", context)
+func (evs *EnhancedVisionService) AnalyzeAllDisplays(ctx context.Context, analysisRequest string) (*MultiDisplayAnalysis, error) {
+	slog.Info("开始多显示器视觉分析", "context", analysisRequest)
 
 	// 获取所有显示器截图
-	captures, err := evs.captureAllDisplays()
+	captures, err := evs.captureAllDisplays(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("获取显示器截图失败: %v", err)
 	}
@@ -56,43 +135,36 @@ func (evs *EnhancedVisionService) AnalyzeAllDisplays(context string) (*MultiDisp
 		return nil, fmt.Errorf("没有可用的显示器")
 	}
 
-	// 如果只有一个显示器，直接分析
-	if len(captures) == 1 {
-		result, err := evs.analyzeSingleDisplay(captures[0], context)
-		if err != nil {
-			return nil, err
-		}
-
-		return &MultiDisplayAnalysis{
-			Displays:              []DisplayAnalysisResult{*result},
-			RecommendedDisplay:    0,
-			GlobalRecommendations: result.toActionRecommendations(),
-		}, nil
+	analysis, err := evs.analyzeMultipleDisplays(ctx, captures, analysisRequest)
+	if err != nil {
+		return nil, err
 	}
 
-	// 多显示器分析
-	return evs.analyzeMultipleDisplays(captures, context)
+	if cache := operation.SharedVisionCache(); cache != nil {
+		stats := cache.Stats()
+		analysis.Cache = &stats
+	}
+	return analysis, nil
 }
 
 // AnalyzeActiveDisplay 分析当前活动显示器
-func (evs *EnhancedVisionService) AnalyzeActiveDisplay(context string) (*domain.VisualAnalysisResponse, error) {
+func (evs *EnhancedVisionService) AnalyzeActiveDisplay(ctx context.Context, analysisRequest string) (*domain.VisualAnalysisResponse, error) {
 	if evs.activeDisplayIndex >= 0 {
 		// 使用固定的显示器
-		return evs.AnalyzeSpecificDisplay(evs.activeDisplayIndex, context)
+		return evs.AnalyzeSpecificDisplay(ctx, evs.activeDisplayIndex, analysisRequest)
 	}
 
 	// 分析所有显示器并选择最佳的
-	multiResult, err := evs.AnalyzeAllDisplays(context)
+	multiResult, err := evs.AnalyzeAllDisplays(ctx, analysisRequest)
 	if err != nil {
 		return nil, err
 	}
 
-	// 设置活动显示器
-	evs.activeDisplayIndex = multiResult.RecommendedDisplay
-
-	// 返回推荐显示器的分析结果
+	// 返回推荐显示器的分析结果，并将其设为活动显示器
 	if multiResult.RecommendedDisplay < len(multiResult.Displays) {
 		displayResult := multiResult.Displays[multiResult.RecommendedDisplay]
+		evs.activeDisplayIndex = displayResult.DisplayIndex
+		slog.Info("选择活动显示器", "display", displayResult.DisplayIndex, "reason", multiResult.RecommendationReason)
 		return &domain.VisualAnalysisResponse{
 			ElementsFound:   displayResult.ElementsFound,
 			ScreenInfo:      displayResult.ScreenInfo,
@@ -104,15 +176,15 @@ func (evs *EnhancedVisionService) AnalyzeActiveDisplay(context string) (*domain.
 }
 
 // AnalyzeSpecificDisplay 分析指定显示器
-func (evs *EnhancedVisionService) AnalyzeSpecificDisplay(displayIndex int, context string) (*domain.VisualAnalysisResponse, error) {
-	slog.Info("分析指定显示器", "display", displayIndex, "context", context)
+func (evs *EnhancedVisionService) AnalyzeSpecificDisplay(ctx context.Context, displayIndex int, analysisRequest string) (*domain.VisualAnalysisResponse, error) {
+	slog.Info("分析指定显示器", "display", displayIndex, "context", analysisRequest)
 
-	capture, err := evs.captureSpecificDisplay(displayIndex)
+	capture, err := evs.captureSpecificDisplay(ctx, displayIndex)
 	if err != nil {
 		return nil, fmt.Errorf("获取显示器 %d 截图失败: %v", displayIndex, err)
 	}
 
-	result, err := evs.analyzeSingleDisplay(*capture, context)
+	result, err := evs.analyzeSingleDisplay(ctx, *capture, analysisRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -142,7 +214,7 @@ func (evs *EnhancedVisionService) GetActiveDisplayIndex() int {
 }
 
 // captureAllDisplays 获取所有显示器截图
-func (evs *EnhancedVisionService) captureAllDisplays() ([]core.DisplayCapture, error) {
+func (evs *EnhancedVisionService) captureAllDisplays(ctx context.Context) ([]core.DisplayCapture, error) {
 	if evs.automationService == nil {
 		return nil, fmt.Errorf("automation service not initialized")
 	}
@@ -154,47 +226,53 @@ func (evs *EnhancedVisionService) captureAllDisplays() ([]core.DisplayCapture, e
 	}
 
 	// 使用标准的Screenshot接口
-	result := engine.Screenshot()
+	_, result := engine.ScreenshotContext(ctx)
 	if !result.Success {
 		return nil, fmt.Errorf("failed to capture screen: %s", result.Error)
 	}
 
-	// 获取屏幕尺寸（使用默认值）
-	width, height := 1920, 1080 // 默认值
-
 	// 从result中获取图像数据
-	var imageData []byte
-	if data, ok := result.Data.(map[string]interface{}); ok {
-		if dataStr, exists := data["data"]; exists {
-			if dataBytes, ok := dataStr.([]byte); ok {
-				imageData = dataBytes
+	screenCapture, ok := core.CaptureFromResult(result)
+	if !ok {
+		return nil, fmt.Errorf("failed to capture screen: no image data")
+	}
+
+	// 多显示器时按显示器范围拆分虚拟桌面截图，各显示器可以分别并发分析
+	if screenCapture.DisplayIndex == core.DisplayIndexVirtual {
+		if displays, displaysResult := engine.GetDisplaysContext(ctx); displaysResult.Success && len(displays) > 1 {
+			var captures []core.DisplayCapture
+			for _, display := range displays {
+				cropped, err := screenCapture.Crop(display.Bounds)
+				if err != nil {
+					slog.Warn("拆分显示器截图失败", "display", display.Index, "error", err)
+					continue
+				}
+				cropped.DisplayIndex = display.Index
+				captures = append(captures, cropped.ToDisplayCapture(display.Primary))
+			}
+			if len(captures) > 0 {
+				slog.Info("成功获取显示器截图", "count", len(captures))
+				return captures, nil
 			}
 		}
 	}
 
-	// 创建单个显示器的capture（作为多显示器的fallback）
-	capture := core.DisplayCapture{
-		Index:     0,
-		ImageData: imageData,
-		Width:     width,
-		Height:    height,
-		IsActive:  true,
+	// 单显示器或无法获取显示器信息时，整个截图作为一个显示器
+	capture := screenCapture.ToDisplayCapture(true)
+	if capture.Index == core.DisplayIndexVirtual {
+		// 整个虚拟桌面的截图视为单个显示器
+		capture.Index = 0
 	}
+	width, height := capture.Width, capture.Height
 
 	captures := []core.DisplayCapture{capture}
 	slog.Info("成功获取显示器截图", "count", len(captures), "size", fmt.Sprintf("%dx%d", width, height))
 	return captures, nil
 }
 
-// captureSpecificDisplay 获取指定显示器截图
-func (evs *EnhancedVisionService) captureSpecificDisplay(displayIndex int) (*core.DisplayCapture, error) {
-	// 对于单显示器系统，忽略displayIndex
-	if displayIndex != 0 {
-		slog.Warn("请求的显示器索引超出范围，使用主显示器", "requested", displayIndex)
-	}
-
-	// 获取所有显示器（实际上是单显示器）
-	captures, err := evs.captureAllDisplays()
+// captureSpecificDisplay 获取指定显示器截图，显示器不存在时使用第一个显示器
+func (evs *EnhancedVisionService) captureSpecificDisplay(ctx context.Context, displayIndex int) (*core.DisplayCapture, error) {
+	captures, err := evs.captureAllDisplays(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -203,18 +281,24 @@ func (evs *EnhancedVisionService) captureSpecificDisplay(displayIndex int) (*cor
 		return nil, fmt.Errorf("no displays available")
 	}
 
-	// 返回第一个（也是唯一的）显示器
+	for i := range captures {
+		if captures[i].Index == displayIndex {
+			return &captures[i], nil
+		}
+	}
+	slog.Warn("请求的显示器索引超出范围，使用第一个显示器", "requested", displayIndex, "display", captures[0].Index)
 	return &captures[0], nil
 }
 
 // analyzeSingleDisplay 分析单个显示器
-func (evs *EnhancedVisionService) analyzeSingleDisplay(capture core.DisplayCapture, context string) (*DisplayAnalysisResult, error) {
+func (evs *EnhancedVisionService) analyzeSingleDisplay(ctx context.Context, capture core.DisplayCapture, analysisRequest string) (*DisplayAnalysisResult, error) {
 	// 构建分析上下文
 	analysisContext := fmt.Sprintf("显示器 %d (%dx%d): %s",
-		capture.Index, capture.Width, capture.Height, context)
+		capture.Index, capture.Width, capture.Height, analysisRequest)
 
-	// 调用LLM进行视觉分析（直接使用图像数据）
-	response, err := evs.llmService.AnalyzeScreenshot(capture.ImageData, analysisContext)
+	// 调用LLM进行视觉分析，返回的坐标已转换为虚拟桌面坐标，可直接用于点击
+	screenCapture := capture.Capture()
+	response, err := evs.llmService.AnalyzeCapture(ctx, screenCapture, analysisContext)
 	if err != nil {
 		return nil, fmt.Errorf("LLM视觉分析失败: %v", err)
 	}
@@ -229,6 +313,8 @@ func (evs *EnhancedVisionService) analyzeSingleDisplay(capture core.DisplayCaptu
 		DisplayIndex:      capture.Index,
 		Width:             capture.Width,
 		Height:            capture.Height,
+		Bounds:            screenCapture.Bounds,
+		ScaleFactor:       screenCapture.ScaleFactor,
 		ElementsFound:     response.ElementsFound,
 		ScreenInfo:        response.ScreenInfo,
 		Confidence:        confidence,
@@ -238,51 +324,185 @@ func (evs *EnhancedVisionService) analyzeSingleDisplay(capture core.DisplayCaptu
 	return result, nil
 }
 
-// analyzeMultipleDisplays 分析多个显示器
-func (evs *EnhancedVisionService) analyzeMultipleDisplays(captures []core.DisplayCapture, context string) (*MultiDisplayAnalysis, error) {
-	var displayResults []DisplayAnalysisResult
-	var bestDisplayIndex int
-	var bestConfidence float64
+// RefineElement 在粗略定位的目标附近截取局部截图，放大后让视觉模型再次定位，返回目标中心的虚拟桌面坐标
+// box为粗略定位的目标范围，宽高为0时表示只有一个点。每一轮的截图和结果都记录在返回的stages中；
+// 第一轮失败时返回错误，之后的轮次失败时使用上一轮的结果
+func (evs *EnhancedVisionService) RefineElement(ctx context.Context, box core.Rect, target string) (core.Point, []domain.RefineStage, error) {
+	options := evs.refine
+	engine := evs.automationService.engine
+	displays, _ := engine.GetDisplaysContext(ctx)
+
+	var stages []domain.RefineStage
+	current := box
+	for stage := 1; stage <= max(options.Stages, 1); stage++ {
+		center := core.Point{X: current.X + current.Width/2, Y: current.Y + current.Height/2}
+		size := max(options.CropSize>>(stage-1), 2*max(current.Width, current.Height), minRefineCrop)
+		region := core.Rect{X: center.X - size/2, Y: center.Y - size/2, Width: size, Height: size}
+		if display := core.DisplayAt(displays, center); display != nil {
+			region = clampRect(region, display.Bounds)
+		}
 
-	// 分析每个显示器
-	for _, capture := range captures {
-		result, err := evs.analyzeSingleDisplay(capture, context)
+		record := domain.RefineStage{Stage: stage, Region: toCoordinates(region)}
+		rect, confidence, err := evs.locateInRegion(ctx, region, target, options.ZoomSize, &record)
 		if err != nil {
-			slog.Error("显示器分析失败", "display", capture.Index, "error", err)
-			continue
+			record.Error = err.Error()
+			stages = append(stages, record)
+			if stage == 1 {
+				return core.Point{}, stages, err
+			}
+			break
 		}
+		record.Target = toCoordinates(rect)
+		record.Confidence = confidence
+		stages = append(stages, record)
+		current = rect
+	}
+	return core.Point{X: current.X + current.Width/2, Y: current.Y + current.Height/2}, stages, nil
+}
 
-		displayResults = append(displayResults, *result)
-
-		// 选择最佳显示器（置信度最高且有目标元素）
-		if result.HasTargetElements && result.Confidence > bestConfidence {
-			bestConfidence = result.Confidence
-			bestDisplayIndex = len(displayResults) - 1
+// locateInRegion 截取区域并放大，由视觉模型定位目标，截图记录在record中
+func (evs *EnhancedVisionService) locateInRegion(ctx context.Context, region core.Rect, target string, zoomSize int, record *domain.RefineStage) (core.Rect, float64, error) {
+	_, result := evs.automationService.engine.ScreenshotAreaContext(ctx, region)
+	crop, ok := core.CaptureFromResult(result)
+	if !ok {
+		if err := result.Err(); err != nil {
+			return core.Rect{}, 0, err
 		}
+		return core.Rect{}, 0, core.NewError(core.ErrUnknown, "区域截图失败: 未获取到图像数据", nil)
 	}
+	record.Crop = crop.ImageData
 
-	if len(displayResults) == 0 {
-		return nil, fmt.Errorf("所有显示器分析都失败")
+	zoomed, err := imageprep.Zoom(crop, zoomSize)
+	if err != nil {
+		return core.Rect{}, 0, fmt.Errorf("放大局部截图失败: %w", err)
 	}
+	record.Zoomed = zoomed.ImageData
+	record.Zoom = zoomed.ScaleFactor
+
+	return evs.llmService.LocateElement(ctx, zoomed, target)
+}
+
+// clampRect 将矩形移动到bounds范围内，超过bounds尺寸时裁剪
+func clampRect(r, bounds core.Rect) core.Rect {
+	r.Width, r.Height = min(r.Width, bounds.Width), min(r.Height, bounds.Height)
+	r.X = min(max(r.X, bounds.X), bounds.X+bounds.Width-r.Width)
+	r.Y = min(max(r.Y, bounds.Y), bounds.Y+bounds.Height-r.Height)
+	return r
+}
 
-	// 如果没有找到有目标元素的显示器，选择置信度最高的
-	if bestConfidence == 0 {
-		for i, result := range displayResults {
-			if result.Confidence > bestConfidence {
-				bestConfidence = result.Confidence
-				bestDisplayIndex = i
+// toCoordinates 转换为领域模型的坐标
+func toCoordinates(r core.Rect) domain.Coordinates {
+	return domain.Coordinates{X: r.X, Y: r.Y, Width: r.Width, Height: r.Height}
+}
+
+// analyzeMultipleDisplays 并发分析多个显示器，同时进行的分析数量受Workers限制
+// 部分显示器失败或超时时使用其余显示器的结果，全部失败时返回错误
+func (evs *EnhancedVisionService) analyzeMultipleDisplays(ctx context.Context, captures []core.DisplayCapture, analysisRequest string) (*MultiDisplayAnalysis, error) {
+	options := evs.multiDisplay
+	workers := min(max(options.Workers, 1), len(captures))
+
+	results := make([]*DisplayAnalysisResult, len(captures))
+	errs := make([]error, len(captures))
+	semaphore := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, capture := range captures {
+		wg.Add(1)
+		go func(i int, capture core.DisplayCapture) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			displayCtx, cancel := ctx, context.CancelFunc(func() {})
+			if options.DisplayTimeout > 0 {
+				displayCtx, cancel = context.WithTimeout(ctx, options.DisplayTimeout)
+			}
+			defer cancel()
+
+			start := time.Now()
+			result, err := evs.analyzeSingleDisplay(displayCtx, capture, analysisRequest)
+			if err != nil {
+				if errors.Is(displayCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+					err = core.NewError(core.ErrTimeout, fmt.Sprintf("显示器 %d 分析超时（%s）", capture.Index, options.DisplayTimeout), err)
+				}
+				errs[i] = err
+				return
 			}
+			result.DurationMS = time.Since(start).Milliseconds()
+			results[i] = result
+		}(i, capture)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	analysis := &MultiDisplayAnalysis{}
+	for i, capture := range captures {
+		if errs[i] != nil {
+			slog.Error("显示器分析失败", "display", capture.Index, "error", errs[i])
+			b := capture.Bounds
+			analysis.Failures = append(analysis.Failures, DisplayFailure{
+				DisplayIndex: capture.Index,
+				Bounds:       core.Rect{X: b.Min.X, Y: b.Min.Y, Width: b.Dx(), Height: b.Dy()},
+				Error:        errs[i].Error(),
+			})
+			continue
 		}
+		analysis.Displays = append(analysis.Displays, *results[i])
+		analysis.ElementsFound = append(analysis.ElementsFound, results[i].ElementsFound...)
 	}
 
-	// 生成全局建议
-	globalRecommendations := evs.generateGlobalRecommendations(displayResults, bestDisplayIndex)
+	if len(analysis.Displays) == 0 {
+		if len(captures) == 1 {
+			return nil, errs[0]
+		}
+		return nil, fmt.Errorf("所有显示器分析都失败: %w", errors.Join(errs...))
+	}
 
-	return &MultiDisplayAnalysis{
-		Displays:              displayResults,
-		RecommendedDisplay:    bestDisplayIndex,
-		GlobalRecommendations: globalRecommendations,
-	}, nil
+	analysis.RecommendedDisplay, analysis.RecommendationReason = recommendDisplay(analysis.Displays, analysis.Failures)
+	analysis.GlobalRecommendations = evs.generateGlobalRecommendations(analysis.Displays, analysis.RecommendedDisplay)
+	return analysis, nil
+}
+
+// recommendDisplay 选择最相关的显示器并说明原因
+// 优先选择有目标元素且置信度最高的显示器，都没有目标元素时选择置信度最高的，置信度相同时选择靠前的
+func recommendDisplay(results []DisplayAnalysisResult, failures []DisplayFailure) (int, string) {
+	best := 0
+	for i, result := range results {
+		current := results[best]
+		if result.HasTargetElements != current.HasTargetElements {
+			if result.HasTargetElements {
+				best = i
+			}
+			continue
+		}
+		if result.Confidence > current.Confidence {
+			best = i
+		}
+	}
+
+	var b strings.Builder
+	chosen := results[best]
+	if chosen.HasTargetElements {
+		fmt.Fprintf(&b, "显示器 %d 找到 %d 个元素，置信度 %.2f 最高", chosen.DisplayIndex, len(chosen.ElementsFound), chosen.Confidence)
+	} else {
+		fmt.Fprintf(&b, "所有显示器都没有找到元素，显示器 %d 置信度 %.2f 最高", chosen.DisplayIndex, chosen.Confidence)
+	}
+	for i, result := range results {
+		if i != best {
+			fmt.Fprintf(&b, "；显示器 %d 找到 %d 个元素，置信度 %.2f", result.DisplayIndex, len(result.ElementsFound), result.Confidence)
+		}
+	}
+	for _, failure := range failures {
+		fmt.Fprintf(&b, "；显示器 %d 分析失败：%s", failure.DisplayIndex, failure.Error)
+	}
+	return best, b.String()
 }
 
 // calculateConfidence 计算分析置信度
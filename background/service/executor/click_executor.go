@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
@@ -9,23 +10,25 @@ import (
 )
 
 // executeClickStep 执行点击步骤
-func (e *EnhancedTaskExecutionEngine) executeClickStep(stepPlan *domain.AutomationStepPlan, screenAnalysis *domain.VisualAnalysisResponse) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeClickStep(ctx context.Context, stepPlan *domain.AutomationStepPlan, screenAnalysis *domain.VisualAnalysisResponse) *StepExecutionResult {
 	result := &StepExecutionResult{
 		StepType: stepPlan.Type,
 		Success:  false,
 	}
 
 	// 使用LLM生成点击操作
-	clickOp, err := e.llmService.GenerateClickOperation(stepPlan.Context, screenAnalysis)
+	clickOp, err := e.visionService.GenerateClick(ctx, stepPlan, nil, screenAnalysis)
 	if err != nil {
-		result.Error = fmt.Sprintf("生成点击操作失败: %v", err)
+		setStepError(result, fmt.Sprintf("生成点击操作失败: %v", err), err)
 		return result
 	}
 
 	slog.Info("生成点击操作",
 		"x", clickOp.X,
 		"y", clickOp.Y,
-		"button", clickOp.Button)
+		"button", clickOp.Button,
+		"grounding", clickOp.Grounding,
+		"mark", clickOp.Mark)
 
 	// 通过AutomationService执行点击操作
 	step := service.AutomationStep{
@@ -37,9 +40,16 @@ func (e *EnhancedTaskExecutionEngine) executeClickStep(stepPlan *domain.Automati
 		},
 	}
 
-	opResult := e.automationService.ExecuteStep(step)
+	opResult := e.automationService.ExecuteStepContext(ctx, step)
+	setStepEffect(result, opResult)
+	if len(clickOp.Refinement) > 0 {
+		if result.Data == nil {
+			result.Data = make(map[string]interface{})
+		}
+		result.Data["refinement"] = clickOp.Refinement
+	}
 	if !opResult.Success {
-		result.Error = fmt.Sprintf("执行点击失败: %s", opResult.Error)
+		setStepError(result, fmt.Sprintf("执行点击失败: %s", opResult.Error), opResult.Err())
 		return result
 	}
 
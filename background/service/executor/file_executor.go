@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
@@ -9,13 +10,13 @@ import (
 )
 
 // executeFileStep 执行文件操作步骤
-func (e *EnhancedTaskExecutionEngine) executeFileStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeFileStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{Success: false}
 
 	// 使用LLM生成文件操作
-	fileOp, err := e.llmService.GenerateFileOperation(stepPlan.Context)
+	fileOp, err := e.llmService.GenerateFileOperation(ctx, stepPlan.Context)
 	if err != nil {
-		result.Error = fmt.Sprintf("生成文件操作失败: %v", err)
+		setStepError(result, fmt.Sprintf("生成文件操作失败: %v", err), err)
 		return result
 	}
 
@@ -35,9 +36,9 @@ func (e *EnhancedTaskExecutionEngine) executeFileStep(stepPlan *domain.Automatio
 		},
 	}
 
-	opResult := e.automationService.ExecuteStep(step)
+	opResult := e.automationService.ExecuteStepContext(ctx, step)
 	if !opResult.Success {
-		result.Error = fmt.Sprintf("执行文件操作失败: %s", opResult.Error)
+		setStepError(result, fmt.Sprintf("执行文件操作失败: %s", opResult.Error), opResult.Err())
 		return result
 	}
 
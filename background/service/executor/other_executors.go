@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 
 	"diandian/background/domain"
@@ -8,7 +9,7 @@ import (
 )
 
 // executeLaunchAppStep 执行启动应用步骤
-func (e *EnhancedTaskExecutionEngine) executeLaunchAppStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeLaunchAppStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{
 		StepType: stepPlan.Type,
 		Success:  false,
@@ -16,7 +17,7 @@ func (e *EnhancedTaskExecutionEngine) executeLaunchAppStep(stepPlan *domain.Auto
 
 	appIdentifier := e.extractAppNameFromContext(stepPlan.Context)
 	if appIdentifier == "" {
-		result.Error = "无法从上下文中提取应用名称"
+		setStepError(result, "无法从上下文中提取应用名称", errStepContext)
 		return result
 	}
 
@@ -24,7 +25,7 @@ func (e *EnhancedTaskExecutionEngine) executeLaunchAppStep(stepPlan *domain.Auto
 	appLauncher := service.NewAppLauncher()
 	err := appLauncher.LaunchApp(appIdentifier)
 	if err != nil {
-		result.Error = fmt.Sprintf("智能启动应用失败: %s", err.Error())
+		setStepError(result, fmt.Sprintf("智能启动应用失败: %s", err.Error()), err)
 
 		// 如果智能启动失败，回退到原始方法
 		step := service.AutomationStep{
@@ -34,9 +35,9 @@ func (e *EnhancedTaskExecutionEngine) executeLaunchAppStep(stepPlan *domain.Auto
 			},
 		}
 
-		opResult := e.automationService.ExecuteStep(step)
+		opResult := e.automationService.ExecuteStepContext(ctx, step)
 		if !opResult.Success {
-			result.Error = fmt.Sprintf("启动应用失败 (智能启动和原始方法都失败): %s", opResult.Error)
+			setStepError(result, fmt.Sprintf("启动应用失败 (智能启动和原始方法都失败): %s", opResult.Error), opResult.Err())
 			return result
 		}
 	}
@@ -47,7 +48,7 @@ func (e *EnhancedTaskExecutionEngine) executeLaunchAppStep(stepPlan *domain.Auto
 }
 
 // executeScreenshotStep 执行截屏步骤
-func (e *EnhancedTaskExecutionEngine) executeScreenshotStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeScreenshotStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{
 		StepType: stepPlan.Type,
 		Success:  false,
@@ -65,9 +66,9 @@ func (e *EnhancedTaskExecutionEngine) executeScreenshotStep(stepPlan *domain.Aut
 		},
 	}
 
-	opResult := e.automationService.ExecuteStep(step)
+	opResult := e.automationService.ExecuteStepContext(ctx, step)
 	if !opResult.Success {
-		result.Error = fmt.Sprintf("截屏失败: %s", opResult.Error)
+		setStepError(result, fmt.Sprintf("截屏失败: %s", opResult.Error), opResult.Err())
 		return result
 	}
 
@@ -78,7 +79,7 @@ func (e *EnhancedTaskExecutionEngine) executeScreenshotStep(stepPlan *domain.Aut
 }
 
 // executeClipboardStep 执行剪贴板步骤
-func (e *EnhancedTaskExecutionEngine) executeClipboardStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeClipboardStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{
 		StepType: stepPlan.Type,
 		Success:  false,
@@ -93,7 +94,7 @@ func (e *EnhancedTaskExecutionEngine) executeClipboardStep(stepPlan *domain.Auto
 		operation = "set"
 		text = e.extractTextFromContext(stepPlan.Context)
 		if text == "" {
-			result.Error = "无法从上下文中提取文本内容"
+			setStepError(result, "无法从上下文中提取文本内容", errStepContext)
 			return result
 		}
 	}
@@ -106,9 +107,9 @@ func (e *EnhancedTaskExecutionEngine) executeClipboardStep(stepPlan *domain.Auto
 		},
 	}
 
-	opResult := e.automationService.ExecuteStep(step)
+	opResult := e.automationService.ExecuteStepContext(ctx, step)
 	if !opResult.Success {
-		result.Error = fmt.Sprintf("剪贴板操作失败: %s", opResult.Error)
+		setStepError(result, fmt.Sprintf("剪贴板操作失败: %s", opResult.Error), opResult.Err())
 		return result
 	}
 
@@ -122,12 +123,29 @@ func (e *EnhancedTaskExecutionEngine) executeClipboardStep(stepPlan *domain.Auto
 }
 
 // executeWaitStep 执行等待步骤
-func (e *EnhancedTaskExecutionEngine) executeWaitStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeWaitStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{
 		StepType: stepPlan.Type,
 		Success:  false,
 	}
 
+	if stepPlan.WaitFor != "" {
+		step := service.AutomationStep{
+			Type: "wait",
+			Parameters: map[string]interface{}{
+				"until": stepPlan.WaitFor,
+			},
+		}
+		opResult := e.automationService.ExecuteStepContext(ctx, step)
+		if !opResult.Success {
+			setStepError(result, fmt.Sprintf("等待条件未满足: %s", opResult.Error), opResult.Err())
+			return result
+		}
+		result.Success = true
+		result.Message = fmt.Sprintf("等待条件满足: %s", stepPlan.WaitFor)
+		return result
+	}
+
 	duration := e.extractDurationFromContext(stepPlan.Context)
 	if duration <= 0 {
 		duration = 1000 // 默认等待1秒
@@ -140,9 +158,9 @@ func (e *EnhancedTaskExecutionEngine) executeWaitStep(stepPlan *domain.Automatio
 		},
 	}
 
-	opResult := e.automationService.ExecuteStep(step)
+	opResult := e.automationService.ExecuteStepContext(ctx, step)
 	if !opResult.Success {
-		result.Error = fmt.Sprintf("等待操作失败: %s", opResult.Error)
+		setStepError(result, fmt.Sprintf("等待操作失败: %s", opResult.Error), opResult.Err())
 		return result
 	}
 
@@ -152,7 +170,7 @@ func (e *EnhancedTaskExecutionEngine) executeWaitStep(stepPlan *domain.Automatio
 }
 
 // executeKeyPressStep 执行按键步骤
-func (e *EnhancedTaskExecutionEngine) executeKeyPressStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeKeyPressStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{
 		StepType: stepPlan.Type,
 		Success:  false,
@@ -160,7 +178,7 @@ func (e *EnhancedTaskExecutionEngine) executeKeyPressStep(stepPlan *domain.Autom
 
 	key, modifiers := e.extractKeyFromContext(stepPlan.Context)
 	if key == "" {
-		result.Error = "无法从上下文中提取按键信息"
+		setStepError(result, "无法从上下文中提取按键信息", errStepContext)
 		return result
 	}
 
@@ -172,9 +190,9 @@ func (e *EnhancedTaskExecutionEngine) executeKeyPressStep(stepPlan *domain.Autom
 		},
 	}
 
-	opResult := e.automationService.ExecuteStep(step)
+	opResult := e.automationService.ExecuteStepContext(ctx, step)
 	if !opResult.Success {
-		result.Error = fmt.Sprintf("按键操作失败: %s", opResult.Error)
+		setStepError(result, fmt.Sprintf("按键操作失败: %s", opResult.Error), opResult.Err())
 		return result
 	}
 
@@ -6,10 +6,36 @@ import (
 	"log/slog"
 	"time"
 
+	"diandian/background/automation/core"
 	"diandian/background/domain"
 	"diandian/background/service"
 )
 
+// maxStepAttempts 步骤遇到可重试错误（超时、引擎不可用、模型限流等）时的最大尝试次数
+const maxStepAttempts = 3
+
+// executeStepWithRetry 执行步骤，失败且错误码可重试时退避后重试
+func (e *EnhancedTaskExecutionEngine) executeStepWithRetry(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+	for attempt := 1; ; attempt++ {
+		result := e.executeStepPlan(ctx, stepPlan)
+		result.RetryCount = attempt - 1
+		if result.Success || !result.Retryable || attempt >= maxStepAttempts || ctx.Err() != nil {
+			return result
+		}
+
+		delay := core.RetryDelay(core.ErrorCode(result.ErrorCode), attempt)
+		slog.Warn("步骤失败，准备重试",
+			"type", stepPlan.Type,
+			"attempt", attempt,
+			"code", result.ErrorCode,
+			"delay", delay,
+			"error", result.Error)
+		if core.Sleep(ctx, delay) != nil {
+			return result
+		}
+	}
+}
+
 // executeStepPlan 执行单个步骤计划
 func (e *EnhancedTaskExecutionEngine) executeStepPlan(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{
@@ -34,9 +60,9 @@ func (e *EnhancedTaskExecutionEngine) executeStepPlan(ctx context.Context, stepP
 			},
 		}
 
-		opResult := e.automationService.ExecuteStep(step)
+		opResult := e.automationService.ExecuteStepContext(ctx, step)
 		if !opResult.Success {
-			result.Error = fmt.Sprintf("截图失败: %s", opResult.Error)
+			setStepError(result, fmt.Sprintf("截图失败: %s", opResult.Error), opResult.Err())
 			result.EndTime = time.Now()
 			result.Duration = result.EndTime.Sub(result.StartTime)
 			return result
@@ -53,23 +79,23 @@ func (e *EnhancedTaskExecutionEngine) executeStepPlan(ctx context.Context, stepP
 	// 根据步骤类型执行相应操作
 	switch stepPlan.Type {
 	case "click":
-		result = e.executeClickStep(stepPlan, screenAnalysis)
+		result = e.executeClickStep(ctx, stepPlan, screenAnalysis)
 	case "type":
-		result = e.executeTypeStep(stepPlan)
+		result = e.executeTypeStep(ctx, stepPlan)
 	case "launch_app":
-		result = e.executeLaunchAppStep(stepPlan)
+		result = e.executeLaunchAppStep(ctx, stepPlan)
 	case "file":
-		result = e.executeFileStep(stepPlan)
+		result = e.executeFileStep(ctx, stepPlan)
 	case "screenshot":
-		result = e.executeScreenshotStep(stepPlan)
+		result = e.executeScreenshotStep(ctx, stepPlan)
 	case "clipboard":
-		result = e.executeClipboardStep(stepPlan)
+		result = e.executeClipboardStep(ctx, stepPlan)
 	case "wait":
-		result = e.executeWaitStep(stepPlan)
+		result = e.executeWaitStep(ctx, stepPlan)
 	case "key_press":
-		result = e.executeKeyPressStep(stepPlan)
+		result = e.executeKeyPressStep(ctx, stepPlan)
 	default:
-		result.Error = fmt.Sprintf("不支持的步骤类型: %s", stepPlan.Type)
+		setStepError(result, fmt.Sprintf("不支持的步骤类型: %s", stepPlan.Type), errUnsupportedStep)
 	}
 
 	result.EndTime = time.Now()
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
@@ -9,16 +10,16 @@ import (
 )
 
 // executeTypeStep 执行输入步骤
-func (e *EnhancedTaskExecutionEngine) executeTypeStep(stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
+func (e *EnhancedTaskExecutionEngine) executeTypeStep(ctx context.Context, stepPlan *domain.AutomationStepPlan) *StepExecutionResult {
 	result := &StepExecutionResult{
 		StepType: stepPlan.Type,
 		Success:  false,
 	}
 
 	// 使用LLM生成输入操作
-	typeOp, err := e.llmService.GenerateTypeOperation(stepPlan.Context)
+	typeOp, err := e.llmService.GenerateTypeOperation(ctx, stepPlan.Context)
 	if err != nil {
-		result.Error = fmt.Sprintf("生成输入操作失败: %v", err)
+		setStepError(result, fmt.Sprintf("生成输入操作失败: %v", err), err)
 		return result
 	}
 
@@ -32,13 +33,17 @@ func (e *EnhancedTaskExecutionEngine) executeTypeStep(stepPlan *domain.Automatio
 		},
 	}
 
-	opResult := e.automationService.ExecuteStep(step)
+	opResult := e.automationService.ExecuteStepContext(ctx, step)
+	setStepEffect(result, opResult)
 	if !opResult.Success {
-		result.Error = fmt.Sprintf("执行输入失败: %s", opResult.Error)
+		setStepError(result, fmt.Sprintf("执行输入失败: %s", opResult.Error), opResult.Err())
 		return result
 	}
 
 	result.Success = true
 	result.Message = fmt.Sprintf("成功输入文本: %s", typeOp.Text)
+	if data, ok := opResult.Data.(map[string]interface{}); ok && data["strategy"] != nil {
+		result.Message = fmt.Sprintf("成功输入文本（%v）: %s", data["strategy"], typeOp.Text)
+	}
 	return result
 }
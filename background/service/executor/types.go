@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"diandian/background/automation/core"
 	"diandian/background/domain"
 	"diandian/background/service"
 )
@@ -41,9 +42,34 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 		StartTime: startTime,
 	}
 
+	// 执行前拒绝引擎无法完成的计划
+	if err := e.automationService.CheckPlan(decomposition); err != nil {
+		result.Message = err.Error()
+		result.ErrorCode = string(core.CodeOf(err))
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime)
+		return result
+	}
+
+	// 登记任务，使AutomationService.StopTask能够中止执行
+	ctx, done, err := e.automationService.BeginTask(ctx, taskID)
+	if err != nil {
+		result.Message = err.Error()
+		result.ErrorCode = string(core.CodeOf(err))
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime)
+		return result
+	}
+	defer done()
+
 	// 执行每个步骤
 	for i, stepPlan := range decomposition.Steps {
-		stepResult := e.executeStepPlan(ctx, &stepPlan)
+		if ctx.Err() != nil {
+			result.Message = fmt.Sprintf("任务在步骤 %d 前被取消", i+1)
+			break
+		}
+
+		stepResult := e.executeStepWithRetry(ctx, &stepPlan)
 		stepResult.StepIndex = i + 1
 		result.Steps = append(result.Steps, stepResult)
 
@@ -78,6 +104,11 @@ func (e *EnhancedTaskExecutionEngine) ExecuteTaskDecomposition(ctx context.Conte
 		result.Success = result.ErrorCount == 0 || (result.SuccessRate >= 0.8 && result.ErrorCount <= 2)
 	}
 
+	// 被取消的任务即使已完成的步骤都成功也视为失败
+	if ctx.Err() != nil {
+		result.Success = false
+	}
+
 	if result.Success {
 		result.Message = fmt.Sprintf("任务执行成功，成功率: %.1f%%", result.SuccessRate*100)
 	} else if result.Message == "" {
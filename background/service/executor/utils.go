@@ -4,8 +4,45 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"diandian/background/automation/core"
+)
+
+var (
+	// errStepContext 无法从步骤上下文中解析出操作参数
+	errStepContext = core.NewError(core.ErrInvalidArgument, "step context cannot be parsed", nil)
+	// errUnsupportedStep 不支持的步骤类型
+	errUnsupportedStep = core.NewError(core.ErrUnsupported, "unsupported step type", nil)
 )
 
+// setStepError 记录步骤失败信息，错误码和是否可重试取自err
+func setStepError(result *StepExecutionResult, message string, err error) {
+	result.Success = false
+	result.Error = message
+	result.ErrorCode = string(core.ErrUnknown)
+	result.Retryable = false
+	if err != nil {
+		result.ErrorCode = string(core.CodeOf(err))
+		result.Retryable = core.IsRetryable(err)
+	}
+}
+
+// setStepEffect 将操作前后的屏幕差异指标复制到步骤结果，便于排查没有效果的操作
+func setStepEffect(result *StepExecutionResult, opResult *core.OperationResult) {
+	data, ok := opResult.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range []string{"effect", "visible_effect", "effect_error"} {
+		if value, ok := data[key]; ok {
+			if result.Data == nil {
+				result.Data = make(map[string]interface{})
+			}
+			result.Data[key] = value
+		}
+	}
+}
+
 // 辅助方法：从上下文中提取信息
 
 // ExtractAppNameFromContext 公开的应用名称提取方法（用于测试）
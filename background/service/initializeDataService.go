@@ -57,6 +57,35 @@ func InitializeData() {
 		Cols:        6,
 	}).FirstOrCreate(&model.Setting{})
 
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyAutomationMode,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("plan"),
+	}).Assign(&model.Setting{
+		GroupName:   "基础",
+		Name:        "自动化执行方式",
+		Desc:        "预先规划：先分解全部步骤再依次执行；逐步执行：模型每一步观察屏幕后调用工具，需要视觉模型支持工具调用",
+		OrderNum:    4,
+		Showable:    util.BoolPtr(true),
+		SettingType: "select",
+		Options:     `[{"label": "预先规划", "value": "plan"}, {"label": "逐步执行", "value": "agent"}]`,
+		Cols:        6,
+	}).FirstOrCreate(&model.Setting{})
+
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyLlmTextProvider,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("openai"),
+	}).Assign(&model.Setting{
+		GroupName:   "文本大模型",
+		Name:        "模型服务",
+		Desc:        "大模型服务的接口类型，DeepSeek、通义千问等兼容OpenAI接口的服务选择OpenAI兼容，Ollama无需Token",
+		OrderNum:    0,
+		Showable:    util.BoolPtr(true),
+		SettingType: "select",
+		Options:     `[{"label": "OpenAI兼容", "value": "openai"}, {"label": "Anthropic", "value": "anthropic"}, {"label": "Gemini", "value": "gemini"}, {"label": "Ollama", "value": "ollama"}]`,
+		Cols:        8,
+	}).FirstOrCreate(&model.Setting{})
 	database.DB.Model(&model.Setting{}).Where(&model.Setting{
 		Key: model.SettingKeyLlmTextBaseUrl,
 	}).Assign(&model.Setting{
@@ -90,7 +119,34 @@ func InitializeData() {
 		SettingType: "password",
 		Cols:        24,
 	}).FirstOrCreate(&model.Setting{})
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyLlmTextContext,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("16000"),
+	}).Assign(&model.Setting{
+		GroupName:   "文本大模型",
+		Name:        "上下文预算",
+		Desc:        "每次发送的对话历史最多占用的token数，超出时较早的消息合并为摘要，0为不限制",
+		OrderNum:    4,
+		Showable:    util.BoolPtr(true),
+		SettingType: "input",
+		Cols:        8,
+	}).FirstOrCreate(&model.Setting{})
 
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyLlmVlProvider,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("openai"),
+	}).Assign(&model.Setting{
+		GroupName:   "视觉大模型",
+		Name:        "模型服务",
+		Desc:        "大模型服务的接口类型，DeepSeek、通义千问等兼容OpenAI接口的服务选择OpenAI兼容，Ollama无需Token",
+		OrderNum:    0,
+		Showable:    util.BoolPtr(true),
+		SettingType: "select",
+		Options:     `[{"label": "OpenAI兼容", "value": "openai"}, {"label": "Anthropic", "value": "anthropic"}, {"label": "Gemini", "value": "gemini"}, {"label": "Ollama", "value": "ollama"}]`,
+		Cols:        8,
+	}).FirstOrCreate(&model.Setting{})
 	database.DB.Model(&model.Setting{}).Where(&model.Setting{
 		Key: model.SettingKeyLlmVlBaseUrl,
 	}).Assign(&model.Setting{
@@ -124,4 +180,112 @@ func InitializeData() {
 		SettingType: "password",
 		Cols:        24,
 	}).FirstOrCreate(&model.Setting{})
+
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyLlmVlImageMaxEdge,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("1568"),
+	}).Assign(&model.Setting{
+		GroupName:   "视觉大模型",
+		Name:        "截图最长边",
+		Desc:        "截图发送前按最长边缩小的像素数，0为不缩放，越小越快但细节越少",
+		OrderNum:    6,
+		Showable:    util.BoolPtr(true),
+		SettingType: "input",
+		Cols:        8,
+	}).FirstOrCreate(&model.Setting{})
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyLlmVlImageFormat,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("jpeg"),
+	}).Assign(&model.Setting{
+		GroupName:   "视觉大模型",
+		Name:        "截图格式",
+		Desc:        "截图发送前的编码格式，JPEG体积最小",
+		OrderNum:    7,
+		Showable:    util.BoolPtr(true),
+		SettingType: "select",
+		Options:     `[{"label": "JPEG", "value": "jpeg"}, {"label": "PNG", "value": "png"}, {"label": "WebP", "value": "webp"}]`,
+		Cols:        8,
+	}).FirstOrCreate(&model.Setting{})
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyLlmVlImageQuality,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("80"),
+	}).Assign(&model.Setting{
+		GroupName:   "视觉大模型",
+		Name:        "截图质量",
+		Desc:        "JPEG/WebP的压缩质量（1-100）",
+		OrderNum:    8,
+		Showable:    util.BoolPtr(true),
+		SettingType: "input",
+		Cols:        8,
+	}).FirstOrCreate(&model.Setting{})
+
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyRedactionEnabled,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("true"),
+	}).Assign(&model.Setting{
+		GroupName:   "隐私保护",
+		Name:        "截图脱敏",
+		Desc:        "截图发送给视觉模型前遮挡敏感窗口和密码输入框",
+		OrderNum:    1,
+		Showable:    util.BoolPtr(true),
+		SettingType: "switch",
+		Cols:        6,
+	}).FirstOrCreate(&model.Setting{})
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyRedactionStrategy,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("blackout"),
+	}).Assign(&model.Setting{
+		GroupName:   "隐私保护",
+		Name:        "遮挡方式",
+		Desc:        "涂黑完全遮挡；马赛克保留大致颜色，便于模型理解界面布局",
+		OrderNum:    2,
+		Showable:    util.BoolPtr(true),
+		SettingType: "select",
+		Options:     `[{"label": "涂黑", "value": "blackout"}, {"label": "马赛克", "value": "blur"}]`,
+		Cols:        6,
+	}).FirstOrCreate(&model.Setting{})
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyRedactionBlockedTitles,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("1Password,KeePass,Bitwarden,LastPass,密码管理"),
+	}).Assign(&model.Setting{
+		GroupName:   "隐私保护",
+		Name:        "屏蔽窗口标题",
+		Desc:        "标题包含这些关键字的窗口整体遮挡，逗号分隔",
+		OrderNum:    3,
+		Showable:    util.BoolPtr(true),
+		SettingType: "input",
+		Cols:        12,
+	}).FirstOrCreate(&model.Setting{})
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyRedactionBlockedApps,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("1Password,KeePass,KeePassXC,Bitwarden,LastPass"),
+	}).Assign(&model.Setting{
+		GroupName:   "隐私保护",
+		Name:        "屏蔽应用",
+		Desc:        "这些应用（进程名）的窗口整体遮挡，逗号分隔",
+		OrderNum:    4,
+		Showable:    util.BoolPtr(true),
+		SettingType: "input",
+		Cols:        12,
+	}).FirstOrCreate(&model.Setting{})
+	database.DB.Model(&model.Setting{}).Where(&model.Setting{
+		Key: model.SettingKeyRedactionPasswordFields,
+	}).Attrs(&model.Setting{
+		Value: util.StringPtr("true"),
+	}).Assign(&model.Setting{
+		GroupName:   "隐私保护",
+		Name:        "遮挡密码输入框",
+		Desc:        "通过本地文字识别找出密码输入框和密钥并遮挡，没有本地OCR时不生效",
+		OrderNum:    5,
+		Showable:    util.BoolPtr(true),
+		SettingType: "switch",
+		Cols:        6,
+	}).FirstOrCreate(&model.Setting{})
 }
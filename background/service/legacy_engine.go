@@ -0,0 +1,20 @@
+//go:build robotgo
+
+package service
+
+import (
+	"fmt"
+
+	"diandian/background/automation/legacy/engine"
+)
+
+// robotgo构建时注册完整的legacy引擎，作为混合引擎的最后一个后端
+func init() {
+	legacyEngineFactory = func() (interface{}, error) {
+		legacy := engine.NewEngine()
+		if result := legacy.Initialize(); !result.Success {
+			return nil, fmt.Errorf("%s: %s", result.Message, result.Error)
+		}
+		return legacy, nil
+	}
+}
@@ -0,0 +1,318 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"diandian/background/automation/core"
+)
+
+const (
+	anthropicBaseURL   = "https://api.anthropic.com/v1"
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 4096 // Messages API要求必须指定max_tokens
+)
+
+// Anthropic Anthropic Messages API
+// 没有原生的JSON输出模式，结构化输出要求写入系统提示词，并预填"{"让回复直接从JSON开始
+type Anthropic struct {
+	baseURL string
+	token   string
+	model   string
+}
+
+// NewAnthropic 创建Anthropic服务
+func NewAnthropic(config Config) *Anthropic {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = anthropicBaseURL
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(baseURL, "/v1") {
+		baseURL += "/v1"
+	}
+	return &Anthropic{baseURL: baseURL, token: config.Token, model: config.Model}
+}
+
+// Name 服务名称
+func (p *Anthropic) Name() string { return ProviderAnthropic }
+
+// Model 默认模型
+func (p *Anthropic) Model() string { return p.model }
+
+// Capabilities 服务支持的能力
+func (p *Anthropic) Capabilities() Capabilities {
+	return Capabilities{Vision: true, Streaming: true, Tools: true}
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature float32            `json:"temperature,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema json.Marshaler `json:"input_schema"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicContent struct {
+	Type   string           `json:"type"`
+	Text   string           `json:"text,omitempty"`
+	Source *anthropicSource `json:"source,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string             `json:"tool_use_id,omitempty"`
+	Content   []anthropicContent `json:"content,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Model      string             `json:"model"`
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      anthropicUsage     `json:"usage"`
+}
+
+type anthropicEvent struct {
+	Type    string             `json:"type"`
+	Message *anthropicResponse `json:"message"`
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Chat 发送对话请求
+func (p *Anthropic) Chat(ctx context.Context, request Request) (*Response, error) {
+	body, prefill, err := p.buildRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := postJSON(ctx, ProviderAnthropic, p.baseURL+"/messages", p.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	var out anthropicResponse
+	if err := decodeJSON(ProviderAnthropic, resp, &out); err != nil {
+		return nil, err
+	}
+
+	var content strings.Builder
+	var calls []ToolCall
+	for _, block := range out.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			calls = append(calls, ToolCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
+		}
+	}
+	if content.Len() == 0 && len(calls) == 0 {
+		return nil, emptyResponse(ProviderAnthropic)
+	}
+	return &Response{
+		Content:      prefill + content.String(),
+		ToolCalls:    calls,
+		Model:        out.Model,
+		FinishReason: out.StopReason,
+		Usage:        Usage{InputTokens: out.Usage.InputTokens, OutputTokens: out.Usage.OutputTokens},
+	}, nil
+}
+
+// Stream 流式发送对话请求
+func (p *Anthropic) Stream(ctx context.Context, request Request, handler StreamHandler) (*Response, error) {
+	if len(request.Tools) > 0 {
+		return nil, errToolsStreaming(ProviderAnthropic)
+	}
+	body, prefill, err := p.buildRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	body.Stream = true
+	resp, err := postJSON(ctx, ProviderAnthropic, p.baseURL+"/messages", p.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Response{}
+	var content strings.Builder
+	if prefill != "" {
+		content.WriteString(prefill)
+		if err := handler(prefill); err != nil {
+			return nil, err
+		}
+	}
+	err = readSSE(resp.Body, func(data []byte) error {
+		var event anthropicEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return core.NewError(core.ErrLLMInvalidOutput, "解析anthropic流式响应失败", err)
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				result.Model = event.Message.Model
+				result.Usage.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				content.WriteString(event.Delta.Text)
+				return handler(event.Delta.Text)
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				result.FinishReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				result.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			message := "anthropic流式响应出错"
+			if event.Error != nil {
+				message += ": " + event.Error.Message
+			}
+			return core.NewError(core.ErrLLMUnavailable, message, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content.Len() == len(prefill) {
+		return nil, emptyResponse(ProviderAnthropic)
+	}
+	result.Content = content.String()
+	return result, nil
+}
+
+// buildRequest 构造请求，返回预填的回复开头
+func (p *Anthropic) buildRequest(request Request) (*anthropicRequest, string, error) {
+	instruction, err := formatInstruction(request.Format)
+	if err != nil {
+		return nil, "", err
+	}
+	system, messages := splitSystem(withInstruction(request.Messages, instruction))
+
+	body := &anthropicRequest{
+		Model:       request.Model,
+		MaxTokens:   request.MaxTokens,
+		System:      system,
+		Temperature: request.Temperature,
+	}
+	if body.Model == "" {
+		body.Model = p.model
+	}
+	if body.MaxTokens <= 0 {
+		body.MaxTokens = anthropicMaxTokens
+	}
+
+	for _, tool := range request.Tools {
+		body.Tools = append(body.Tools, anthropicTool{Name: tool.Name, Description: tool.Description, InputSchema: tool.Parameters})
+	}
+
+	for _, message := range messages {
+		role := message.Role
+		var content []anthropicContent
+		switch role {
+		case RoleTool:
+			// 工具结果作为用户消息中的tool_result块，图片可以直接放在结果中
+			role = RoleUser
+			content = []anthropicContent{{
+				Type:      "tool_result",
+				ToolUseID: message.ToolCallID,
+				Content:   anthropicBlocks(message),
+			}}
+		default:
+			content = anthropicBlocks(message)
+			for _, call := range message.ToolCalls {
+				content = append(content, anthropicContent{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Name,
+					Input: toolArguments(call.Arguments),
+				})
+			}
+		}
+		if len(content) == 0 {
+			continue
+		}
+		// 角色必须交替出现，连续的同角色消息（如多个工具结果）合并为一条
+		if last := len(body.Messages) - 1; last >= 0 && body.Messages[last].Role == role {
+			body.Messages[last].Content = append(body.Messages[last].Content, content...)
+			continue
+		}
+		body.Messages = append(body.Messages, anthropicMessage{Role: role, Content: content})
+	}
+
+	prefill := ""
+	// 使用工具时模型需要自行决定先调用工具还是直接回答，不预填
+	if request.Format != nil && len(request.Tools) == 0 && len(body.Messages) > 0 && body.Messages[len(body.Messages)-1].Role == RoleUser {
+		prefill = "{"
+		body.Messages = append(body.Messages, anthropicMessage{
+			Role:    RoleAssistant,
+			Content: []anthropicContent{{Type: "text", Text: prefill}},
+		})
+	}
+	return body, prefill, nil
+}
+
+// anthropicBlocks 消息的图片和文字块，官方建议图片放在文字之前
+func anthropicBlocks(message Message) []anthropicContent {
+	var content []anthropicContent
+	for _, image := range message.Images {
+		content = append(content, anthropicContent{
+			Type: "image",
+			Source: &anthropicSource{
+				Type:      "base64",
+				MediaType: image.MIME(),
+				Data:      base64.StdEncoding.EncodeToString(image.Data),
+			},
+		})
+	}
+	if message.Content != "" {
+		content = append(content, anthropicContent{Type: "text", Text: message.Content})
+	}
+	return content
+}
+
+// headers 请求头
+func (p *Anthropic) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.token,
+		"anthropic-version": anthropicVersion,
+	}
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"unicode/utf8"
+
+	"diandian/background/automation/core"
+)
+
+// FakeHandler 根据请求生成假模型的回复
+type FakeHandler func(request Request) (string, error)
+
+// Fake 进程内的假模型，按顺序返回预设的回复或由Handler生成回复，并记录收到的请求
+// 通过operation.SetTextProvider/SetVisionProvider注入后，生成器和服务不需要真实的模型服务即可运行
+type Fake struct {
+	mu        sync.Mutex
+	model     string
+	responses []Response
+	handler   FakeHandler
+	requests  []Request
+}
+
+// NewFake 创建按顺序返回预设回复的假模型，回复用完后返回错误
+func NewFake(responses ...string) *Fake {
+	p := &Fake{model: ProviderFake}
+	p.Push(responses...)
+	return p
+}
+
+// NewFakeWithHandler 创建由handler生成回复的假模型
+func NewFakeWithHandler(handler FakeHandler) *Fake {
+	return &Fake{model: ProviderFake, handler: handler}
+}
+
+// Push 追加预设的回复
+func (p *Fake) Push(responses ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, content := range responses {
+		p.responses = append(p.responses, Response{Content: content})
+	}
+}
+
+// PushResponse 追加预设的完整回复，可以包含工具调用
+func (p *Fake) PushResponse(responses ...Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = append(p.responses, responses...)
+}
+
+// Requests 收到的请求
+func (p *Fake) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Request(nil), p.requests...)
+}
+
+// Name 服务名称
+func (p *Fake) Name() string { return ProviderFake }
+
+// Model 默认模型
+func (p *Fake) Model() string { return p.model }
+
+// Capabilities 假模型支持所有能力
+func (p *Fake) Capabilities() Capabilities {
+	return Capabilities{Vision: true, JSONMode: true, JSONSchema: true, Streaming: true, Tools: true}
+}
+
+// Chat 返回下一条回复
+func (p *Fake) Chat(ctx context.Context, request Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, core.NewError(core.ErrCancelled, "请求已取消", err)
+	}
+	resp, err := p.next(request)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Model == "" {
+		resp.Model = p.model
+	}
+	if resp.FinishReason == "" {
+		resp.FinishReason = "stop"
+		if len(resp.ToolCalls) > 0 {
+			resp.FinishReason = "tool_calls"
+		}
+	}
+	if resp.Usage.OutputTokens == 0 {
+		resp.Usage.OutputTokens = utf8.RuneCountInString(resp.Content)
+	}
+	return resp, nil
+}
+
+// Stream 逐字符回调下一条回复
+func (p *Fake) Stream(ctx context.Context, request Request, handler StreamHandler) (*Response, error) {
+	if len(request.Tools) > 0 {
+		return nil, errToolsStreaming(ProviderFake)
+	}
+	resp, err := p.Chat(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resp.Content {
+		if err := ctx.Err(); err != nil {
+			return nil, core.NewError(core.ErrCancelled, "请求已取消", err)
+		}
+		if err := handler(string(r)); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// next 记录请求并取出回复
+func (p *Fake) next(request Request) (*Response, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, request)
+	handler := p.handler
+	if handler == nil {
+		defer p.mu.Unlock()
+		if len(p.responses) == 0 {
+			return nil, core.NewError(core.ErrLLMUnavailable, "假模型没有预设的回复", nil)
+		}
+		resp := p.responses[0]
+		p.responses = p.responses[1:]
+		return &resp, nil
+	}
+	p.mu.Unlock()
+	content, err := handler(request)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Content: content}, nil
+}
@@ -0,0 +1,143 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// FieldStream 从流式输出的JSON中提取顶层某个字符串字段的内容
+// 模型按JSON结构输出时，可以把其中给用户看的字段边生成边显示；转义字符在回调前已还原
+type FieldStream struct {
+	field   string
+	handler StreamHandler
+
+	depth     int  // 当前的嵌套层数，顶层对象为1
+	expectKey bool // 顶层对象中下一个字符串是键
+	inString  bool
+	isKey     bool // 当前字符串是顶层对象的键
+	isTarget  bool // 当前字符串是目标字段的值
+	escape    bool
+	hexLeft   int // \u转义还需要读取的十六进制位数
+	hex       rune
+	high      rune // 等待低位代理的高位代理
+	key       strings.Builder
+	lastKey   string
+	value     strings.Builder
+}
+
+// NewFieldStream 创建字段提取器，目标字段每收到一段内容调用一次handler
+func NewFieldStream(field string, handler StreamHandler) *FieldStream {
+	return &FieldStream{field: field, handler: handler}
+}
+
+// Write 处理一段模型输出，可直接作为StreamHandler使用
+func (f *FieldStream) Write(delta string) error {
+	var out strings.Builder
+	for _, r := range delta {
+		if f.inString {
+			f.readString(r, &out)
+			continue
+		}
+		switch r {
+		case '{', '[':
+			f.depth++
+			if r == '{' && f.depth == 1 {
+				f.expectKey = true
+			}
+		case '}', ']':
+			f.depth--
+		case ',':
+			if f.depth == 1 {
+				f.expectKey = true
+			}
+		case '"':
+			f.inString = true
+			f.isKey = f.depth == 1 && f.expectKey
+			f.isTarget = f.depth == 1 && !f.isKey && f.lastKey == f.field
+			f.expectKey = false
+			f.key.Reset()
+		}
+	}
+	if out.Len() == 0 || f.handler == nil {
+		return nil
+	}
+	return f.handler(out.String())
+}
+
+// Value 目前为止提取到的字段内容
+func (f *FieldStream) Value() string {
+	return f.value.String()
+}
+
+// readString 读取字符串中的一个字符，目标字段的内容写入out
+func (f *FieldStream) readString(r rune, out *strings.Builder) {
+	switch {
+	case f.hexLeft > 0:
+		f.hex = f.hex<<4 | hexValue(r)
+		f.hexLeft--
+		if f.hexLeft == 0 {
+			f.emitRune(f.hex, out)
+		}
+		return
+	case f.escape:
+		f.escape = false
+		switch r {
+		case 'n':
+			r = '\n'
+		case 't':
+			r = '\t'
+		case 'r':
+			r = '\r'
+		case 'b':
+			r = '\b'
+		case 'f':
+			r = '\f'
+		case 'u':
+			f.hexLeft, f.hex = 4, 0
+			return
+		}
+	case r == '\\':
+		f.escape = true
+		return
+	case r == '"':
+		f.inString = false
+		if f.isKey {
+			f.lastKey = f.key.String()
+		}
+		f.isKey, f.isTarget = false, false
+		return
+	}
+	f.emitRune(r, out)
+}
+
+// emitRune 记录一个字符，处理\u转义的代理对
+func (f *FieldStream) emitRune(r rune, out *strings.Builder) {
+	if utf16.IsSurrogate(r) {
+		if f.high == 0 {
+			f.high = r
+			return
+		}
+		r = utf16.DecodeRune(f.high, r)
+	}
+	f.high = 0
+	switch {
+	case f.isKey:
+		f.key.WriteRune(r)
+	case f.isTarget:
+		f.value.WriteRune(r)
+		out.WriteRune(r)
+	}
+}
+
+// hexValue 十六进制字符的值
+func hexValue(r rune) rune {
+	switch {
+	case r >= '0' && r <= '9':
+		return r - '0'
+	case r >= 'a' && r <= 'f':
+		return r - 'a' + 10
+	case r >= 'A' && r <= 'F':
+		return r - 'A' + 10
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFieldStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"普通字段", `{"chat_response": "好的", "is_automation_task": false}`, "好的"},
+		{"目标字段在后", `{"intent": {"chat_response": "嵌套的不算"}, "chat_response": "外层"}`, "外层"},
+		{"转义字符", `{"chat_response": "第一行\n\"引号\"\\路径\t结束"}`, "第一行\n\"引号\"\\路径\t结束"},
+		{"unicode转义", `{"chat_response": "\u4f60\u597d\ud83d\ude00"}`, "你好😀"},
+		{"值等于字段名", `{"note": "chat_response", "chat_response": "ok"}`, "ok"},
+		{"数组中的字符串", `{"steps": ["chat_response", "x"], "chat_response": "ok"}`, "ok"},
+		{"没有目标字段", `{"message": "hi"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var streamed strings.Builder
+			stream := NewFieldStream("chat_response", func(delta string) error {
+				streamed.WriteString(delta)
+				return nil
+			})
+			// 逐字符写入，转义序列会被拆到多次写入中
+			for _, r := range tt.output {
+				if err := stream.Write(string(r)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if stream.Value() != tt.want || streamed.String() != tt.want {
+				t.Errorf("提取结果 = %q，回调内容 = %q，期望 %q", stream.Value(), streamed.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldStreamWithFakeProvider(t *testing.T) {
+	fake := NewFake(`{"is_automation_task": true, "chat_response": "好的，马上打开记事本。", "task_description": "打开记事本"}`)
+
+	var deltas []string
+	stream := NewFieldStream("chat_response", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	resp, err := fake.Stream(context.Background(), Request{Messages: []Message{UserMessage("打开记事本")}}, stream.Write)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(deltas, ""); got != "好的，马上打开记事本。" {
+		t.Errorf("推送给前端的内容 = %q", got)
+	}
+	if len(deltas) < 2 {
+		t.Errorf("回调次数 = %d，期望边生成边推送", len(deltas))
+	}
+	if !strings.Contains(resp.Content, `"task_description"`) {
+		t.Errorf("完整回复 = %q，期望包含全部字段", resp.Content)
+	}
+}
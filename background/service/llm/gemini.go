@@ -0,0 +1,396 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"diandian/background/automation/core"
+)
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// Gemini Google Gemini API
+// responseSchema只支持OpenAPI Schema的子集，转换时去掉不支持的字段，无法表达的Schema改为写入提示词
+type Gemini struct {
+	baseURL string
+	token   string
+	model   string
+}
+
+// NewGemini 创建Gemini服务
+func NewGemini(config Config) *Gemini {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = geminiBaseURL
+	}
+	return &Gemini{baseURL: strings.TrimRight(baseURL, "/"), token: config.Token, model: config.Model}
+}
+
+// Name 服务名称
+func (p *Gemini) Name() string { return ProviderGemini }
+
+// Model 默认模型
+func (p *Gemini) Model() string { return p.model }
+
+// Capabilities 服务支持的能力
+func (p *Gemini) Capabilities() Capabilities {
+	return Capabilities{Vision: true, JSONMode: true, JSONSchema: true, Streaming: true, Tools: true}
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent        `json:"contents"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+	Tools             []geminiTool           `json:"tools,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *geminiInlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+	ThoughtSignature string                  `json:"thoughtSignature,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature      float32        `json:"temperature,omitempty"`
+	MaxOutputTokens  int            `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string         `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]any `json:"responseSchema,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion   string `json:"modelVersion"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+}
+
+// Chat 发送对话请求
+func (p *Gemini) Chat(ctx context.Context, request Request) (*Response, error) {
+	body, model, err := p.buildRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := postJSON(ctx, ProviderGemini, p.endpoint(model, "generateContent"), p.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	var out geminiResponse
+	if err := decodeJSON(ProviderGemini, resp, &out); err != nil {
+		return nil, err
+	}
+
+	result := &Response{Model: model}
+	if err := result.mergeGemini(&out); err != nil {
+		return nil, err
+	}
+	if result.Content == "" && len(result.ToolCalls) == 0 {
+		return nil, emptyResponse(ProviderGemini)
+	}
+	return result, nil
+}
+
+// Stream 流式发送对话请求
+func (p *Gemini) Stream(ctx context.Context, request Request, handler StreamHandler) (*Response, error) {
+	if len(request.Tools) > 0 {
+		return nil, errToolsStreaming(ProviderGemini)
+	}
+	body, model, err := p.buildRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := postJSON(ctx, ProviderGemini, p.endpoint(model, "streamGenerateContent")+"?alt=sse", p.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Response{Model: model}
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return core.NewError(core.ErrLLMInvalidOutput, "解析gemini流式响应失败", err)
+		}
+		before := len(result.Content)
+		if err := result.mergeGemini(&chunk); err != nil {
+			return err
+		}
+		if delta := result.Content[before:]; delta != "" {
+			return handler(delta)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Content == "" {
+		return nil, emptyResponse(ProviderGemini)
+	}
+	return result, nil
+}
+
+// mergeGemini 合并一次响应（流式时为一段）的文字、工具调用、结束原因和用量
+func (r *Response) mergeGemini(out *geminiResponse) error {
+	if out.PromptFeedback != nil && out.PromptFeedback.BlockReason != "" {
+		return core.NewError(core.ErrLLMInvalidOutput, fmt.Sprintf("gemini拒绝了请求: %s", out.PromptFeedback.BlockReason), nil)
+	}
+	if out.ModelVersion != "" {
+		r.Model = out.ModelVersion
+	}
+	if out.UsageMetadata.PromptTokenCount > 0 {
+		r.Usage = Usage{InputTokens: out.UsageMetadata.PromptTokenCount, OutputTokens: out.UsageMetadata.CandidatesTokenCount}
+	}
+	if len(out.Candidates) == 0 {
+		return nil
+	}
+	candidate := out.Candidates[0]
+	if candidate.FinishReason != "" {
+		r.FinishReason = candidate.FinishReason
+	}
+	for _, part := range candidate.Content.Parts {
+		r.Content += part.Text
+		if call := part.FunctionCall; call != nil {
+			// 旧版本的接口不返回调用ID，按顺序生成
+			id := call.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", len(r.ToolCalls)+1)
+			}
+			r.ToolCalls = append(r.ToolCalls, ToolCall{
+				ID:        id,
+				Name:      call.Name,
+				Arguments: string(call.Args),
+				Signature: part.ThoughtSignature,
+			})
+		}
+	}
+	return nil
+}
+
+// buildRequest 构造请求，返回实际使用的模型
+func (p *Gemini) buildRequest(request Request) (*geminiRequest, string, error) {
+	model := request.Model
+	if model == "" {
+		model = p.model
+	}
+	body := &geminiRequest{
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     request.Temperature,
+			MaxOutputTokens: request.MaxTokens,
+		},
+	}
+
+	messages := request.Messages
+	if format := request.Format; format != nil {
+		body.GenerationConfig.ResponseMimeType = "application/json"
+		schema, err := geminiResponseSchema(format)
+		if err != nil {
+			return nil, "", err
+		}
+		if schema != nil {
+			body.GenerationConfig.ResponseSchema = schema
+		} else if format.Type == FormatJSONSchema {
+			instruction, err := formatInstruction(format)
+			if err != nil {
+				return nil, "", err
+			}
+			messages = withInstruction(messages, instruction)
+		}
+	}
+
+	system, messages := splitSystem(messages)
+	if system != "" {
+		body.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+	if len(request.Tools) > 0 {
+		tool := geminiTool{}
+		for _, t := range request.Tools {
+			declaration := geminiFunctionDeclaration{Name: t.Name, Description: t.Description}
+			if t.Parameters != nil {
+				parameters, err := geminiSchema(t.Parameters)
+				if err != nil {
+					return nil, "", err
+				}
+				// 没有参数的工具省略parameters
+				declaration.Parameters = parameters
+			}
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, declaration)
+		}
+		body.Tools = []geminiTool{tool}
+	}
+
+	for _, message := range messages {
+		role := "user"
+		if message.Role == RoleAssistant {
+			role = "model"
+		}
+		content := geminiContent{Role: role}
+		if message.Role == RoleTool {
+			// 调用ID可能是生成的，结果按工具名称和顺序对应
+			content.Parts = append(content.Parts, geminiPart{FunctionResponse: &geminiFunctionResponse{
+				Name:     message.ToolName,
+				Response: map[string]any{"content": message.Content},
+			}})
+		} else if message.Content != "" {
+			content.Parts = append(content.Parts, geminiPart{Text: message.Content})
+		}
+		for _, image := range message.Images {
+			content.Parts = append(content.Parts, geminiPart{InlineData: &geminiInlineData{
+				MimeType: image.MIME(),
+				Data:     base64.StdEncoding.EncodeToString(image.Data),
+			}})
+		}
+		for _, call := range message.ToolCalls {
+			content.Parts = append(content.Parts, geminiPart{
+				FunctionCall:     &geminiFunctionCall{Name: call.Name, Args: toolArguments(call.Arguments)},
+				ThoughtSignature: call.Signature,
+			})
+		}
+		if len(content.Parts) == 0 {
+			continue
+		}
+		// 多个工具结果需要放在同一轮中，连续的同角色消息合并
+		if last := len(body.Contents) - 1; last >= 0 && body.Contents[last].Role == role {
+			body.Contents[last].Parts = append(body.Contents[last].Parts, content.Parts...)
+			continue
+		}
+		body.Contents = append(body.Contents, content)
+	}
+	return body, model, nil
+}
+
+// endpoint 模型方法的地址
+func (p *Gemini) endpoint(model, method string) string {
+	model = strings.TrimPrefix(model, "models/")
+	return fmt.Sprintf("%s/models/%s:%s", p.baseURL, url.PathEscape(model), method)
+}
+
+// headers 请求头
+func (p *Gemini) headers() map[string]string {
+	return map[string]string{"x-goog-api-key": p.token}
+}
+
+// geminiSchemaKeys responseSchema支持的字段
+var geminiSchemaKeys = map[string]bool{
+	"type": true, "format": true, "description": true, "nullable": true, "enum": true,
+	"properties": true, "required": true, "items": true, "minItems": true, "maxItems": true,
+}
+
+// geminiResponseSchema 将JSON Schema转换为Gemini的responseSchema，无法表达时返回nil
+func geminiResponseSchema(format *Format) (map[string]any, error) {
+	if format.Type != FormatJSONSchema || format.Schema == nil {
+		return nil, nil
+	}
+	return geminiSchema(format.Schema)
+}
+
+// geminiSchema 将JSON Schema转换为Gemini支持的子集，无法表达时返回nil
+func geminiSchema(marshaler json.Marshaler) (map[string]any, error) {
+	data, err := marshaler.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("序列化JSON Schema失败: %w", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("解析JSON Schema失败: %w", err)
+	}
+	converted, ok := convertGeminiSchema(schema)
+	if !ok {
+		return nil, nil
+	}
+	return converted, nil
+}
+
+// convertGeminiSchema 递归转换Schema：类型名改为大写，去掉additionalProperties等不支持的字段
+// Gemini要求OBJECT必须声明properties，任意键的对象（如map）无法表达
+func convertGeminiSchema(schema map[string]any) (map[string]any, bool) {
+	result := make(map[string]any, len(schema))
+	for key, value := range schema {
+		if !geminiSchemaKeys[key] {
+			continue
+		}
+		switch key {
+		case "type":
+			name, ok := value.(string)
+			if !ok {
+				return nil, false
+			}
+			result[key] = strings.ToUpper(name)
+		case "properties":
+			properties, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			converted := make(map[string]any, len(properties))
+			for name, property := range properties {
+				child, ok := property.(map[string]any)
+				if !ok {
+					return nil, false
+				}
+				if converted[name], ok = convertGeminiSchema(child); !ok {
+					return nil, false
+				}
+			}
+			result[key] = converted
+		case "items":
+			child, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if result[key], ok = convertGeminiSchema(child); !ok {
+				return nil, false
+			}
+		default:
+			result[key] = value
+		}
+	}
+	if result["type"] == "OBJECT" {
+		if properties, _ := result["properties"].(map[string]any); len(properties) == 0 {
+			return nil, false
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,49 @@
+package llm
+
+// SplitHistory 从最新的消息往前保留，直到占满limit，返回需要合并到摘要的消息数
+// 保留的部分从用户消息开始，最后一条用户消息及其后的回复总是保留
+func SplitHistory(model string, messages []Message, limit int) int {
+	keep := len(messages)
+	tokens := 0
+	for keep > 0 {
+		tokens += CountMessageTokens(model, messages[keep-1])
+		if tokens > limit {
+			break
+		}
+		keep--
+	}
+	for keep < len(messages) && messages[keep].Role != RoleUser {
+		keep++
+	}
+	if keep == len(messages) {
+		// 预算放不下最近一轮对话，从最后一条用户消息开始保留
+		for keep > 0 && messages[keep-1].Role != RoleUser {
+			keep--
+		}
+		if keep > 0 {
+			keep--
+		}
+	}
+	return keep
+}
+
+// FitHistory 丢弃最早的消息使对话历史不超过budget，开头的摘要和最后一条消息总是保留
+func FitHistory(model string, history []Message, budget int) []Message {
+	if budget <= 0 || CountMessagesTokens(model, history) <= budget {
+		return history
+	}
+	var head []Message
+	if len(history) > 0 && history[0].Role == RoleSystem {
+		head, history = history[:1], history[1:]
+	}
+	tokens := CountMessagesTokens(model, head) + CountMessagesTokens(model, history)
+	for len(history) > 1 && tokens > budget {
+		tokens -= CountMessageTokens(model, history[0])
+		history = history[1:]
+	}
+	// 保留的部分从用户消息开始，部分服务要求第一条对话消息来自用户
+	for len(history) > 1 && history[0].Role != RoleUser {
+		history = history[1:]
+	}
+	return append(append([]Message(nil), head...), history...)
+}
@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+// testHistory 轮流生成用户和助手消息，gpt-4o下每条消息14个token
+func testHistory(n int) []Message {
+	messages := make([]Message, n)
+	for i := range messages {
+		content := strings.Repeat("x", 36)
+		if i%2 == 0 {
+			messages[i] = UserMessage(content)
+		} else {
+			messages[i] = AssistantMessage(content)
+		}
+	}
+	return messages
+}
+
+func TestSplitHistory(t *testing.T) {
+	messages := testHistory(6)
+	if got := CountMessageTokens("gpt-4o", messages[0]); got != 14 {
+		t.Fatalf("每条消息 = %d token，期望 14", got)
+	}
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{1000, 0}, // 全部放得下
+		{30, 4},   // 保留最后一轮
+		{50, 4},   // 放得下3条，但保留的部分要从用户消息开始
+		{60, 2},
+		{10, 4}, // 放不下最后一轮也总是保留
+	}
+	for _, tt := range tests {
+		if got := SplitHistory("gpt-4o", messages, tt.limit); got != tt.want {
+			t.Errorf("SplitHistory(limit=%d) = %d，期望 %d", tt.limit, got, tt.want)
+		}
+	}
+	if got := SplitHistory("gpt-4o", nil, 10); got != 0 {
+		t.Errorf("空历史 = %d，期望 0", got)
+	}
+}
+
+func TestFitHistory(t *testing.T) {
+	summary := SystemMessage(strings.Repeat("s", 36))
+	history := append([]Message{summary}, testHistory(6)...)
+
+	if got := FitHistory("gpt-4o", history, 0); len(got) != len(history) {
+		t.Errorf("预算为0时不裁剪，得到 %d 条", len(got))
+	}
+	if got := FitHistory("gpt-4o", history, 1000); len(got) != len(history) {
+		t.Errorf("预算足够时不裁剪，得到 %d 条", len(got))
+	}
+
+	// 98 token裁剪到60以内：丢弃前3条后从助手消息开始，再丢弃一条
+	got := FitHistory("gpt-4o", history, 60)
+	if len(got) != 3 || got[0].Role != RoleSystem || got[1].Role != RoleUser || got[2].Role != RoleAssistant {
+		t.Errorf("裁剪结果 = %v，期望摘要加最后一轮", roles(got))
+	}
+	if CountMessagesTokens("gpt-4o", got) > 60 {
+		t.Errorf("裁剪后仍有 %d token", CountMessagesTokens("gpt-4o", got))
+	}
+
+	// 预算放不下任何消息时仍保留摘要和最后一条消息
+	got = FitHistory("gpt-4o", history, 1)
+	if len(got) != 2 || got[0].Role != RoleSystem || got[1].Role != RoleAssistant {
+		t.Errorf("预算极小时 = %v，期望摘要和最后一条消息", roles(got))
+	}
+	if history[0].Role != RoleSystem || len(history) != 7 {
+		t.Error("原对话历史被修改")
+	}
+}
+
+func roles(messages []Message) []string {
+	var result []string
+	for _, message := range messages {
+		result = append(result, message.Role)
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"diandian/background/automation/core"
+)
+
+// maxErrorBody 错误信息中保留的响应内容长度
+const maxErrorBody = 512
+
+// httpClient 原生HTTP接口的客户端，超时由调用方的ctx控制
+var httpClient = &http.Client{}
+
+// postJSON 发送JSON请求，非2xx状态码转换为带错误码的错误，调用方负责关闭响应
+func postJSON(ctx context.Context, provider, url string, headers map[string]string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化%s请求失败: %w", provider, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("创建%s请求失败: %w", provider, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, core.NewError(StatusCode(resp.StatusCode),
+			fmt.Sprintf("%s返回HTTP %d: %s", provider, resp.StatusCode, strings.TrimSpace(string(data))), nil)
+	}
+	return resp, nil
+}
+
+// decodeJSON 读取并解析响应
+func decodeJSON(provider string, resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return core.NewError(core.ErrLLMInvalidOutput, fmt.Sprintf("解析%s响应失败", provider), err)
+	}
+	return nil
+}
+
+// readSSE 逐条读取server-sent events的data字段，handler返回错误时停止
+func readSSE(body io.Reader, handler func(data []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var data []byte
+	flush := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		event := data
+		data = nil
+		if string(event) == "[DONE]" {
+			return nil
+		}
+		return handler(event)
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// readNDJSON 逐行读取JSON，handler返回错误时停止
+func readNDJSON(body io.Reader, handler func(line []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := handler(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// emptyResponse 模型没有返回内容
+func emptyResponse(provider string) error {
+	return core.NewError(core.ErrLLMInvalidOutput, fmt.Sprintf("%s返回空响应", provider), nil)
+}
+
+// joinURL 拼接服务地址和路径
+func joinURL(baseURL, path string) string {
+	return strings.TrimRight(baseURL, "/") + path
+}
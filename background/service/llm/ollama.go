@@ -0,0 +1,246 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"diandian/background/automation/core"
+)
+
+const ollamaBaseURL = "http://localhost:11434"
+
+// Ollama 本地Ollama服务，不需要Token
+// format字段可以直接传入JSON Schema，但不区分严格模式
+type Ollama struct {
+	baseURL string
+	token   string
+	model   string
+}
+
+// NewOllama 创建Ollama服务
+func NewOllama(config Config) *Ollama {
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = ollamaBaseURL
+	}
+	// 兼容填写了OpenAI兼容地址或/api前缀的配置
+	baseURL = strings.TrimSuffix(strings.TrimSuffix(baseURL, "/v1"), "/api")
+	return &Ollama{baseURL: baseURL, token: config.Token, model: config.Model}
+}
+
+// Name 服务名称
+func (p *Ollama) Name() string { return ProviderOllama }
+
+// Model 默认模型
+func (p *Ollama) Model() string { return p.model }
+
+// Capabilities 服务支持的能力，是否支持图片取决于具体模型
+func (p *Ollama) Capabilities() Capabilities {
+	return Capabilities{Vision: true, JSONMode: true, JSONSchema: true, Streaming: true, Tools: true}
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+}
+
+type ollamaTool struct {
+	Type     string         `json:"type"`
+	Function ollamaFunction `json:"function"`
+}
+
+type ollamaFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  json.Marshaler `json:"parameters,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// Chat 发送对话请求
+func (p *Ollama) Chat(ctx context.Context, request Request) (*Response, error) {
+	body, err := p.buildRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := postJSON(ctx, ProviderOllama, joinURL(p.baseURL, "/api/chat"), p.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	var out ollamaResponse
+	if err := decodeJSON(ProviderOllama, resp, &out); err != nil {
+		return nil, err
+	}
+	if out.Error != "" {
+		return nil, core.NewError(core.ErrLLMUnavailable, "ollama返回错误: "+out.Error, nil)
+	}
+	if out.Message.Content == "" && len(out.Message.ToolCalls) == 0 {
+		return nil, emptyResponse(ProviderOllama)
+	}
+	result := &Response{
+		Content:      out.Message.Content,
+		Model:        out.Model,
+		FinishReason: out.DoneReason,
+		Usage:        Usage{InputTokens: out.PromptEvalCount, OutputTokens: out.EvalCount},
+	}
+	// Ollama不返回调用ID，按顺序生成
+	for i, call := range out.Message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", i+1),
+			Name:      call.Function.Name,
+			Arguments: string(call.Function.Arguments),
+		})
+	}
+	return result, nil
+}
+
+// Stream 流式发送对话请求，响应为每行一个JSON对象
+func (p *Ollama) Stream(ctx context.Context, request Request, handler StreamHandler) (*Response, error) {
+	if len(request.Tools) > 0 {
+		return nil, errToolsStreaming(ProviderOllama)
+	}
+	body, err := p.buildRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	body.Stream = true
+	resp, err := postJSON(ctx, ProviderOllama, joinURL(p.baseURL, "/api/chat"), p.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Response{}
+	var content strings.Builder
+	err = readNDJSON(resp.Body, func(line []byte) error {
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return core.NewError(core.ErrLLMInvalidOutput, "解析ollama流式响应失败", err)
+		}
+		if chunk.Error != "" {
+			return core.NewError(core.ErrLLMUnavailable, "ollama返回错误: "+chunk.Error, nil)
+		}
+		result.Model = chunk.Model
+		if chunk.Done {
+			result.FinishReason = chunk.DoneReason
+			result.Usage = Usage{InputTokens: chunk.PromptEvalCount, OutputTokens: chunk.EvalCount}
+		}
+		if delta := chunk.Message.Content; delta != "" {
+			content.WriteString(delta)
+			return handler(delta)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content.Len() == 0 {
+		return nil, emptyResponse(ProviderOllama)
+	}
+	result.Content = content.String()
+	return result, nil
+}
+
+// buildRequest 构造请求，图片以base64传递
+func (p *Ollama) buildRequest(request Request) (*ollamaRequest, error) {
+	body := &ollamaRequest{Model: request.Model}
+	if body.Model == "" {
+		body.Model = p.model
+	}
+	if format := request.Format; format != nil {
+		if format.Type == FormatJSONSchema && format.Schema != nil {
+			schema, err := format.Schema.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			body.Format = schema
+		} else {
+			body.Format = json.RawMessage(`"json"`)
+		}
+	}
+	options := map[string]any{}
+	if request.Temperature > 0 {
+		options["temperature"] = request.Temperature
+	}
+	if request.MaxTokens > 0 {
+		options["num_predict"] = request.MaxTokens
+	}
+	if len(options) > 0 {
+		body.Options = options
+	}
+
+	for _, tool := range request.Tools {
+		body.Tools = append(body.Tools, ollamaTool{
+			Type:     "function",
+			Function: ollamaFunction{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+
+	for _, message := range request.Messages {
+		converted := ollamaMessage{Role: message.Role, Content: message.Content}
+		var images []string
+		for _, image := range message.Images {
+			images = append(images, base64.StdEncoding.EncodeToString(image.Data))
+		}
+		for _, call := range message.ToolCalls {
+			var toolCall ollamaToolCall
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = toolArguments(call.Arguments)
+			converted.ToolCalls = append(converted.ToolCalls, toolCall)
+		}
+		if message.Role == RoleTool {
+			converted.ToolName = message.ToolName
+			body.Messages = append(body.Messages, converted)
+			// 视觉模型只读取用户消息中的图片，工具结果的图片改为紧随其后的用户消息
+			if len(images) > 0 {
+				body.Messages = append(body.Messages, ollamaMessage{
+					Role:    RoleUser,
+					Content: fmt.Sprintf("%s的结果图片：", message.ToolName),
+					Images:  images,
+				})
+			}
+			continue
+		}
+		converted.Images = images
+		body.Messages = append(body.Messages, converted)
+	}
+	return body, nil
+}
+
+// headers 请求头，经反向代理访问时可能需要Token
+func (p *Ollama) headers() map[string]string {
+	if p.token == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + p.token}
+}
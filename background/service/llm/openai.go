@@ -0,0 +1,313 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"diandian/background/automation/core"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// formatLevel OpenAI兼容服务实际支持的结构化输出级别
+type formatLevel int
+
+const (
+	formatLevelSchema formatLevel = iota // 支持json_schema
+	formatLevelJSON                      // 只支持json_object
+	formatLevelNone                      // 不支持response_format，只能写入提示词
+)
+
+// OpenAI OpenAI兼容接口的服务
+// 兼容服务对response_format的支持参差不齐，被拒绝时自动降级并记住，之后的请求不再尝试
+type OpenAI struct {
+	client *openai.Client
+	model  string
+
+	mu    sync.Mutex
+	level formatLevel
+}
+
+// NewOpenAI 创建OpenAI兼容接口的服务
+func NewOpenAI(config Config) *OpenAI {
+	clientConfig := openai.DefaultConfig(config.Token)
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = config.BaseURL
+	}
+	return &OpenAI{client: openai.NewClientWithConfig(clientConfig), model: config.Model}
+}
+
+// Name 服务名称
+func (p *OpenAI) Name() string { return ProviderOpenAI }
+
+// Model 默认模型
+func (p *OpenAI) Model() string { return p.model }
+
+// Capabilities 服务支持的能力，按已知的降级情况返回
+func (p *OpenAI) Capabilities() Capabilities {
+	level := p.formatLevel()
+	return Capabilities{
+		Vision:     true,
+		JSONMode:   level <= formatLevelJSON,
+		JSONSchema: level == formatLevelSchema,
+		Streaming:  true,
+		Tools:      true,
+	}
+}
+
+// Chat 发送对话请求
+func (p *OpenAI) Chat(ctx context.Context, request Request) (*Response, error) {
+	for {
+		level := p.formatLevel()
+		req, err := p.buildRequest(request, level)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := p.client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			if p.downgrade(request.Format, level, err) {
+				continue
+			}
+			return nil, classifyOpenAIError(err)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, emptyResponse(ProviderOpenAI)
+		}
+		message := resp.Choices[0].Message
+		result := &Response{
+			Content:      message.Content,
+			Model:        resp.Model,
+			FinishReason: string(resp.Choices[0].FinishReason),
+			Usage:        Usage{InputTokens: resp.Usage.PromptTokens, OutputTokens: resp.Usage.CompletionTokens},
+		}
+		for _, call := range message.ToolCalls {
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		return result, nil
+	}
+}
+
+// Stream 流式发送对话请求
+func (p *OpenAI) Stream(ctx context.Context, request Request, handler StreamHandler) (*Response, error) {
+	if len(request.Tools) > 0 {
+		return nil, errToolsStreaming(ProviderOpenAI)
+	}
+	var stream *openai.ChatCompletionStream
+	for {
+		level := p.formatLevel()
+		req, err := p.buildRequest(request, level)
+		if err != nil {
+			return nil, err
+		}
+		req.Stream = true
+		stream, err = p.client.CreateChatCompletionStream(ctx, req)
+		if err != nil {
+			if p.downgrade(request.Format, level, err) {
+				continue
+			}
+			return nil, classifyOpenAIError(err)
+		}
+		break
+	}
+	defer stream.Close()
+
+	result := &Response{}
+	var content strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, classifyOpenAIError(err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = Usage{InputTokens: chunk.Usage.PromptTokens, OutputTokens: chunk.Usage.CompletionTokens}
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			result.FinishReason = string(choice.FinishReason)
+		}
+		if delta := choice.Delta.Content; delta != "" {
+			content.WriteString(delta)
+			if err := handler(delta); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if content.Len() == 0 {
+		return nil, emptyResponse(ProviderOpenAI)
+	}
+	result.Content = content.String()
+	return result, nil
+}
+
+// buildRequest 按支持级别构造请求
+func (p *OpenAI) buildRequest(request Request, level formatLevel) (openai.ChatCompletionRequest, error) {
+	messages := request.Messages
+	req := openai.ChatCompletionRequest{
+		Model:       request.Model,
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+	}
+	if req.Model == "" {
+		req.Model = p.model
+	}
+
+	if format := request.Format; format != nil {
+		switch {
+		case format.Type == FormatJSONSchema && level == formatLevelSchema:
+			req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+				JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+					Name:   format.Name,
+					Schema: format.Schema,
+					Strict: format.Strict,
+				},
+			}
+		case level <= formatLevelJSON:
+			req.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+			if format.Type == FormatJSONSchema {
+				instruction, err := formatInstruction(format)
+				if err != nil {
+					return req, err
+				}
+				messages = withInstruction(messages, instruction)
+			}
+		default:
+			instruction, err := formatInstruction(format)
+			if err != nil {
+				return req, err
+			}
+			messages = withInstruction(messages, instruction)
+		}
+	}
+
+	for _, tool := range request.Tools {
+		req.Tools = append(req.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	for _, message := range messages {
+		req.Messages = append(req.Messages, openAIMessages(message)...)
+	}
+	return req, nil
+}
+
+// downgrade 服务拒绝response_format时降级，返回是否需要重试
+func (p *OpenAI) downgrade(format *Format, level formatLevel, err error) bool {
+	if format == nil || level == formatLevelNone || !rejectsFormat(err) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.level == level {
+		if format.Type == FormatJSONSchema && level == formatLevelSchema {
+			p.level = formatLevelJSON
+		} else {
+			p.level = formatLevelNone
+		}
+	}
+	return true
+}
+
+// formatLevel 当前的结构化输出支持级别
+func (p *OpenAI) formatLevel() formatLevel {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.level
+}
+
+// rejectsFormat 错误是否由不支持的response_format引起
+func rejectsFormat(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode != 400 && apiErr.HTTPStatusCode != 422 {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "response_format") || strings.Contains(message, "json_schema")
+}
+
+// openAIMessages 转换消息
+// tool消息不能携带图片，工具结果中的图片改为紧随其后的用户消息
+func openAIMessages(message Message) []openai.ChatCompletionMessage {
+	switch message.Role {
+	case RoleTool:
+		result := []openai.ChatCompletionMessage{{
+			Role:       openai.ChatMessageRoleTool,
+			Content:    message.Content,
+			ToolCallID: message.ToolCallID,
+		}}
+		if len(message.Images) > 0 {
+			images := Message{Role: RoleUser, Content: fmt.Sprintf("%s的结果图片：", message.ToolName), Images: message.Images}
+			result = append(result, openAIMessage(images))
+		}
+		return result
+	case RoleAssistant:
+		if len(message.ToolCalls) > 0 {
+			converted := openai.ChatCompletionMessage{Role: message.Role, Content: message.Content}
+			for _, call := range message.ToolCalls {
+				converted.ToolCalls = append(converted.ToolCalls, openai.ToolCall{
+					ID:   call.ID,
+					Type: openai.ToolTypeFunction,
+					Function: openai.FunctionCall{
+						Name:      call.Name,
+						Arguments: string(toolArguments(call.Arguments)),
+					},
+				})
+			}
+			return []openai.ChatCompletionMessage{converted}
+		}
+	}
+	return []openai.ChatCompletionMessage{openAIMessage(message)}
+}
+
+// openAIMessage 转换普通消息，图片以data URL传递
+func openAIMessage(message Message) openai.ChatCompletionMessage {
+	if len(message.Images) == 0 {
+		return openai.ChatCompletionMessage{Role: message.Role, Content: message.Content}
+	}
+	parts := []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: message.Content}}
+	for _, image := range message.Images {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{
+				URL: fmt.Sprintf("data:%s;base64,%s", image.MIME(), base64.StdEncoding.EncodeToString(image.Data)),
+			},
+		})
+	}
+	return openai.ChatCompletionMessage{Role: message.Role, MultiContent: parts}
+}
+
+// classifyOpenAIError 为go-openai的错误补充错误码，网络错误由调用方分类
+func classifyOpenAIError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return core.NewError(StatusCode(apiErr.HTTPStatusCode), "模型服务返回错误", err)
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) && requestErr.HTTPStatusCode > 0 {
+		return core.NewError(StatusCode(requestErr.HTTPStatusCode), "模型服务请求失败", err)
+	}
+	return err
+}
@@ -0,0 +1,290 @@
+// Package llm 大模型服务的统一接口
+// 生成器和服务只依赖Provider接口，具体的模型服务（OpenAI兼容、Anthropic、Gemini、Ollama）由设置决定，
+// 各服务在图片传递、结构化输出和流式输出上的差异都在各自的实现中处理
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"diandian/background/automation/core"
+)
+
+// 支持的模型服务
+const (
+	ProviderOpenAI    = "openai"    // OpenAI及兼容OpenAI接口的服务（DeepSeek、通义千问、vLLM等）
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+	ProviderGemini    = "gemini"    // Google Gemini API
+	ProviderOllama    = "ollama"    // 本地Ollama
+	ProviderFake      = "fake"      // 进程内的假模型，用于测试
+)
+
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool" // 工具调用的结果
+)
+
+// FormatType 结构化输出的类型
+type FormatType string
+
+const (
+	FormatJSON       FormatType = "json_object" // 输出任意JSON对象
+	FormatJSONSchema FormatType = "json_schema" // 输出符合指定JSON Schema的对象
+)
+
+// Image 消息中的图片
+type Image struct {
+	Data     []byte
+	MimeType string // 为空时根据内容识别
+}
+
+// MIME 图片的MIME类型
+func (i Image) MIME() string {
+	if i.MimeType != "" {
+		return i.MimeType
+	}
+	if mimeType := http.DetectContentType(i.Data); strings.HasPrefix(mimeType, "image/") {
+		return mimeType
+	}
+	return "image/png"
+}
+
+// Message 对话消息，Images不为空时需要模型支持视觉输入
+type Message struct {
+	Role      string
+	Content   string
+	Images    []Image
+	ToolCalls []ToolCall // 模型回复中的工具调用，Role为assistant时有效
+
+	ToolCallID string // 对应的工具调用ID，Role为tool时有效
+	ToolName   string // 对应的工具名称，Role为tool时有效
+}
+
+// SystemMessage 系统消息
+func SystemMessage(content string) Message {
+	return Message{Role: RoleSystem, Content: content}
+}
+
+// UserMessage 用户消息，可以附带图片
+func UserMessage(content string, images ...[]byte) Message {
+	message := Message{Role: RoleUser, Content: content}
+	for _, data := range images {
+		message.Images = append(message.Images, Image{Data: data})
+	}
+	return message
+}
+
+// AssistantMessage 模型回复
+func AssistantMessage(content string) Message {
+	return Message{Role: RoleAssistant, Content: content}
+}
+
+// ToolMessage 工具调用的结果，可以附带图片（如操作后的截图）
+// 不支持在工具结果中放图片的服务由实现改为紧随其后的用户消息
+func ToolMessage(callID, name, content string, images ...[]byte) Message {
+	message := Message{Role: RoleTool, Content: content, ToolCallID: callID, ToolName: name}
+	for _, data := range images {
+		message.Images = append(message.Images, Image{Data: data})
+	}
+	return message
+}
+
+// Tool 提供给模型调用的工具
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.Marshaler // 参数的JSON Schema，顶层必须是object
+}
+
+// ToolCall 模型发起的一次工具调用
+type ToolCall struct {
+	ID        string // 部分服务不返回ID，由实现生成
+	Name      string
+	Arguments string // JSON对象形式的参数
+	Signature string // 需要随调用原样传回的签名，如Gemini的thoughtSignature
+}
+
+// Format 结构化输出要求
+type Format struct {
+	Type   FormatType
+	Name   string         // Schema名称，部分服务要求提供
+	Schema json.Marshaler // JSON Schema，Type为FormatJSONSchema时必填
+	Strict bool           // 是否要求严格遵守Schema，不支持严格模式的服务忽略
+}
+
+// JSONFormat 输出任意JSON对象
+func JSONFormat() *Format {
+	return &Format{Type: FormatJSON}
+}
+
+// SchemaFormat 输出符合Schema的JSON对象
+func SchemaFormat(name string, schema json.Marshaler, strict bool) *Format {
+	return &Format{Type: FormatJSONSchema, Name: name, Schema: schema, Strict: strict}
+}
+
+// Request 对话请求
+type Request struct {
+	Model       string // 为空时使用创建Provider时配置的模型
+	Messages    []Message
+	Format      *Format // 为nil时输出普通文本
+	MaxTokens   int     // 为0时使用服务的默认值
+	Temperature float32 // 为0时使用服务的默认值
+	Tools       []Tool  // 可供调用的工具，需要服务支持工具调用；不能与流式输出同时使用
+}
+
+// Usage token用量
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Response 对话结果
+type Response struct {
+	Content      string
+	ToolCalls    []ToolCall // 模型要求调用的工具，此时Content可能为空
+	Model        string
+	FinishReason string
+	Usage        Usage
+}
+
+// Capabilities 模型服务支持的能力
+type Capabilities struct {
+	Vision     bool // 支持图片输入
+	JSONMode   bool // 原生支持输出JSON对象
+	JSONSchema bool // 原生支持按JSON Schema约束输出，不支持时Schema写入提示词
+	Streaming  bool // 支持流式输出
+	Tools      bool // 支持工具调用
+}
+
+// StreamHandler 流式输出的回调，每收到一段文字调用一次，返回错误时中止请求
+type StreamHandler func(delta string) error
+
+// Provider 大模型服务
+type Provider interface {
+	// Name 服务名称，如openai、anthropic
+	Name() string
+	// Model 默认使用的模型
+	Model() string
+	// Capabilities 服务支持的能力
+	Capabilities() Capabilities
+	// Chat 发送对话请求，返回完整的回复
+	Chat(ctx context.Context, request Request) (*Response, error)
+	// Stream 发送对话请求并流式返回回复，结束后返回完整的回复
+	Stream(ctx context.Context, request Request, handler StreamHandler) (*Response, error)
+}
+
+// Config 模型服务配置
+type Config struct {
+	Provider string // 服务类型，为空时视为openai
+	BaseURL  string // 为空时使用服务的默认地址
+	Token    string
+	Model    string
+}
+
+// New 按配置创建模型服务
+func New(config Config) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(config.Provider)) {
+	case "", ProviderOpenAI:
+		return NewOpenAI(config), nil
+	case ProviderAnthropic:
+		return NewAnthropic(config), nil
+	case ProviderGemini:
+		return NewGemini(config), nil
+	case ProviderOllama:
+		return NewOllama(config), nil
+	default:
+		return nil, core.NewError(core.ErrNotConfigured, fmt.Sprintf("不支持的模型服务: %s", config.Provider), nil)
+	}
+}
+
+// RequiresToken 服务是否需要Token，本地Ollama不需要
+func RequiresToken(provider string) bool {
+	return !strings.EqualFold(strings.TrimSpace(provider), ProviderOllama)
+}
+
+// StatusCode 根据HTTP状态码确定错误码
+func StatusCode(status int) core.ErrorCode {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return core.ErrLLMRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return core.ErrPermissionDenied
+	case status == http.StatusNotFound:
+		return core.ErrNotFound
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return core.ErrInvalidArgument
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return core.ErrTimeout
+	default:
+		return core.ErrLLMUnavailable
+	}
+}
+
+// toolArguments 工具参数的JSON文本，为空时视为空对象
+func toolArguments(arguments string) json.RawMessage {
+	if strings.TrimSpace(arguments) == "" {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// errToolsStreaming 请求同时要求流式输出和工具调用
+func errToolsStreaming(provider string) error {
+	return core.NewError(core.ErrUnsupported, fmt.Sprintf("%s不支持在流式输出中调用工具", provider), nil)
+}
+
+// formatInstruction 不支持原生结构化输出的服务将输出要求写入提示词
+func formatInstruction(format *Format) (string, error) {
+	if format == nil {
+		return "", nil
+	}
+	instruction := "只输出一个JSON对象，不要使用markdown代码块，也不要输出其他内容。"
+	if format.Type == FormatJSONSchema && format.Schema != nil {
+		schema, err := format.Schema.MarshalJSON()
+		if err != nil {
+			return "", fmt.Errorf("序列化JSON Schema失败: %w", err)
+		}
+		instruction += "\nJSON必须符合以下JSON Schema：\n" + string(schema)
+	}
+	return instruction, nil
+}
+
+// withInstruction 将输出要求追加到系统消息，没有系统消息时插入一条
+func withInstruction(messages []Message, instruction string) []Message {
+	if instruction == "" {
+		return messages
+	}
+	result := make([]Message, 0, len(messages)+1)
+	appended := false
+	for _, message := range messages {
+		if message.Role == RoleSystem && !appended {
+			message.Content = strings.TrimSpace(message.Content + "\n\n" + instruction)
+			appended = true
+		}
+		result = append(result, message)
+	}
+	if !appended {
+		result = append([]Message{SystemMessage(instruction)}, result...)
+	}
+	return result
+}
+
+// splitSystem 拆分系统消息和对话消息，多条系统消息合并为一条
+func splitSystem(messages []Message) (string, []Message) {
+	var system []string
+	var rest []Message
+	for _, message := range messages {
+		if message.Role == RoleSystem {
+			system = append(system, message.Content)
+			continue
+		}
+		rest = append(rest, message)
+	}
+	return strings.Join(system, "\n\n"), rest
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"diandian/background/automation/core"
+)
+
+// clickTool 测试用的工具定义
+var clickTool = Tool{
+	Name:        "click",
+	Description: "点击屏幕上的位置",
+	Parameters:  json.RawMessage(`{"type":"object","properties":{"x":{"type":"integer"},"y":{"type":"integer"}},"required":["x","y"]}`),
+}
+
+func TestToolCallParsing(t *testing.T) {
+	tests := []struct {
+		provider string
+		path     string
+		response string
+		want     ToolCall
+	}{
+		{
+			provider: ProviderOpenAI,
+			path:     "/chat/completions",
+			response: `{"model":"gpt-4o","choices":[{"finish_reason":"tool_calls","message":{"role":"assistant",
+				"tool_calls":[{"id":"call_abc","type":"function","function":{"name":"click","arguments":"{\"x\":10,\"y\":20}"}}]}}]}`,
+			want: ToolCall{ID: "call_abc", Name: "click", Arguments: `{"x":10,"y":20}`},
+		},
+		{
+			provider: ProviderAnthropic,
+			path:     "/v1/messages",
+			response: `{"model":"claude","stop_reason":"tool_use","content":[{"type":"text","text":"我来点击"},
+				{"type":"tool_use","id":"toolu_1","name":"click","input":{"x":10,"y":20}}]}`,
+			want: ToolCall{ID: "toolu_1", Name: "click", Arguments: `{"x":10,"y":20}`},
+		},
+		{
+			provider: ProviderGemini,
+			path:     "/models/gemini-2.5-flash:generateContent",
+			response: `{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[
+				{"functionCall":{"name":"click","args":{"x":10,"y":20}},"thoughtSignature":"sig"}]}}]}`,
+			want: ToolCall{ID: "call_1", Name: "click", Arguments: `{"x":10,"y":20}`, Signature: "sig"},
+		},
+		{
+			provider: ProviderOllama,
+			path:     "/api/chat",
+			response: `{"model":"qwen3","done":true,"message":{"role":"assistant","content":"",
+				"tool_calls":[{"function":{"name":"click","arguments":{"x":10,"y":20}}}]}}`,
+			want: ToolCall{ID: "call_1", Name: "click", Arguments: `{"x":10,"y":20}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("请求路径 = %s，期望 %s", r.URL.Path, tt.path)
+				}
+				data, _ := io.ReadAll(r.Body)
+				body = string(data)
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			provider, err := New(Config{Provider: tt.provider, BaseURL: server.URL, Token: "test", Model: "gemini-2.5-flash"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := provider.Chat(context.Background(), Request{
+				Messages: []Message{UserMessage("点击确定按钮")},
+				Tools:    []Tool{clickTool},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body, `"click"`) || !strings.Contains(body, "点击屏幕上的位置") {
+				t.Errorf("请求中没有工具定义: %s", body)
+			}
+			if len(resp.ToolCalls) != 1 {
+				t.Fatalf("工具调用 = %v，期望 1 个", resp.ToolCalls)
+			}
+			got := resp.ToolCalls[0]
+			var args struct{ X, Y int }
+			if err := json.Unmarshal([]byte(got.Arguments), &args); err != nil || args.X != 10 || args.Y != 20 {
+				t.Errorf("参数 = %s，期望 x=10 y=20", got.Arguments)
+			}
+			got.Arguments = tt.want.Arguments
+			if got != tt.want {
+				t.Errorf("工具调用 = %+v，期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeToolCalls(t *testing.T) {
+	fake := NewFake()
+	fake.PushResponse(
+		Response{ToolCalls: []ToolCall{{ID: "1", Name: "click", Arguments: `{"x":1,"y":2}`}}},
+		Response{Content: "完成"},
+	)
+	request := Request{Messages: []Message{UserMessage("点击")}, Tools: []Tool{clickTool}}
+
+	resp, err := fake.Chat(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.FinishReason != "tool_calls" || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "click" {
+		t.Errorf("第一次回复 = %+v，期望调用click", resp)
+	}
+	if resp, err = fake.Chat(context.Background(), request); err != nil || resp.Content != "完成" || resp.FinishReason != "stop" {
+		t.Errorf("第二次回复 = %+v, %v", resp, err)
+	}
+	if _, err := fake.Chat(context.Background(), request); core.CodeOf(err) != core.ErrLLMUnavailable {
+		t.Errorf("回复用完后错误码 = %s，期望 llm_unavailable", core.CodeOf(err))
+	}
+	if len(fake.Requests()) != 3 {
+		t.Errorf("记录的请求 = %d，期望 3", len(fake.Requests()))
+	}
+
+	if _, err := fake.Stream(context.Background(), request, func(string) error { return nil }); core.CodeOf(err) != core.ErrUnsupported {
+		t.Errorf("流式输出中调用工具错误码 = %s，期望 unsupported", core.CodeOf(err))
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := map[int]core.ErrorCode{
+		http.StatusTooManyRequests:     core.ErrLLMRateLimited,
+		http.StatusUnauthorized:        core.ErrPermissionDenied,
+		http.StatusBadRequest:          core.ErrInvalidArgument,
+		http.StatusGatewayTimeout:      core.ErrTimeout,
+		http.StatusInternalServerError: core.ErrLLMUnavailable,
+	}
+	for status, want := range tests {
+		if got := StatusCode(status); got != want {
+			t.Errorf("StatusCode(%d) = %s，期望 %s", status, got, want)
+		}
+	}
+}
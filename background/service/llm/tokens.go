@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 每条消息的格式开销（角色、分隔符等）和每张图片按中等分辨率估计的token数
+const (
+	messageOverhead = 4
+	imageTokens     = 1000
+)
+
+// tokenRate 模型分词器的粗略换算比例
+type tokenRate struct {
+	cjk   float64 // 每个中日韩字符对应的token数
+	chars float64 // 其他文字每个token对应的字节数
+}
+
+// tokenRates 按模型名称前缀匹配的换算比例，先匹配的优先
+// 没有内置分词器，按各家分词器对中英文的平均压缩率估算，宁可偏多不要偏少
+var tokenRates = []struct {
+	prefix string
+	rate   tokenRate
+}{
+	{"gpt-4o", tokenRate{cjk: 0.8, chars: 4}},
+	{"gpt-4.1", tokenRate{cjk: 0.8, chars: 4}},
+	{"gpt-5", tokenRate{cjk: 0.8, chars: 4}},
+	{"o1", tokenRate{cjk: 0.8, chars: 4}},
+	{"o3", tokenRate{cjk: 0.8, chars: 4}},
+	{"o4", tokenRate{cjk: 0.8, chars: 4}},
+	{"gpt-", tokenRate{cjk: 1.2, chars: 4}},
+	{"claude", tokenRate{cjk: 1.3, chars: 3.5}},
+	{"gemini", tokenRate{cjk: 0.7, chars: 4}},
+	{"gemma", tokenRate{cjk: 0.7, chars: 4}},
+	{"qwen", tokenRate{cjk: 0.7, chars: 4}},
+	{"deepseek", tokenRate{cjk: 0.7, chars: 4}},
+	{"glm", tokenRate{cjk: 0.7, chars: 4}},
+	{"moonshot", tokenRate{cjk: 0.7, chars: 4}},
+	{"kimi", tokenRate{cjk: 0.7, chars: 4}},
+	{"llama", tokenRate{cjk: 1.5, chars: 4}},
+}
+
+// defaultTokenRate 未知模型使用较保守的比例
+var defaultTokenRate = tokenRate{cjk: 1.3, chars: 3.5}
+
+// rateFor 模型对应的换算比例，忽略服务商前缀（如openai/gpt-4o、models/gemini-2.0）
+func rateFor(model string) tokenRate {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, entry := range tokenRates {
+		if strings.HasPrefix(name, entry.prefix) {
+			return entry.rate
+		}
+	}
+	return defaultTokenRate
+}
+
+// CountTokens 估算文本在指定模型下的token数
+func CountTokens(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	rate := rateFor(model)
+	cjk, other := 0, 0
+	for _, r := range text {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	tokens := float64(cjk)*rate.cjk + float64(other)/rate.chars
+	return int(tokens) + 1
+}
+
+// CountMessageTokens 估算一条消息在指定模型下的token数，包括图片和工具调用
+func CountMessageTokens(model string, message Message) int {
+	tokens := messageOverhead + CountTokens(model, message.Content)
+	tokens += len(message.Images) * imageTokens
+	for _, call := range message.ToolCalls {
+		tokens += CountTokens(model, call.Name) + CountTokens(model, call.Arguments)
+	}
+	return tokens
+}
+
+// CountMessagesTokens 估算一组消息在指定模型下的token数
+func CountMessagesTokens(model string, messages []Message) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += CountMessageTokens(model, message)
+	}
+	return tokens
+}
+
+// isCJK 是否是中日韩文字或全角标点，这些字符通常单独成为一个或多个token
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF)
+}
@@ -0,0 +1,49 @@
+package llm
+
+import "testing"
+
+func TestCountTokens(t *testing.T) {
+	if got := CountTokens("gpt-4o", ""); got != 0 {
+		t.Errorf("空文本 = %d，期望 0", got)
+	}
+	// 400字节英文按每token 4字节计算
+	if got := CountTokens("gpt-4o", string(make([]byte, 400))); got != 101 {
+		t.Errorf("400字节英文 = %d，期望 101", got)
+	}
+
+	chinese := "打开记事本并输入今天的工作总结"
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"gpt-4o-mini", 13},       // 15 * 0.8
+		{"openai/gpt-4o", 13},     // 忽略服务商前缀
+		{"claude-sonnet-4", 20},   // 15 * 1.3
+		{"models/gemini-2.5", 11}, // 15 * 0.7
+		{"unknown-model", 20},     // 未知模型使用保守比例
+	}
+	for _, tt := range tests {
+		if got := CountTokens(tt.model, chinese); got != tt.want {
+			t.Errorf("CountTokens(%q) = %d，期望 %d", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestCountMessageTokens(t *testing.T) {
+	text := CountMessageTokens("gpt-4o", UserMessage("hello"))
+	if text != messageOverhead+CountTokens("gpt-4o", "hello") {
+		t.Errorf("文本消息 = %d", text)
+	}
+	if got := CountMessageTokens("gpt-4o", UserMessage("hello", []byte{1}, []byte{2})); got != text+2*imageTokens {
+		t.Errorf("带两张图片的消息 = %d，期望 %d", got, text+2*imageTokens)
+	}
+
+	call := Message{Role: RoleAssistant, ToolCalls: []ToolCall{{Name: "click", Arguments: `{"x":1,"y":2}`}}}
+	want := messageOverhead + CountTokens("gpt-4o", "click") + CountTokens("gpt-4o", `{"x":1,"y":2}`)
+	if got := CountMessageTokens("gpt-4o", call); got != want {
+		t.Errorf("工具调用消息 = %d，期望 %d", got, want)
+	}
+	if got := CountMessagesTokens("gpt-4o", []Message{UserMessage("hello"), call}); got != text+want {
+		t.Errorf("多条消息 = %d，期望 %d", got, text+want)
+	}
+}
@@ -2,17 +2,20 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 
+	"diandian/background/automation/core"
 	"diandian/background/constant"
 	"diandian/background/database"
 	"diandian/background/domain"
 	"diandian/background/model"
+	"diandian/background/service/llm"
 	"diandian/background/service/operation"
 
-	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
@@ -40,140 +43,37 @@ type AutomationTaskResponse struct {
 	NeedsConfirm bool     `json:"needs_confirm"` // 是否需要用户确认
 }
 
-// TextModelConfig 文本模型配置
-type TextModelConfig struct {
-	BaseURL string
-	Token   string
-	Model   string
-}
-
-// VisionModelConfig 视觉模型配置
-type VisionModelConfig struct {
-	BaseURL string
-	Token   string
-	Model   string
-}
-
 // GetTextModelConfig 获取文本模型配置 (公开方法用于测试)
-func (s *LLMService) GetTextModelConfig() (*TextModelConfig, error) {
-	return s.getTextModelConfig()
-}
-
-// 获取文本模型配置
-func (s *LLMService) getTextModelConfig() (*TextModelConfig, error) {
-	var settings []*model.Setting
-	err := database.DB.Where("key IN ?", []string{
-		model.SettingKeyLlmTextBaseUrl,
-		model.SettingKeyLlmTextToken,
-		model.SettingKeyLlmTextModel,
-	}).Find(&settings).Error
-
-	if err != nil {
-		return nil, fmt.Errorf("获取文本模型配置失败: %v", err)
-	}
-
-	config := &TextModelConfig{}
-	for _, setting := range settings {
-		if setting.Value == nil {
-			continue
-		}
-		switch setting.Key {
-		case model.SettingKeyLlmTextBaseUrl:
-			config.BaseURL = *setting.Value
-		case model.SettingKeyLlmTextToken:
-			config.Token = *setting.Value
-		case model.SettingKeyLlmTextModel:
-			config.Model = *setting.Value
-		}
-	}
-
-	if config.Token == "" || config.Model == "" {
-		return nil, fmt.Errorf("文本模型配置不完整")
-	}
-
-	return config, nil
+func (s *LLMService) GetTextModelConfig() (*operation.TextModelConfig, error) {
+	return operation.GetTextModelConfig()
 }
 
 // GetVisionModelConfig 获取视觉模型配置 (公开方法用于测试)
-func (s *LLMService) GetVisionModelConfig() (*VisionModelConfig, error) {
-	return s.getVisionModelConfig()
+func (s *LLMService) GetVisionModelConfig() (*operation.VisionModelConfig, error) {
+	return operation.GetVisionModelConfig()
 }
 
-// 获取视觉模型配置
-func (s *LLMService) getVisionModelConfig() (*VisionModelConfig, error) {
-	var settings []*model.Setting
-	err := database.DB.Where("key IN ?", []string{
-		model.SettingKeyLlmVlBaseUrl,
-		model.SettingKeyLlmVlToken,
-		model.SettingKeyLlmVlModel,
-	}).Find(&settings).Error
-
-	if err != nil {
-		return nil, fmt.Errorf("获取视觉模型配置失败: %v", err)
-	}
-
-	config := &VisionModelConfig{}
-	for _, setting := range settings {
-		if setting.Value == nil {
-			continue
-		}
-		switch setting.Key {
-		case model.SettingKeyLlmVlBaseUrl:
-			config.BaseURL = *setting.Value
-		case model.SettingKeyLlmVlToken:
-			config.Token = *setting.Value
-		case model.SettingKeyLlmVlModel:
-			config.Model = *setting.Value
-		}
-	}
-
-	if config.Token == "" || config.Model == "" {
-		return nil, fmt.Errorf("视觉模型配置不完整")
-	}
-
-	return config, nil
+// CreateTextProvider 创建文本模型 (公开方法用于测试)
+func (s *LLMService) CreateTextProvider() (llm.Provider, error) {
+	return s.createTextProvider()
 }
 
-// CreateTextClient 创建文本模型客户端 (公开方法用于测试)
-func (s *LLMService) CreateTextClient() (*openai.Client, string, error) {
-	return s.createTextClient()
-}
-
-// 创建文本模型客户端
-func (s *LLMService) createTextClient() (*openai.Client, string, error) {
-	config, err := s.getTextModelConfig()
+// 创建文本模型，具体的模型服务由设置决定
+func (s *LLMService) createTextProvider() (llm.Provider, error) {
+	provider, err := operation.NewTextProvider()
 	if err != nil {
-		return nil, "", fmt.Errorf("获取文本模型配置失败: %v", err)
+		return nil, fmt.Errorf("获取文本模型配置失败: %w", err)
 	}
-
-	clientConfig := openai.DefaultConfig(config.Token)
-	if config.BaseURL != "" {
-		clientConfig.BaseURL = config.BaseURL
-	}
-
-	client := openai.NewClientWithConfig(clientConfig)
-	return client, config.Model, nil
+	return provider, nil
 }
 
-// CreateVisionClient 创建视觉模型客户端 (公开方法用于测试)
-func (s *LLMService) CreateVisionClient() (*openai.Client, string, error) {
-	return s.createVisionClient()
-}
-
-// 创建视觉模型客户端
-func (s *LLMService) createVisionClient() (*openai.Client, string, error) {
-	config, err := s.getVisionModelConfig()
+// CreateVisionProvider 创建视觉模型 (公开方法用于测试)
+func (s *LLMService) CreateVisionProvider() (llm.Provider, error) {
+	provider, _, err := operation.NewVisionProvider()
 	if err != nil {
-		return nil, "", fmt.Errorf("获取视觉模型配置失败: %v", err)
-	}
-
-	clientConfig := openai.DefaultConfig(config.Token)
-	if config.BaseURL != "" {
-		clientConfig.BaseURL = config.BaseURL
+		return nil, fmt.Errorf("获取视觉模型配置失败: %w", err)
 	}
-
-	client := openai.NewClientWithConfig(clientConfig)
-	return client, config.Model, nil
+	return provider, nil
 }
 
 // cleanMarkdownCodeBlock 清理markdown代码块标记和其他格式标记
@@ -235,21 +135,31 @@ func cleanMarkdownCodeBlock(content string) string {
 }
 
 // retryLLMCall 重试LLM调用的通用方法
+// 是否重试由错误码决定，鉴权失败、未配置等不可重试的错误直接返回；ctx取消时立即停止重试和退避等待
 func (s *LLMService) retryLLMCall(
+	ctx context.Context,
 	callFunc func() (string, error),
 	validateFunc func(content string) error,
 	maxRetries int,
-	operation string,
+	operationName string,
 ) (string, error) {
 	var lastErr error
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
+		// 调用方已取消时不再重试
+		if err := ctx.Err(); err != nil {
+			return "", fmt.Errorf("%s已取消: %w", operationName, err)
+		}
+
 		// 调用LLM
 		content, err := callFunc()
 		if err != nil {
-			lastErr = fmt.Errorf("LLM调用失败: %v", err)
-			if attempt < maxRetries {
-				fmt.Printf("🔄 %s第%d次尝试失败，重试中... 错误: %v\n", operation, attempt, err)
+			lastErr = operation.ClassifyLLMError(err)
+			if attempt < maxRetries && core.IsRetryable(lastErr) {
+				slog.Warn("模型调用失败，稍后重试", "operation", operationName, "attempt", attempt, "error", err)
+				if err := core.Sleep(ctx, core.RetryDelay(core.CodeOf(lastErr), attempt)); err != nil {
+					return "", fmt.Errorf("%s已取消: %w", operationName, err)
+				}
 				continue
 			}
 			break
@@ -261,9 +171,9 @@ func (s *LLMService) retryLLMCall(
 		// 验证内容
 		if validateFunc != nil {
 			if err := validateFunc(cleanedContent); err != nil {
-				lastErr = fmt.Errorf("内容验证失败: %v", err)
+				lastErr = operation.InvalidOutput("内容验证失败", err)
 				if attempt < maxRetries {
-					fmt.Printf("🔄 %s第%d次尝试验证失败，重试中... 错误: %v\n", operation, attempt, err)
+					slog.Warn("模型输出验证失败，重试", "operation", operationName, "attempt", attempt, "error", err)
 					continue
 				}
 				break
@@ -272,81 +182,47 @@ func (s *LLMService) retryLLMCall(
 
 		// 成功
 		if attempt > 1 {
-			fmt.Printf("✅ %s在第%d次尝试后成功\n", operation, attempt)
+			slog.Info("模型调用重试成功", "operation", operationName, "attempt", attempt)
 		}
 		return cleanedContent, nil
 	}
 
-	return "", fmt.Errorf("%s在%d次尝试后仍然失败，最后错误: %v", operation, maxRetries, lastErr)
+	return "", fmt.Errorf("%s失败: %w", operationName, lastErr)
 }
 
 // 简单的文本聊天接口
 func (s *LLMService) SimpleChat(userMessage string) (string, error) {
-	client, model, err := s.createTextClient()
+	provider, err := s.createTextProvider()
 	if err != nil {
 		return "", err
 	}
 
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: userMessage,
-				},
-			},
-			MaxTokens:   2000,
-			Temperature: 0.7,
-		},
-	)
-
+	resp, err := provider.Chat(context.Background(), llm.Request{
+		Messages:    []llm.Message{llm.UserMessage(userMessage)},
+		MaxTokens:   2000,
+		Temperature: 0.7,
+	})
 	if err != nil {
-		return "", fmt.Errorf("调用LLM失败: %v", err)
-	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("API返回空响应")
+		return "", fmt.Errorf("调用LLM失败: %w", operation.ClassifyLLMError(err))
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return resp.Content, nil
 }
 
 // 统一处理用户消息：同时进行聊天回复和任务判断
 func (s *LLMService) ProcessMessage(conversationID uint64) (*model.Message, *UnifiedMessageResponse, error) {
-	client, m, err := s.createTextClient()
-	if err != nil {
-		slog.Error("创建文本模型客户端失败", "error", err)
-		return nil, nil, err
-	}
-
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: constant.PromptAnalyzeUserMessage,
-		},
-	}
+	return s.ProcessMessageStream(context.Background(), conversationID, 0, nil)
+}
 
-	// 获取所有历史消息
-	var msgs []*model.Message
-	err = database.DB.Where("conversation_id = ?", conversationID).Order("created_at asc").Find(&msgs).Error
+// ProcessMessageStream 流式处理用户消息，回复中的chat_response字段每生成一段调用一次onDelta，onDelta为nil时不使用流式输出
+// messageID为预先分配的回复消息ID，为0时自动分配；ctx取消时停止生成，已生成的部分作为聊天回复保存
+func (s *LLMService) ProcessMessageStream(ctx context.Context, conversationID, messageID uint64, onDelta llm.StreamHandler) (*model.Message, *UnifiedMessageResponse, error) {
+	provider, err := s.createTextProvider()
 	if err != nil {
+		slog.Error("创建文本模型失败", "error", err)
 		return nil, nil, err
 	}
 
-	// 构造对话消息
-	for _, msg := range msgs {
-		role := openai.ChatMessageRoleUser
-		if msg.Role == model.MessageRoleAssistant {
-			role = openai.ChatMessageRoleAssistant
-		}
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    role,
-			Content: msg.Content,
-		})
-	}
-
 	var result UnifiedMessageResponse
 	schema, err := jsonschema.GenerateSchemaForType(result)
 	if err != nil {
@@ -354,80 +230,91 @@ func (s *LLMService) ProcessMessage(conversationID uint64) (*model.Message, *Uni
 		return nil, nil, err
 	}
 
-	slog.Debug("准备调用大模型消息处理API")
+	// 构造对话消息，历史超出上下文预算时较早的消息以摘要代替
+	messages := []llm.Message{llm.SystemMessage(constant.PromptAnalyzeUserMessage)}
+	reserved := llm.CountMessagesTokens(provider.Model(), messages) + schemaTokens(provider.Model(), schema)
+	history, err := s.conversationHistory(ctx, provider, conversationID, reserved)
+	if err != nil {
+		if ctx.Err() != nil {
+			return s.saveStoppedResponse(conversationID, messageID, "")
+		}
+		return nil, nil, err
+	}
+	messages = append(messages, history...)
 
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model:    m,
-			Messages: messages,
-			// MaxTokens:   1500,
-			// Temperature: 0.3,
-			ResponseFormat: &openai.ChatCompletionResponseFormat{
-				Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
-				JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
-					Name:   "UnifiedMessageResponse",
-					Schema: schema,
-					Strict: true,
-				},
-			},
-		},
-	)
+	slog.Debug("准备调用大模型消息处理API")
 
+	request := llm.Request{
+		Messages: messages,
+		Format:   llm.SchemaFormat("UnifiedMessageResponse", schema, true),
+	}
+	var resp *llm.Response
+	if onDelta == nil {
+		resp, err = provider.Chat(ctx, request)
+	} else {
+		// 回复是JSON结构，只把chat_response字段的内容推送给前端
+		chat := llm.NewFieldStream("chat_response", onDelta)
+		resp, err = provider.Stream(ctx, request, chat.Write)
+		if err != nil && ctx.Err() != nil {
+			slog.Info("用户停止了回复生成", "conversation_id", conversationID, "generated", len(chat.Value()))
+			return s.saveStoppedResponse(conversationID, messageID, chat.Value())
+		}
+	}
 	if err != nil {
 		slog.Error("调用消息处理API失败", "error", err)
-		return nil, nil, fmt.Errorf("调用消息处理API失败: %v", err)
+		return nil, nil, fmt.Errorf("调用消息处理API失败: %w", operation.ClassifyLLMError(err))
 	}
 
-	if len(resp.Choices) == 0 {
-		slog.Error("消息处理API返回空响应")
-		return nil, nil, fmt.Errorf("消息处理API返回空响应")
-	}
-
-	slog.Debug("消息处理API返回", "content", resp.Choices[0].Message.Content)
+	slog.Debug("消息处理API返回", "content", resp.Content)
 
 	// 清理markdown标记并解析JSON响应
-	cleanedContent := cleanMarkdownCodeBlock(resp.Choices[0].Message.Content)
+	cleanedContent := cleanMarkdownCodeBlock(resp.Content)
 	slog.Debug("清理后的内容", "cleaned_content", cleanedContent)
 
 	err = schema.Unmarshal(cleanedContent, &result)
 	if err != nil {
 		slog.Error("解析消息处理结果失败",
 			"error", err,
-			"raw_content", resp.Choices[0].Message.Content,
+			"raw_content", resp.Content,
 			"cleaned_content", cleanedContent)
-		return nil, nil, fmt.Errorf("解析消息处理结果失败: %v", err)
+		return nil, nil, operation.InvalidOutput("解析消息处理结果失败", err)
 	}
 
 	// 记录返回结果
-	msg := &model.Message{
-		ConversationID: conversationID,
-		Role:           model.MessageRoleAssistant,
-		Content:        resp.Choices[0].Message.Content,
-	}
-	database.DB.Create(msg)
+	msg := s.saveAssistantMessage(conversationID, messageID, resp.Content)
 
 	return msg, &result, nil
 }
 
-// 自动化任务分解响应结构（第一阶段：高级分解）
-type AutomationTaskDecomposition struct {
-	TaskType        string               `json:"task_type"`        // simple, composite, complex
-	Description     string               `json:"description"`      // 任务描述
-	Steps           []AutomationStepPlan `json:"steps"`            // 执行步骤计划
-	ExpectedOutcome string               `json:"expected_outcome"` // 预期结果
-	RiskLevel       string               `json:"risk_level"`       // low, medium, high
-	EstimatedTime   int                  `json:"estimated_time"`   // 预估时间(秒)
+// saveStoppedResponse 保存被用户停止的回复，已生成的部分作为聊天回复
+func (s *LLMService) saveStoppedResponse(conversationID, messageID uint64, partial string) (*model.Message, *UnifiedMessageResponse, error) {
+	result := &UnifiedMessageResponse{
+		MessageType:  "chat",
+		ChatResponse: strings.TrimRight(partial, " \n") + "\n\n（已停止生成）",
+		Explanation:  "用户停止了回复生成",
+	}
+	if strings.TrimSpace(partial) == "" {
+		result.ChatResponse = "（已停止生成）"
+	}
+	content, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.saveAssistantMessage(conversationID, messageID, string(content)), result, nil
 }
 
-// 自动化步骤计划（高级步骤，不包含具体参数）
-type AutomationStepPlan struct {
-	StepType               string `json:"step_type"`                // "click", "type", "launch_app", "file", "screenshot", "clipboard", "wait", "key_press"
-	Description            string `json:"description"`              // 步骤描述
-	RequiresScreenAnalysis bool   `json:"requires_screen_analysis"` // 是否需要屏幕分析
-	Context                string `json:"context"`                  // 上下文信息，用于第二阶段生成具体操作
-	Priority               int    `json:"priority"`                 // 优先级 1-10
-	Optional               bool   `json:"optional"`                 // 是否可选
+// saveAssistantMessage 保存模型的回复，messageID为0时自动分配
+func (s *LLMService) saveAssistantMessage(conversationID, messageID uint64, content string) *model.Message {
+	msg := &model.Message{
+		Base:           model.Base{ID: messageID},
+		ConversationID: conversationID,
+		Role:           model.MessageRoleAssistant,
+		Content:        content,
+	}
+	if err := database.DB.Create(msg).Error; err != nil {
+		slog.Error("保存回复消息失败", "error", err, "conversation_id", conversationID)
+	}
+	return msg
 }
 
 // ===== 第二阶段：具体操作定义 =====
@@ -525,21 +412,19 @@ type ActionRecommendation struct {
 }
 
 // 分析自动化任务并分解为具体步骤
-func (s *LLMService) DecomposeAutomationTask(conversationHistory []openai.ChatCompletionMessage) (*domain.AutomationTaskDecomposition, error) {
-	client, model, err := s.createTextClient()
+// capabilities不为nil时会告知模型当前引擎的能力，避免生成无法执行的步骤
+// ctx取消时中止进行中的模型调用
+func (s *LLMService) DecomposeAutomationTask(ctx context.Context, conversationHistory []llm.Message, capabilities *core.Capabilities) (*domain.AutomationTaskDecomposition, error) {
+	provider, err := s.createTextProvider()
 	if err != nil {
 		return nil, err
 	}
 
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: constant.PromptAutomationTaskDecomposition,
-		},
+	systemPrompt := constant.PromptAutomationTaskDecomposition
+	if capabilities != nil {
+		systemPrompt += "\n\n" + describeCapabilities(capabilities)
 	}
-
-	// 添加对话历史
-	messages = append(messages, conversationHistory...)
+	messages := []llm.Message{llm.SystemMessage(systemPrompt)}
 
 	var result domain.AutomationTaskDecomposition
 	schema, err := jsonschema.GenerateSchemaForType(result)
@@ -547,38 +432,29 @@ func (s *LLMService) DecomposeAutomationTask(conversationHistory []openai.ChatCo
 		return nil, fmt.Errorf("生成schema失败: %v", err)
 	}
 
+	// 添加对话历史，超出上下文预算时丢弃最早的消息
+	if budget := contextBudget(); budget > 0 {
+		reserved := llm.CountMessagesTokens(provider.Model(), messages) + schemaTokens(provider.Model(), schema)
+		conversationHistory = llm.FitHistory(provider.Model(), conversationHistory, budget-reserved)
+	}
+	messages = append(messages, conversationHistory...)
+
 	// 定义LLM调用函数
 	callFunc := func() (string, error) {
-		resp, err := client.CreateChatCompletion(
-			context.Background(),
-			openai.ChatCompletionRequest{
-				Model:    model,
-				Messages: messages,
-				ResponseFormat: &openai.ChatCompletionResponseFormat{
-					Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
-					JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
-						Name:   "AutomationTaskDecomposition",
-						Schema: schema,
-						Strict: true,
-					},
-				},
-			},
-		)
-
+		resp, err := provider.Chat(ctx, llm.Request{
+			Messages: messages,
+			Format:   llm.SchemaFormat("AutomationTaskDecomposition", schema, true),
+		})
 		if err != nil {
 			return "", err
 		}
 
-		if len(resp.Choices) == 0 {
-			return "", fmt.Errorf("API返回空响应")
-		}
-
-		return resp.Choices[0].Message.Content, nil
+		return resp.Content, nil
 	}
 
 	// 定义验证函数
 	validateFunc := func(content string) error {
-		var tempResult AutomationTaskDecomposition
+		var tempResult domain.AutomationTaskDecomposition
 		if err := schema.Unmarshal(content, &tempResult); err != nil {
 			// 记录原始LLM返回内容用于排查
 			slog.Error("任务分解JSON解析失败",
@@ -604,12 +480,12 @@ func (s *LLMService) DecomposeAutomationTask(conversationHistory []openai.ChatCo
 
 		// 验证每个步骤
 		for i, step := range tempResult.Steps {
-			if step.StepType == "" {
-				slog.Error("任务分解验证失败：步骤缺少step_type",
+			if step.Type == "" {
+				slog.Error("任务分解验证失败：步骤缺少type",
 					"step_index", i+1,
 					"step", step,
 					"content", content)
-				return fmt.Errorf("步骤%d缺少step_type字段", i+1)
+				return fmt.Errorf("步骤%d缺少type字段", i+1)
 			}
 			if step.Description == "" {
 				slog.Error("任务分解验证失败：步骤缺少description",
@@ -630,9 +506,9 @@ func (s *LLMService) DecomposeAutomationTask(conversationHistory []openai.ChatCo
 	}
 
 	// 使用重试机制调用LLM
-	content, err := s.retryLLMCall(callFunc, validateFunc, 3, "任务分解")
+	content, err := s.retryLLMCall(ctx, callFunc, validateFunc, 3, "任务分解")
 	if err != nil {
-		return nil, fmt.Errorf("任务分解失败: %v", err)
+		return nil, fmt.Errorf("任务分解失败: %w", err)
 	}
 
 	// 最终解析 - 再次清理确保万无一失
@@ -645,17 +521,77 @@ func (s *LLMService) DecomposeAutomationTask(conversationHistory []openai.ChatCo
 			"error", err,
 			"original_content", content,
 			"final_cleaned_content", finalCleanedContent)
-		return nil, fmt.Errorf("解析任务分解结果失败: %v", err)
+		return nil, operation.InvalidOutput("解析任务分解结果失败", err)
 	}
 
 	return &result, nil
 }
 
-// 分析屏幕截图
-func (s *LLMService) AnalyzeScreenshot(imageData []byte, analysisRequest string) (*domain.VisualAnalysisResponse, error) {
+// describeCapabilities 将引擎能力描述转换为任务分解提示词
+func describeCapabilities(caps *core.Capabilities) string {
+	var supported, unsupported []string
+	for stepType := range stepTypeOperations {
+		if StepTypeSupported(caps, stepType) {
+			supported = append(supported, stepType)
+		} else {
+			unsupported = append(unsupported, stepType)
+		}
+	}
+	sort.Strings(supported)
+	sort.Strings(unsupported)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "当前自动化引擎（%s）的能力：\n", caps.Engine)
+	fmt.Fprintf(&b, "- 可用的步骤类型：%s\n", strings.Join(supported, ", "))
+	if len(unsupported) > 0 {
+		fmt.Fprintf(&b, "- 不可用的步骤类型：%s（不要生成这些步骤）\n", strings.Join(unsupported, ", "))
+	}
+	if len(caps.Keys) > 0 {
+		fmt.Fprintf(&b, "- 支持的按键：%s，以及单个字母和数字\n", strings.Join(caps.Keys, ", "))
+	}
+	if len(caps.Modifiers) > 0 {
+		modifiers := make([]string, len(caps.Modifiers))
+		for i, modifier := range caps.Modifiers {
+			modifiers[i] = string(modifier)
+		}
+		fmt.Fprintf(&b, "- 支持的修饰键：%s\n", strings.Join(modifiers, ", "))
+	}
+	if caps.UnicodeText {
+		b.WriteString("- 文本输入：支持任意文本（包括中文）\n")
+	} else {
+		b.WriteString("- 文本输入：只能输入英文字母、数字、空格、Tab和换行，其他文本请通过剪贴板粘贴\n")
+	}
+	if caps.MultiMonitor {
+		b.WriteString("- 支持多显示器\n")
+	} else {
+		b.WriteString("- 只能操作主显示器\n")
+	}
+	b.WriteString("只能使用上述能力完成任务；如果任务无法完成，请在description中说明原因。")
+	return b.String()
+}
+
+// 分析屏幕截图，返回的坐标为截图的像素坐标
+func (s *LLMService) AnalyzeScreenshot(ctx context.Context, imageData []byte, analysisRequest string) (*domain.VisualAnalysisResponse, error) {
+	capture, err := core.NewScreenCapture(imageData, core.DisplayIndexVirtual, core.Rect{})
+	if err != nil {
+		return nil, core.NewError(core.ErrInvalidArgument, "截图数据无效", err)
+	}
+	return s.AnalyzeCapture(ctx, capture, analysisRequest)
+}
+
+// VisionCacheStats 视觉分析缓存的命中统计，未启用缓存时返回零值
+func (s *LLMService) VisionCacheStats() operation.VisionCacheStats {
+	if cache := operation.SharedVisionCache(); cache != nil {
+		return cache.Stats()
+	}
+	return operation.VisionCacheStats{}
+}
+
+// AnalyzeCapture 分析屏幕截图，返回的坐标为虚拟桌面坐标，可直接用于点击
+func (s *LLMService) AnalyzeCapture(ctx context.Context, capture *core.ScreenCapture, analysisRequest string) (*domain.VisualAnalysisResponse, error) {
 	generator := operation.NewVisionGenerator()
 
-	result, err := generator.Analyze(imageData, analysisRequest)
+	result, err := generator.AnalyzeCapture(ctx, capture, analysisRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -697,7 +633,7 @@ func (s *LLMService) AnalyzeScreenshot(imageData []byte, analysisRequest string)
 // ===== 第二阶段：具体操作生成方法 =====
 
 // 生成点击操作
-func (s *LLMService) GenerateClickOperation(contextInfo string, screenAnalysis *domain.VisualAnalysisResponse) (*domain.ClickOperation, error) {
+func (s *LLMService) GenerateClickOperation(ctx context.Context, contextInfo string, screenAnalysis *domain.VisualAnalysisResponse) (*domain.ClickOperation, error) {
 	generator := operation.NewClickGenerator()
 
 	// 简化转换：只传递必要信息
@@ -725,7 +661,7 @@ func (s *LLMService) GenerateClickOperation(contextInfo string, screenAnalysis *
 		}
 	}
 
-	result, err := generator.Generate(contextInfo, operationScreenAnalysis)
+	result, err := generator.Generate(ctx, contextInfo, operationScreenAnalysis)
 	if err != nil {
 		return nil, err
 	}
@@ -738,11 +674,41 @@ func (s *LLMService) GenerateClickOperation(contextInfo string, screenAnalysis *
 	}, nil
 }
 
+// GenerateMarkedClickOperation 在截图上为候选元素编号，由视觉模型选择要点击的编号
+func (s *LLMService) GenerateMarkedClickOperation(ctx context.Context, contextInfo string, capture *core.ScreenCapture, marks []operation.Mark) (*domain.ClickOperation, error) {
+	generator := operation.NewClickGenerator()
+
+	result, mark, err := generator.GenerateWithMarks(ctx, contextInfo, capture, marks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.ClickOperation{
+		X:         result.X,
+		Y:         result.Y,
+		Button:    result.Button,
+		Grounding: operation.GroundingMarks,
+		Mark:      mark.ID,
+		Label:     mark.Label,
+		Target: &domain.Coordinates{
+			X:      mark.Rect.X,
+			Y:      mark.Rect.Y,
+			Width:  mark.Rect.Width,
+			Height: mark.Rect.Height,
+		},
+	}, nil
+}
+
+// LocateElement 在放大的局部截图中精确定位目标，返回目标的虚拟桌面坐标范围和置信度
+func (s *LLMService) LocateElement(ctx context.Context, capture *core.ScreenCapture, target string) (core.Rect, float64, error) {
+	return operation.NewVisionGenerator().Locate(ctx, capture, target)
+}
+
 // 生成输入操作
-func (s *LLMService) GenerateTypeOperation(contextInfo string) (*domain.TypeOperation, error) {
+func (s *LLMService) GenerateTypeOperation(ctx context.Context, contextInfo string) (*domain.TypeOperation, error) {
 	generator := operation.NewTypeGenerator()
 
-	result, err := generator.Generate(contextInfo)
+	result, err := generator.Generate(ctx, contextInfo)
 	if err != nil {
 		return nil, err
 	}
@@ -754,10 +720,10 @@ func (s *LLMService) GenerateTypeOperation(contextInfo string) (*domain.TypeOper
 }
 
 // 生成文件操作
-func (s *LLMService) GenerateFileOperation(contextInfo string) (*domain.FileOperation, error) {
+func (s *LLMService) GenerateFileOperation(ctx context.Context, contextInfo string) (*domain.FileOperation, error) {
 	generator := operation.NewFileGenerator()
 
-	result, err := generator.Generate(contextInfo)
+	result, err := generator.Generate(ctx, contextInfo)
 	if err != nil {
 		return nil, err
 	}
@@ -770,3 +736,100 @@ func (s *LLMService) GenerateFileOperation(contextInfo string) (*domain.FileOper
 		Content:    result.Content,
 	}, nil
 }
+
+// VerifyStep 根据执行后的截图判断步骤的预期结果是否达成
+func (s *LLMService) VerifyStep(ctx context.Context, capture *core.ScreenCapture, stepPlan *domain.AutomationStepPlan, outcome string) (*domain.StepVerification, error) {
+	expected := stepPlan.ExpectedOutcome
+	if expected == "" {
+		expected = stepPlan.Description
+	}
+
+	result, err := operation.NewStepVerifier().Verify(ctx, capture, stepPlan.Description, expected, outcome)
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换回原类型
+	return &domain.StepVerification{
+		Achieved:    result.Achieved,
+		Observation: result.Observation,
+		Reason:      result.Reason,
+		Confidence:  result.Confidence,
+	}, nil
+}
+
+// ReplanAutomationTask 执行出现偏差后，根据当前截图重新规划剩余步骤
+// progress为已执行步骤及结果的说明，divergence为出现的偏差
+func (s *LLMService) ReplanAutomationTask(ctx context.Context, decomposition *domain.AutomationTaskDecomposition, progress, divergence string, capture *core.ScreenCapture, capabilities *core.Capabilities) (*domain.AutomationReplan, error) {
+	provider, image, err := operation.NewVisionProvider()
+	if err != nil {
+		return nil, err
+	}
+	prepared, err := operation.PrepareForVision(ctx, capture, image, operation.PurposeReplan)
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt := constant.PromptAutomationReplan
+	if capabilities != nil {
+		systemPrompt += "\n\n" + describeCapabilities(capabilities)
+	}
+	plan, err := json.Marshal(decomposition.Steps)
+	if err != nil {
+		return nil, fmt.Errorf("序列化原计划失败: %w", err)
+	}
+	request := fmt.Sprintf("任务目标：%s\n预期结果：%s\n\n原计划：\n%s\n\n已执行的步骤：\n%s\n\n出现的偏差：%s\n\n当前屏幕截图（%dx%d像素）：",
+		decomposition.Description, decomposition.ExpectedOutcome, plan, progress, divergence, prepared.Width, prepared.Height)
+	messages := []llm.Message{
+		llm.SystemMessage(systemPrompt),
+		llm.UserMessage(request, prepared.ImageData),
+	}
+
+	var result domain.AutomationReplan
+	schema, err := jsonschema.GenerateSchemaForType(result)
+	if err != nil {
+		return nil, fmt.Errorf("生成schema失败: %v", err)
+	}
+
+	callFunc := func() (string, error) {
+		resp, err := provider.Chat(ctx, llm.Request{
+			Messages: messages,
+			Format:   llm.SchemaFormat("AutomationReplan", schema, true),
+		})
+		if err != nil {
+			return "", err
+		}
+		return resp.Content, nil
+	}
+
+	validateFunc := func(content string) error {
+		result = domain.AutomationReplan{}
+		if err := schema.Unmarshal(content, &result); err != nil {
+			slog.Error("重新规划JSON解析失败", "error", err, "raw_content", content)
+			return fmt.Errorf("JSON schema验证失败: %v", err)
+		}
+		switch result.Status {
+		case domain.ReplanContinue:
+			if len(result.Steps) == 0 {
+				return fmt.Errorf("status为continue时steps不能为空")
+			}
+		case domain.ReplanDone, domain.ReplanImpossible:
+			result.Steps = nil
+		default:
+			return fmt.Errorf("未知的status: %s", result.Status)
+		}
+		for i, step := range result.Steps {
+			if step.Type == "" || step.Description == "" {
+				return fmt.Errorf("步骤%d缺少type或description字段", i+1)
+			}
+		}
+		return nil
+	}
+
+	if _, err := s.retryLLMCall(ctx, callFunc, validateFunc, 3, "重新规划"); err != nil {
+		return nil, err
+	}
+
+	slog.Info("重新规划完成", "status", result.Status, "steps_count", len(result.Steps), "reason", result.Reason)
+	return &result, nil
+}
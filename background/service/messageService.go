@@ -4,19 +4,34 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
 
 	"diandian/background/app"
+	"diandian/background/automation/core"
 	"diandian/background/constant"
 	"diandian/background/database"
 	"diandian/background/domain"
 	"diandian/background/model"
+	"diandian/background/service/llm"
+	"diandian/background/util"
 
-	"github.com/sashabaranov/go-openai"
 	"gorm.io/gorm"
 )
 
-type MessageService struct{}
+type MessageService struct {
+	running sync.Map // 任务ID -> 正在执行该任务的*AutomationService
+	streams sync.Map // 回复消息ID -> 停止生成该回复的context.CancelFunc
+}
+
+// MessageDelta 流式回复的增量内容，同一条回复的事件MessageID相同，结束时以相同ID发送完整的消息
+type MessageDelta struct {
+	MessageID      uint64 `json:"message_id,string"`
+	ConversationID uint64 `json:"conversation_id,string"`
+	Delta          string `json:"delta"`   // 本次新增的内容
+	Content        string `json:"content"` // 目前为止生成的全部内容
+}
 
 // 处理新消息
 func (s *MessageService) NewMessage(msg *model.Message) {
@@ -44,24 +59,32 @@ func (s *MessageService) processMessageAsync(msg *model.Message) {
 		return
 	}
 
-	assistantMsg, response, err := DefaultLLMService.ProcessMessage(msg.ConversationID)
+	// 预先分配回复的消息ID，前端据此拼接增量内容和停止生成
+	messageID := util.NextID()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.streams.Store(messageID, cancel)
+	defer func() {
+		s.streams.Delete(messageID)
+		cancel()
+	}()
+
+	var content strings.Builder
+	assistantMsg, response, err := DefaultLLMService.ProcessMessageStream(ctx, msg.ConversationID, messageID, func(delta string) error {
+		content.WriteString(delta)
+		app.EmitEvent(constant.EventMessageDelta, &MessageDelta{
+			MessageID:      messageID,
+			ConversationID: msg.ConversationID,
+			Delta:          delta,
+			Content:        content.String(),
+		})
+		return nil
+	})
 
 	if err != nil {
-		slog.Error("处理用户消息失败", "error", err, "conversation_id", msg.ConversationID, "message_content", msg.Content)
-
-		// 根据错误类型提供更具体的错误信息
-		var errorMsg string
-		if strings.Contains(err.Error(), "API") {
-			errorMsg = "AI服务暂时不可用，请稍后重试"
-		} else if strings.Contains(err.Error(), "解析") {
-			errorMsg = "AI响应格式异常，请重新发送消息"
-		} else if strings.Contains(err.Error(), "网络") || strings.Contains(err.Error(), "timeout") {
-			errorMsg = "网络连接异常，请检查网络后重试"
-		} else {
-			errorMsg = "系统错误，请稍后重试"
-		}
+		slog.Error("处理用户消息失败", "error", err, "code", core.CodeOf(err), "conversation_id", msg.ConversationID, "message_content", msg.Content)
 
-		s.sendErrorMessage(errorMsg)
+		// 根据错误码提供更具体的错误信息
+		s.sendErrorMessage(userErrorMessage(err))
 		return
 	}
 
@@ -80,6 +103,30 @@ func (s *MessageService) processMessageAsync(msg *model.Message) {
 	}
 }
 
+// 停止生成回复，messageID为message-delta事件中的消息ID，为空时停止所有正在生成的回复
+// 已生成的部分仍会作为回复保存并发送
+func (s *MessageService) CancelMessage(messageID string) bool {
+	if messageID == "" {
+		cancelled := false
+		s.streams.Range(func(_, value any) bool {
+			value.(context.CancelFunc)()
+			cancelled = true
+			return true
+		})
+		return cancelled
+	}
+	id, err := strconv.ParseUint(messageID, 10, 64)
+	if err != nil {
+		return false
+	}
+	value, ok := s.streams.Load(id)
+	if !ok {
+		return false
+	}
+	value.(context.CancelFunc)()
+	return true
+}
+
 // 处理自动化任务
 func (s *MessageService) handleAutomationTask(response *UnifiedMessageResponse, conversationID uint64) {
 	if response.AutomationTask == nil {
@@ -132,6 +179,28 @@ func (s *MessageService) sendMessage(msg *model.Message) {
 	app.EmitEvent(constant.EventMessageResponsed, msg)
 }
 
+// userErrorMessage 根据错误码生成面向用户的错误提示
+func userErrorMessage(err error) string {
+	switch core.CodeOf(err) {
+	case core.ErrLLMRateLimited:
+		return "AI服务请求过于频繁，请稍后重试"
+	case core.ErrLLMUnavailable:
+		return "AI服务暂时不可用，请稍后重试"
+	case core.ErrLLMInvalidOutput:
+		return "AI响应格式异常，请重新发送消息"
+	case core.ErrNetwork, core.ErrTimeout:
+		return "网络连接异常，请检查网络后重试"
+	case core.ErrNotConfigured:
+		return "AI模型尚未配置，请先在设置中完成配置"
+	case core.ErrPermissionDenied:
+		return "AI服务拒绝访问，请检查API密钥是否正确"
+	case core.ErrNotFound:
+		return "AI模型不存在，请检查模型名称设置"
+	default:
+		return "系统错误，请稍后重试"
+	}
+}
+
 // 发送错误消息
 func (s *MessageService) sendErrorMessage(content string) {
 	app.EmitEvent(constant.EventOperateFailed, &model.Step{
@@ -179,7 +248,15 @@ func (s *MessageService) runAutomationTask(task *model.Task, analysis *Automatio
 }
 
 // 执行新的自动化任务（使用增强的执行引擎）
-func (s *MessageService) executeAutomationTaskEnhanced(task *model.Task, decomposition *domain.AutomationTaskDecomposition) {
+func (s *MessageService) executeAutomationTaskEnhanced(ctx context.Context, task *model.Task, decomposition *domain.AutomationTaskDecomposition, automationService *AutomationService, done func()) {
+	s.startAutomationTask(ctx, task, automationService, done, func(ctx context.Context) *TaskExecutionResult {
+		return NewEnhancedTaskExecutionEngine(automationService).ExecuteTaskDecomposition(ctx, uint(task.ID), decomposition)
+	})
+}
+
+// startAutomationTask 更新任务状态并在后台执行，run为具体的执行方式（预先分解或工具调用代理）
+// ctx为已登记的任务ctx，done在任务结束时取消登记
+func (s *MessageService) startAutomationTask(ctx context.Context, task *model.Task, automationService *AutomationService, done func(), run func(ctx context.Context) *TaskExecutionResult) {
 	app.EmitEvent(constant.EventNotify, "增强任务开始执行...")
 
 	// 发送任务执行开始事件，触发窗口切换
@@ -192,30 +269,29 @@ func (s *MessageService) executeAutomationTaskEnhanced(task *model.Task, decompo
 	s.sendTaskUpdate(task)
 
 	// 启动增强任务执行（异步）
-	go s.runAutomationTaskEnhanced(task, decomposition)
+	go s.runAutomationTaskEnhanced(ctx, task, automationService, done, run)
 }
 
 // 运行增强的自动化任务（后台执行）
-func (s *MessageService) runAutomationTaskEnhanced(task *model.Task, decomposition *domain.AutomationTaskDecomposition) {
-	// 创建自动化服务
-	automationService := NewAutomationService(app.GetApp())
+func (s *MessageService) runAutomationTaskEnhanced(ctx context.Context, task *model.Task, automationService *AutomationService, done func(), run func(ctx context.Context) *TaskExecutionResult) {
+	defer automationService.Cleanup()
+	defer done()
+
 	err := automationService.Initialize()
 	if err != nil {
 		slog.Error("初始化自动化服务失败", "error", err)
 		s.updateTaskStatus(task, model.TaskStatusFailed, "初始化自动化服务失败")
 		return
 	}
-	defer automationService.Cleanup()
-
-	// 创建增强的任务执行引擎
-	enhancedEngine := NewEnhancedTaskExecutionEngine(automationService)
 
 	// 执行任务
-	ctx := context.Background()
-	result := enhancedEngine.ExecuteTaskDecomposition(ctx, uint(task.ID), decomposition)
+	result := run(ctx)
 
-	if result.Success {
-		s.updateTaskStatus(task, model.TaskStatusCompleted, "增强任务执行完成")
+	if result.Cancelled {
+		s.updateTaskStatus(task, model.TaskStatusCancelled, "任务已被用户停止")
+		app.EmitEvent(constant.EventNotify, "⏹ 自动化任务已停止")
+	} else if result.Success {
+		s.updateTaskStatus(task, model.TaskStatusCompleted, result.Message)
 		app.EmitEvent(constant.EventNotify, "✅ 增强自动化任务执行完成")
 	} else {
 		s.updateTaskStatus(task, model.TaskStatusFailed, fmt.Sprintf("增强任务执行失败: %s", result.Error))
@@ -234,6 +310,36 @@ func (s *MessageService) runAutomationTaskEnhanced(task *model.Task, decompositi
 // 	})
 // }
 
+// beginAutomationTask 登记正在执行的任务，供StopAutomationTask取消
+// 返回的done函数取消登记，调用方必须在任务结束时调用
+func (s *MessageService) beginAutomationTask(task *model.Task, automationService *AutomationService) (context.Context, func(), error) {
+	ctx, done, err := automationService.BeginTask(context.Background(), uint(task.ID))
+	if err != nil {
+		slog.Error("登记自动化任务失败", "task_id", task.ID, "error", err)
+		return nil, nil, err
+	}
+	s.running.Store(uint(task.ID), automationService)
+	return ctx, func() {
+		s.running.Delete(uint(task.ID))
+		done()
+	}, nil
+}
+
+// StopAutomationTask 停止正在执行的自动化任务
+// 取消会中止进行中的LLM调用、等待和worker请求
+func (s *MessageService) StopAutomationTask(taskID uint) error {
+	value, ok := s.running.Load(taskID)
+	if !ok {
+		return fmt.Errorf("任务未在执行")
+	}
+
+	if !value.(*AutomationService).StopTask(taskID) {
+		return fmt.Errorf("任务未在执行")
+	}
+	slog.Info("已请求停止自动化任务", "task_id", taskID)
+	return nil
+}
+
 // 确认执行自动化任务
 func (s *MessageService) ConfirmAutomationTask(t *model.Task, confirmed bool) error {
 	// 查找任务
@@ -243,24 +349,56 @@ func (s *MessageService) ConfirmAutomationTask(t *model.Task, confirmed bool) er
 	}
 
 	if confirmed {
+		// 先创建自动化服务，任务分解时需要知道引擎的能力
+		automationService := NewAutomationService(app.GetApp())
+		if automationService == nil {
+			s.updateTaskStatus(&task, model.TaskStatusFailed, "初始化自动化服务失败")
+			return fmt.Errorf("初始化自动化服务失败")
+		}
+
+		// 从分解开始登记任务，StopAutomationTask可以中止进行中的模型调用
+		ctx, done, err := s.beginAutomationTask(&task, automationService)
+		if err != nil {
+			automationService.Cleanup()
+			s.updateTaskStatus(&task, model.TaskStatusFailed, "已有任务正在执行")
+			return err
+		}
+
+		// 工具调用模式由代理边观察边执行，不需要预先分解任务
+		if GetAutomationMode() == AutomationModeAgent {
+			s.startAutomationTask(ctx, &task, automationService, done, func(ctx context.Context) *TaskExecutionResult {
+				return NewAutomationAgent(automationService).Run(ctx, uint(task.ID), task.Description)
+			})
+			return nil
+		}
+
 		// 重新分析任务（从数据库获取原始内容）
 		llmService := &LLMService{}
 
-		// 构建对话历史
-		var conversationHistory []openai.ChatCompletionMessage
-		// TODO: 从数据库获取对话历史
-
-		conversationHistory = append(conversationHistory, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: task.Description,
-		})
+		// 构建对话历史，获取失败时只根据任务描述分解
+		var conversationHistory []llm.Message
+		if task.ConversationID != 0 {
+			history, err := llmService.ConversationHistory(ctx, task.ConversationID)
+			if err != nil {
+				slog.Warn("获取对话历史失败", "task_id", task.ID, "error", err)
+			}
+			conversationHistory = history
+		}
+		conversationHistory = append(conversationHistory, llm.UserMessage(task.Description))
 
-		taskDecomposition, err := llmService.DecomposeAutomationTask(conversationHistory)
+		taskDecomposition, err := llmService.DecomposeAutomationTask(ctx, conversationHistory, automationService.CapabilitiesContext(ctx))
 		if err != nil {
+			cancelled := ctx.Err() != nil
+			done()
+			automationService.Cleanup()
+			if cancelled {
+				s.updateTaskStatus(&task, model.TaskStatusCancelled, "任务已被用户停止")
+				return nil
+			}
 			s.updateTaskStatus(&task, model.TaskStatusFailed, "重新分析任务失败")
 			return err
 		}
-		s.executeAutomationTaskEnhanced(&task, taskDecomposition)
+		s.executeAutomationTaskEnhanced(ctx, &task, taskDecomposition, automationService, done)
 	} else {
 		s.updateTaskStatus(&task, model.TaskStatusCancelled, "用户取消执行")
 	}
@@ -1,22 +1,32 @@
 package operation
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/imageprep"
 	"diandian/background/database"
 	"diandian/background/model"
+	"diandian/background/service/llm"
+)
 
-	"github.com/sashabaranov/go-openai"
+// 单次模型调用的超时时间
+const (
+	textCallTimeout   = 30 * time.Second
+	visionCallTimeout = 60 * time.Second
 )
 
 // BaseGenerator 操作生成器基础结构
 type BaseGenerator struct {
-	textClient   *openai.Client
-	textModel    string
-	visionClient *openai.Client
-	visionModel  string
+	textProvider   llm.Provider
+	visionProvider llm.Provider
+	visionImage    imageprep.Options // 截图发送给视觉模型前的预处理选项
 }
 
 // NewBaseGenerator 创建基础生成器
@@ -24,134 +34,225 @@ func NewBaseGenerator() *BaseGenerator {
 	return &BaseGenerator{}
 }
 
-// createTextClient 创建文本模型客户端
-func (g *BaseGenerator) createTextClient() (*openai.Client, string, error) {
-	if g.textClient != nil {
-		return g.textClient, g.textModel, nil
+// createTextProvider 创建文本模型
+func (g *BaseGenerator) createTextProvider() (llm.Provider, error) {
+	if g.textProvider != nil {
+		return g.textProvider, nil
+	}
+
+	provider, err := NewTextProvider()
+	if err != nil {
+		return nil, err
+	}
+	g.textProvider = provider
+	return provider, nil
+}
+
+// createVisionProvider 创建视觉模型
+func (g *BaseGenerator) createVisionProvider() (llm.Provider, error) {
+	if g.visionProvider != nil {
+		return g.visionProvider, nil
 	}
 
-	config, err := g.getTextModelConfig()
+	provider, image, err := NewVisionProvider()
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
+	g.visionProvider = provider
+	g.visionImage = image
+	return provider, nil
+}
 
-	clientConfig := openai.DefaultConfig(config.Token)
-	if config.BaseURL != "" {
-		clientConfig.BaseURL = config.BaseURL
+// chat 调用模型并返回回复内容
+func (g *BaseGenerator) chat(ctx context.Context, provider llm.Provider, messages []llm.Message, format *llm.Format, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := provider.Chat(ctx, llm.Request{Messages: messages, Format: format})
+	if err != nil {
+		return "", err
 	}
+	return resp.Content, nil
+}
+
+var (
+	providerMu     sync.RWMutex
+	textOverride   llm.Provider
+	visionOverride llm.Provider
+)
+
+// SetTextProvider 使用指定的文本模型代替设置中的配置，为nil时恢复按设置创建
+// 测试时可以注入llm.Fake
+func SetTextProvider(provider llm.Provider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	textOverride = provider
+}
 
-	g.textClient = openai.NewClientWithConfig(clientConfig)
-	g.textModel = config.Model
-	return g.textClient, g.textModel, nil
+// SetVisionProvider 使用指定的视觉模型代替设置中的配置，为nil时恢复按设置创建
+func SetVisionProvider(provider llm.Provider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	visionOverride = provider
 }
 
-// createVisionClient 创建视觉模型客户端
-func (g *BaseGenerator) createVisionClient() (*openai.Client, string, error) {
-	if g.visionClient != nil {
-		return g.visionClient, g.visionModel, nil
+// NewTextProvider 按设置创建文本模型
+func NewTextProvider() (llm.Provider, error) {
+	providerMu.RLock()
+	override := textOverride
+	providerMu.RUnlock()
+	if override != nil {
+		return override, nil
 	}
 
-	config, err := g.getVisionModelConfig()
+	config, err := GetTextModelConfig()
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
+	return llm.New(llm.Config{Provider: config.Provider, BaseURL: config.BaseURL, Token: config.Token, Model: config.Model})
+}
 
-	clientConfig := openai.DefaultConfig(config.Token)
-	if config.BaseURL != "" {
-		clientConfig.BaseURL = config.BaseURL
+// NewVisionProvider 按设置创建视觉模型，同时返回截图预处理选项，注入的模型使用默认预处理选项
+func NewVisionProvider() (llm.Provider, imageprep.Options, error) {
+	providerMu.RLock()
+	override := visionOverride
+	providerMu.RUnlock()
+	if override != nil {
+		return override, imageprep.DefaultOptions(), nil
 	}
 
-	g.visionClient = openai.NewClientWithConfig(clientConfig)
-	g.visionModel = config.Model
-	return g.visionClient, g.visionModel, nil
+	config, err := GetVisionModelConfig()
+	if err != nil {
+		return nil, imageprep.Options{}, err
+	}
+	provider, err := llm.New(llm.Config{Provider: config.Provider, BaseURL: config.BaseURL, Token: config.Token, Model: config.Model})
+	if err != nil {
+		return nil, imageprep.Options{}, err
+	}
+	return provider, config.Image, nil
 }
 
+// DefaultContextBudget 默认的对话上下文预算（token数）
+const DefaultContextBudget = 16000
+
 // TextModelConfig 文本模型配置
 type TextModelConfig struct {
-	Model   string
-	Token   string
-	BaseURL string
+	Provider      string // 模型服务类型，见llm.ProviderOpenAI等
+	Model         string
+	Token         string
+	BaseURL       string
+	ContextBudget int // 发送的对话历史最多占用的token数，0为不限制
 }
 
 // VisionModelConfig 视觉模型配置
 type VisionModelConfig struct {
-	Model   string
-	Token   string
-	BaseURL string
+	Provider string
+	Model    string
+	Token    string
+	BaseURL  string
+	Image    imageprep.Options // 截图预处理选项
 }
 
-// getTextModelConfig 获取文本模型配置
-func (g *BaseGenerator) getTextModelConfig() (*TextModelConfig, error) {
+// GetTextModelConfig 从设置读取文本模型配置
+func GetTextModelConfig() (*TextModelConfig, error) {
 	var settings []*model.Setting
 	err := database.DB.Where("key IN ?", []string{
+		model.SettingKeyLlmTextProvider,
 		model.SettingKeyLlmTextModel,
 		model.SettingKeyLlmTextToken,
 		model.SettingKeyLlmTextBaseUrl,
+		model.SettingKeyLlmTextContext,
 	}).Find(&settings).Error
 	if err != nil {
 		slog.Error("获取文本模型配置失败", "error", err)
-		return nil, fmt.Errorf("获取文本模型配置失败: %v", err)
+		return nil, fmt.Errorf("获取文本模型配置失败: %w", err)
 	}
 
-	config := &TextModelConfig{}
+	config := &TextModelConfig{ContextBudget: DefaultContextBudget}
 	for _, setting := range settings {
 		if setting.Value == nil {
 			continue
 		}
 		switch setting.Key {
+		case model.SettingKeyLlmTextProvider:
+			config.Provider = *setting.Value
 		case model.SettingKeyLlmTextModel:
 			config.Model = *setting.Value
 		case model.SettingKeyLlmTextToken:
 			config.Token = *setting.Value
 		case model.SettingKeyLlmTextBaseUrl:
 			config.BaseURL = *setting.Value
+		case model.SettingKeyLlmTextContext:
+			if v, err := strconv.Atoi(strings.TrimSpace(*setting.Value)); err == nil && v >= 0 {
+				config.ContextBudget = v
+			}
 		}
 	}
 
-	if config.Model == "" || config.Token == "" {
-		return nil, fmt.Errorf("文本模型配置不完整")
+	if config.Model == "" || (config.Token == "" && llm.RequiresToken(config.Provider)) {
+		return nil, ErrTextModelNotConfigured
 	}
 
 	return config, nil
 }
 
-// getVisionModelConfig 获取视觉模型配置
-func (g *BaseGenerator) getVisionModelConfig() (*VisionModelConfig, error) {
+// GetVisionModelConfig 从设置读取视觉模型配置
+func GetVisionModelConfig() (*VisionModelConfig, error) {
 	var settings []*model.Setting
 	err := database.DB.Where("key IN ?", []string{
+		model.SettingKeyLlmVlProvider,
 		model.SettingKeyLlmVlModel,
 		model.SettingKeyLlmVlToken,
 		model.SettingKeyLlmVlBaseUrl,
+		model.SettingKeyLlmVlImageMaxEdge,
+		model.SettingKeyLlmVlImageFormat,
+		model.SettingKeyLlmVlImageQuality,
 	}).Find(&settings).Error
 	if err != nil {
 		slog.Error("获取视觉模型配置失败", "error", err)
-		return nil, fmt.Errorf("获取视觉模型配置失败: %v", err)
+		return nil, fmt.Errorf("获取视觉模型配置失败: %w", err)
 	}
 
-	config := &VisionModelConfig{}
+	config := &VisionModelConfig{Image: imageprep.DefaultOptions()}
 	for _, setting := range settings {
 		if setting.Value == nil {
 			continue
 		}
 		switch setting.Key {
+		case model.SettingKeyLlmVlProvider:
+			config.Provider = *setting.Value
 		case model.SettingKeyLlmVlModel:
 			config.Model = *setting.Value
 		case model.SettingKeyLlmVlToken:
 			config.Token = *setting.Value
 		case model.SettingKeyLlmVlBaseUrl:
 			config.BaseURL = *setting.Value
+		case model.SettingKeyLlmVlImageMaxEdge:
+			if v, err := strconv.Atoi(strings.TrimSpace(*setting.Value)); err == nil && v >= 0 {
+				config.Image.MaxEdge = v
+			}
+		case model.SettingKeyLlmVlImageFormat:
+			if v := strings.TrimSpace(*setting.Value); v != "" {
+				config.Image.Format = v
+			}
+		case model.SettingKeyLlmVlImageQuality:
+			if v, err := strconv.Atoi(strings.TrimSpace(*setting.Value)); err == nil && v > 0 && v <= 100 {
+				config.Image.Quality = v
+			}
 		}
 	}
 
-	if config.Model == "" || config.Token == "" {
-		return nil, fmt.Errorf("视觉模型配置不完整")
+	if config.Model == "" || (config.Token == "" && llm.RequiresToken(config.Provider)) {
+		return nil, ErrVisionModelNotConfigured
 	}
 
 	return config, nil
 }
 
 // retryLLMCall 重试LLM调用的通用方法
+// 是否重试由错误码决定：网络、超时、限流和输出格式错误会重试，鉴权失败、未配置等直接返回
 func (g *BaseGenerator) retryLLMCall(
+	ctx context.Context,
 	callFunc func() (string, error),
 	validateFunc func(content string) error,
 	maxRetries int,
@@ -160,12 +261,20 @@ func (g *BaseGenerator) retryLLMCall(
 	var lastErr error
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
+		// 调用方已取消时不再重试
+		if err := ctx.Err(); err != nil {
+			return "", fmt.Errorf("%s已取消: %w", operation, err)
+		}
+
 		// 调用LLM
 		content, err := callFunc()
 		if err != nil {
-			lastErr = fmt.Errorf("LLM调用失败: %v", err)
-			if attempt < maxRetries {
-				fmt.Printf("🔄 %s第%d次尝试失败，重试中... 错误: %v\n", operation, attempt, err)
+			lastErr = ClassifyLLMError(err)
+			if attempt < maxRetries && core.IsRetryable(lastErr) {
+				slog.Warn("模型调用失败，稍后重试", "operation", operation, "attempt", attempt, "error", err)
+				if err := core.Sleep(ctx, core.RetryDelay(core.CodeOf(lastErr), attempt)); err != nil {
+					return "", fmt.Errorf("%s已取消: %w", operation, err)
+				}
 				continue
 			}
 			break
@@ -177,9 +286,9 @@ func (g *BaseGenerator) retryLLMCall(
 		// 验证内容
 		if validateFunc != nil {
 			if err := validateFunc(cleanedContent); err != nil {
-				lastErr = fmt.Errorf("内容验证失败: %v", err)
+				lastErr = InvalidOutput("内容验证失败", err)
 				if attempt < maxRetries {
-					fmt.Printf("🔄 %s第%d次尝试验证失败，重试中... 错误: %v\n", operation, attempt, err)
+					slog.Warn("模型输出验证失败，重试", "operation", operation, "attempt", attempt, "error", err)
 					continue
 				}
 				break
@@ -188,12 +297,20 @@ func (g *BaseGenerator) retryLLMCall(
 
 		// 成功
 		if attempt > 1 {
-			fmt.Printf("✅ %s在第%d次尝试后成功\n", operation, attempt)
+			slog.Info("模型调用重试成功", "operation", operation, "attempt", attempt)
 		}
 		return cleanedContent, nil
 	}
 
-	return "", fmt.Errorf("%s在%d次尝试后仍然失败，最后错误: %v", operation, maxRetries, lastErr)
+	return "", wrapRetryError(operation, lastErr)
+}
+
+// wrapRetryError 包装重试结束后的错误，保留原错误的错误码
+func wrapRetryError(operation string, err error) error {
+	if core.CodeOf(err) == core.ErrCancelled {
+		return fmt.Errorf("%s已取消: %w", operation, err)
+	}
+	return fmt.Errorf("%s失败: %w", operation, err)
 }
 
 // cleanMarkdownCodeBlock 清理markdown代码块标记
@@ -2,13 +2,15 @@ package operation
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
-	"time"
+	"strings"
 
+	"diandian/background/automation/core"
 	"diandian/background/constant"
+	"diandian/background/service/llm"
 
-	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
@@ -25,20 +27,15 @@ func NewClickGenerator() *ClickGenerator {
 }
 
 // Generate 生成点击操作
-func (g *ClickGenerator) Generate(contextInfo string, screenAnalysis *VisualAnalysisResponse) (*ClickOperation, error) {
-	client, model, err := g.createTextClient()
+func (g *ClickGenerator) Generate(ctx context.Context, contextInfo string, screenAnalysis *VisualAnalysisResponse) (*ClickOperation, error) {
+	provider, err := g.createTextProvider()
 	if err != nil {
-		slog.Error("创建文本模型客户端失败", "error", err)
+		slog.Error("创建文本模型失败", "error", err)
 		return nil, err
 	}
 
 	// 构建消息
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: constant.PromptGenerateClickOperation,
-		},
-	}
+	messages := []llm.Message{llm.SystemMessage(constant.PromptGenerateClickOperation)}
 
 	// 构建用户消息（只包含上下文和屏幕分析结果）
 	userMessage := fmt.Sprintf("上下文：%s", contextInfo)
@@ -56,15 +53,13 @@ func (g *ClickGenerator) Generate(contextInfo string, screenAnalysis *VisualAnal
 		userMessage += analysisText
 	}
 
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: userMessage,
-	})
+	messages = append(messages, llm.UserMessage(userMessage))
 
 	// 使用重试机制调用LLM
 	content, err := g.retryLLMCall(
+		ctx,
 		func() (string, error) {
-			return g.callLLM(client, model, messages)
+			return g.chat(ctx, provider, messages, nil, textCallTimeout)
 		},
 		func(content string) error {
 			return g.validateClickOperation(content)
@@ -88,31 +83,91 @@ func (g *ClickGenerator) Generate(contextInfo string, screenAnalysis *VisualAnal
 			"error", err,
 			"raw_content", content,
 			"content_length", len(content))
-		return nil, fmt.Errorf("JSON schema验证失败: %v", err)
+		return nil, InvalidOutput("JSON schema验证失败", err)
 	}
 
 	slog.Info("点击操作验证成功", "x", result.X, "y", result.Y, "button", result.Button)
 	return &result, nil
 }
 
-// callLLM 调用LLM
-func (g *ClickGenerator) callLLM(client *openai.Client, model string, messages []openai.ChatCompletionMessage) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// GenerateWithMarks 在截图上为候选元素绘制编号框，由视觉模型选择编号，返回所选元素的中心点
+// marks须已通过MergeMarks编号；模型认为没有合适的候选时返回not_found错误，调用方可以退回坐标方式
+func (g *ClickGenerator) GenerateWithMarks(ctx context.Context, contextInfo string, capture *core.ScreenCapture, marks []Mark) (*ClickOperation, Mark, error) {
+	if len(marks) == 0 {
+		return nil, Mark{}, core.NewError(core.ErrNotFound, "没有可供选择的候选元素", nil)
+	}
+	provider, err := g.createVisionProvider()
+	if err != nil {
+		slog.Error("创建视觉模型失败", "error", err)
+		return nil, Mark{}, err
+	}
+
+	// 先脱敏再绘制编号，与遮挡区域重叠的候选不发送文字描述，避免识别出的敏感文字经提示词泄露
+	redacted, regions, err := redactCapture(ctx, capture, PurposeMarks)
+	if err != nil {
+		slog.Error("截图脱敏失败，取消发送", "purpose", PurposeMarks, "error", err)
+		return nil, Mark{}, fmt.Errorf("截图脱敏失败: %w", err)
+	}
+	marks = hideRedactedLabels(marks, regions)
 
-	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-	})
+	marked, err := DrawMarks(redacted, marks)
 	if err != nil {
-		return "", err
+		return nil, Mark{}, fmt.Errorf("绘制候选元素编号失败: %w", err)
+	}
+	marked = prepareVisionImage(marked, g.visionImage)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "上下文：%s\n\n截图中的候选元素（共%d个）：", contextInfo, len(marks))
+	for _, mark := range marks {
+		label := []rune(mark.Label)
+		if len(label) > markLabelLength {
+			label = append(label[:markLabelLength], '…')
+		}
+		fmt.Fprintf(&b, "\n[%d] %s", mark.ID, string(label))
+	}
+
+	messages := []llm.Message{
+		llm.SystemMessage(constant.PromptGenerateMarkClickOperation),
+		llm.UserMessage(b.String(), marked.ImageData),
 	}
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("LLM返回空响应")
+	var result MarkClickOperation
+	_, err = g.retryLLMCall(
+		ctx,
+		func() (string, error) {
+			return g.chat(ctx, provider, messages, llm.JSONFormat(), visionCallTimeout)
+		},
+		func(content string) error {
+			result = MarkClickOperation{}
+			if err := json.Unmarshal([]byte(content), &result); err != nil {
+				return fmt.Errorf("JSON解析失败: %v", err)
+			}
+			if result.Button == "" {
+				result.Button = "left"
+			}
+			if result.Button != "left" && result.Button != "right" && result.Button != "middle" {
+				return fmt.Errorf("按钮类型必须是 left、right 或 middle")
+			}
+			if _, ok := FindMark(marks, result.Mark); !ok && result.Mark != 0 {
+				return fmt.Errorf("编号%d不在候选元素中", result.Mark)
+			}
+			return nil
+		},
+		3,
+		"编号点击生成",
+	)
+	if err != nil {
+		return nil, Mark{}, err
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	mark, ok := FindMark(marks, result.Mark)
+	if !ok {
+		return nil, Mark{}, core.NewError(core.ErrNotFound, fmt.Sprintf("没有合适的候选元素: %s", result.Reason), nil)
+	}
+	center := mark.Center()
+	slog.Info("编号点击生成成功", "mark", mark.ID, "label", mark.Label, "source", mark.Source,
+		"x", center.X, "y", center.Y, "button", result.Button, "reason", result.Reason)
+	return &ClickOperation{X: center.X, Y: center.Y, Button: result.Button}, mark, nil
 }
 
 // validateClickOperation 验证点击操作
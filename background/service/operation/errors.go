@@ -0,0 +1,43 @@
+package operation
+
+import (
+	"errors"
+	"net"
+
+	"diandian/background/automation/core"
+)
+
+var (
+	// ErrEmptyResponse 模型没有返回任何候选结果
+	ErrEmptyResponse = core.NewError(core.ErrLLMInvalidOutput, "LLM返回空响应", nil)
+	// ErrTextModelNotConfigured 文本模型配置不完整
+	ErrTextModelNotConfigured = core.NewError(core.ErrNotConfigured, "文本模型配置不完整", nil)
+	// ErrVisionModelNotConfigured 视觉模型配置不完整
+	ErrVisionModelNotConfigured = core.NewError(core.ErrNotConfigured, "视觉模型配置不完整", nil)
+)
+
+// ClassifyLLMError 为模型调用错误补充错误码
+// 已带错误码的错误（包括ctx取消和超时，以及llm包按HTTP状态码分类的错误）原样返回
+func ClassifyLLMError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if core.CodeOf(err) != core.ErrUnknown {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return core.NewError(core.ErrTimeout, "模型服务请求超时", err)
+		}
+		return core.NewError(core.ErrNetwork, "无法连接模型服务", err)
+	}
+
+	return core.NewError(core.ErrLLMUnavailable, "模型服务调用失败", err)
+}
+
+// InvalidOutput 将模型输出的解析或校验错误标记为llm_invalid_output
+func InvalidOutput(message string, err error) error {
+	return core.NewError(core.ErrLLMInvalidOutput, message, err)
+}
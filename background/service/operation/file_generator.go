@@ -4,11 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"time"
 
 	"diandian/background/constant"
+	"diandian/background/service/llm"
 
-	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
@@ -25,29 +24,24 @@ func NewFileGenerator() *FileGenerator {
 }
 
 // Generate 生成文件操作
-func (g *FileGenerator) Generate(contextInfo string) (*FileOperation, error) {
-	client, model, err := g.createTextClient()
+func (g *FileGenerator) Generate(ctx context.Context, contextInfo string) (*FileOperation, error) {
+	provider, err := g.createTextProvider()
 	if err != nil {
-		slog.Error("创建文本模型客户端失败", "error", err)
+		slog.Error("创建文本模型失败", "error", err)
 		return nil, err
 	}
 
 	// 构建消息
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: constant.PromptGenerateFileOperation,
-		},
-		{
-			Role:    openai.ChatMessageRoleUser,
-			Content: fmt.Sprintf("上下文：%s", contextInfo),
-		},
+	messages := []llm.Message{
+		llm.SystemMessage(constant.PromptGenerateFileOperation),
+		llm.UserMessage(fmt.Sprintf("上下文：%s", contextInfo)),
 	}
 
 	// 使用重试机制调用LLM
 	content, err := g.retryLLMCall(
+		ctx,
 		func() (string, error) {
-			return g.callLLM(client, model, messages)
+			return g.chat(ctx, provider, messages, nil, textCallTimeout)
 		},
 		func(content string) error {
 			return g.validateFileOperation(content)
@@ -71,7 +65,7 @@ func (g *FileGenerator) Generate(contextInfo string) (*FileOperation, error) {
 			"error", err,
 			"raw_content", content,
 			"content_length", len(content))
-		return nil, fmt.Errorf("JSON schema验证失败: %v", err)
+		return nil, InvalidOutput("JSON schema验证失败", err)
 	}
 
 	slog.Info("文件操作验证成功",
@@ -82,26 +76,6 @@ func (g *FileGenerator) Generate(contextInfo string) (*FileOperation, error) {
 	return &result, nil
 }
 
-// callLLM 调用LLM
-func (g *FileGenerator) callLLM(client *openai.Client, model string, messages []openai.ChatCompletionMessage) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-	})
-	if err != nil {
-		return "", err
-	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("LLM返回空响应")
-	}
-
-	return resp.Choices[0].Message.Content, nil
-}
-
 // validateFileOperation 验证文件操作
 func (g *FileGenerator) validateFileOperation(content string) error {
 	var tempResult FileOperation
@@ -0,0 +1,70 @@
+package operation
+
+import (
+	"context"
+	"sync"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/redact"
+)
+
+// 发送截图的用途，记录在脱敏审计中
+const (
+	PurposeAnalyze = "analyze" // 屏幕分析
+	PurposeLocate  = "locate"  // 放大截图精确定位
+	PurposeMarks   = "marks"   // 编号候选元素选择
+	PurposeOCR     = "ocr"     // 视觉模型文字识别
+	PurposeAgent   = "agent"   // 自动化代理每轮观察的截图
+	PurposeVerify  = "verify"  // 步骤执行后验证结果
+	PurposeReplan  = "replan"  // 重新规划剩余步骤
+)
+
+// Redactor 截图发送给视觉模型前的脱敏处理
+// 返回遮挡后的截图（Bounds与原截图相同）和实际遮挡的区域；返回错误时截图不会被发送
+type Redactor interface {
+	Redact(ctx context.Context, capture *core.ScreenCapture, purpose string) (*core.ScreenCapture, []redact.Region, error)
+}
+
+var (
+	redactorMu sync.RWMutex
+	redactor   Redactor
+)
+
+// SetRedactor 设置截图脱敏处理，为nil时截图原样发送
+func SetRedactor(r Redactor) {
+	redactorMu.Lock()
+	defer redactorMu.Unlock()
+	redactor = r
+}
+
+// redactCapture 使用已设置的脱敏处理遮挡截图中的敏感区域
+func redactCapture(ctx context.Context, capture *core.ScreenCapture, purpose string) (*core.ScreenCapture, []redact.Region, error) {
+	redactorMu.RLock()
+	r := redactor
+	redactorMu.RUnlock()
+	if r == nil {
+		return capture, nil, nil
+	}
+	return r.Redact(ctx, capture, purpose)
+}
+
+// redactedLabel 与遮挡区域重叠的候选元素使用的描述
+const redactedLabel = "（已遮挡）"
+
+// hideRedactedLabels 返回候选副本，与遮挡区域重叠的候选描述替换为占位文字
+func hideRedactedLabels(marks []Mark, regions []redact.Region) []Mark {
+	if len(regions) == 0 {
+		return marks
+	}
+	hidden := make([]Mark, len(marks))
+	for i, mark := range marks {
+		for _, region := range regions {
+			if markOverlapRatio(mark.Rect, region.Rect) > 0 {
+				mark.Label = redactedLabel
+				break
+			}
+		}
+		hidden[i] = mark
+	}
+	return hidden
+}
@@ -0,0 +1,277 @@
+package operation
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"strconv"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/imagematch"
+)
+
+// 点击目标的定位方式
+const (
+	GroundingCoordinates = "coordinates" // 模型直接给出坐标
+	GroundingMarks       = "marks"       // 候选元素编号后由模型选择编号（set-of-marks），失败时退回坐标方式
+)
+
+// 候选元素来源
+const (
+	MarkSourceVision   = "vision"   // 视觉分析结果中的元素
+	MarkSourceOCR      = "ocr"      // 文字识别结果
+	MarkSourceTemplate = "template" // 模板匹配结果
+)
+
+const (
+	maxMarks        = 60  // 一张截图上最多标注的候选元素数量，太多时编号互相遮挡
+	markOverlap     = 0.6 // 与已有候选重叠比例超过该值的候选视为同一个元素
+	markMinSize     = 4   // 宽高小于该值（桌面坐标）的候选忽略
+	markLabelLength = 40  // 提示词中候选元素描述的最大长度
+)
+
+// Mark 截图上编号标注的一个候选元素
+type Mark struct {
+	ID     int       `json:"id"`
+	Rect   core.Rect `json:"rect"`   // 虚拟桌面坐标
+	Label  string    `json:"label"`  // 元素描述或文字
+	Source string    `json:"source"` // 候选来源
+}
+
+// Center 候选元素的中心点
+func (m Mark) Center() core.Point {
+	return core.Point{X: m.Rect.X + m.Rect.Width/2, Y: m.Rect.Y + m.Rect.Height/2}
+}
+
+// MarksFromElements 视觉分析结果中的元素作为候选，坐标须已转换为虚拟桌面坐标
+// 有可点击元素时只使用可点击元素
+func MarksFromElements(elements []VisualElement) []Mark {
+	clickable := false
+	for _, element := range elements {
+		clickable = clickable || element.Clickable
+	}
+
+	var marks []Mark
+	for _, element := range elements {
+		if clickable && !element.Clickable {
+			continue
+		}
+		label := element.Description
+		if element.TextContent != "" && element.TextContent != label {
+			label = fmt.Sprintf("%s「%s」", label, element.TextContent)
+		}
+		c := element.Coordinates
+		marks = append(marks, Mark{
+			Rect:   core.Rect{X: c.X, Y: c.Y, Width: c.Width, Height: c.Height},
+			Label:  label,
+			Source: MarkSourceVision,
+		})
+	}
+	return marks
+}
+
+// MarksFromTextBoxes 文字识别结果作为候选，优先使用整行粒度
+func MarksFromTextBoxes(boxes []core.TextBox) []Mark {
+	level := core.TextLevelWord
+	for _, box := range boxes {
+		if box.Level == core.TextLevelLine {
+			level = core.TextLevelLine
+			break
+		}
+	}
+
+	var marks []Mark
+	for _, box := range boxes {
+		if box.Level != level {
+			continue
+		}
+		marks = append(marks, Mark{
+			Rect:   box.Rect,
+			Label:  fmt.Sprintf("文字「%s」", box.Text),
+			Source: MarkSourceOCR,
+		})
+	}
+	return marks
+}
+
+// MarksFromMatches 模板匹配结果作为候选
+func MarksFromMatches(matches []imagematch.Match, label string) []Mark {
+	marks := make([]Mark, 0, len(matches))
+	for _, match := range matches {
+		marks = append(marks, Mark{
+			Rect:   match.Rect,
+			Label:  fmt.Sprintf("图片%s（相似度%.2f）", label, match.Confidence),
+			Source: MarkSourceTemplate,
+		})
+	}
+	return marks
+}
+
+// MergeMarks 合并多个来源的候选并重新编号（从1开始）
+// 排在前面的来源优先，与已有候选大面积重叠的候选被丢弃；编号按从上到下、从左到右的顺序分配
+func MergeMarks(groups ...[]Mark) []Mark {
+	var merged []Mark
+	for _, group := range groups {
+		for _, mark := range group {
+			if mark.Rect.Width < markMinSize || mark.Rect.Height < markMinSize {
+				continue
+			}
+			duplicate := false
+			for _, existing := range merged {
+				if markOverlapRatio(existing.Rect, mark.Rect) > markOverlap {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate && len(merged) < maxMarks {
+				merged = append(merged, mark)
+			}
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i].Rect, merged[j].Rect
+		if abs(a.Y-b.Y) > min(a.Height, b.Height)/2 {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+	for i := range merged {
+		merged[i].ID = i + 1
+	}
+	return merged
+}
+
+// FindMark 按编号查找候选
+func FindMark(marks []Mark, id int) (Mark, bool) {
+	for _, mark := range marks {
+		if mark.ID == id {
+			return mark, true
+		}
+	}
+	return Mark{}, false
+}
+
+// markOverlapRatio 交集面积占较小矩形面积的比例
+func markOverlapRatio(a, b core.Rect) float64 {
+	x0, y0 := max(a.X, b.X), max(a.Y, b.Y)
+	x1, y1 := min(a.X+a.Width, b.X+b.Width), min(a.Y+a.Height, b.Y+b.Height)
+	if x1 <= x0 || y1 <= y0 {
+		return 0
+	}
+	smaller := min(a.Width*a.Height, b.Width*b.Height)
+	return float64((x1-x0)*(y1-y0)) / float64(smaller)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// markColors 标注框的颜色，相邻编号使用不同颜色便于区分
+var markColors = []color.RGBA{
+	{R: 230, G: 25, B: 75, A: 255},
+	{R: 0, G: 130, B: 200, A: 255},
+	{R: 60, G: 180, B: 75, A: 255},
+	{R: 245, G: 130, B: 48, A: 255},
+	{R: 145, G: 30, B: 180, A: 255},
+	{R: 0, G: 128, B: 128, A: 255},
+}
+
+// digitGlyphs 3x5点阵数字，每行3位，高位在左
+var digitGlyphs = [10][5]uint8{
+	{7, 5, 5, 5, 7}, // 0
+	{2, 6, 2, 2, 7}, // 1
+	{7, 1, 7, 4, 7}, // 2
+	{7, 1, 7, 1, 7}, // 3
+	{5, 5, 7, 1, 1}, // 4
+	{7, 4, 7, 1, 7}, // 5
+	{7, 4, 7, 5, 7}, // 6
+	{7, 1, 1, 1, 1}, // 7
+	{7, 5, 7, 5, 7}, // 8
+	{7, 5, 7, 1, 7}, // 9
+}
+
+// DrawMarks 在截图上绘制候选元素的编号框，返回新的PNG截图（Bounds与原截图相同）
+// 编号标签的大小随截图尺寸变化，保证截图被缩小发送给模型后仍然清晰
+func DrawMarks(capture *core.ScreenCapture, marks []Mark) (*core.ScreenCapture, error) {
+	src, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image data: %w", err)
+	}
+	bounds := src.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), src, bounds.Min, draw.Src)
+
+	dot := max(2, (max(bounds.Dx(), bounds.Dy())+350)/700) // 点阵中一个点的像素大小
+	thickness := max(2, dot/2)
+	for _, mark := range marks {
+		c := markColors[(mark.ID-1+len(markColors))%len(markColors)]
+		p := capture.ToPixels(mark.Rect)
+		box := image.Rect(p.X, p.Y, p.X+p.Width, p.Y+p.Height).Intersect(canvas.Bounds())
+		if box.Empty() {
+			continue
+		}
+		strokeRect(canvas, box, thickness, c)
+		drawLabel(canvas, box.Min, strconv.Itoa(mark.ID), dot, c)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+	marked := *capture
+	marked.ImageData = buf.Bytes()
+	marked.Format = "png"
+	marked.Size = buf.Len()
+	return &marked, nil
+}
+
+// strokeRect 绘制矩形边框
+func strokeRect(img *image.RGBA, r image.Rectangle, thickness int, c color.RGBA) {
+	fill := image.NewUniform(c)
+	t := min(thickness, r.Dx()/2, r.Dy()/2)
+	t = max(t, 1)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+t), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Max.Y-t, r.Max.X, r.Max.Y), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+t, r.Max.Y), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Max.X-t, r.Min.Y, r.Max.X, r.Max.Y), fill, image.Point{}, draw.Src)
+}
+
+// drawLabel 在框的左上角绘制实心底色的编号标签，靠近图像边缘时移到图像内
+func drawLabel(img *image.RGBA, at image.Point, text string, dot int, c color.RGBA) {
+	padding := dot
+	width := len(text)*4*dot - dot + 2*padding
+	height := 5*dot + 2*padding
+	label := image.Rect(at.X, at.Y, at.X+width, at.Y+height)
+	if label.Max.X > img.Bounds().Max.X {
+		label = label.Add(image.Pt(img.Bounds().Max.X-label.Max.X, 0))
+	}
+	if label.Max.Y > img.Bounds().Max.Y {
+		label = label.Add(image.Pt(0, img.Bounds().Max.Y-label.Max.Y))
+	}
+	draw.Draw(img, label, image.NewUniform(c), image.Point{}, draw.Src)
+
+	white := image.NewUniform(color.White)
+	x := label.Min.X + padding
+	for _, r := range text {
+		glyph := digitGlyphs[r-'0']
+		for row := 0; row < 5; row++ {
+			for col := 0; col < 3; col++ {
+				if glyph[row]&(4>>col) == 0 {
+					continue
+				}
+				px := x + col*dot
+				py := label.Min.Y + padding + row*dot
+				draw.Draw(img, image.Rect(px, py, px+dot, py+dot), white, image.Point{}, draw.Src)
+			}
+		}
+		x += 4 * dot
+	}
+}
@@ -0,0 +1,71 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"diandian/background/automation/core"
+	"diandian/background/constant"
+	"diandian/background/service/llm"
+)
+
+// StepVerifier 步骤结果验证器，根据执行后的截图判断步骤的预期结果是否达成
+type StepVerifier struct {
+	*BaseGenerator
+}
+
+// NewStepVerifier 创建步骤结果验证器
+func NewStepVerifier() *StepVerifier {
+	return &StepVerifier{
+		BaseGenerator: NewBaseGenerator(),
+	}
+}
+
+// Verify 验证步骤结果，description为步骤描述，expected为预期结果，outcome为执行结果的简要说明
+// 截图先脱敏，再按配置缩小和重新编码后发送
+func (g *StepVerifier) Verify(ctx context.Context, capture *core.ScreenCapture, description, expected, outcome string) (*StepVerification, error) {
+	provider, err := g.createVisionProvider()
+	if err != nil {
+		slog.Error("创建视觉模型失败", "error", err)
+		return nil, err
+	}
+	prepared, err := redactAndPrepare(ctx, capture, g.visionImage, PurposeVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	request := fmt.Sprintf("刚执行的步骤：%s\n预期结果：%s", description, expected)
+	if outcome != "" {
+		request += "\n执行结果：" + outcome
+	}
+	messages := []llm.Message{
+		llm.SystemMessage(constant.PromptVerifyStep),
+		llm.UserMessage(request, prepared.ImageData),
+	}
+
+	var result StepVerification
+	_, err = g.retryLLMCall(
+		ctx,
+		func() (string, error) {
+			return g.chat(ctx, provider, messages, llm.JSONFormat(), visionCallTimeout)
+		},
+		func(content string) error {
+			result = StepVerification{}
+			if err := json.Unmarshal([]byte(content), &result); err != nil {
+				return fmt.Errorf("JSON解析失败: %v", err)
+			}
+			if !result.Achieved && result.Reason == "" {
+				return fmt.Errorf("未达成时必须说明原因")
+			}
+			return nil
+		},
+		2,
+		"步骤验证",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
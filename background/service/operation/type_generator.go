@@ -4,11 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"time"
 
 	"diandian/background/constant"
+	"diandian/background/service/llm"
 
-	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
@@ -25,29 +24,24 @@ func NewTypeGenerator() *TypeGenerator {
 }
 
 // Generate 生成输入操作
-func (g *TypeGenerator) Generate(contextInfo string) (*TypeOperation, error) {
-	client, model, err := g.createTextClient()
+func (g *TypeGenerator) Generate(ctx context.Context, contextInfo string) (*TypeOperation, error) {
+	provider, err := g.createTextProvider()
 	if err != nil {
-		slog.Error("创建文本模型客户端失败", "error", err)
+		slog.Error("创建文本模型失败", "error", err)
 		return nil, err
 	}
 
 	// 构建消息
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: constant.PromptGenerateTypeOperation,
-		},
-		{
-			Role:    openai.ChatMessageRoleUser,
-			Content: fmt.Sprintf("上下文：%s", contextInfo),
-		},
+	messages := []llm.Message{
+		llm.SystemMessage(constant.PromptGenerateTypeOperation),
+		llm.UserMessage(fmt.Sprintf("上下文：%s", contextInfo)),
 	}
 
 	// 使用重试机制调用LLM
 	content, err := g.retryLLMCall(
+		ctx,
 		func() (string, error) {
-			return g.callLLM(client, model, messages)
+			return g.chat(ctx, provider, messages, nil, textCallTimeout)
 		},
 		func(content string) error {
 			return g.validateTypeOperation(content)
@@ -71,33 +65,13 @@ func (g *TypeGenerator) Generate(contextInfo string) (*TypeOperation, error) {
 			"error", err,
 			"raw_content", content,
 			"content_length", len(content))
-		return nil, fmt.Errorf("JSON schema验证失败: %v", err)
+		return nil, InvalidOutput("JSON schema验证失败", err)
 	}
 
 	slog.Info("输入操作验证成功", "text_length", len(result.Text), "text_preview", result.Text)
 	return &result, nil
 }
 
-// callLLM 调用LLM
-func (g *TypeGenerator) callLLM(client *openai.Client, model string, messages []openai.ChatCompletionMessage) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-	})
-	if err != nil {
-		return "", err
-	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("LLM返回空响应")
-	}
-
-	return resp.Choices[0].Message.Content, nil
-}
-
 // validateTypeOperation 验证输入操作
 func (g *TypeGenerator) validateTypeOperation(content string) error {
 	var tempResult TypeOperation
@@ -7,6 +7,13 @@ type ClickOperation struct {
 	Button string `json:"button"` // 按钮类型: left, right, middle
 }
 
+// 编号点击操作结构（set-of-marks），模型从截图上的编号框中选择点击目标
+type MarkClickOperation struct {
+	Mark   int    `json:"mark"`   // 候选元素编号，0表示没有合适的候选
+	Button string `json:"button"` // 按钮类型: left, right, middle
+	Reason string `json:"reason"` // 选择理由
+}
+
 // 输入操作结构
 type TypeOperation struct {
 	Text string `json:"text"` // 要输入的文本
@@ -20,6 +27,17 @@ type FileOperation struct {
 	Content    string `json:"content"`     // 文件内容（创建时需要）
 }
 
+// 精确定位结果结构，坐标为放大后局部截图中的像素坐标
+type LocateResult struct {
+	Found      bool    `json:"found"`      // 是否找到目标
+	X          int     `json:"x"`          // 目标左上角X坐标
+	Y          int     `json:"y"`          // 目标左上角Y坐标
+	Width      int     `json:"width"`      // 目标宽度
+	Height     int     `json:"height"`     // 目标高度
+	Confidence float64 `json:"confidence"` // 置信度
+	Reason     string  `json:"reason"`     // 未找到时的原因
+}
+
 // 视觉分析响应结构
 type VisualAnalysisResponse struct {
 	ElementsFound   []VisualElement        `json:"elements_found"`  // 找到的元素
@@ -57,3 +75,11 @@ type Coordinates struct {
 	Width  int `json:"width"`  // 宽度
 	Height int `json:"height"` // 高度
 }
+
+// 步骤结果验证结构，由视觉模型根据执行后的截图判断
+type StepVerification struct {
+	Achieved    bool    `json:"achieved"`    // 步骤的预期结果是否达成
+	Observation string  `json:"observation"` // 截图中与该步骤相关的界面状态
+	Reason      string  `json:"reason"`      // 判断理由，未达成时说明与预期的差异
+	Confidence  float64 `json:"confidence"`  // 置信度
+}
@@ -0,0 +1,234 @@
+package operation
+
+import (
+	"bytes"
+	"image"
+	"log/slog"
+	"sync"
+	"time"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/screendiff"
+)
+
+// VisionCacheOptions 视觉分析结果缓存选项
+type VisionCacheOptions struct {
+	TTL             time.Duration // 缓存有效期，<=0表示不缓存
+	MaxHashDistance int           // 感知哈希汉明距离不超过该值的截图才逐像素比较
+	MaxChangeRatio  float64       // 发生变化的像素比例不超过该值时视为同一画面
+	MaxEntries      int           // 最多缓存的结果数量，超出时淘汰最久未使用的
+}
+
+// DefaultVisionCacheOptions 默认选项：有效期2分钟，变化像素不超过0.2%，最多32条
+// 感知哈希只反映整体明暗分布，输入一个字符之类的小变化需要逐像素比较才能发现
+func DefaultVisionCacheOptions() VisionCacheOptions {
+	return VisionCacheOptions{
+		TTL:             2 * time.Minute,
+		MaxHashDistance: 2,
+		MaxChangeRatio:  0.002,
+		MaxEntries:      32,
+	}
+}
+
+// VisionCacheStats 缓存命中统计
+type VisionCacheStats struct {
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"` // 因过期或超出数量被淘汰的条目数
+	Entries   int     `json:"entries"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// VisionCache 按截图感知哈希和分析请求缓存视觉分析结果，同一画面重复分析时不再调用模型
+// 截图须是脱敏和缩小之后实际发送给模型的截图，Bounds不同的截图不会命中
+type VisionCache struct {
+	mu      sync.Mutex
+	options VisionCacheOptions
+	entries []*visionCacheEntry // 按最近使用时间排序，最近使用的在末尾
+	stats   VisionCacheStats
+}
+
+type visionCacheEntry struct {
+	key     string
+	hash    uint64
+	capture *core.ScreenCapture
+	result  *VisualAnalysisResponse
+	created time.Time
+}
+
+// NewVisionCache 创建视觉分析结果缓存
+func NewVisionCache(options VisionCacheOptions) *VisionCache {
+	return &VisionCache{options: options}
+}
+
+// SetOptions 设置缓存选项，已缓存的条目按新选项淘汰
+func (c *VisionCache) SetOptions(options VisionCacheOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.options = options
+	c.evictLocked(time.Now())
+}
+
+// Options 当前缓存选项
+func (c *VisionCache) Options() VisionCacheOptions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.options
+}
+
+// Get 查找与截图画面相同且分析请求相同的结果，返回结果的副本
+func (c *VisionCache) Get(key string, capture *core.ScreenCapture) (*VisualAnalysisResponse, bool) {
+	c.mu.Lock()
+	if c.options.TTL <= 0 {
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.evictLocked(time.Now())
+	options := c.options
+	var candidates []*visionCacheEntry
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		if c.entries[i].key == key {
+			candidates = append(candidates, c.entries[i])
+		}
+	}
+	c.mu.Unlock()
+
+	// 解码和逐像素比较较慢，不持有锁，多个显示器可以同时查找
+	var hit *visionCacheEntry
+	if hash, ok := captureHash(capture); ok {
+		for _, entry := range candidates {
+			if screendiff.Distance(entry.hash, hash) > options.MaxHashDistance {
+				continue
+			}
+			metrics, err := screendiff.Compare(entry.capture, capture, nil, 0)
+			if err == nil && metrics.ScreenRatio <= options.MaxChangeRatio {
+				slog.Debug("视觉分析命中缓存", "hash_distance", metrics.HashDistance, "change_ratio", metrics.ScreenRatio)
+				hit = entry
+				break
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit == nil {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	// 移到末尾表示最近使用，查找期间已被淘汰的条目不再放回
+	for i, entry := range c.entries {
+		if entry == hit {
+			c.entries = append(append(c.entries[:i], c.entries[i+1:]...), entry)
+			break
+		}
+	}
+	return copyAnalysis(hit.result), true
+}
+
+// Put 缓存分析结果
+func (c *VisionCache) Put(key string, capture *core.ScreenCapture, result *VisualAnalysisResponse) {
+	if result == nil {
+		return
+	}
+	hash, ok := captureHash(capture)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.options.TTL <= 0 || c.options.MaxEntries <= 0 {
+		return
+	}
+	c.entries = append(c.entries, &visionCacheEntry{
+		key:     key,
+		hash:    hash,
+		capture: capture,
+		result:  copyAnalysis(result),
+		created: time.Now(),
+	})
+	c.evictLocked(time.Now())
+}
+
+// Stats 缓存命中统计
+func (c *VisionCache) Stats() VisionCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// Clear 清空缓存，统计保留
+func (c *VisionCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// evictLocked 淘汰过期和超出数量的条目，调用方须持有锁
+func (c *VisionCache) evictLocked(now time.Time) {
+	kept := c.entries[:0]
+	for _, entry := range c.entries {
+		if c.options.TTL > 0 && now.Sub(entry.created) <= c.options.TTL {
+			kept = append(kept, entry)
+		} else {
+			c.stats.Evictions++
+		}
+	}
+	for i := len(kept); i < len(c.entries); i++ {
+		c.entries[i] = nil
+	}
+	c.entries = kept
+
+	if overflow := len(c.entries) - max(c.options.MaxEntries, 0); overflow > 0 {
+		for i := 0; i < overflow; i++ {
+			c.entries[i] = nil
+		}
+		c.entries = append([]*visionCacheEntry(nil), c.entries[overflow:]...)
+		c.stats.Evictions += int64(overflow)
+	}
+}
+
+// captureHash 计算截图的感知哈希
+func captureHash(capture *core.ScreenCapture) (uint64, bool) {
+	if capture == nil || len(capture.ImageData) == 0 {
+		return 0, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(capture.ImageData))
+	if err != nil {
+		return 0, false
+	}
+	return screendiff.Hash(img), true
+}
+
+// copyAnalysis 复制分析结果，调用方修改坐标不影响缓存
+func copyAnalysis(result *VisualAnalysisResponse) *VisualAnalysisResponse {
+	copied := *result
+	copied.ElementsFound = append([]VisualElement(nil), result.ElementsFound...)
+	copied.Recommendations = append([]ActionRecommendation(nil), result.Recommendations...)
+	return &copied
+}
+
+var (
+	visionCacheMu sync.RWMutex
+	visionCache   = NewVisionCache(DefaultVisionCacheOptions())
+)
+
+// SetVisionCache 设置视觉分析使用的缓存，为nil时不缓存
+func SetVisionCache(cache *VisionCache) {
+	visionCacheMu.Lock()
+	defer visionCacheMu.Unlock()
+	visionCache = cache
+}
+
+// SharedVisionCache 视觉分析使用的缓存，未设置时返回nil
+func SharedVisionCache() *VisionCache {
+	visionCacheMu.RLock()
+	defer visionCacheMu.RUnlock()
+	return visionCache
+}
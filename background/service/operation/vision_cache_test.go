@@ -0,0 +1,121 @@
+package operation
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"diandian/background/automation/core"
+)
+
+// testCapture 生成灰色截图，shade改变左侧区域的亮度，dot在(50,50)画一个白点
+func testCapture(t *testing.T, shade uint8, dot bool) *core.ScreenCapture {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			i := img.PixOffset(x, y)
+			v := uint8(128)
+			if x < 100 {
+				v = shade
+			}
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 255
+		}
+	}
+	if dot {
+		i := img.PixOffset(150, 50)
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2] = 255, 255, 255
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	capture, err := core.NewScreenCapture(buf.Bytes(), 0, core.Rect{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return capture
+}
+
+func analysis(description string) *VisualAnalysisResponse {
+	return &VisualAnalysisResponse{ElementsFound: []VisualElement{{Type: "button", Description: description}}}
+}
+
+func TestVisionCacheHitAndMiss(t *testing.T) {
+	cache := NewVisionCache(DefaultVisionCacheOptions())
+	screen := testCapture(t, 128, false)
+	cache.Put("保存按钮", screen, analysis("保存"))
+
+	// 一个像素的变化仍视为同一画面
+	result, ok := cache.Get("保存按钮", testCapture(t, 128, true))
+	if !ok || result.ElementsFound[0].Description != "保存" {
+		t.Fatalf("同一画面没有命中缓存: %v %v", result, ok)
+	}
+	// 修改返回的结果不影响缓存
+	result.ElementsFound[0].Description = "已修改"
+	if result, _ = cache.Get("保存按钮", screen); result.ElementsFound[0].Description != "保存" {
+		t.Errorf("缓存的结果被修改为 %q", result.ElementsFound[0].Description)
+	}
+
+	if _, ok := cache.Get("取消按钮", screen); ok {
+		t.Error("不同的分析请求命中了缓存")
+	}
+	if _, ok := cache.Get("保存按钮", testCapture(t, 20, false)); ok {
+		t.Error("不同的画面命中了缓存")
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 1 || stats.HitRate != 0.5 {
+		t.Errorf("统计 = %+v", stats)
+	}
+}
+
+func TestVisionCacheLRU(t *testing.T) {
+	options := DefaultVisionCacheOptions()
+	options.MaxEntries = 2
+	cache := NewVisionCache(options)
+	screen := testCapture(t, 128, false)
+
+	cache.Put("a", screen, analysis("a"))
+	cache.Put("b", screen, analysis("b"))
+	cache.Get("a", screen) // a最近使用过，超出数量时先淘汰b
+	cache.Put("c", screen, analysis("c"))
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := cache.Get(key, screen); ok != want {
+			t.Errorf("Get(%q) 命中 = %v，期望 %v", key, ok, want)
+		}
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("统计 = %+v，期望淘汰1条、剩余2条", stats)
+	}
+}
+
+func TestVisionCacheTTL(t *testing.T) {
+	options := DefaultVisionCacheOptions()
+	options.TTL = 20 * time.Millisecond
+	cache := NewVisionCache(options)
+	screen := testCapture(t, 128, false)
+
+	cache.Put("a", screen, analysis("a"))
+	if _, ok := cache.Get("a", screen); !ok {
+		t.Fatal("有效期内没有命中缓存")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.Get("a", screen); ok {
+		t.Error("过期的结果命中了缓存")
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Evictions != 1 {
+		t.Errorf("统计 = %+v，期望过期条目被淘汰", stats)
+	}
+
+	// 有效期为0时不缓存
+	options.TTL = 0
+	cache.SetOptions(options)
+	cache.Put("a", screen, analysis("a"))
+	if _, ok := cache.Get("a", screen); ok || cache.Stats().Entries != 0 {
+		t.Error("关闭缓存后仍然命中")
+	}
+}
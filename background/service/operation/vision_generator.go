@@ -2,15 +2,15 @@ package operation
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log/slog"
-	"strings"
-	"time"
 
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/imageprep"
 	"diandian/background/constant"
+	"diandian/background/service/llm"
 
-	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
@@ -27,54 +27,126 @@ func NewVisionGenerator() *VisionGenerator {
 }
 
 // Analyze 分析屏幕截图
-func (g *VisionGenerator) Analyze(imageData []byte, analysisRequest string) (*VisualAnalysisResponse, error) {
+// 截图按配置缩小和重新编码后再发送，返回的坐标已还原为原图的像素坐标
+func (g *VisionGenerator) Analyze(ctx context.Context, imageData []byte, analysisRequest string) (*VisualAnalysisResponse, error) {
+	capture, err := core.NewScreenCapture(imageData, core.DisplayIndexVirtual, core.Rect{})
+	if err != nil {
+		return nil, core.NewError(core.ErrInvalidArgument, "截图数据无效", err)
+	}
+	return g.AnalyzeCapture(ctx, capture, analysisRequest)
+}
+
+// AnalyzeCapture 分析屏幕截图，返回的坐标已还原为截图的虚拟桌面坐标
+// 截图先脱敏，再按配置缩小和重新编码后发送
+func (g *VisionGenerator) AnalyzeCapture(ctx context.Context, capture *core.ScreenCapture, analysisRequest string) (*VisualAnalysisResponse, error) {
+	provider, err := g.createVisionProvider()
+	if err != nil {
+		slog.Error("创建视觉模型失败", "error", err)
+		return nil, err
+	}
+	prepared, err := redactAndPrepare(ctx, capture, g.visionImage, PurposeAnalyze)
+	if err != nil {
+		return nil, err
+	}
+	analysisRequest = fmt.Sprintf("截图尺寸为 %dx%d 像素，坐标请以此为准。\n%s", prepared.Width, prepared.Height, analysisRequest)
+
+	// 同一画面、同一请求的分析结果直接复用，缓存的坐标已是虚拟桌面坐标
+	cache := SharedVisionCache()
+	cacheKey := provider.Name() + "/" + provider.Model() + "\x00" + analysisRequest
+	if cache != nil {
+		if cached, ok := cache.Get(cacheKey, prepared); ok {
+			return cached, nil
+		}
+	}
+
 	// 首先尝试使用JSON格式
-	result, err := g.analyzeWithJSONFormat(imageData, analysisRequest)
+	result, err := g.analyzeWithJSONFormat(ctx, prepared.ImageData, analysisRequest)
 	if err != nil {
 		slog.Warn("JSON格式分析失败，尝试降级到文本格式", "error", err)
 		// 降级到文本格式，然后转换为JSON
-		return g.analyzeWithTextFallback(imageData, analysisRequest)
+		if result, err = g.analyzeWithTextFallback(ctx, prepared.ImageData, analysisRequest); err != nil {
+			return nil, err
+		}
+	}
+
+	// 模型坐标是缩小后图像中的位置，还原为虚拟桌面坐标
+	for i := range result.ElementsFound {
+		c := &result.ElementsFound[i].Coordinates
+		r := prepared.ToDesktop(core.Rect{X: c.X, Y: c.Y, Width: c.Width, Height: c.Height})
+		c.X, c.Y, c.Width, c.Height = r.X, r.Y, r.Width, r.Height
+	}
+	if cache != nil {
+		cache.Put(cacheKey, prepared, result)
 	}
 	return result, nil
 }
 
-// analyzeWithJSONFormat 使用JSON格式进行分析
-func (g *VisionGenerator) analyzeWithJSONFormat(imageData []byte, analysisRequest string) (*VisualAnalysisResponse, error) {
-	client, model, err := g.createVisionClient()
+// Locate 在放大的局部截图中精确定位目标，返回目标的虚拟桌面坐标范围
+// capture须是局部截图（Bounds为截图区域，ScaleFactor为放大倍数），target描述要查找的元素
+func (g *VisionGenerator) Locate(ctx context.Context, capture *core.ScreenCapture, target string) (core.Rect, float64, error) {
+	provider, err := g.createVisionProvider()
 	if err != nil {
-		slog.Error("创建视觉模型客户端失败", "error", err)
-		return nil, err
+		slog.Error("创建视觉模型失败", "error", err)
+		return core.Rect{}, 0, err
+	}
+	prepared, err := redactAndPrepare(ctx, capture, g.visionImage, PurposeLocate)
+	if err != nil {
+		return core.Rect{}, 0, err
 	}
 
-	// 将图片转换为base64
-	imageBase64 := fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(imageData))
+	messages := []llm.Message{
+		llm.SystemMessage(constant.PromptVisualLocate),
+		llm.UserMessage(fmt.Sprintf("截图尺寸为 %dx%d 像素。要查找的目标：%s", prepared.Width, prepared.Height, target), prepared.ImageData),
+	}
 
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: constant.PromptVisualAnalysis,
+	var result LocateResult
+	_, err = g.retryLLMCall(
+		ctx,
+		func() (string, error) {
+			return g.chat(ctx, provider, messages, llm.JSONFormat(), visionCallTimeout)
 		},
-		{
-			Role: openai.ChatMessageRoleUser,
-			MultiContent: []openai.ChatMessagePart{
-				{
-					Type: openai.ChatMessagePartTypeText,
-					Text: analysisRequest,
-				},
-				{
-					Type: openai.ChatMessagePartTypeImageURL,
-					ImageURL: &openai.ChatMessageImageURL{
-						URL: imageBase64,
-					},
-				},
-			},
+		func(content string) error {
+			result = LocateResult{}
+			if err := json.Unmarshal([]byte(content), &result); err != nil {
+				return fmt.Errorf("JSON解析失败: %v", err)
+			}
+			if result.Found && (result.Width <= 0 || result.Height <= 0) {
+				return fmt.Errorf("目标的宽高必须为正数")
+			}
+			return nil
 		},
+		2,
+		"精确定位",
+	)
+	if err != nil {
+		return core.Rect{}, 0, err
+	}
+	if !result.Found {
+		return core.Rect{}, 0, core.NewError(core.ErrNotFound, fmt.Sprintf("局部截图中没有找到目标: %s", result.Reason), nil)
+	}
+
+	rect := prepared.ToDesktop(core.Rect{X: result.X, Y: result.Y, Width: result.Width, Height: result.Height})
+	return rect, result.Confidence, nil
+}
+
+// analyzeWithJSONFormat 使用JSON格式进行分析
+func (g *VisionGenerator) analyzeWithJSONFormat(ctx context.Context, imageData []byte, analysisRequest string) (*VisualAnalysisResponse, error) {
+	provider, err := g.createVisionProvider()
+	if err != nil {
+		slog.Error("创建视觉模型失败", "error", err)
+		return nil, err
+	}
+
+	messages := []llm.Message{
+		llm.SystemMessage(constant.PromptVisualAnalysis),
+		llm.UserMessage(analysisRequest, imageData),
 	}
 
 	// 使用重试机制调用LLM
 	content, err := g.retryLLMCall(
+		ctx,
 		func() (string, error) {
-			return g.callVisionLLM(client, model, messages, true) // 尝试JSON格式
+			return g.chat(ctx, provider, messages, llm.JSONFormat(), visionCallTimeout)
 		},
 		func(content string) error {
 			return g.validateVisualAnalysis(content)
@@ -98,7 +170,7 @@ func (g *VisionGenerator) analyzeWithJSONFormat(imageData []byte, analysisReques
 			"error", err,
 			"raw_content", content,
 			"content_length", len(content))
-		return nil, fmt.Errorf("JSON schema验证失败: %v", err)
+		return nil, InvalidOutput("JSON schema验证失败", err)
 	}
 
 	slog.Info("视觉分析验证成功", "elements_count", len(result.ElementsFound))
@@ -106,29 +178,26 @@ func (g *VisionGenerator) analyzeWithJSONFormat(imageData []byte, analysisReques
 }
 
 // analyzeWithTextFallback 降级到文本格式分析
-func (g *VisionGenerator) analyzeWithTextFallback(imageData []byte, analysisRequest string) (*VisualAnalysisResponse, error) {
+func (g *VisionGenerator) analyzeWithTextFallback(ctx context.Context, imageData []byte, analysisRequest string) (*VisualAnalysisResponse, error) {
 	slog.Info("使用文本降级模式进行视觉分析")
 
 	// 第一步：使用视觉模型生成文本描述
-	textDescription, err := g.generateTextDescription(imageData, analysisRequest)
+	textDescription, err := g.generateTextDescription(ctx, imageData, analysisRequest)
 	if err != nil {
-		return nil, fmt.Errorf("生成文本描述失败: %v", err)
+		return nil, fmt.Errorf("生成文本描述失败: %w", err)
 	}
 
 	// 第二步：使用文本模型将描述转换为JSON
-	return g.convertTextToJSON(textDescription, analysisRequest)
+	return g.convertTextToJSON(ctx, textDescription, analysisRequest)
 }
 
 // generateTextDescription 生成文本描述
-func (g *VisionGenerator) generateTextDescription(imageData []byte, analysisRequest string) (string, error) {
-	client, model, err := g.createVisionClient()
+func (g *VisionGenerator) generateTextDescription(ctx context.Context, imageData []byte, analysisRequest string) (string, error) {
+	provider, err := g.createVisionProvider()
 	if err != nil {
 		return "", err
 	}
 
-	// 将图片转换为base64
-	imageBase64 := fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(imageData))
-
 	// 使用简化的文本提示词
 	textPrompt := fmt.Sprintf(`请详细描述这个屏幕截图中的所有可交互元素，包括：
 1. 按钮的位置和文字
@@ -141,30 +210,14 @@ func (g *VisionGenerator) generateTextDescription(imageData []byte, analysisRequ
 
 请用自然语言详细描述，不要使用JSON格式。`, analysisRequest)
 
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role: openai.ChatMessageRoleUser,
-			MultiContent: []openai.ChatMessagePart{
-				{
-					Type: openai.ChatMessagePartTypeText,
-					Text: textPrompt,
-				},
-				{
-					Type: openai.ChatMessagePartTypeImageURL,
-					ImageURL: &openai.ChatMessageImageURL{
-						URL: imageBase64,
-					},
-				},
-			},
-		},
-	}
+	messages := []llm.Message{llm.UserMessage(textPrompt, imageData)}
 
-	return g.callVisionLLM(client, model, messages, false) // 不使用JSON格式
+	return g.chat(ctx, provider, messages, nil, visionCallTimeout) // 不使用JSON格式
 }
 
 // convertTextToJSON 将文本描述转换为JSON
-func (g *VisionGenerator) convertTextToJSON(textDescription, originalRequest string) (*VisualAnalysisResponse, error) {
-	client, model, err := g.createTextClient()
+func (g *VisionGenerator) convertTextToJSON(ctx context.Context, textDescription, originalRequest string) (*VisualAnalysisResponse, error) {
+	provider, err := g.createTextProvider()
 	if err != nil {
 		return nil, err
 	}
@@ -204,21 +257,16 @@ func (g *VisionGenerator) convertTextToJSON(textDescription, originalRequest str
 2. 不要输出其他内容，只输出JSON
 3. 确保JSON格式完全正确`, originalRequest, textDescription)
 
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: "你是一个专业的数据转换专家，负责将文本描述转换为结构化的JSON数据。",
-		},
-		{
-			Role:    openai.ChatMessageRoleUser,
-			Content: convertPrompt,
-		},
+	messages := []llm.Message{
+		llm.SystemMessage("你是一个专业的数据转换专家，负责将文本描述转换为结构化的JSON数据。"),
+		llm.UserMessage(convertPrompt),
 	}
 
 	// 使用重试机制调用文本模型
 	content, err := g.retryLLMCall(
+		ctx,
 		func() (string, error) {
-			return g.callTextLLM(client, model, messages)
+			return g.chat(ctx, provider, messages, nil, textCallTimeout)
 		},
 		func(content string) error {
 			return g.validateVisualAnalysis(content)
@@ -242,66 +290,13 @@ func (g *VisionGenerator) convertTextToJSON(textDescription, originalRequest str
 			"error", err,
 			"raw_content", content,
 			"content_length", len(content))
-		return nil, fmt.Errorf("JSON schema验证失败: %v", err)
+		return nil, InvalidOutput("JSON schema验证失败", err)
 	}
 
 	slog.Info("视觉分析(降级模式)验证成功", "elements_count", len(result.ElementsFound))
 	return &result, nil
 }
 
-// callVisionLLM 调用视觉LLM
-func (g *VisionGenerator) callVisionLLM(client *openai.Client, model string, messages []openai.ChatCompletionMessage, useJSONFormat bool) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	request := openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-	}
-
-	// 如果支持JSON格式，添加response_format
-	if useJSONFormat {
-		request.ResponseFormat = &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-		}
-	}
-
-	resp, err := client.CreateChatCompletion(ctx, request)
-	if err != nil {
-		// 检查是否是response_format不支持的错误
-		if useJSONFormat && strings.Contains(err.Error(), "response_format") {
-			return "", fmt.Errorf("模型不支持JSON格式: %v", err)
-		}
-		return "", err
-	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("LLM返回空响应")
-	}
-
-	return resp.Choices[0].Message.Content, nil
-}
-
-// callTextLLM 调用文本LLM
-func (g *VisionGenerator) callTextLLM(client *openai.Client, model string, messages []openai.ChatCompletionMessage) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-	})
-	if err != nil {
-		return "", err
-	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("LLM返回空响应")
-	}
-
-	return resp.Choices[0].Message.Content, nil
-}
-
 // validateVisualAnalysis 验证视觉分析结果
 func (g *VisionGenerator) validateVisualAnalysis(content string) error {
 	var tempResult VisualAnalysisResponse
@@ -326,3 +321,35 @@ func (g *VisionGenerator) validateVisualAnalysis(content string) error {
 
 	return nil
 }
+
+// prepareVisionImage 按选项缩小并编码截图，返回截图的ToDesktop可以将模型坐标还原为原截图的桌面坐标
+// 预处理失败时记录日志并使用原截图，不影响分析
+func prepareVisionImage(capture *core.ScreenCapture, options imageprep.Options) *core.ScreenCapture {
+	prepared, err := imageprep.Prepare(capture, options)
+	if err != nil {
+		slog.Warn("截图预处理失败，使用原图", "error", err)
+		return capture
+	}
+	if prepared != capture {
+		slog.Debug("截图预处理完成",
+			"from", fmt.Sprintf("%dx%d %s %dB", capture.Width, capture.Height, capture.Format, capture.Size),
+			"to", fmt.Sprintf("%dx%d %s %dB", prepared.Width, prepared.Height, prepared.Format, prepared.Size))
+	}
+	return prepared
+}
+
+// redactAndPrepare 遮挡截图中的敏感区域后按选项缩小并编码
+// 脱敏失败时返回错误，截图不会被发送
+func redactAndPrepare(ctx context.Context, capture *core.ScreenCapture, options imageprep.Options, purpose string) (*core.ScreenCapture, error) {
+	redacted, _, err := redactCapture(ctx, capture, purpose)
+	if err != nil {
+		slog.Error("截图脱敏失败，取消发送", "purpose", purpose, "error", err)
+		return nil, fmt.Errorf("截图脱敏失败: %w", err)
+	}
+	return prepareVisionImage(redacted, options), nil
+}
+
+// PrepareForVision 遮挡敏感区域并按选项预处理截图，供直接向视觉模型发送截图的调用方使用
+func PrepareForVision(ctx context.Context, capture *core.ScreenCapture, options imageprep.Options, purpose string) (*core.ScreenCapture, error) {
+	return redactAndPrepare(ctx, capture, options, purpose)
+}
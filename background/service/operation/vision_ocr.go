@@ -0,0 +1,140 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"diandian/background/automation/core"
+	"diandian/background/constant"
+	"diandian/background/service/llm"
+)
+
+// VisionOCR 使用视觉模型识别文字，实现core.OCRProvider
+// 没有安装tesseract时的后备方案，速度和坐标精度都不如本地OCR
+type VisionOCR struct {
+	*BaseGenerator
+	mu sync.Mutex // 保护BaseGenerator中缓存的模型
+}
+
+// 确保VisionOCR实现了文字识别接口
+var _ core.OCRProvider = (*VisionOCR)(nil)
+
+// NewVisionOCR 创建视觉模型文字识别后端
+func NewVisionOCR() *VisionOCR {
+	return &VisionOCR{
+		BaseGenerator: NewBaseGenerator(),
+	}
+}
+
+// Name 后端名称
+func (v *VisionOCR) Name() string {
+	return "vision"
+}
+
+// recognizedText 视觉模型返回的一段文字
+type recognizedText struct {
+	Text       string  `json:"text"`
+	X          int     `json:"x"`
+	Y          int     `json:"y"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Confidence float64 `json:"confidence"`
+}
+
+// textRecognitionResponse 视觉模型的文字识别结果
+type textRecognitionResponse struct {
+	Texts []recognizedText `json:"texts"`
+}
+
+// ocrLanguageNames 语言提示在提示词中的名称
+var ocrLanguageNames = map[string]string{
+	"zh": "中文",
+	"en": "英文",
+	"ja": "日文",
+	"ko": "韩文",
+}
+
+// Recognize 识别截图中的文字，返回整行粒度的文本块
+func (v *VisionOCR) Recognize(ctx context.Context, capture *core.ScreenCapture, options core.OCROptions) ([]core.TextBox, error) {
+	if capture == nil || len(capture.ImageData) == 0 {
+		return nil, core.NewError(core.ErrInvalidArgument, "empty screen capture", nil)
+	}
+	if options.Region != nil {
+		cropped, err := capture.Crop(*options.Region)
+		if err != nil {
+			return nil, err
+		}
+		capture = cropped
+	}
+
+	v.mu.Lock()
+	provider, err := v.createVisionProvider()
+	imageOptions := v.visionImage
+	v.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	// 缩小后的截图Bounds不变，识别结果仍然通过capture.ToDesktop还原
+	capture, err = redactAndPrepare(ctx, capture, imageOptions, PurposeOCR)
+	if err != nil {
+		return nil, err
+	}
+
+	request := fmt.Sprintf("截图尺寸为 %dx%d 像素。", capture.Width, capture.Height)
+	if len(options.Languages) > 0 {
+		var names []string
+		for _, language := range options.Languages {
+			if name, ok := ocrLanguageNames[strings.ToLower(language)]; ok {
+				names = append(names, name)
+			} else {
+				names = append(names, language)
+			}
+		}
+		request += fmt.Sprintf("截图中的文字主要是%s。", strings.Join(names, "和"))
+	}
+
+	messages := []llm.Message{
+		llm.SystemMessage(constant.PromptTextRecognition),
+		llm.UserMessage(request, capture.ImageData),
+	}
+
+	var response textRecognitionResponse
+	_, err = v.retryLLMCall(
+		ctx,
+		func() (string, error) {
+			return v.chat(ctx, provider, messages, llm.JSONFormat(), visionCallTimeout)
+		},
+		func(content string) error {
+			response = textRecognitionResponse{}
+			return json.Unmarshal([]byte(content), &response)
+		},
+		2,
+		"视觉文字识别",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	boxes := make([]core.TextBox, 0, len(response.Texts))
+	for _, text := range response.Texts {
+		if strings.TrimSpace(text.Text) == "" || text.Width <= 0 || text.Height <= 0 {
+			continue
+		}
+		confidence := text.Confidence
+		if confidence <= 0 || confidence > 1 {
+			confidence = 0.5
+		}
+		boxes = append(boxes, core.TextBox{
+			Text:       strings.TrimSpace(text.Text),
+			Rect:       capture.ToDesktop(core.Rect{X: text.X, Y: text.Y, Width: text.Width, Height: text.Height}),
+			Confidence: confidence,
+			Level:      core.TextLevelLine,
+		})
+	}
+	slog.Info("视觉文字识别完成", "texts", len(boxes))
+	return boxes, nil
+}
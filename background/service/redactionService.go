@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"diandian/background/automation/core"
+	"diandian/background/automation/core/redact"
+	"diandian/background/automation/hybrid"
+	"diandian/background/database"
+	"diandian/background/model"
+)
+
+// RedactionService 截图发送给视觉模型前的脱敏服务
+// 遮挡被屏蔽的窗口和本地文字识别发现的密码输入框，每次遮挡都记录审计
+type RedactionService struct {
+	engine *hybrid.HybridEngine
+}
+
+// NewRedactionService 创建脱敏服务
+func NewRedactionService(engine *hybrid.HybridEngine) *RedactionService {
+	return &RedactionService{engine: engine}
+}
+
+// Policy 从设置中读取脱敏策略，未设置的项使用默认值
+func (r *RedactionService) Policy() redact.Policy {
+	policy := redact.DefaultPolicy()
+
+	var settings []*model.Setting
+	err := database.DB.Where("key IN ?", []string{
+		model.SettingKeyRedactionEnabled,
+		model.SettingKeyRedactionBlockedTitles,
+		model.SettingKeyRedactionBlockedApps,
+		model.SettingKeyRedactionPasswordFields,
+		model.SettingKeyRedactionStrategy,
+	}).Find(&settings).Error
+	if err != nil {
+		slog.Warn("读取脱敏设置失败，使用默认策略", "error", err)
+		return policy
+	}
+
+	for _, setting := range settings {
+		if setting.Value == nil {
+			continue
+		}
+		value := strings.TrimSpace(*setting.Value)
+		switch setting.Key {
+		case model.SettingKeyRedactionEnabled:
+			policy.Enabled = value != "false"
+		case model.SettingKeyRedactionBlockedTitles:
+			policy.BlockedTitles = splitSettingList(value)
+		case model.SettingKeyRedactionBlockedApps:
+			policy.BlockedApps = splitSettingList(value)
+		case model.SettingKeyRedactionPasswordFields:
+			policy.PasswordFields = value != "false"
+		case model.SettingKeyRedactionStrategy:
+			if value == string(redact.StrategyBlur) {
+				policy.Strategy = redact.StrategyBlur
+			}
+		}
+	}
+	return policy
+}
+
+// Redact 遮挡截图中的敏感区域，实现operation.Redactor
+// 有屏蔽规则但无法获取窗口列表时返回错误，宁可不发送也不发送未遮挡的截图；
+// 密码输入框检测只使用本地OCR，视觉模型OCR会把未遮挡的截图发出去
+func (r *RedactionService) Redact(ctx context.Context, capture *core.ScreenCapture, purpose string) (*core.ScreenCapture, []redact.Region, error) {
+	policy := r.Policy()
+	if !policy.Enabled {
+		return capture, nil, nil
+	}
+
+	var regions []redact.Region
+	if len(policy.BlockedTitles) > 0 || len(policy.BlockedApps) > 0 {
+		windows, result := r.engine.GetWindowsContext(ctx)
+		switch err := result.Err(); {
+		case err == nil:
+			regions = append(regions, policy.WindowRegions(windows)...)
+		case core.CodeOf(err) == core.ErrUnsupported:
+			slog.Warn("当前平台无法获取窗口列表，屏蔽窗口规则不生效", "error", err)
+		default:
+			return nil, nil, core.NewError(core.CodeOf(err), "获取窗口列表失败，无法确认截图中没有被屏蔽的窗口", err)
+		}
+	}
+
+	if policy.PasswordFields {
+		if provider := r.engine.OCRProvider(); provider != nil && provider.Name() != "vision" {
+			if boxes, err := provider.Recognize(ctx, capture, core.OCROptions{}); err != nil {
+				if ctx.Err() != nil {
+					return nil, nil, ctx.Err()
+				}
+				slog.Warn("文字识别失败，跳过密码输入框检测", "provider", provider.Name(), "error", err)
+			} else {
+				regions = append(regions, redact.PasswordRegions(boxes)...)
+			}
+		}
+	}
+
+	redacted, applied, err := redact.Apply(capture, regions, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(applied) > 0 {
+		r.audit(purpose, policy, capture, applied)
+	}
+	return redacted, applied, nil
+}
+
+// audit 记录遮挡的区域，审计记录写入失败不影响截图发送
+func (r *RedactionService) audit(purpose string, policy redact.Policy, capture *core.ScreenCapture, regions []redact.Region) {
+	slog.Info("截图已脱敏", "purpose", purpose, "strategy", policy.Strategy, "regions", len(regions))
+
+	data, err := json.Marshal(regions)
+	if err != nil {
+		slog.Warn("序列化脱敏区域失败", "error", err)
+		return
+	}
+	b := capture.Bounds
+	entry := &model.RedactionAudit{
+		Purpose:  purpose,
+		Strategy: string(policy.Strategy),
+		Bounds:   fmt.Sprintf("%d,%d %dx%d", b.X, b.Y, b.Width, b.Height),
+		Count:    len(regions),
+		Regions:  string(data),
+	}
+	if err := database.DB.Create(entry).Error; err != nil {
+		slog.Warn("保存脱敏审计记录失败", "error", err)
+	}
+}
+
+// splitSettingList 拆分逗号或换行分隔的设置值
+func splitSettingList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '，' || r == '\n' || r == ';'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
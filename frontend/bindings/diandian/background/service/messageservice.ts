@@ -9,6 +9,14 @@ import { Call as $Call, CancellablePromise as $CancellablePromise, Create as $Cr
 // @ts-ignore: Unused imports
 import * as model$0 from "../model/models.js";
 
+/**
+ * 停止生成回复，messageID为message-delta事件中的消息ID，为空时停止所有正在生成的回复
+ * 已生成的部分仍会作为回复保存并发送
+ */
+export function CancelMessage(messageID: string): $CancellablePromise<boolean> {
+    return $Call.ByID(718550549, messageID);
+}
+
 /**
  * 确认执行自动化任务
  */
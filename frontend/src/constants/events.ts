@@ -6,6 +6,7 @@ export const EVENT_NAMES = {
   MOUSE_LEAVE_FLOATING: 'mouse-leave-floating',
 
   MESSAGE_RESPONSED: "message-responsed",
+  MESSAGE_DELTA: "message-delta",
   TASK_STATUS_CHANGED: "task-status-changed",
   OPERATE_FAILED: "operate-failed",
 